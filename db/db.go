@@ -61,5 +61,5 @@ func GetEngine() *xorm.Engine {
 
 // SyncSchema synchronizes the database schema with the model structs
 func SyncSchema() error {
-	return Engine.Sync2(new(User), new(UserDevice), new(Image), new(Label), new(ImageLabel), new(TextKeyword), new(ImageTextKeyword), new(Chat), new(ChatMessage))
+	return Engine.Sync2(new(User), new(UserDevice), new(UserSession), new(Image), new(Label), new(ImageLabel), new(LabelParent), new(LabelAlias), new(TaxonomyBlock), new(ImageLabelInstance), new(TextKeyword), new(ImageTextKeyword), new(ImageTextBlock), new(ImagePageText), new(ImageModerationLabel), new(ImageThumbnail), new(ImageCaption), new(ImageEmbedding), new(VideoLabelSegment), new(VideoTextSegment), new(DocumentField), new(DocumentTable), new(DocumentTableCell), new(MediaJob), new(Chat), new(ChatMessage), new(ChatReadState))
 }
@@ -6,11 +6,12 @@ import (
 
 // User represents the user table
 type User struct {
-	ID        int64     `xorm:"pk autoincr 'id'" json:"id"`
-	Nickname  string    `xorm:"varchar(100) notnull 'nickname'" json:"nickname"`
-	Email     string    `xorm:"varchar(255) notnull unique 'email'" json:"email"`
-	CreatedAt time.Time `xorm:"created 'created_at'" json:"createdAt"`
-	UpdatedAt time.Time `xorm:"updated 'updated_at'" json:"updatedAt"`
+	ID           int64     `xorm:"pk autoincr 'id'" json:"id"`
+	Nickname     string    `xorm:"varchar(100) notnull 'nickname'" json:"nickname"`
+	Email        string    `xorm:"varchar(255) notnull unique 'email'" json:"email"`
+	PasswordHash string    `xorm:"varchar(255) 'password_hash'" json:"-"`
+	CreatedAt    time.Time `xorm:"created 'created_at'" json:"createdAt"`
+	UpdatedAt    time.Time `xorm:"updated 'updated_at'" json:"updatedAt"`
 }
 
 // TableName returns the table name for User
@@ -32,27 +33,185 @@ func (UserDevice) TableName() string {
 	return "user_device"
 }
 
+// UserSession represents the user_session table for issued session tokens
+type UserSession struct {
+	ID        int64     `xorm:"pk autoincr 'id'" json:"id"`
+	UserID    int64     `xorm:"notnull index 'user_id'" json:"userId"`
+	Token     string    `xorm:"varchar(64) notnull unique 'token'" json:"token"`
+	ExpiresAt time.Time `xorm:"notnull 'expires_at'" json:"expiresAt"`
+	Revoked   bool      `xorm:"tinyint(1) notnull default(0) 'revoked'" json:"revoked"`
+	CreatedAt time.Time `xorm:"created 'created_at'" json:"createdAt"`
+	UpdatedAt time.Time `xorm:"updated 'updated_at'" json:"updatedAt"`
+}
+
+// TableName returns the table name for UserSession
+func (UserSession) TableName() string {
+	return "user_session"
+}
+
+// Image is an uploaded file; ModerationStatus is one of the Moderation* constants
+// and only images in ModerationVisibleStatuses appear in library queries.
+// ContentHash is the hex SHA-256 of the file and PerceptualHash its hex dHash; near-duplicates share a
+// ClusterID, the ID of the cluster's first image, and images without near-duplicates have 0.
+// Width and Height are the displayed size after EXIF orientation, 0 when the format could not be decoded;
+// CapturedAt is the EXIF capture time and nil when the file had none. OwnerID is the user who uploaded
+// the file through the API and 0 for files ingested from IMAGE_DIR.
 type Image struct {
-	ID             int64     `xorm:"'id' pk autoincr" json:"id"`
-	Filename       string    `xorm:"'filename' varchar(255) notnull" json:"filename"`
-	OriginFilename string    `xorm:"'origin_filename' varchar(255) notnull" json:"originFilename"`
-	FileExtension  string    `xorm:"'file_extension' varchar(10) notnull" json:"fileExtension"`
-	Bucket         string    `xorm:"'bucket' varchar(255) notnull" json:"bucket"`
-	ObjectKey      string    `xorm:"'object_key' varchar(255) notnull" json:"objectKey"`
-	Uploaded       bool      `xorm:"'uploaded' tinyint(1) notnull default(0)" json:"uploaded"`
-	LabelDetected  bool      `xorm:"'label_detected' tinyint(1) notnull default(0)" json:"labelDetected"`
-	TextDetected   bool      `xorm:"'text_detected' tinyint(1) notnull default(0)" json:"textDetected"`
-	CreatedAt      time.Time `xorm:"'created_at' created" json:"createdAt"`
-	UpdatedAt      time.Time `xorm:"'updated_at' updated" json:"updatedAt"`
+	ID               int64      `xorm:"'id' pk autoincr" json:"id"`
+	Filename         string     `xorm:"'filename' varchar(255) notnull" json:"filename"`
+	OriginFilename   string     `xorm:"'origin_filename' varchar(255) notnull" json:"originFilename"`
+	FileExtension    string     `xorm:"'file_extension' varchar(10) notnull" json:"fileExtension"`
+	Bucket           string     `xorm:"'bucket' varchar(255) notnull" json:"bucket"`
+	ObjectKey        string     `xorm:"'object_key' varchar(255) notnull" json:"objectKey"`
+	Uploaded         bool       `xorm:"'uploaded' tinyint(1) notnull default(0)" json:"uploaded"`
+	LabelDetected    bool       `xorm:"'label_detected' tinyint(1) notnull default(0)" json:"labelDetected"`
+	TextDetected     bool       `xorm:"'text_detected' tinyint(1) notnull default(0)" json:"textDetected"`
+	FullText         string     `xorm:"'full_text' mediumtext" json:"fullText"`
+	ModerationStatus string     `xorm:"'moderation_status' varchar(16) notnull default('pending') index" json:"moderationStatus"`
+	ContentHash      string     `xorm:"'content_hash' varchar(64) notnull default('') index" json:"contentHash"`
+	PerceptualHash   string     `xorm:"'perceptual_hash' varchar(16) notnull default('')" json:"perceptualHash"`
+	ClusterID        int64      `xorm:"'cluster_id' notnull default(0) index" json:"clusterId"`
+	MimeType         string     `xorm:"'mime_type' varchar(100) notnull default('')" json:"mimeType"`
+	ByteSize         int64      `xorm:"'byte_size' notnull default(0)" json:"byteSize"`
+	Width            int        `xorm:"'width' notnull default(0)" json:"width"`
+	Height           int        `xorm:"'height' notnull default(0)" json:"height"`
+	Orientation      int        `xorm:"'orientation' notnull default(1)" json:"orientation"`
+	CapturedAt       *time.Time `xorm:"'captured_at' null index" json:"capturedAt"`
+	CameraMake       string     `xorm:"'camera_make' varchar(100) notnull default('')" json:"cameraMake"`
+	CameraModel      string     `xorm:"'camera_model' varchar(100) notnull default('')" json:"cameraModel"`
+	OwnerID          int64      `xorm:"'owner_id' notnull default(0) index" json:"ownerId"`
+	// DurationMs is the length of a video reported by Rekognition Video, 0 for still images
+	DurationMs int64     `xorm:"'duration_ms' notnull default(0)" json:"durationMs"`
+	CreatedAt  time.Time `xorm:"'created_at' created" json:"createdAt"`
+	UpdatedAt  time.Time `xorm:"'updated_at' updated" json:"updatedAt"`
 }
 
 func (Image) TableName() string {
 	return "image"
 }
 
+// ImageThumbnail is a resized JPEG copy of an image stored next to the original; Size is its longest edge
+type ImageThumbnail struct {
+	ID        int64     `xorm:"pk autoincr 'id'" json:"id"`
+	ImageID   int64     `xorm:"notnull unique(image_size) 'image_id'" json:"imageId"`
+	Size      int       `xorm:"notnull unique(image_size) 'size'" json:"size"`
+	Width     int       `xorm:"notnull 'width'" json:"width"`
+	Height    int       `xorm:"notnull 'height'" json:"height"`
+	MimeType  string    `xorm:"varchar(100) notnull 'mime_type'" json:"mimeType"`
+	ByteSize  int64     `xorm:"notnull 'byte_size'" json:"byteSize"`
+	Bucket    string    `xorm:"varchar(255) notnull 'bucket'" json:"bucket"`
+	ObjectKey string    `xorm:"varchar(255) notnull 'object_key'" json:"objectKey"`
+	CreatedAt time.Time `xorm:"created 'created_at'" json:"createdAt"`
+}
+
+func (ImageThumbnail) TableName() string {
+	return "image_thumbnail"
+}
+
+// ImageCaption is the generated caption, description and alt text of an image in one language.
+// The override columns hold edited text that is shown instead and survives regeneration; empty means not overridden.
+type ImageCaption struct {
+	ID                  int64      `xorm:"pk autoincr 'id'" json:"id"`
+	ImageID             int64      `xorm:"notnull unique(image_language) 'image_id'" json:"imageId"`
+	Language            string     `xorm:"varchar(16) notnull unique(image_language) 'language'" json:"language"`
+	Caption             string     `xorm:"text 'caption'" json:"caption"`
+	Description         string     `xorm:"text 'description'" json:"description"`
+	AltText             string     `xorm:"text 'alt_text'" json:"altText"`
+	CaptionOverride     string     `xorm:"text 'caption_override'" json:"captionOverride"`
+	DescriptionOverride string     `xorm:"text 'description_override'" json:"descriptionOverride"`
+	AltTextOverride     string     `xorm:"text 'alt_text_override'" json:"altTextOverride"`
+	Model               string     `xorm:"varchar(64) notnull default('') 'model'" json:"model"`
+	GeneratedAt         *time.Time `xorm:"null 'generated_at'" json:"generatedAt"`
+	CreatedAt           time.Time  `xorm:"created 'created_at'" json:"createdAt"`
+	UpdatedAt           time.Time  `xorm:"updated 'updated_at'" json:"updatedAt"`
+}
+
+func (ImageCaption) TableName() string {
+	return "image_caption"
+}
+
+// ImageEmbedding is the vector of an image's caption, labels and OCR text from one embedding model.
+// Vector holds Dimensions little-endian float32 values; SourceHash is the SHA-256 of the embedded text.
+type ImageEmbedding struct {
+	ID         int64     `xorm:"pk autoincr 'id'" json:"id"`
+	ImageID    int64     `xorm:"notnull unique(image_model) 'image_id'" json:"imageId"`
+	Model      string    `xorm:"varchar(128) notnull unique(image_model) 'model'" json:"model"`
+	Dimensions int       `xorm:"notnull 'dimensions'" json:"dimensions"`
+	Vector     []byte    `xorm:"blob notnull 'vector'" json:"-"`
+	SourceHash string    `xorm:"varchar(64) notnull 'source_hash'" json:"sourceHash"`
+	CreatedAt  time.Time `xorm:"created 'created_at'" json:"createdAt"`
+	UpdatedAt  time.Time `xorm:"updated index 'updated_at'" json:"updatedAt"`
+}
+
+func (ImageEmbedding) TableName() string {
+	return "image_embedding"
+}
+
+// VideoLabelSegment is a time range in which a label is visible in a video, in milliseconds from its start
+type VideoLabelSegment struct {
+	ID         int64     `xorm:"pk autoincr 'id'" json:"id"`
+	ImageID    int64     `xorm:"notnull index(image_start) 'image_id'" json:"imageId"`
+	LabelID    int64     `xorm:"notnull index 'label_id'" json:"labelId"`
+	StartMs    int64     `xorm:"notnull index(image_start) 'start_ms'" json:"startMs"`
+	EndMs      int64     `xorm:"notnull 'end_ms'" json:"endMs"`
+	Confidence float64   `xorm:"notnull 'confidence'" json:"confidence"`
+	CreatedAt  time.Time `xorm:"created 'created_at'" json:"createdAt"`
+}
+
+func (VideoLabelSegment) TableName() string {
+	return "video_label_segment"
+}
+
+// VideoTextSegment is a time range in which a line of text stays on screen in a video, in milliseconds from its start
+type VideoTextSegment struct {
+	ID         int64     `xorm:"pk autoincr 'id'" json:"id"`
+	ImageID    int64     `xorm:"notnull index(image_start) 'image_id'" json:"imageId"`
+	Text       string    `xorm:"varchar(512) notnull 'text'" json:"text"`
+	StartMs    int64     `xorm:"notnull index(image_start) 'start_ms'" json:"startMs"`
+	EndMs      int64     `xorm:"notnull 'end_ms'" json:"endMs"`
+	Confidence float64   `xorm:"notnull 'confidence'" json:"confidence"`
+	CreatedAt  time.Time `xorm:"created 'created_at'" json:"createdAt"`
+}
+
+func (VideoTextSegment) TableName() string {
+	return "video_text_segment"
+}
+
+// Image moderation statuses
+const (
+	// ModerationPending images have not been checked yet
+	ModerationPending = "pending"
+	// ModerationPassed images had no moderation label above the quarantine thresholds
+	ModerationPassed = "passed"
+	// ModerationQuarantined images wait in the review queue
+	ModerationQuarantined = "quarantined"
+	ModerationApproved    = "approved"
+	ModerationRejected    = "rejected"
+)
+
+// ModerationVisibleStatuses are the moderation statuses of images that may be shown
+var ModerationVisibleStatuses = []string{ModerationPassed, ModerationApproved}
+
+// ImageModerationLabel is an unsafe-content label Rekognition detected in an image
+type ImageModerationLabel struct {
+	ID         int64     `xorm:"pk autoincr 'id'" json:"id"`
+	ImageID    int64     `xorm:"notnull index 'image_id'" json:"imageId"`
+	Name       string    `xorm:"varchar(255) notnull 'name'" json:"name"`
+	ParentName string    `xorm:"varchar(255) notnull default('') 'parent_name'" json:"parentName"`
+	Confidence float64   `xorm:"notnull 'confidence'" json:"confidence"`
+	CreatedAt  time.Time `xorm:"created 'created_at'" json:"createdAt"`
+}
+
+func (ImageModerationLabel) TableName() string {
+	return "image_moderation_label"
+}
+
+// Label is a detected label; hidden labels stay linked to their images but are left out of the media API and search
 type Label struct {
 	ID        int64     `xorm:"pk autoincr 'id'" json:"id"`
 	Name      string    `xorm:"varchar(255) notnull unique 'name'" json:"name"`
+	Category  string    `xorm:"varchar(255) 'category'" json:"category"`
+	Hidden    bool      `xorm:"notnull default(0) 'hidden'" json:"hidden"`
 	CreatedAt time.Time `xorm:"created 'created_at'" json:"createdAt"`
 	UpdatedAt time.Time `xorm:"updated 'updated_at'" json:"updatedAt"`
 }
@@ -62,20 +221,86 @@ func (Label) TableName() string {
 }
 
 type ImageLabel struct {
-	ID        int64     `xorm:"pk autoincr 'id'" json:"id"`
-	ImageID   int64     `xorm:"notnull 'image_id' unique(image_label)" json:"imageId"`
-	LabelID   int64     `xorm:"notnull 'label_id' unique(image_label)" json:"labelId"`
-	CreatedAt time.Time `xorm:"created 'created_at'" json:"createdAt"`
-	UpdatedAt time.Time `xorm:"updated 'updated_at'" json:"updatedAt"`
+	ID         int64     `xorm:"pk autoincr 'id'" json:"id"`
+	ImageID    int64     `xorm:"notnull 'image_id' unique(image_label)" json:"imageId"`
+	LabelID    int64     `xorm:"notnull 'label_id' unique(image_label)" json:"labelId"`
+	Confidence float64   `xorm:"notnull default(0) 'confidence'" json:"confidence"`
+	CreatedAt  time.Time `xorm:"created 'created_at'" json:"createdAt"`
+	UpdatedAt  time.Time `xorm:"updated 'updated_at'" json:"updatedAt"`
 }
 
 func (ImageLabel) TableName() string {
 	return "image_label"
 }
 
+// LabelParent links a label to its parent in the hierarchy. A link removed by curation is kept with Removed set,
+// so Rekognition reporting the same parent again does not restore it.
+type LabelParent struct {
+	ID            int64     `xorm:"pk autoincr 'id'" json:"id"`
+	LabelID       int64     `xorm:"notnull 'label_id' unique(label_parent)" json:"labelId"`
+	ParentLabelID int64     `xorm:"notnull 'parent_label_id' unique(label_parent)" json:"parentLabelId"`
+	Removed       bool      `xorm:"notnull default(0) 'removed'" json:"removed"`
+	CreatedAt     time.Time `xorm:"created 'created_at'" json:"createdAt"`
+}
+
+func (LabelParent) TableName() string {
+	return "label_parent"
+}
+
+// LabelAlias is another name of a label; detections and searches using it resolve to the label
+type LabelAlias struct {
+	ID        int64     `xorm:"pk autoincr 'id'" json:"id"`
+	Alias     string    `xorm:"varchar(255) notnull unique 'alias'" json:"alias"`
+	LabelID   int64     `xorm:"notnull index 'label_id'" json:"labelId"`
+	CreatedAt time.Time `xorm:"created 'created_at'" json:"createdAt"`
+}
+
+func (LabelAlias) TableName() string {
+	return "label_alias"
+}
+
+// Taxonomy blocklist kinds
+const (
+	TaxonomyBlockLabel   = "label"
+	TaxonomyBlockKeyword = "keyword"
+)
+
+// TaxonomyBlock keeps labels or keywords matching Pattern out of the library. Pattern is matched without regard
+// to case, with "*" standing for any run of characters and "?" for one character.
+type TaxonomyBlock struct {
+	ID        int64     `xorm:"pk autoincr 'id'" json:"id"`
+	Kind      string    `xorm:"varchar(16) notnull unique(kind_pattern) 'kind'" json:"kind"`
+	Pattern   string    `xorm:"varchar(255) notnull unique(kind_pattern) 'pattern'" json:"pattern"`
+	CreatedAt time.Time `xorm:"created 'created_at'" json:"createdAt"`
+}
+
+func (TaxonomyBlock) TableName() string {
+	return "taxonomy_block"
+}
+
+// ImageLabelInstance is a located occurrence of a label in an image.
+// The bounding box is in ratios of the image width and height.
+type ImageLabelInstance struct {
+	ID         int64     `xorm:"pk autoincr 'id'" json:"id"`
+	ImageID    int64     `xorm:"notnull index 'image_id'" json:"imageId"`
+	LabelID    int64     `xorm:"notnull 'label_id'" json:"labelId"`
+	Confidence float64   `xorm:"notnull 'confidence'" json:"confidence"`
+	BoxLeft    float64   `xorm:"notnull 'box_left'" json:"boxLeft"`
+	BoxTop     float64   `xorm:"notnull 'box_top'" json:"boxTop"`
+	BoxWidth   float64   `xorm:"notnull 'box_width'" json:"boxWidth"`
+	BoxHeight  float64   `xorm:"notnull 'box_height'" json:"boxHeight"`
+	CreatedAt  time.Time `xorm:"created 'created_at'" json:"createdAt"`
+}
+
+func (ImageLabelInstance) TableName() string {
+	return "image_label_instance"
+}
+
+// TextKeyword is a word read from images; hidden keywords are left out of the media API and search
 type TextKeyword struct {
 	ID        int64     `xorm:"pk autoincr 'id'" json:"id"`
 	Keyword   string    `xorm:"varchar(255) notnull unique 'keyword'" json:"keyword"`
+	Hidden    bool      `xorm:"notnull default(0) 'hidden'" json:"hidden"`
 	CreatedAt time.Time `xorm:"created 'created_at'" json:"createdAt"`
 	UpdatedAt time.Time `xorm:"updated 'updated_at'" json:"updatedAt"`
 }
@@ -96,6 +321,95 @@ func (ImageTextKeyword) TableName() string {
 	return "image_text_keyword"
 }
 
+// ImageTextBlock is a line or word of OCR output for an image or document page.
+// BlockIndex gives the reading order within the image; geometry is in ratios of the page size.
+type ImageTextBlock struct {
+	ID         int64     `xorm:"pk autoincr 'id'" json:"id"`
+	ImageID    int64     `xorm:"notnull index(image_page) 'image_id'" json:"imageId"`
+	Page       int       `xorm:"notnull default(1) index(image_page) 'page'" json:"page"`
+	BlockIndex int       `xorm:"notnull 'block_index'" json:"blockIndex"`
+	BlockType  string    `xorm:"varchar(10) notnull 'block_type'" json:"blockType"`
+	Text       string    `xorm:"text notnull 'text'" json:"text"`
+	Confidence float64   `xorm:"notnull 'confidence'" json:"confidence"`
+	BoxLeft    float64   `xorm:"notnull 'box_left'" json:"boxLeft"`
+	BoxTop     float64   `xorm:"notnull 'box_top'" json:"boxTop"`
+	BoxWidth   float64   `xorm:"notnull 'box_width'" json:"boxWidth"`
+	BoxHeight  float64   `xorm:"notnull 'box_height'" json:"boxHeight"`
+	Polygon    string    `xorm:"text 'polygon'" json:"polygon"` // JSON array of {x, y}
+	CreatedAt  time.Time `xorm:"created 'created_at'" json:"createdAt"`
+}
+
+func (ImageTextBlock) TableName() string {
+	return "image_text_block"
+}
+
+// ImagePageText holds the reconstructed OCR text of one page of an image or document
+type ImagePageText struct {
+	ID        int64     `xorm:"pk autoincr 'id'" json:"id"`
+	ImageID   int64     `xorm:"notnull unique(image_page) 'image_id'" json:"imageId"`
+	Page      int       `xorm:"notnull unique(image_page) 'page'" json:"page"`
+	Text      string    `xorm:"mediumtext notnull 'text'" json:"text"`
+	CreatedAt time.Time `xorm:"created 'created_at'" json:"createdAt"`
+}
+
+func (ImagePageText) TableName() string {
+	return "image_page_text"
+}
+
+// DocumentField is a form key-value pair extracted from a document by Textract AnalyzeDocument
+type DocumentField struct {
+	ID              int64     `xorm:"pk autoincr 'id'" json:"id"`
+	ImageID         int64     `xorm:"notnull index 'image_id'" json:"imageId"`
+	Page            int       `xorm:"notnull default(1) 'page'" json:"page"`
+	FieldIndex      int       `xorm:"notnull 'field_index'" json:"fieldIndex"`
+	KeyText         string    `xorm:"text notnull 'key_text'" json:"keyText"`
+	ValueText       string    `xorm:"text notnull 'value_text'" json:"valueText"`
+	KeyConfidence   float64   `xorm:"notnull 'key_confidence'" json:"keyConfidence"`
+	ValueConfidence float64   `xorm:"notnull 'value_confidence'" json:"valueConfidence"`
+	CreatedAt       time.Time `xorm:"created 'created_at'" json:"createdAt"`
+}
+
+func (DocumentField) TableName() string {
+	return "document_field"
+}
+
+// DocumentTable is a table extracted from a document by Textract AnalyzeDocument; its cells are DocumentTableCell rows
+type DocumentTable struct {
+	ID          int64     `xorm:"pk autoincr 'id'" json:"id"`
+	ImageID     int64     `xorm:"notnull index 'image_id'" json:"imageId"`
+	Page        int       `xorm:"notnull default(1) 'page'" json:"page"`
+	TableIndex  int       `xorm:"notnull 'table_index'" json:"tableIndex"`
+	RowCount    int       `xorm:"notnull 'row_count'" json:"rowCount"`
+	ColumnCount int       `xorm:"notnull 'column_count'" json:"columnCount"`
+	Confidence  float64   `xorm:"notnull 'confidence'" json:"confidence"`
+	BoxLeft     float64   `xorm:"notnull 'box_left'" json:"boxLeft"`
+	BoxTop      float64   `xorm:"notnull 'box_top'" json:"boxTop"`
+	BoxWidth    float64   `xorm:"notnull 'box_width'" json:"boxWidth"`
+	BoxHeight   float64   `xorm:"notnull 'box_height'" json:"boxHeight"`
+	CreatedAt   time.Time `xorm:"created 'created_at'" json:"createdAt"`
+}
+
+func (DocumentTable) TableName() string {
+	return "document_table"
+}
+
+// DocumentTableCell is one cell of a DocumentTable; row and column indexes are 1-based
+type DocumentTableCell struct {
+	ID          int64   `xorm:"pk autoincr 'id'" json:"id"`
+	TableID     int64   `xorm:"notnull index 'table_id'" json:"tableId"`
+	RowIndex    int     `xorm:"notnull 'row_index'" json:"rowIndex"`
+	ColumnIndex int     `xorm:"notnull 'column_index'" json:"columnIndex"`
+	RowSpan     int     `xorm:"notnull default(1) 'row_span'" json:"rowSpan"`
+	ColumnSpan  int     `xorm:"notnull default(1) 'column_span'" json:"columnSpan"`
+	Text        string  `xorm:"text notnull 'text'" json:"text"`
+	Confidence  float64 `xorm:"notnull 'confidence'" json:"confidence"`
+	IsHeader    bool    `xorm:"tinyint(1) notnull default(0) 'is_header'" json:"isHeader"`
+}
+
+func (DocumentTableCell) TableName() string {
+	return "document_table_cell"
+}
+
 // Chat represents the chat table for chat sessions
 type Chat struct {
 	ID        int64     `xorm:"pk autoincr 'id'" json:"id"`
@@ -127,3 +441,63 @@ type ChatMessage struct {
 func (ChatMessage) TableName() string {
 	return "chat_message"
 }
+
+// ChatReadState represents the chat_read_state table tracking the last message a user has read in a chat
+type ChatReadState struct {
+	ID                int64     `xorm:"pk autoincr 'id'" json:"id"`
+	UserID            int64     `xorm:"notnull 'user_id' unique(user_chat)" json:"userId"`
+	ChatID            int64     `xorm:"notnull 'chat_id' unique(user_chat)" json:"chatId"`
+	LastReadMessageID int64     `xorm:"notnull default(0) 'last_read_message_id'" json:"lastReadMessageId"`
+	CreatedAt         time.Time `xorm:"created 'created_at'" json:"createdAt"`
+	UpdatedAt         time.Time `xorm:"updated 'updated_at'" json:"updatedAt"`
+}
+
+func (ChatReadState) TableName() string {
+	return "chat_read_state"
+}
+
+// Media job types
+const (
+	MediaJobLabelDetection = "label_detection"
+	MediaJobTextDetection  = "text_detection"
+	MediaJobModeration     = "moderation"
+	// MediaJobDocumentAnalysis extracts form fields and tables; only created for DOCUMENT_ANALYSIS_EXTENSIONS
+	MediaJobDocumentAnalysis = "document_analysis"
+	// MediaJobCaption writes captions and alt text once the other jobs are done; only created for images with thumbnails
+	MediaJobCaption = "caption"
+	// MediaJobEmbedding stores the vector used by semantic search once the captions and detection jobs are done
+	MediaJobEmbedding = "embedding"
+)
+
+// Media job statuses; failed attempts return to pending until the job is dead-lettered
+const (
+	MediaJobPending   = "pending"
+	MediaJobRunning   = "running"
+	MediaJobSucceeded = "succeeded"
+	MediaJobDead      = "dead"
+	MediaJobCancelled = "cancelled"
+)
+
+// MediaJob represents the media_job table, one processing step for an image with its retry state
+type MediaJob struct {
+	ID          int64     `xorm:"pk autoincr 'id'" json:"id"`
+	ImageID     int64     `xorm:"notnull 'image_id' unique(image_job)" json:"imageId"`
+	JobType     string    `xorm:"varchar(32) notnull 'job_type' unique(image_job)" json:"jobType"`
+	Status      string    `xorm:"varchar(16) notnull index(status_next_run) 'status'" json:"status"`
+	Attempts    int       `xorm:"notnull default(0) 'attempts'" json:"attempts"`
+	MaxAttempts int       `xorm:"notnull default(0) 'max_attempts'" json:"maxAttempts"`
+	LastError   string    `xorm:"text 'last_error'" json:"lastError"`
+	NextRunAt   time.Time `xorm:"notnull index(status_next_run) 'next_run_at'" json:"nextRunAt"`
+	// ErrorCategory is a short summary of LastError that is safe to show the image's owner
+	ErrorCategory string `xorm:"varchar(64) 'error_category'" json:"errorCategory"`
+	// ExternalJobID is the ID of an asynchronous AWS job the media job is waiting on
+	ExternalJobID string `xorm:"varchar(128) 'external_job_id'" json:"externalJobId"`
+	// WaitingSince is when the job started waiting on an asynchronous AWS job or a review; nil while it is not waiting
+	WaitingSince *time.Time `xorm:"'waiting_since'" json:"waitingSince"`
+	CreatedAt    time.Time  `xorm:"created 'created_at'" json:"createdAt"`
+	UpdatedAt    time.Time  `xorm:"updated 'updated_at'" json:"updatedAt"`
+}
+
+func (MediaJob) TableName() string {
+	return "media_job"
+}
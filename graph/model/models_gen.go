@@ -3,17 +3,29 @@
 package model
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
 	"time"
 )
 
+type BoundingBox struct {
+	Left   float64 `json:"left"`
+	Top    float64 `json:"top"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
 type Chat struct {
-	ID        int64     `json:"id"`
-	UserID    int64     `json:"userId"`
-	Title     string    `json:"title"`
-	BotName   string    `json:"botName"`
-	SessionID string    `json:"sessionId"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID          int64     `json:"id"`
+	UserID      int64     `json:"userId"`
+	Title       string    `json:"title"`
+	BotName     string    `json:"botName"`
+	SessionID   string    `json:"sessionId"`
+	UnreadCount int32     `json:"unreadCount"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
 }
 
 type ChatHistory struct {
@@ -63,10 +75,151 @@ type DetectCustomLabelsInput struct {
 	S3Key string `json:"s3Key"`
 }
 
+type DocumentField struct {
+	ID              int64   `json:"id"`
+	Page            int32   `json:"page"`
+	Key             string  `json:"key"`
+	Value           string  `json:"value"`
+	KeyConfidence   float64 `json:"keyConfidence"`
+	ValueConfidence float64 `json:"valueConfidence"`
+}
+
+type DocumentTable struct {
+	ID          int64        `json:"id"`
+	ImageID     int64        `json:"imageId"`
+	Page        int32        `json:"page"`
+	Index       int32        `json:"index"`
+	RowCount    int32        `json:"rowCount"`
+	ColumnCount int32        `json:"columnCount"`
+	Confidence  float64      `json:"confidence"`
+	BoundingBox *BoundingBox `json:"boundingBox"`
+	Cells       []*TableCell `json:"cells"`
+	CSV         string       `json:"csv"`
+	CSVURL      string       `json:"csvUrl"`
+}
+
+type Facet struct {
+	Value string `json:"value"`
+	Count int32  `json:"count"`
+}
+
 type GenerateCommentRepliesInput struct {
 	OriginalComment string `json:"originalComment"`
 }
 
+type Image struct {
+	ID               int64              `json:"id"`
+	Filename         string             `json:"filename"`
+	OriginFilename   string             `json:"originFilename"`
+	FileExtension    string             `json:"fileExtension"`
+	Bucket           string             `json:"bucket"`
+	ObjectKey        string             `json:"objectKey"`
+	Uploaded         bool               `json:"uploaded"`
+	LabelDetected    bool               `json:"labelDetected"`
+	TextDetected     bool               `json:"textDetected"`
+	URL              *string            `json:"url,omitempty"`
+	Labels           []*Label           `json:"labels"`
+	LabelDetails     []*ImageLabel      `json:"labelDetails"`
+	Keywords         []*TextKeyword     `json:"keywords"`
+	FullText         *string            `json:"fullText,omitempty"`
+	ModerationStatus ModerationStatus   `json:"moderationStatus"`
+	ModerationLabels []*ModerationLabel `json:"moderationLabels"`
+	ContentHash      *string            `json:"contentHash,omitempty"`
+	PerceptualHash   *string            `json:"perceptualHash,omitempty"`
+	ClusterID        *int64             `json:"clusterId,omitempty"`
+	OwnerID          *int64             `json:"ownerId,omitempty"`
+	MimeType         *string            `json:"mimeType,omitempty"`
+	ByteSize         *int               `json:"byteSize,omitempty"`
+	Width            *int32             `json:"width,omitempty"`
+	Height           *int32             `json:"height,omitempty"`
+	Orientation      int32              `json:"orientation"`
+	CapturedAt       *time.Time         `json:"capturedAt,omitempty"`
+	CameraMake       *string            `json:"cameraMake,omitempty"`
+	CameraModel      *string            `json:"cameraModel,omitempty"`
+	Thumbnails       []*Thumbnail       `json:"thumbnails"`
+	DurationMs       *int               `json:"durationMs,omitempty"`
+	Captions         []*ImageCaption    `json:"captions"`
+	CreatedAt        time.Time          `json:"createdAt"`
+	UpdatedAt        time.Time          `json:"updatedAt"`
+}
+
+type ImageCaption struct {
+	Language    string            `json:"language"`
+	Caption     string            `json:"caption"`
+	Description string            `json:"description"`
+	AltText     string            `json:"altText"`
+	Generated   *ImageCaptionText `json:"generated"`
+	Overridden  bool              `json:"overridden"`
+	Model       *string           `json:"model,omitempty"`
+	GeneratedAt *time.Time        `json:"generatedAt,omitempty"`
+	UpdatedAt   time.Time         `json:"updatedAt"`
+}
+
+type ImageCaptionInput struct {
+	Caption     *string `json:"caption,omitempty"`
+	Description *string `json:"description,omitempty"`
+	AltText     *string `json:"altText,omitempty"`
+}
+
+type ImageCaptionText struct {
+	Caption     string `json:"caption"`
+	Description string `json:"description"`
+	AltText     string `json:"altText"`
+}
+
+type ImageConnection struct {
+	Edges      []*ImageEdge `json:"edges"`
+	PageInfo   *PageInfo    `json:"pageInfo"`
+	TotalCount int32        `json:"totalCount"`
+}
+
+type ImageEdge struct {
+	Cursor string `json:"cursor"`
+	Node   *Image `json:"node"`
+}
+
+type ImageFilter struct {
+	Labels        []string `json:"labels,omitempty"`
+	Keywords      []string `json:"keywords,omitempty"`
+	Filename      *string  `json:"filename,omitempty"`
+	LabelDetected *bool    `json:"labelDetected,omitempty"`
+	TextDetected  *bool    `json:"textDetected,omitempty"`
+}
+
+type ImageLabel struct {
+	Label      *Label           `json:"label"`
+	Confidence float64          `json:"confidence"`
+	Instances  []*LabelInstance `json:"instances"`
+}
+
+type ImageSearchFilter struct {
+	Must          []*SearchTerm `json:"must,omitempty"`
+	Should        []*SearchTerm `json:"should,omitempty"`
+	MustNot       []*SearchTerm `json:"mustNot,omitempty"`
+	CreatedAfter  *time.Time    `json:"createdAfter,omitempty"`
+	CreatedBefore *time.Time    `json:"createdBefore,omitempty"`
+}
+
+type ImageSearchResult struct {
+	Images        *ImageConnection `json:"images"`
+	LabelFacets   []*Facet         `json:"labelFacets"`
+	KeywordFacets []*Facet         `json:"keywordFacets"`
+}
+
+type Label struct {
+	ID       int64    `json:"id"`
+	Name     string   `json:"name"`
+	Category *string  `json:"category,omitempty"`
+	Parents  []string `json:"parents"`
+	Aliases  []string `json:"aliases"`
+	Hidden   bool     `json:"hidden"`
+}
+
+type LabelInstance struct {
+	Confidence  float64      `json:"confidence"`
+	BoundingBox *BoundingBox `json:"boundingBox"`
+}
+
 type LexConfig struct {
 	BotName  string `json:"botName"`
 	BotID    string `json:"botId"`
@@ -80,12 +233,73 @@ type LoginUser struct {
 	DeviceID string `json:"deviceId"`
 }
 
+type MediaJob struct {
+	ID          int64          `json:"id"`
+	ImageID     int64          `json:"imageId"`
+	Type        MediaJobType   `json:"type"`
+	Status      MediaJobStatus `json:"status"`
+	Attempts    int32          `json:"attempts"`
+	MaxAttempts int32          `json:"maxAttempts"`
+	LastError   *string        `json:"lastError,omitempty"`
+	NextRunAt   time.Time      `json:"nextRunAt"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	UpdatedAt   time.Time      `json:"updatedAt"`
+}
+
+type MediaProcessedEvent struct {
+	ImageID     int64     `json:"imageId"`
+	Stage       string    `json:"stage"`
+	ProcessedAt time.Time `json:"processedAt"`
+}
+
+type MediaUpload struct {
+	Filename  string      `json:"filename"`
+	ImageID   *int64      `json:"imageId,omitempty"`
+	Duplicate bool        `json:"duplicate"`
+	Jobs      []*MediaJob `json:"jobs"`
+	Error     *string     `json:"error,omitempty"`
+}
+
+type MediaUploadTicket struct {
+	UploadURL string    `json:"uploadUrl"`
+	Key       string    `json:"key"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type ModerationLabel struct {
+	Name       string  `json:"name"`
+	ParentName string  `json:"parentName"`
+	Confidence float64 `json:"confidence"`
+}
+
 type Mutation struct {
 }
 
+type PageInfo struct {
+	HasNextPage bool    `json:"hasNextPage"`
+	EndCursor   *string `json:"endCursor,omitempty"`
+}
+
+type PageText struct {
+	Page int32  `json:"page"`
+	Text string `json:"text"`
+}
+
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
 type Query struct {
 }
 
+type RegisterUser struct {
+	Nickname string `json:"nickname"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	DeviceID string `json:"deviceId"`
+}
+
 type S3Field struct {
 	Name  string `json:"name"`
 	Value string `json:"value"`
@@ -97,15 +311,83 @@ type S3PresignedURL struct {
 	Fields    []*S3Field `json:"fields"`
 }
 
+type SearchTerm struct {
+	Label   *string `json:"label,omitempty"`
+	Keyword *string `json:"keyword,omitempty"`
+}
+
+type SemanticSearchHit struct {
+	Image *Image  `json:"image"`
+	Score float64 `json:"score"`
+}
+
 type SendMessageInput struct {
 	ChatID  int64  `json:"chatId"`
 	Message string `json:"message"`
 }
 
+type Session struct {
+	Token     string    `json:"token"`
+	UserID    int64     `json:"userId"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	User      *User     `json:"user,omitempty"`
+}
+
+type SimilarImage struct {
+	Image    *Image `json:"image"`
+	Distance int32  `json:"distance"`
+}
+
+type Subscription struct {
+}
+
+type TableCell struct {
+	Row        int32   `json:"row"`
+	Column     int32   `json:"column"`
+	RowSpan    int32   `json:"rowSpan"`
+	ColumnSpan int32   `json:"columnSpan"`
+	Text       string  `json:"text"`
+	Confidence float64 `json:"confidence"`
+	Header     bool    `json:"header"`
+}
+
+type TaxonomyBlock struct {
+	ID        int64        `json:"id"`
+	Kind      TaxonomyKind `json:"kind"`
+	Pattern   string       `json:"pattern"`
+	CreatedAt time.Time    `json:"createdAt"`
+}
+
+type TextBlock struct {
+	ID          int64         `json:"id"`
+	Type        TextBlockType `json:"type"`
+	Text        string        `json:"text"`
+	Confidence  float64       `json:"confidence"`
+	Page        int32         `json:"page"`
+	Index       int32         `json:"index"`
+	BoundingBox *BoundingBox  `json:"boundingBox"`
+	Polygon     []*Point      `json:"polygon"`
+}
+
+type TextKeyword struct {
+	ID      int64  `json:"id"`
+	Keyword string `json:"keyword"`
+	Hidden  bool   `json:"hidden"`
+}
+
 type TextToSpeech struct {
 	Text string `json:"text"`
 }
 
+type Thumbnail struct {
+	Size     int32   `json:"size"`
+	Width    int32   `json:"width"`
+	Height   int32   `json:"height"`
+	MimeType string  `json:"mimeType"`
+	ByteSize int     `json:"byteSize"`
+	URL      *string `json:"url,omitempty"`
+}
+
 type TranslateText struct {
 	Text           string `json:"text"`
 	SourceLanguage string `json:"sourceLanguage"`
@@ -127,3 +409,323 @@ type UserDevice struct {
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 }
+
+type VideoLabelTrack struct {
+	Label      *Label          `json:"label"`
+	Confidence float64         `json:"confidence"`
+	Segments   []*VideoSegment `json:"segments"`
+}
+
+type VideoSegment struct {
+	StartMs    int     `json:"startMs"`
+	EndMs      int     `json:"endMs"`
+	Confidence float64 `json:"confidence"`
+}
+
+type VideoTextTrack struct {
+	Text       string          `json:"text"`
+	Confidence float64         `json:"confidence"`
+	Segments   []*VideoSegment `json:"segments"`
+}
+
+type VideoTimeline struct {
+	ImageID    int64              `json:"imageId"`
+	DurationMs *int               `json:"durationMs,omitempty"`
+	Labels     []*VideoLabelTrack `json:"labels"`
+	Texts      []*VideoTextTrack  `json:"texts"`
+}
+
+type MediaJobStatus string
+
+const (
+	MediaJobStatusPending   MediaJobStatus = "PENDING"
+	MediaJobStatusRunning   MediaJobStatus = "RUNNING"
+	MediaJobStatusSucceeded MediaJobStatus = "SUCCEEDED"
+	MediaJobStatusDead      MediaJobStatus = "DEAD"
+	MediaJobStatusCancelled MediaJobStatus = "CANCELLED"
+)
+
+var AllMediaJobStatus = []MediaJobStatus{
+	MediaJobStatusPending,
+	MediaJobStatusRunning,
+	MediaJobStatusSucceeded,
+	MediaJobStatusDead,
+	MediaJobStatusCancelled,
+}
+
+func (e MediaJobStatus) IsValid() bool {
+	switch e {
+	case MediaJobStatusPending, MediaJobStatusRunning, MediaJobStatusSucceeded, MediaJobStatusDead, MediaJobStatusCancelled:
+		return true
+	}
+	return false
+}
+
+func (e MediaJobStatus) String() string {
+	return string(e)
+}
+
+func (e *MediaJobStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = MediaJobStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid MediaJobStatus", str)
+	}
+	return nil
+}
+
+func (e MediaJobStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *MediaJobStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e MediaJobStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type MediaJobType string
+
+const (
+	MediaJobTypeLabelDetection   MediaJobType = "LABEL_DETECTION"
+	MediaJobTypeTextDetection    MediaJobType = "TEXT_DETECTION"
+	MediaJobTypeModeration       MediaJobType = "MODERATION"
+	MediaJobTypeDocumentAnalysis MediaJobType = "DOCUMENT_ANALYSIS"
+	MediaJobTypeCaption          MediaJobType = "CAPTION"
+	MediaJobTypeEmbedding        MediaJobType = "EMBEDDING"
+)
+
+var AllMediaJobType = []MediaJobType{
+	MediaJobTypeLabelDetection,
+	MediaJobTypeTextDetection,
+	MediaJobTypeModeration,
+	MediaJobTypeDocumentAnalysis,
+	MediaJobTypeCaption,
+	MediaJobTypeEmbedding,
+}
+
+func (e MediaJobType) IsValid() bool {
+	switch e {
+	case MediaJobTypeLabelDetection, MediaJobTypeTextDetection, MediaJobTypeModeration, MediaJobTypeDocumentAnalysis, MediaJobTypeCaption, MediaJobTypeEmbedding:
+		return true
+	}
+	return false
+}
+
+func (e MediaJobType) String() string {
+	return string(e)
+}
+
+func (e *MediaJobType) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = MediaJobType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid MediaJobType", str)
+	}
+	return nil
+}
+
+func (e MediaJobType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *MediaJobType) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e MediaJobType) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type ModerationStatus string
+
+const (
+	ModerationStatusPending     ModerationStatus = "PENDING"
+	ModerationStatusPassed      ModerationStatus = "PASSED"
+	ModerationStatusQuarantined ModerationStatus = "QUARANTINED"
+	ModerationStatusApproved    ModerationStatus = "APPROVED"
+	ModerationStatusRejected    ModerationStatus = "REJECTED"
+)
+
+var AllModerationStatus = []ModerationStatus{
+	ModerationStatusPending,
+	ModerationStatusPassed,
+	ModerationStatusQuarantined,
+	ModerationStatusApproved,
+	ModerationStatusRejected,
+}
+
+func (e ModerationStatus) IsValid() bool {
+	switch e {
+	case ModerationStatusPending, ModerationStatusPassed, ModerationStatusQuarantined, ModerationStatusApproved, ModerationStatusRejected:
+		return true
+	}
+	return false
+}
+
+func (e ModerationStatus) String() string {
+	return string(e)
+}
+
+func (e *ModerationStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ModerationStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ModerationStatus", str)
+	}
+	return nil
+}
+
+func (e ModerationStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *ModerationStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e ModerationStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type TaxonomyKind string
+
+const (
+	TaxonomyKindLabel   TaxonomyKind = "LABEL"
+	TaxonomyKindKeyword TaxonomyKind = "KEYWORD"
+)
+
+var AllTaxonomyKind = []TaxonomyKind{
+	TaxonomyKindLabel,
+	TaxonomyKindKeyword,
+}
+
+func (e TaxonomyKind) IsValid() bool {
+	switch e {
+	case TaxonomyKindLabel, TaxonomyKindKeyword:
+		return true
+	}
+	return false
+}
+
+func (e TaxonomyKind) String() string {
+	return string(e)
+}
+
+func (e *TaxonomyKind) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = TaxonomyKind(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid TaxonomyKind", str)
+	}
+	return nil
+}
+
+func (e TaxonomyKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *TaxonomyKind) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e TaxonomyKind) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type TextBlockType string
+
+const (
+	TextBlockTypeLine TextBlockType = "LINE"
+	TextBlockTypeWord TextBlockType = "WORD"
+)
+
+var AllTextBlockType = []TextBlockType{
+	TextBlockTypeLine,
+	TextBlockTypeWord,
+}
+
+func (e TextBlockType) IsValid() bool {
+	switch e {
+	case TextBlockTypeLine, TextBlockTypeWord:
+		return true
+	}
+	return false
+}
+
+func (e TextBlockType) String() string {
+	return string(e)
+}
+
+func (e *TextBlockType) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = TextBlockType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid TextBlockType", str)
+	}
+	return nil
+}
+
+func (e TextBlockType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *TextBlockType) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e TextBlockType) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
@@ -16,6 +16,26 @@ func (r *mutationResolver) Login(ctx context.Context, input model.LoginUser) (*m
 	return r.Resolver.Login(ctx, input)
 }
 
+// Register is the resolver for the register field.
+func (r *mutationResolver) Register(ctx context.Context, input model.RegisterUser) (*model.Session, error) {
+	return r.Resolver.Register(ctx, input)
+}
+
+// SignIn is the resolver for the signIn field.
+func (r *mutationResolver) SignIn(ctx context.Context, email string, password string) (*model.Session, error) {
+	return r.Resolver.SignIn(ctx, email, password)
+}
+
+// SetPassword is the resolver for the setPassword field.
+func (r *mutationResolver) SetPassword(ctx context.Context, currentPassword *string, newPassword string) (bool, error) {
+	return r.Resolver.SetPassword(ctx, currentPassword, newPassword)
+}
+
+// RevokeSession is the resolver for the revokeSession field.
+func (r *mutationResolver) RevokeSession(ctx context.Context, token string) (bool, error) {
+	return r.Resolver.RevokeSession(ctx, token)
+}
+
 // DetectLanguage is the resolver for the detectLanguage field.
 func (r *mutationResolver) DetectLanguage(ctx context.Context, input string) (string, error) {
 	return r.Resolver.DetectLanguage(ctx, input)
@@ -66,6 +86,96 @@ func (r *mutationResolver) GenerateCommentReplies(ctx context.Context, input mod
 	return r.Resolver.GenerateCommentReplies(ctx, input, file)
 }
 
+// UploadMedia is the resolver for the uploadMedia field.
+func (r *mutationResolver) UploadMedia(ctx context.Context, files []*graphql.Upload) ([]*model.MediaUpload, error) {
+	return r.Resolver.UploadMedia(ctx, files)
+}
+
+// RequestMediaUpload is the resolver for the requestMediaUpload field.
+func (r *mutationResolver) RequestMediaUpload(ctx context.Context, filename string) (*model.MediaUploadTicket, error) {
+	return r.Resolver.RequestMediaUpload(ctx, filename)
+}
+
+// CompleteMediaUpload is the resolver for the completeMediaUpload field.
+func (r *mutationResolver) CompleteMediaUpload(ctx context.Context, key string, filename *string) (*model.MediaUpload, error) {
+	return r.Resolver.CompleteMediaUpload(ctx, key, filename)
+}
+
+// CreateSession is the resolver for the createSession field.
+func (r *mutationResolver) CreateSession(ctx context.Context, userID int64) (*model.Session, error) {
+	return r.Resolver.CreateSession(ctx, userID)
+}
+
+// RetryJob is the resolver for the retryJob field.
+func (r *mutationResolver) RetryJob(ctx context.Context, id int64) (*model.MediaJob, error) {
+	return r.Resolver.RetryJob(ctx, id)
+}
+
+// CancelJob is the resolver for the cancelJob field.
+func (r *mutationResolver) CancelJob(ctx context.Context, id int64) (*model.MediaJob, error) {
+	return r.Resolver.CancelJob(ctx, id)
+}
+
+// ApproveImage is the resolver for the approveImage field.
+func (r *mutationResolver) ApproveImage(ctx context.Context, id int64) (*model.Image, error) {
+	return r.Resolver.ApproveImage(ctx, id)
+}
+
+// RejectImage is the resolver for the rejectImage field.
+func (r *mutationResolver) RejectImage(ctx context.Context, id int64) (*model.Image, error) {
+	return r.Resolver.RejectImage(ctx, id)
+}
+
+// UpdateImageCaption is the resolver for the updateImageCaption field.
+func (r *mutationResolver) UpdateImageCaption(ctx context.Context, imageID int64, language string, input model.ImageCaptionInput) (*model.ImageCaption, error) {
+	return r.Resolver.UpdateImageCaption(ctx, imageID, language, input)
+}
+
+// AddLabelAlias is the resolver for the addLabelAlias field.
+func (r *mutationResolver) AddLabelAlias(ctx context.Context, labelID int64, alias string) (*model.Label, error) {
+	return r.Resolver.AddLabelAlias(ctx, labelID, alias)
+}
+
+// RemoveLabelAlias is the resolver for the removeLabelAlias field.
+func (r *mutationResolver) RemoveLabelAlias(ctx context.Context, alias string) (bool, error) {
+	return r.Resolver.RemoveLabelAlias(ctx, alias)
+}
+
+// MergeLabels is the resolver for the mergeLabels field.
+func (r *mutationResolver) MergeLabels(ctx context.Context, sourceIds []int64, targetID int64) (*model.Label, error) {
+	return r.Resolver.MergeLabels(ctx, sourceIds, targetID)
+}
+
+// SetLabelParent is the resolver for the setLabelParent field.
+func (r *mutationResolver) SetLabelParent(ctx context.Context, labelID int64, parentID int64) (*model.Label, error) {
+	return r.Resolver.SetLabelParent(ctx, labelID, parentID)
+}
+
+// RemoveLabelParent is the resolver for the removeLabelParent field.
+func (r *mutationResolver) RemoveLabelParent(ctx context.Context, labelID int64, parentID int64) (*model.Label, error) {
+	return r.Resolver.RemoveLabelParent(ctx, labelID, parentID)
+}
+
+// SetLabelHidden is the resolver for the setLabelHidden field.
+func (r *mutationResolver) SetLabelHidden(ctx context.Context, id int64, hidden bool) (*model.Label, error) {
+	return r.Resolver.SetLabelHidden(ctx, id, hidden)
+}
+
+// SetKeywordHidden is the resolver for the setKeywordHidden field.
+func (r *mutationResolver) SetKeywordHidden(ctx context.Context, keyword string, hidden bool) (*model.TextKeyword, error) {
+	return r.Resolver.SetKeywordHidden(ctx, keyword, hidden)
+}
+
+// AddTaxonomyBlock is the resolver for the addTaxonomyBlock field.
+func (r *mutationResolver) AddTaxonomyBlock(ctx context.Context, kind model.TaxonomyKind, pattern string) (*model.TaxonomyBlock, error) {
+	return r.Resolver.AddTaxonomyBlock(ctx, kind, pattern)
+}
+
+// RemoveTaxonomyBlock is the resolver for the removeTaxonomyBlock field.
+func (r *mutationResolver) RemoveTaxonomyBlock(ctx context.Context, id int64) (bool, error) {
+	return r.Resolver.RemoveTaxonomyBlock(ctx, id)
+}
+
 // Users is the resolver for the users field.
 func (r *queryResolver) Users(ctx context.Context) ([]*model.User, error) {
 	return r.Resolver.Users(ctx)
@@ -96,11 +206,115 @@ func (r *queryResolver) GenerateS3UploadURL(ctx context.Context, filename string
 	return r.Resolver.GenerateS3UploadURL(ctx, filename)
 }
 
+// Images is the resolver for the images field.
+func (r *queryResolver) Images(ctx context.Context, filter *model.ImageFilter, first *int32, after *string) (*model.ImageConnection, error) {
+	return r.Resolver.Images(ctx, filter, first, after)
+}
+
+// Image is the resolver for the image field.
+func (r *queryResolver) Image(ctx context.Context, id int64) (*model.Image, error) {
+	return r.Resolver.Image(ctx, id)
+}
+
+// Labels is the resolver for the labels field.
+func (r *queryResolver) Labels(ctx context.Context, includeHidden *bool) ([]*model.Label, error) {
+	return r.Resolver.Labels(ctx, includeHidden)
+}
+
+// ImageTextBlocks is the resolver for the imageTextBlocks field.
+func (r *queryResolver) ImageTextBlocks(ctx context.Context, imageID int64, page *int32, typeArg *model.TextBlockType) ([]*model.TextBlock, error) {
+	return r.Resolver.ImageTextBlocks(ctx, imageID, page, typeArg)
+}
+
+// ImagePages is the resolver for the imagePages field.
+func (r *queryResolver) ImagePages(ctx context.Context, imageID int64) ([]*model.PageText, error) {
+	return r.Resolver.ImagePages(ctx, imageID)
+}
+
+// SimilarImages is the resolver for the similarImages field.
+func (r *queryResolver) SimilarImages(ctx context.Context, imageID int64, maxDistance *int32, first *int32) ([]*model.SimilarImage, error) {
+	return r.Resolver.SimilarImages(ctx, imageID, maxDistance, first)
+}
+
+// VideoTimeline is the resolver for the videoTimeline field.
+func (r *queryResolver) VideoTimeline(ctx context.Context, imageID int64, minConfidence *float64) (*model.VideoTimeline, error) {
+	return r.Resolver.VideoTimeline(ctx, imageID, minConfidence)
+}
+
+// DocumentFields is the resolver for the documentFields field.
+func (r *queryResolver) DocumentFields(ctx context.Context, imageID int64) ([]*model.DocumentField, error) {
+	return r.Resolver.DocumentFields(ctx, imageID)
+}
+
+// DocumentTables is the resolver for the documentTables field.
+func (r *queryResolver) DocumentTables(ctx context.Context, imageID int64) ([]*model.DocumentTable, error) {
+	return r.Resolver.DocumentTables(ctx, imageID)
+}
+
+// DocumentTable is the resolver for the documentTable field.
+func (r *queryResolver) DocumentTable(ctx context.Context, id int64) (*model.DocumentTable, error) {
+	return r.Resolver.DocumentTable(ctx, id)
+}
+
+// SearchImages is the resolver for the searchImages field.
+func (r *queryResolver) SearchImages(ctx context.Context, query *string, filter *model.ImageSearchFilter, first *int32, after *string, facetLimit *int32) (*model.ImageSearchResult, error) {
+	return r.Resolver.SearchImages(ctx, query, filter, first, after, facetLimit)
+}
+
+// SemanticSearchImages is the resolver for the semanticSearchImages field.
+func (r *queryResolver) SemanticSearchImages(ctx context.Context, query string, k *int32) ([]*model.SemanticSearchHit, error) {
+	return r.Resolver.SemanticSearchImages(ctx, query, k)
+}
+
+// MediaJob is the resolver for the mediaJob field.
+func (r *queryResolver) MediaJob(ctx context.Context, id int64) (*model.MediaJob, error) {
+	return r.Resolver.MediaJob(ctx, id)
+}
+
+// ImageJobs is the resolver for the imageJobs field.
+func (r *queryResolver) ImageJobs(ctx context.Context, imageID int64) ([]*model.MediaJob, error) {
+	return r.Resolver.ImageJobs(ctx, imageID)
+}
+
+// MediaJobs is the resolver for the mediaJobs field.
+func (r *queryResolver) MediaJobs(ctx context.Context, status *model.MediaJobStatus, imageID *int64, first *int32) ([]*model.MediaJob, error) {
+	return r.Resolver.MediaJobs(ctx, status, imageID, first)
+}
+
+// ModerationQueue is the resolver for the moderationQueue field.
+func (r *queryResolver) ModerationQueue(ctx context.Context, first *int32, after *string) (*model.ImageConnection, error) {
+	return r.Resolver.ModerationQueue(ctx, first, after)
+}
+
+// TaxonomyBlocks is the resolver for the taxonomyBlocks field.
+func (r *queryResolver) TaxonomyBlocks(ctx context.Context) ([]*model.TaxonomyBlock, error) {
+	return r.Resolver.TaxonomyBlocks(ctx)
+}
+
+// MessageAdded is the resolver for the messageAdded field.
+func (r *subscriptionResolver) MessageAdded(ctx context.Context, chatID int64) (<-chan *model.ChatMessage, error) {
+	return r.Resolver.MessageAdded(ctx, chatID)
+}
+
+// ChatUpdated is the resolver for the chatUpdated field.
+func (r *subscriptionResolver) ChatUpdated(ctx context.Context, userID int64) (<-chan *model.Chat, error) {
+	return r.Resolver.ChatUpdated(ctx, userID)
+}
+
+// MediaProcessed is the resolver for the mediaProcessed field.
+func (r *subscriptionResolver) MediaProcessed(ctx context.Context) (<-chan *model.MediaProcessedEvent, error) {
+	return r.Resolver.MediaProcessed(ctx)
+}
+
 // Mutation returns MutationResolver implementation.
 func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
 
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 
+// Subscription returns SubscriptionResolver implementation.
+func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
+
 type mutationResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
+type subscriptionResolver struct{ *Resolver }
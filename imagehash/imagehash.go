@@ -0,0 +1,163 @@
+// Package imagehash fingerprints files for duplicate detection: a SHA-256 of the content
+// for exact copies and a 64-bit difference hash (dHash) of the pixels for near-duplicates.
+package imagehash
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"  // register GIF decoding for image.Decode
+	_ "image/jpeg" // register JPEG decoding for image.Decode
+	_ "image/png"  // register PNG decoding for image.Decode
+	"io"
+	"math/bits"
+	"os"
+	"strconv"
+)
+
+// dHash compares each pixel with its right neighbour on a 9x8 grayscale thumbnail
+const (
+	hashWidth  = 9
+	hashHeight = 8
+)
+
+// MaxPixels is the largest width × height Perceptual decodes; larger images only get their content hash
+const MaxPixels = 40_000_000
+
+// ErrUnsupportedImage is returned by Perceptual for content the standard decoders cannot read
+var ErrUnsupportedImage = errors.New("unsupported image format")
+
+// SHA256 returns the hex SHA-256 of the reader's content
+func SHA256(r io.Reader) (string, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Perceptual returns the dHash of a JPEG, PNG or GIF image; visually similar images have hashes
+// a small Hamming distance apart regardless of size, compression or minor edits. Images above MaxPixels are
+// rejected with ErrUnsupportedImage before their pixels are decoded.
+func Perceptual(r io.Reader) (uint64, error) {
+	// Keep the header DecodeConfig consumes so Decode can start over from the beginning
+	var header bytes.Buffer
+	config, _, err := image.DecodeConfig(io.TeeReader(r, &header))
+	if err != nil {
+		if errors.Is(err, image.ErrFormat) {
+			return 0, ErrUnsupportedImage
+		}
+		return 0, fmt.Errorf("failed to decode image header: %w", err)
+	}
+	if int64(config.Width)*int64(config.Height) > MaxPixels {
+		return 0, fmt.Errorf("%w: %dx%d exceeds %d pixels", ErrUnsupportedImage, config.Width, config.Height, MaxPixels)
+	}
+
+	img, _, err := image.Decode(io.MultiReader(&header, r))
+	if err != nil {
+		if errors.Is(err, image.ErrFormat) {
+			return 0, ErrUnsupportedImage
+		}
+		return 0, fmt.Errorf("failed to decode image: %w", err)
+	}
+	return DHash(img), nil
+}
+
+// DHash computes the difference hash of an image
+func DHash(img image.Image) uint64 {
+	gray := thumbnail(img)
+	var hash uint64
+	for y := 0; y < hashHeight; y++ {
+		for x := 0; x < hashWidth-1; x++ {
+			hash <<= 1
+			if gray[y][x] < gray[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// thumbnail shrinks the image to hashWidth x hashHeight luminance values by averaging each box of pixels
+func thumbnail(img image.Image) [hashHeight][hashWidth]float64 {
+	var result [hashHeight][hashWidth]float64
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return result
+	}
+
+	for ty := 0; ty < hashHeight; ty++ {
+		y0 := bounds.Min.Y + ty*height/hashHeight
+		y1 := bounds.Min.Y + (ty+1)*height/hashHeight
+		if y1 == y0 {
+			y1 = y0 + 1
+		}
+		for tx := 0; tx < hashWidth; tx++ {
+			x0 := bounds.Min.X + tx*width/hashWidth
+			x1 := bounds.Min.X + (tx+1)*width/hashWidth
+			if x1 == x0 {
+				x1 = x0 + 1
+			}
+
+			var sum float64
+			for y := y0; y < y1 && y < bounds.Max.Y; y++ {
+				for x := x0; x < x1 && x < bounds.Max.X; x++ {
+					r, g, b, _ := img.At(x, y).RGBA()
+					// ITU-R BT.601 luma
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+				}
+			}
+			result[ty][tx] = sum / float64((y1-y0)*(x1-x0))
+		}
+	}
+	return result
+}
+
+// Distance is the number of differing bits between two hashes
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Format encodes a hash as 16 hex digits for storage
+func Format(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+// Parse decodes a hash written by Format
+func Parse(value string) (uint64, error) {
+	return strconv.ParseUint(value, 16, 64)
+}
+
+// Fingerprint is the content and perceptual hash of a file; Perceptual is empty for files that cannot be decoded as images
+type Fingerprint struct {
+	SHA256     string
+	Perceptual string
+}
+
+// FingerprintFile hashes the file at path, skipping the perceptual hash for formats it cannot decode
+func FingerprintFile(path string) (*Fingerprint, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	sum, err := SHA256(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	fingerprint := &Fingerprint{SHA256: sum}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	// Files that are not images, or are damaged, still get their content hash
+	if hash, err := Perceptual(file); err == nil {
+		fingerprint.Perceptual = Format(hash)
+	}
+	return fingerprint, nil
+}
@@ -0,0 +1,190 @@
+package imagehash
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// rowsImage is a 9x8 grayscale image, so its thumbnail is the image itself. Even rows get brighter to the
+// right and odd rows darker.
+func rowsImage() *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, hashWidth, hashHeight))
+	for y := 0; y < hashHeight; y++ {
+		for x := 0; x < hashWidth; x++ {
+			value := uint8(20 * x)
+			if y%2 == 1 {
+				value = uint8(20 * (hashWidth - x))
+			}
+			img.SetGray(x, y, color.Gray{Y: value})
+		}
+	}
+	return img
+}
+
+// wavesImage is a smooth pattern large enough for resampling and compression to matter
+func wavesImage(mirrored bool) *image.RGBA {
+	const size = 256
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			fx := float64(x)
+			if mirrored {
+				fx = float64(size - 1 - x)
+			}
+			value := uint8(128 + 100*math.Sin(fx/23+float64(y)/37)*math.Cos(float64(y)/19-fx/51))
+			img.Set(x, y, color.RGBA{R: value, G: value / 2, B: 255 - value, A: 255})
+		}
+	}
+	return img
+}
+
+func TestDHash(t *testing.T) {
+	if got, want := DHash(rowsImage()), uint64(0xff00ff00ff00ff00); got != want {
+		t.Fatalf("DHash = %016x, want %016x", got, want)
+	}
+	if got := DHash(image.NewGray(image.Rect(0, 0, 100, 100))); got != 0 {
+		t.Fatalf("DHash of a flat image = %016x, want 0", got)
+	}
+	if got := DHash(image.NewGray(image.Rect(0, 0, 0, 0))); got != 0 {
+		t.Fatalf("DHash of an empty image = %016x, want 0", got)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	for _, tc := range []struct {
+		a, b uint64
+		want int
+	}{
+		{0, 0, 0},
+		{0, math.MaxUint64, 64},
+		{0xff00ff00ff00ff00, 0x00ff00ff00ff00ff, 64},
+		{0b1011, 0b0001, 2},
+		{0x8000000000000000, 0x8000000000000001, 1},
+	} {
+		if got := Distance(tc.a, tc.b); got != tc.want {
+			t.Errorf("Distance(%016x, %016x) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestFormatParse(t *testing.T) {
+	formatted := Format(0xff00)
+	if formatted != "000000000000ff00" {
+		t.Fatalf("Format = %q", formatted)
+	}
+	if parsed, err := Parse(formatted); err != nil || parsed != 0xff00 {
+		t.Fatalf("Parse = %x, %v", parsed, err)
+	}
+}
+
+func TestPerceptualNearDuplicate(t *testing.T) {
+	var original bytes.Buffer
+	if err := png.Encode(&original, wavesImage(false)); err != nil {
+		t.Fatal(err)
+	}
+	var recompressed bytes.Buffer
+	if err := jpeg.Encode(&recompressed, wavesImage(false), &jpeg.Options{Quality: 40}); err != nil {
+		t.Fatal(err)
+	}
+	var mirrored bytes.Buffer
+	if err := png.Encode(&mirrored, wavesImage(true)); err != nil {
+		t.Fatal(err)
+	}
+
+	hashes := make([]uint64, 0, 3)
+	for _, data := range []*bytes.Buffer{&original, &recompressed, &mirrored} {
+		hash, err := Perceptual(data)
+		if err != nil {
+			t.Fatalf("Perceptual: %v", err)
+		}
+		hashes = append(hashes, hash)
+	}
+
+	// 10 is the default DUPLICATE_MAX_DISTANCE
+	if distance := Distance(hashes[0], hashes[1]); distance > 10 {
+		t.Errorf("a recompressed copy is %d bits away, want a near-duplicate", distance)
+	}
+	if distance := Distance(hashes[0], hashes[2]); distance <= 10 {
+		t.Errorf("a mirrored image is only %d bits away, want a different image", distance)
+	}
+}
+
+// pngHeader returns only the signature and IHDR chunk of a PNG of the given size
+func pngHeader(width, height uint32) []byte {
+	var ihdr bytes.Buffer
+	ihdr.WriteString("IHDR")
+	binary.Write(&ihdr, binary.BigEndian, width)
+	binary.Write(&ihdr, binary.BigEndian, height)
+	// 8-bit truecolor, default compression, filter and no interlacing
+	ihdr.Write([]byte{8, 2, 0, 0, 0})
+
+	var header bytes.Buffer
+	header.WriteString("\x89PNG\r\n\x1a\n")
+	binary.Write(&header, binary.BigEndian, uint32(ihdr.Len()-4))
+	header.Write(ihdr.Bytes())
+	binary.Write(&header, binary.BigEndian, crc32.ChecksumIEEE(ihdr.Bytes()))
+	return header.Bytes()
+}
+
+func TestPerceptualRejectsOversizedHeader(t *testing.T) {
+	// No pixel data follows the header, so decoding the image would fail with a different error
+	_, err := Perceptual(bytes.NewReader(pngHeader(10000, 10000)))
+	if !errors.Is(err, ErrUnsupportedImage) || !strings.Contains(err.Error(), "exceeds") {
+		t.Fatalf("got %v, want the pixel cap to reject the image", err)
+	}
+
+	_, err = Perceptual(bytes.NewReader(pngHeader(100, 100)))
+	if err == nil || errors.Is(err, ErrUnsupportedImage) {
+		t.Fatalf("got %v, want a decode error for a small truncated image", err)
+	}
+}
+
+func TestPerceptualUnsupportedFormat(t *testing.T) {
+	if _, err := Perceptual(strings.NewReader("%PDF-1.7 not an image")); !errors.Is(err, ErrUnsupportedImage) {
+		t.Fatalf("got %v, want ErrUnsupportedImage", err)
+	}
+}
+
+func TestFingerprintFile(t *testing.T) {
+	dir := t.TempDir()
+	imagePath := filepath.Join(dir, "rows.png")
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, rowsImage()); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(imagePath, encoded.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	textPath := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(textPath, []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fingerprint, err := FingerprintFile(imagePath)
+	if err != nil {
+		t.Fatalf("FingerprintFile: %v", err)
+	}
+	if fingerprint.Perceptual != "ff00ff00ff00ff00" || len(fingerprint.SHA256) != 64 {
+		t.Fatalf("unexpected fingerprint %+v", fingerprint)
+	}
+
+	fingerprint, err = FingerprintFile(textPath)
+	if err != nil {
+		t.Fatalf("FingerprintFile: %v", err)
+	}
+	// SHA-256 of "hello"
+	if fingerprint.Perceptual != "" || fingerprint.SHA256 != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Fatalf("unexpected fingerprint %+v", fingerprint)
+	}
+}
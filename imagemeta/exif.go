@@ -0,0 +1,234 @@
+package imagemeta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"time"
+)
+
+// EXIF orientations, named after the transform that displays the stored pixels upright
+const (
+	OrientationNormal     = 1
+	OrientationFlipH      = 2
+	OrientationRotate180  = 3
+	OrientationFlipV      = 4
+	OrientationTranspose  = 5
+	OrientationRotate90   = 6
+	OrientationTransverse = 7
+	OrientationRotate270  = 8
+)
+
+// TIFF tags read from IFD0 and the Exif sub-IFD
+const (
+	tagMake               = 0x010f
+	tagModel              = 0x0110
+	tagOrientation        = 0x0112
+	tagDateTime           = 0x0132
+	tagExifIFD            = 0x8769
+	tagDateTimeOriginal   = 0x9003
+	tagOffsetTimeOriginal = 0x9011
+)
+
+// TIFF field types used by those tags
+const (
+	typeASCII = 2
+	typeShort = 3
+	typeLong  = 4
+)
+
+// exifDateLayout is the format of EXIF date and time values, which carry no time zone
+const exifDateLayout = "2006:01:02 15:04:05"
+
+var errNoExif = errors.New("no EXIF data")
+
+// exifData holds the EXIF fields the media library keeps
+type exifData struct {
+	make        string
+	model       string
+	orientation int
+	capturedAt  *time.Time
+}
+
+// readJPEGExif finds the APP1 Exif segment of a JPEG file and parses it
+func readJPEGExif(content []byte) (*exifData, error) {
+	if len(content) < 4 || content[0] != 0xff || content[1] != 0xd8 {
+		return nil, errNoExif
+	}
+
+	offset := 2
+	for offset+4 <= len(content) {
+		if content[offset] != 0xff {
+			return nil, errNoExif
+		}
+		marker := content[offset+1]
+		// Start of scan or end of image: metadata segments always come before the pixels
+		if marker == 0xda || marker == 0xd9 {
+			return nil, errNoExif
+		}
+		length := int(binary.BigEndian.Uint16(content[offset+2:]))
+		if length < 2 || offset+2+length > len(content) {
+			return nil, errNoExif
+		}
+		segment := content[offset+4 : offset+2+length]
+		if marker == 0xe1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return parseTIFF(segment[6:])
+		}
+		offset += 2 + length
+	}
+	return nil, errNoExif
+}
+
+// tiffReader reads IFD entries from a TIFF structure in either byte order
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+// ifdEntry is one tag of an image file directory
+type ifdEntry struct {
+	tag      uint16
+	dataType uint16
+	count    uint32
+	// value holds the field data inline when it fits in four bytes, otherwise its offset
+	value []byte
+}
+
+func parseTIFF(data []byte) (*exifData, error) {
+	if len(data) < 8 {
+		return nil, errNoExif
+	}
+	reader := &tiffReader{data: data}
+	switch string(data[:2]) {
+	case "II":
+		reader.order = binary.LittleEndian
+	case "MM":
+		reader.order = binary.BigEndian
+	default:
+		return nil, errNoExif
+	}
+	if reader.order.Uint16(data[2:]) != 42 {
+		return nil, errNoExif
+	}
+
+	ifd0, err := reader.entries(reader.order.Uint32(data[4:]))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &exifData{}
+	var dateTime, dateTimeOriginal, offsetTimeOriginal string
+	for _, entry := range ifd0 {
+		switch entry.tag {
+		case tagMake:
+			result.make = reader.ascii(entry)
+		case tagModel:
+			result.model = reader.ascii(entry)
+		case tagOrientation:
+			result.orientation = int(reader.uint(entry))
+		case tagDateTime:
+			dateTime = reader.ascii(entry)
+		case tagExifIFD:
+			exifIFD, err := reader.entries(reader.uint(entry))
+			if err != nil {
+				continue
+			}
+			for _, exifEntry := range exifIFD {
+				switch exifEntry.tag {
+				case tagDateTimeOriginal:
+					dateTimeOriginal = reader.ascii(exifEntry)
+				case tagOffsetTimeOriginal:
+					offsetTimeOriginal = reader.ascii(exifEntry)
+				}
+			}
+		}
+	}
+
+	// Prefer the shutter time; DateTime is when the file was last changed
+	if capturedAt, ok := parseExifTime(dateTimeOriginal, offsetTimeOriginal); ok {
+		result.capturedAt = &capturedAt
+	} else if capturedAt, ok := parseExifTime(dateTime, ""); ok {
+		result.capturedAt = &capturedAt
+	}
+	return result, nil
+}
+
+// entries reads the image file directory at offset
+func (r *tiffReader) entries(offset uint32) ([]ifdEntry, error) {
+	start := int(offset)
+	if start < 0 || start+2 > len(r.data) {
+		return nil, errNoExif
+	}
+	count := int(r.order.Uint16(r.data[start:]))
+	if start+2+count*12 > len(r.data) {
+		return nil, errNoExif
+	}
+
+	entries := make([]ifdEntry, 0, count)
+	for i := 0; i < count; i++ {
+		raw := r.data[start+2+i*12:]
+		entries = append(entries, ifdEntry{
+			tag:      r.order.Uint16(raw),
+			dataType: r.order.Uint16(raw[2:]),
+			count:    r.order.Uint32(raw[4:]),
+			value:    raw[8:12],
+		})
+	}
+	return entries, nil
+}
+
+// uint returns a SHORT or LONG value, 0 for other types
+func (r *tiffReader) uint(entry ifdEntry) uint32 {
+	switch entry.dataType {
+	case typeShort:
+		return uint32(r.order.Uint16(entry.value))
+	case typeLong:
+		return r.order.Uint32(entry.value)
+	}
+	return 0
+}
+
+// ascii returns an ASCII value without its NUL terminator and padding, "" for other types
+func (r *tiffReader) ascii(entry ifdEntry) string {
+	if entry.dataType != typeASCII || entry.count == 0 {
+		return ""
+	}
+	length := int(entry.count)
+	raw := entry.value
+	if length > 4 {
+		start := int(r.order.Uint32(entry.value))
+		if start < 0 || start+length > len(r.data) {
+			return ""
+		}
+		raw = r.data[start : start+length]
+	} else {
+		raw = raw[:length]
+	}
+	if end := bytes.IndexByte(raw, 0); end >= 0 {
+		raw = raw[:end]
+	}
+	return strings.TrimSpace(string(raw))
+}
+
+// parseExifTime parses an EXIF date, using the "+09:00" style offset when given and UTC otherwise
+func parseExifTime(value, offset string) (time.Time, bool) {
+	if value == "" || strings.HasPrefix(value, "0000") {
+		return time.Time{}, false
+	}
+	if offset != "" {
+		if parsed, err := time.Parse(exifDateLayout+"-07:00", value+offset); err == nil {
+			return parsed, true
+		}
+	}
+	parsed, err := time.Parse(exifDateLayout, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return parsed, true
+}
+
+// swapsAxes reports whether displaying the image upright exchanges its width and height
+func swapsAxes(orientation int) bool {
+	return orientation >= OrientationTranspose && orientation <= OrientationRotate270
+}
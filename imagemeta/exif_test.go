@@ -0,0 +1,234 @@
+package imagemeta
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+)
+
+// testEntry is an IFD entry written by buildTIFF; number holds SHORT and LONG values and text ASCII ones
+type testEntry struct {
+	tag      uint16
+	dataType uint16
+	number   uint32
+	text     string
+}
+
+// buildTIFF lays out a TIFF header, IFD0 and, when exif is not nil, an Exif sub-IFD linked from IFD0.
+// ASCII values longer than four bytes go to a data area after the directories.
+func buildTIFF(order binary.ByteOrder, ifd0, exif []testEntry) []byte {
+	ifd0Offset := 8
+	exifOffset := ifd0Offset + 2 + 12*(len(ifd0)+1) + 4
+	dataOffset := exifOffset
+	if exif != nil {
+		ifd0 = append(ifd0, testEntry{tag: tagExifIFD, dataType: typeLong, number: uint32(exifOffset)})
+		dataOffset += 2 + 12*len(exif) + 4
+	}
+
+	data := make([]byte, dataOffset)
+	if order == binary.LittleEndian {
+		copy(data, "II")
+	} else {
+		copy(data, "MM")
+	}
+	order.PutUint16(data[2:], 42)
+	order.PutUint32(data[4:], uint32(ifd0Offset))
+
+	writeIFD := func(start int, entries []testEntry) {
+		order.PutUint16(data[start:], uint16(len(entries)))
+		for i, entry := range entries {
+			raw := data[start+2+i*12:]
+			order.PutUint16(raw, entry.tag)
+			order.PutUint16(raw[2:], entry.dataType)
+			switch entry.dataType {
+			case typeShort:
+				order.PutUint32(raw[4:], 1)
+				order.PutUint16(raw[8:], uint16(entry.number))
+			case typeLong:
+				order.PutUint32(raw[4:], 1)
+				order.PutUint32(raw[8:], entry.number)
+			case typeASCII:
+				value := entry.text + "\x00"
+				order.PutUint32(raw[4:], uint32(len(value)))
+				if len(value) <= 4 {
+					copy(raw[8:12], value)
+				} else {
+					order.PutUint32(raw[8:], uint32(len(data)))
+					data = append(data, value...)
+				}
+			}
+		}
+	}
+	writeIFD(ifd0Offset, ifd0)
+	if exif != nil {
+		writeIFD(exifOffset, exif)
+	}
+	return data
+}
+
+// cameraTIFF is a typical camera file: make, model, orientation and both capture times
+func cameraTIFF(order binary.ByteOrder) []byte {
+	return buildTIFF(order, []testEntry{
+		{tag: tagMake, dataType: typeASCII, text: "Canon"},
+		{tag: tagModel, dataType: typeASCII, text: "R5"},
+		{tag: tagOrientation, dataType: typeShort, number: OrientationRotate90},
+		{tag: tagDateTime, dataType: typeASCII, text: "2021:03:04 05:06:07"},
+	}, []testEntry{
+		{tag: tagDateTimeOriginal, dataType: typeASCII, text: "2020:01:02 03:04:05"},
+		{tag: tagOffsetTimeOriginal, dataType: typeASCII, text: "+09:00"},
+	})
+}
+
+func TestParseTIFFByteOrders(t *testing.T) {
+	want := time.Date(2020, 1, 1, 18, 4, 5, 0, time.UTC)
+	for name, order := range map[string]binary.ByteOrder{"little endian": binary.LittleEndian, "big endian": binary.BigEndian} {
+		t.Run(name, func(t *testing.T) {
+			exif, err := parseTIFF(cameraTIFF(order))
+			if err != nil {
+				t.Fatalf("parseTIFF: %v", err)
+			}
+			if exif.make != "Canon" || exif.model != "R5" || exif.orientation != OrientationRotate90 {
+				t.Fatalf("got %+v", exif)
+			}
+			if exif.capturedAt == nil || !exif.capturedAt.Equal(want) {
+				t.Fatalf("captured at %v, want %s", exif.capturedAt, want)
+			}
+		})
+	}
+}
+
+func TestParseTIFFFallsBackToDateTime(t *testing.T) {
+	exif, err := parseTIFF(buildTIFF(binary.BigEndian, []testEntry{
+		{tag: tagOrientation, dataType: typeLong, number: OrientationFlipV},
+		{tag: tagDateTime, dataType: typeASCII, text: "2021:03:04 05:06:07"},
+	}, []testEntry{
+		{tag: tagDateTimeOriginal, dataType: typeASCII, text: "0000:00:00 00:00:00"},
+	}))
+	if err != nil {
+		t.Fatalf("parseTIFF: %v", err)
+	}
+	if exif.orientation != OrientationFlipV {
+		t.Errorf("orientation %d from a LONG field, want %d", exif.orientation, OrientationFlipV)
+	}
+	if want := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC); exif.capturedAt == nil || !exif.capturedAt.Equal(want) {
+		t.Errorf("captured at %v, want %s", exif.capturedAt, want)
+	}
+}
+
+func TestParseTIFFTruncated(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		data := cameraTIFF(order)
+		// IFD0 at offset 8 holds five entries including the Exif pointer
+		ifd0End := 8 + 2 + 5*12
+		for length := 0; length < len(data); length++ {
+			exif, err := parseTIFF(data[:length])
+			if length < ifd0End {
+				if !errors.Is(err, errNoExif) {
+					t.Fatalf("%s cut at %d: got %+v, %v; want errNoExif", order, length, exif, err)
+				}
+				continue
+			}
+			// Values past the cut are dropped instead of read out of range
+			if err != nil {
+				t.Fatalf("%s cut at %d: %v", order, length, err)
+			}
+		}
+	}
+}
+
+func TestParseTIFFBadHeaders(t *testing.T) {
+	valid := cameraTIFF(binary.LittleEndian)
+	for name, patch := range map[string]func([]byte){
+		"byte order": func(data []byte) { copy(data, "XX") },
+		"magic":      func(data []byte) { binary.LittleEndian.PutUint16(data[2:], 43) },
+		"IFD0 offset": func(data []byte) {
+			binary.LittleEndian.PutUint32(data[4:], 0xffffffff)
+		},
+		"entry count": func(data []byte) { binary.LittleEndian.PutUint16(data[8:], 0xffff) },
+	} {
+		data := append([]byte(nil), valid...)
+		patch(data)
+		if _, err := parseTIFF(data); !errors.Is(err, errNoExif) {
+			t.Errorf("%s: got %v, want errNoExif", name, err)
+		}
+	}
+}
+
+func TestParseTIFFOutOfRangeOffsets(t *testing.T) {
+	data := cameraTIFF(binary.LittleEndian)
+	// Point the make value and the Exif sub-IFD past the end of the data
+	entry := func(i int) []byte { return data[8+2+i*12:] }
+	binary.LittleEndian.PutUint32(entry(0)[8:], 0xfffffff0)
+	binary.LittleEndian.PutUint32(entry(4)[8:], 0xfffffff0)
+
+	exif, err := parseTIFF(data)
+	if err != nil {
+		t.Fatalf("parseTIFF: %v", err)
+	}
+	if exif.make != "" || exif.model != "R5" {
+		t.Errorf("got make %q and model %q, want only the make dropped", exif.make, exif.model)
+	}
+	// Without the Exif sub-IFD the file's DateTime is used
+	if want := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC); exif.capturedAt == nil || !exif.capturedAt.Equal(want) {
+		t.Errorf("captured at %v, want %s", exif.capturedAt, want)
+	}
+}
+
+func TestParseTIFFLoopingIFD(t *testing.T) {
+	// The Exif pointer leads back to IFD0, which holds the pointer again
+	data := buildTIFF(binary.BigEndian, []testEntry{
+		{tag: tagMake, dataType: typeASCII, text: "Nikon"},
+		{tag: tagExifIFD, dataType: typeLong, number: 8},
+	}, nil)
+
+	done := make(chan *exifData)
+	go func() {
+		exif, _ := parseTIFF(data)
+		done <- exif
+	}()
+	select {
+	case exif := <-done:
+		if exif == nil || exif.make != "Nikon" {
+			t.Fatalf("got %+v", exif)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("parseTIFF followed the IFD loop")
+	}
+}
+
+// jpegWithSegments wraps segments, each a marker followed by its payload, in a JPEG start and end of image
+func jpegWithSegments(segments ...[]byte) []byte {
+	content := []byte{0xff, 0xd8}
+	for _, segment := range segments {
+		// The length counts itself but not the marker
+		length := len(segment)
+		content = append(content, segment[0], segment[1], byte(length>>8), byte(length))
+		content = append(content, segment[2:]...)
+	}
+	return append(content, 0xff, 0xd9)
+}
+
+func TestReadJPEGExif(t *testing.T) {
+	app0 := append([]byte{0xff, 0xe0}, "JFIF\x00\x01\x01"...)
+	app1 := append(append([]byte{0xff, 0xe1}, "Exif\x00\x00"...), cameraTIFF(binary.BigEndian)...)
+	sos := []byte{0xff, 0xda, 0x00}
+
+	exif, err := readJPEGExif(jpegWithSegments(app0, app1))
+	if err != nil || exif.make != "Canon" {
+		t.Fatalf("got %+v, %v", exif, err)
+	}
+
+	truncated := jpegWithSegments(app0, app1)
+	for name, content := range map[string][]byte{
+		"not a JPEG":         []byte("\x89PNG\r\n\x1a\n"),
+		"no Exif segment":    jpegWithSegments(app0),
+		"Exif after pixels":  jpegWithSegments(app0, sos, app1),
+		"truncated segment":  truncated[:len(truncated)-40],
+		"segment length < 2": {0xff, 0xd8, 0xff, 0xe1, 0x00, 0x01},
+	} {
+		if _, err := readJPEGExif(content); !errors.Is(err, errNoExif) {
+			t.Errorf("%s: got %v, want errNoExif", name, err)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Package imagemeta reads image properties and EXIF metadata from local files and renders thumbnails.
+package imagemeta
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"  // register GIF decoding for image.DecodeConfig
+	_ "image/jpeg" // register JPEG decoding for image.DecodeConfig
+	_ "image/png"  // register PNG decoding for image.DecodeConfig
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// sniffLength is the number of bytes http.DetectContentType looks at
+	sniffLength = 512
+	// headerLength covers the JPEG segments that may precede the EXIF block and the EXIF block itself,
+	// whose segment is at most 64 KiB
+	headerLength = 256 << 10
+)
+
+// Metadata describes a file as found on disk
+type Metadata struct {
+	MimeType string
+	ByteSize int64
+	// Width and Height are the displayed size after applying Orientation; 0 when the format cannot be decoded
+	Width  int
+	Height int
+	// Orientation is the EXIF orientation from 1 (upright) to 8, 1 when absent
+	Orientation int
+	// CapturedAt is the EXIF original date and time, nil when absent
+	CapturedAt  *time.Time
+	CameraMake  string
+	CameraModel string
+}
+
+// Read returns the metadata of the file at path. Dimensions are read for JPEG, PNG and GIF and
+// EXIF for JPEG; other files only get their MIME type and size. Only the file's header is read.
+func Read(path string) (*Metadata, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	header := make([]byte, headerLength)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	header = header[:n]
+
+	metadata := &Metadata{
+		MimeType:    mimeType(path, header),
+		ByteSize:    info.Size(),
+		Orientation: OrientationNormal,
+	}
+
+	// DecodeConfig stops at the size; it only reads past the header when large segments come first
+	if config, _, err := image.DecodeConfig(io.MultiReader(bytes.NewReader(header), file)); err == nil {
+		metadata.Width, metadata.Height = config.Width, config.Height
+	}
+
+	if metadata.MimeType == "image/jpeg" {
+		exif, err := readJPEGExif(header)
+		if err != nil {
+			// A broken EXIF block must not stop ingestion; the image itself may be fine
+			return metadata, nil
+		}
+		metadata.CameraMake = exif.make
+		metadata.CameraModel = exif.model
+		metadata.CapturedAt = exif.capturedAt
+		if exif.orientation >= OrientationNormal && exif.orientation <= OrientationRotate270 {
+			metadata.Orientation = exif.orientation
+		}
+	}
+	if swapsAxes(metadata.Orientation) {
+		metadata.Width, metadata.Height = metadata.Height, metadata.Width
+	}
+	return metadata, nil
+}
+
+// mimeType sniffs the content and falls back to the file extension when sniffing is inconclusive
+func mimeType(path string, content []byte) string {
+	sniffed := http.DetectContentType(content[:min(len(content), sniffLength)])
+	if !strings.HasPrefix(sniffed, "application/octet-stream") && !strings.HasPrefix(sniffed, "text/plain") {
+		mediaType, _, _ := mime.ParseMediaType(sniffed)
+		return mediaType
+	}
+	if byExtension := mime.TypeByExtension(strings.ToLower(filepath.Ext(path))); byExtension != "" {
+		mediaType, _, _ := mime.ParseMediaType(byExtension)
+		return mediaType
+	}
+	mediaType, _, _ := mime.ParseMediaType(sniffed)
+	return mediaType
+}
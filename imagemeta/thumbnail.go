@@ -0,0 +1,230 @@
+package imagemeta
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultThumbnailSizes   = "256,1024"
+	defaultThumbnailQuality = 82
+	// defaultThumbnailMaxPixels keeps a decoded image and its RGBA copy well under a gigabyte
+	defaultThumbnailMaxPixels = 40_000_000
+	// ThumbnailMimeType is the format of every thumbnail
+	ThumbnailMimeType = "image/jpeg"
+	// ThumbnailExtension is the file extension of every thumbnail
+	ThumbnailExtension = ".jpg"
+)
+
+// ErrUnsupportedImage is returned by Thumbnails for files the standard decoders cannot read
+var ErrUnsupportedImage = errors.New("unsupported image format")
+
+// ThumbnailOptions configures thumbnail rendering
+type ThumbnailOptions struct {
+	// Sizes are the longest edges in pixels, smallest first
+	Sizes   []int
+	Quality int
+	// MaxPixels is the largest width × height decoded; larger images get no thumbnails
+	MaxPixels int
+}
+
+// ThumbnailOptionsFromEnv reads THUMBNAIL_SIZES, a comma-separated list of longest edges, THUMBNAIL_QUALITY
+// and THUMBNAIL_MAX_PIXELS
+func ThumbnailOptionsFromEnv() ThumbnailOptions {
+	options := ThumbnailOptions{Quality: defaultThumbnailQuality, MaxPixels: defaultThumbnailMaxPixels}
+
+	sizes := os.Getenv("THUMBNAIL_SIZES")
+	if sizes == "" {
+		sizes = defaultThumbnailSizes
+	}
+	seen := make(map[int]bool)
+	for _, value := range strings.Split(sizes, ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || size <= 0 {
+			log.Printf("Invalid THUMBNAIL_SIZES entry %q, ignoring it", value)
+			continue
+		}
+		if !seen[size] {
+			seen[size] = true
+			options.Sizes = append(options.Sizes, size)
+		}
+	}
+	sort.Ints(options.Sizes)
+
+	if value := os.Getenv("THUMBNAIL_QUALITY"); value != "" {
+		quality, err := strconv.Atoi(value)
+		if err != nil || quality < 1 || quality > 100 {
+			log.Printf("Invalid THUMBNAIL_QUALITY %q, using default %d", value, options.Quality)
+		} else {
+			options.Quality = quality
+		}
+	}
+
+	if value := os.Getenv("THUMBNAIL_MAX_PIXELS"); value != "" {
+		maxPixels, err := strconv.Atoi(value)
+		if err != nil || maxPixels < 1 {
+			log.Printf("Invalid THUMBNAIL_MAX_PIXELS %q, using default %d", value, options.MaxPixels)
+		} else {
+			options.MaxPixels = maxPixels
+		}
+	}
+	return options
+}
+
+// Thumbnail is a rendered JPEG thumbnail
+type Thumbnail struct {
+	// Size is the requested longest edge
+	Size   int
+	Width  int
+	Height int
+	Data   []byte
+}
+
+// Thumbnails renders one upright JPEG per configured size from a JPEG, PNG or GIF file.
+// Images are never enlarged, so a small image's thumbnails keep its own size. Images above options.MaxPixels
+// are rejected with ErrUnsupportedImage before their pixels are decoded.
+func Thumbnails(path string, orientation int, options ThumbnailOptions) ([]Thumbnail, error) {
+	if len(options.Sizes) == 0 {
+		return nil, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		if errors.Is(err, image.ErrFormat) {
+			return nil, ErrUnsupportedImage
+		}
+		return nil, fmt.Errorf("failed to decode image header: %w", err)
+	}
+	if options.MaxPixels > 0 && int64(config.Width)*int64(config.Height) > int64(options.MaxPixels) {
+		return nil, fmt.Errorf("%w: %dx%d exceeds %d pixels", ErrUnsupportedImage, config.Width, config.Height, options.MaxPixels)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to rewind file: %w", err)
+	}
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		if errors.Is(err, image.ErrFormat) {
+			return nil, ErrUnsupportedImage
+		}
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	// Flatten transparency onto white since JPEG has no alpha channel
+	bounds := img.Bounds()
+	source := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(source, source.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(source, source.Bounds(), img, bounds.Min, draw.Over)
+
+	thumbnails := make([]Thumbnail, 0, len(options.Sizes))
+	for _, size := range options.Sizes {
+		resized := orient(downscale(source, size), orientation)
+
+		var buffer bytes.Buffer
+		if err := jpeg.Encode(&buffer, resized, &jpeg.Options{Quality: options.Quality}); err != nil {
+			return nil, fmt.Errorf("failed to encode %dpx thumbnail: %w", size, err)
+		}
+		thumbnails = append(thumbnails, Thumbnail{
+			Size:   size,
+			Width:  resized.Bounds().Dx(),
+			Height: resized.Bounds().Dy(),
+			Data:   buffer.Bytes(),
+		})
+	}
+	return thumbnails, nil
+}
+
+// downscale shrinks the image so its longest edge is at most size, averaging each box of source pixels.
+// The longest edge is the same before and after orienting, so it runs on the stored pixels.
+func downscale(source *image.RGBA, size int) *image.RGBA {
+	width, height := source.Bounds().Dx(), source.Bounds().Dy()
+	longest := max(width, height)
+	if longest <= size {
+		return source
+	}
+
+	targetWidth := max(1, width*size/longest)
+	targetHeight := max(1, height*size/longest)
+	target := image.NewRGBA(image.Rect(0, 0, targetWidth, targetHeight))
+
+	for ty := 0; ty < targetHeight; ty++ {
+		y0 := ty * height / targetHeight
+		y1 := max(y0+1, (ty+1)*height/targetHeight)
+		for tx := 0; tx < targetWidth; tx++ {
+			x0 := tx * width / targetWidth
+			x1 := max(x0+1, (tx+1)*width/targetWidth)
+
+			var r, g, b, count int
+			for y := y0; y < y1; y++ {
+				row := source.Pix[y*source.Stride:]
+				for x := x0; x < x1; x++ {
+					r += int(row[x*4])
+					g += int(row[x*4+1])
+					b += int(row[x*4+2])
+					count++
+				}
+			}
+			offset := ty*target.Stride + tx*4
+			target.Pix[offset] = uint8(r / count)
+			target.Pix[offset+1] = uint8(g / count)
+			target.Pix[offset+2] = uint8(b / count)
+			target.Pix[offset+3] = 0xff
+		}
+	}
+	return target
+}
+
+// orient applies the EXIF orientation so the image displays upright
+func orient(source *image.RGBA, orientation int) *image.RGBA {
+	if orientation <= OrientationNormal || orientation > OrientationRotate270 {
+		return source
+	}
+
+	width, height := source.Bounds().Dx(), source.Bounds().Dy()
+	targetWidth, targetHeight := width, height
+	if swapsAxes(orientation) {
+		targetWidth, targetHeight = height, width
+	}
+	target := image.NewRGBA(image.Rect(0, 0, targetWidth, targetHeight))
+
+	for y := 0; y < targetHeight; y++ {
+		for x := 0; x < targetWidth; x++ {
+			var sx, sy int
+			switch orientation {
+			case OrientationFlipH:
+				sx, sy = width-1-x, y
+			case OrientationRotate180:
+				sx, sy = width-1-x, height-1-y
+			case OrientationFlipV:
+				sx, sy = x, height-1-y
+			case OrientationTranspose:
+				sx, sy = y, x
+			case OrientationRotate90:
+				sx, sy = y, height-1-x
+			case OrientationTransverse:
+				sx, sy = width-1-y, height-1-x
+			case OrientationRotate270:
+				sx, sy = width-1-y, x
+			}
+			copy(target.Pix[y*target.Stride+x*4:y*target.Stride+x*4+4], source.Pix[sy*source.Stride+sx*4:])
+		}
+	}
+	return target
+}
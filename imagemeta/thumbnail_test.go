@@ -0,0 +1,120 @@
+package imagemeta
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// letterImage is a 3x2 image whose pixels are A to F in reading order, stored in the red channel
+//
+//	A B C
+//	D E F
+func letterImage() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	for i := 0; i < 6; i++ {
+		img.Set(i%3, i/3, color.RGBA{R: 'A' + uint8(i), A: 0xff})
+	}
+	return img
+}
+
+// letters reads the image back as one string per row
+func letters(img *image.RGBA) []string {
+	var rows []string
+	for y := 0; y < img.Bounds().Dy(); y++ {
+		var row []byte
+		for x := 0; x < img.Bounds().Dx(); x++ {
+			row = append(row, img.RGBAAt(x, y).R)
+		}
+		rows = append(rows, string(row))
+	}
+	return rows
+}
+
+func TestOrient(t *testing.T) {
+	for _, tc := range []struct {
+		orientation int
+		want        []string
+	}{
+		{0, []string{"ABC", "DEF"}},
+		{OrientationNormal, []string{"ABC", "DEF"}},
+		{OrientationFlipH, []string{"CBA", "FED"}},
+		{OrientationRotate180, []string{"FED", "CBA"}},
+		{OrientationFlipV, []string{"DEF", "ABC"}},
+		{OrientationTranspose, []string{"AD", "BE", "CF"}},
+		{OrientationRotate90, []string{"DA", "EB", "FC"}},
+		{OrientationTransverse, []string{"FC", "EB", "DA"}},
+		{OrientationRotate270, []string{"CF", "BE", "AD"}},
+		{9, []string{"ABC", "DEF"}},
+	} {
+		got := letters(orient(letterImage(), tc.orientation))
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("orientation %d: got %v, want %v", tc.orientation, got, tc.want)
+		}
+		if swapsAxes(tc.orientation) != (len(tc.want) == 3) {
+			t.Errorf("orientation %d: swapsAxes = %v", tc.orientation, swapsAxes(tc.orientation))
+		}
+	}
+}
+
+func TestDownscale(t *testing.T) {
+	source := image.NewRGBA(image.Rect(0, 0, 400, 100))
+	for x := 0; x < 400; x++ {
+		for y := 0; y < 100; y++ {
+			source.Set(x, y, color.RGBA{R: uint8(x % 2 * 200), G: 50, B: 100, A: 0xff})
+		}
+	}
+
+	resized := downscale(source, 100)
+	if resized.Bounds().Dx() != 100 || resized.Bounds().Dy() != 25 {
+		t.Fatalf("got %v, want 100x25", resized.Bounds())
+	}
+	// Each target pixel averages alternating columns of 0 and 200
+	if got := resized.RGBAAt(10, 10); got != (color.RGBA{R: 100, G: 50, B: 100, A: 0xff}) {
+		t.Fatalf("got %v", got)
+	}
+	if downscale(source, 1000) != source {
+		t.Fatal("a small image was enlarged")
+	}
+}
+
+func TestThumbnails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wide.png")
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, image.NewRGBA(image.Rect(0, 0, 400, 200))); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, encoded.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	thumbnails, err := Thumbnails(path, OrientationRotate90, ThumbnailOptions{Sizes: []int{100, 1000}, Quality: 80, MaxPixels: 1_000_000})
+	if err != nil {
+		t.Fatalf("Thumbnails: %v", err)
+	}
+	if len(thumbnails) != 2 {
+		t.Fatalf("got %d thumbnails, want 2", len(thumbnails))
+	}
+	// The stored image is wide; rotated upright it is tall, and it is never enlarged past 400px
+	for i, want := range [][2]int{{50, 100}, {200, 400}} {
+		thumbnail := thumbnails[i]
+		if thumbnail.Width != want[0] || thumbnail.Height != want[1] {
+			t.Errorf("%dpx thumbnail is %dx%d, want %dx%d", thumbnail.Size, thumbnail.Width, thumbnail.Height, want[0], want[1])
+		}
+		config, err := jpeg.DecodeConfig(bytes.NewReader(thumbnail.Data))
+		if err != nil || config.Width != want[0] || config.Height != want[1] {
+			t.Errorf("%dpx thumbnail decodes as %+v, %v", thumbnail.Size, config, err)
+		}
+	}
+
+	if _, err := Thumbnails(path, OrientationNormal, ThumbnailOptions{Sizes: []int{100}, Quality: 80, MaxPixels: 400*200 - 1}); !errors.Is(err, ErrUnsupportedImage) {
+		t.Fatalf("got %v, want the pixel cap to reject the image", err)
+	}
+}
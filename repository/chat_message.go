@@ -10,6 +10,8 @@ type ChatMessageRepository interface {
 	GetMessagesByChatID(chatID int64) ([]*db.ChatMessage, error)
 	GetRecentMessagesByChatID(chatID int64, limit int) ([]*db.ChatMessage, error)
 	DeleteMessagesByChatID(chatID int64) error
+	CountBotMessagesAfter(chatID int64, afterMessageID int64) (int64, error)
+	GetMessagesAfter(chatID int64, afterMessageID int64) ([]*db.ChatMessage, error)
 }
 
 type chatMessageRepository struct {
@@ -42,4 +44,14 @@ func (r *chatMessageRepository) GetRecentMessagesByChatID(chatID int64, limit in
 func (r *chatMessageRepository) DeleteMessagesByChatID(chatID int64) error {
 	_, err := r.engine.Where("chat_id = ?", chatID).Delete(&db.ChatMessage{})
 	return err
+}
+
+func (r *chatMessageRepository) CountBotMessagesAfter(chatID int64, afterMessageID int64) (int64, error) {
+	return r.engine.Where("chat_id = ? AND id > ? AND is_user = ?", chatID, afterMessageID, false).Count(&db.ChatMessage{})
+}
+
+func (r *chatMessageRepository) GetMessagesAfter(chatID int64, afterMessageID int64) ([]*db.ChatMessage, error) {
+	var messages []*db.ChatMessage
+	err := r.engine.Where("chat_id = ? AND id > ?", chatID, afterMessageID).OrderBy("id ASC").Find(&messages)
+	return messages, err
 }
\ No newline at end of file
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"blog-fanchiikawa-service/db"
+	"xorm.io/xorm"
+)
+
+type ChatReadStateRepository interface {
+	GetByUserAndChat(userID, chatID int64) (*db.ChatReadState, error)
+	MarkRead(userID, chatID, messageID int64) (*db.ChatReadState, error)
+	DeleteByChatID(chatID int64) error
+}
+
+type chatReadStateRepository struct {
+	engine *xorm.Engine
+}
+
+func NewChatReadStateRepository(engine *xorm.Engine) ChatReadStateRepository {
+	return &chatReadStateRepository{
+		engine: engine,
+	}
+}
+
+func (r *chatReadStateRepository) GetByUserAndChat(userID, chatID int64) (*db.ChatReadState, error) {
+	state := &db.ChatReadState{}
+	has, err := r.engine.Where("user_id = ? AND chat_id = ?", userID, chatID).Get(state)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, nil
+	}
+	return state, nil
+}
+
+// MarkRead moves the read marker forward; it never moves backwards
+func (r *chatReadStateRepository) MarkRead(userID, chatID, messageID int64) (*db.ChatReadState, error) {
+	state, err := r.GetByUserAndChat(userID, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	if state == nil {
+		state = &db.ChatReadState{
+			UserID:            userID,
+			ChatID:            chatID,
+			LastReadMessageID: messageID,
+		}
+		_, err = r.engine.Insert(state)
+		return state, err
+	}
+
+	if messageID > state.LastReadMessageID {
+		state.LastReadMessageID = messageID
+		_, err = r.engine.ID(state.ID).Cols("last_read_message_id").Update(state)
+	}
+	return state, err
+}
+
+func (r *chatReadStateRepository) DeleteByChatID(chatID int64) error {
+	_, err := r.engine.Where("chat_id = ?", chatID).Delete(&db.ChatReadState{})
+	return err
+}
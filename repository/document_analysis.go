@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"blog-fanchiikawa-service/db"
+	"xorm.io/xorm"
+)
+
+type documentAnalysisRepository struct{}
+
+func NewDocumentAnalysisRepository() DocumentAnalysisRepository {
+	return &documentAnalysisRepository{}
+}
+
+func (r *documentAnalysisRepository) ReplaceForImage(session *xorm.Session, imageID int64, fields []*db.DocumentField, tables []*db.DocumentTable, cells [][]*db.DocumentTableCell) error {
+	if _, err := querier(session).Where("image_id = ?", imageID).Delete(&db.DocumentField{}); err != nil {
+		return err
+	}
+	if _, err := querier(session).Where("table_id IN (SELECT id FROM document_table WHERE image_id = ?)", imageID).Delete(&db.DocumentTableCell{}); err != nil {
+		return err
+	}
+	if _, err := querier(session).Where("image_id = ?", imageID).Delete(&db.DocumentTable{}); err != nil {
+		return err
+	}
+
+	if len(fields) > 0 {
+		if _, err := querier(session).Insert(fields); err != nil {
+			return err
+		}
+	}
+
+	for i, table := range tables {
+		// Tables are inserted one by one so their IDs are known for the cells
+		if _, err := querier(session).Insert(table); err != nil {
+			return err
+		}
+		if len(cells[i]) == 0 {
+			continue
+		}
+		for _, cell := range cells[i] {
+			cell.TableID = table.ID
+		}
+		if _, err := querier(session).Insert(cells[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *documentAnalysisRepository) GetFieldsByImageID(imageID int64) ([]*db.DocumentField, error) {
+	var fields []*db.DocumentField
+	err := db.Engine.Where("image_id = ?", imageID).OrderBy("field_index ASC").Find(&fields)
+	return fields, err
+}
+
+func (r *documentAnalysisRepository) GetTablesByImageID(imageID int64) ([]*db.DocumentTable, error) {
+	var tables []*db.DocumentTable
+	err := db.Engine.Where("image_id = ?", imageID).OrderBy("table_index ASC").Find(&tables)
+	return tables, err
+}
+
+func (r *documentAnalysisRepository) GetTableByID(id int64) (*db.DocumentTable, error) {
+	var table db.DocumentTable
+	has, err := db.Engine.ID(id).Get(&table)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, nil
+	}
+	return &table, nil
+}
+
+func (r *documentAnalysisRepository) GetCellsByTableIDs(tableIDs []int64) (map[int64][]*db.DocumentTableCell, error) {
+	result := make(map[int64][]*db.DocumentTableCell)
+	if len(tableIDs) == 0 {
+		return result, nil
+	}
+
+	var cells []*db.DocumentTableCell
+	err := db.Engine.In("table_id", tableIDs).OrderBy("row_index ASC, column_index ASC").Find(&cells)
+	if err != nil {
+		return nil, err
+	}
+	for _, cell := range cells {
+		result[cell.TableID] = append(result[cell.TableID], cell)
+	}
+	return result, nil
+}
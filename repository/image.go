@@ -2,8 +2,22 @@ package repository
 
 import (
 	"blog-fanchiikawa-service/db"
+	"xorm.io/xorm"
 )
 
+// ImageFilter narrows image listings; empty fields match every image
+type ImageFilter struct {
+	// Labels holds one group of alternative names per requested label; the image must carry a label of every group
+	Labels [][]string
+	// Keywords must all be attached to the image
+	Keywords      []string
+	Filename      string
+	LabelDetected *bool
+	TextDetected  *bool
+	// ModerationStatuses restricts images to those moderation statuses when not empty
+	ModerationStatuses []string
+}
+
 type imageRepository struct{}
 
 func NewImageReposity() ImageRepository {
@@ -24,8 +38,58 @@ func (r *imageRepository) GetByID(id int64) (*db.Image, error) {
 	return &image, err
 }
 
-func (r *imageRepository) UpdateLabelDetected(id int64, labelDetected bool) (int64, error) {
-	affected, err := db.Engine.ID(id).Cols("label_detected").Update(&db.Image{LabelDetected: labelDetected})
+func (r *imageRepository) GetByObjectKey(bucket, objectKey string) (*db.Image, error) {
+	var image db.Image
+	has, err := db.Engine.Where("bucket = ? AND object_key = ?", bucket, objectKey).Get(&image)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, nil
+	}
+	return &image, nil
+}
+
+func (r *imageRepository) GetExistingObjectKeys(bucket string, objectKeys []string) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	if len(objectKeys) == 0 {
+		return existing, nil
+	}
+	var images []*db.Image
+	err := db.Engine.Cols("object_key").Where("bucket = ?", bucket).In("object_key", objectKeys).Find(&images)
+	if err != nil {
+		return nil, err
+	}
+	for _, image := range images {
+		existing[image.ObjectKey] = true
+	}
+	return existing, nil
+}
+
+func (r *imageRepository) GetUncaptioned() ([]*db.Image, error) {
+	var images []*db.Image
+	err := db.Engine.
+		Where("id IN (SELECT image_id FROM image_thumbnail)").
+		And("id NOT IN (SELECT image_id FROM image_caption WHERE generated_at IS NOT NULL)").
+		Find(&images)
+	return images, err
+}
+
+func (r *imageRepository) GetUnembedded(model string) ([]*db.Image, error) {
+	var images []*db.Image
+	err := db.Engine.
+		Where("id NOT IN (SELECT image_id FROM image_embedding WHERE model = ?)", model).
+		Find(&images)
+	return images, err
+}
+
+func (r *imageRepository) UpdateVideoMetadata(id int64, durationMs int64, width, height int) (int64, error) {
+	return db.Engine.ID(id).Cols("duration_ms", "width", "height").
+		Update(&db.Image{DurationMs: durationMs, Width: width, Height: height})
+}
+
+func (r *imageRepository) UpdateLabelDetected(session *xorm.Session, id int64, labelDetected bool) (int64, error) {
+	affected, err := querier(session).ID(id).Cols("label_detected").Update(&db.Image{LabelDetected: labelDetected})
 	return affected, err
 }
 
@@ -35,8 +99,8 @@ func (r *imageRepository) GetByLabelDetected(labelDetected bool) ([]*db.Image, e
 	return images, err
 }
 
-func (r *imageRepository) UpdateTextDetected(id int64, textDetected bool) (int64, error) {
-	affected, err := db.Engine.ID(id).Cols("text_detected").Update(&db.Image{TextDetected: textDetected})
+func (r *imageRepository) UpdateTextDetected(session *xorm.Session, id int64, textDetected bool) (int64, error) {
+	affected, err := querier(session).ID(id).Cols("text_detected").Update(&db.Image{TextDetected: textDetected})
 	return affected, err
 }
 
@@ -45,3 +109,67 @@ func (r *imageRepository) GetByTextDetected(textDetected bool) ([]*db.Image, err
 	err := db.Engine.Where("text_detected = ?", textDetected).Find(&images)
 	return images, err
 }
+
+func (r *imageRepository) UpdateFullText(session *xorm.Session, id int64, fullText string) (int64, error) {
+	affected, err := querier(session).ID(id).Cols("full_text").Update(&db.Image{FullText: fullText})
+	return affected, err
+}
+
+func (r *imageRepository) GetByModerationStatus(status string) ([]*db.Image, error) {
+	var images []*db.Image
+	err := db.Engine.Where("moderation_status = ?", status).Find(&images)
+	return images, err
+}
+
+func (r *imageRepository) UpdateModerationStatus(id int64, status string, fromStatuses ...string) (int64, error) {
+	session := db.Engine.ID(id)
+	if len(fromStatuses) > 0 {
+		session = session.In("moderation_status", fromStatuses)
+	}
+	return session.Cols("moderation_status").Update(&db.Image{ModerationStatus: status})
+}
+
+func (r *imageRepository) List(filter *ImageFilter, beforeID int64, limit int) ([]*db.Image, error) {
+	var images []*db.Image
+	session := applyImageFilter(db.Engine.NewSession(), filter)
+	defer session.Close()
+	if beforeID > 0 {
+		session = session.And("id < ?", beforeID)
+	}
+	err := session.OrderBy("id DESC").Limit(limit).Find(&images)
+	return images, err
+}
+
+func (r *imageRepository) Count(filter *ImageFilter) (int64, error) {
+	session := applyImageFilter(db.Engine.NewSession(), filter)
+	defer session.Close()
+	return session.Count(new(db.Image))
+}
+
+func applyImageFilter(session *xorm.Session, filter *ImageFilter) *xorm.Session {
+	session = session.Where("1 = 1")
+	if filter == nil {
+		return session
+	}
+	for _, names := range filter.Labels {
+		where, args := labelCondition(names)
+		session = session.And(where, args...)
+	}
+	for _, keyword := range filter.Keywords {
+		where, args := keywordCondition(keyword)
+		session = session.And(where, args...)
+	}
+	if filter.Filename != "" {
+		session = session.And("origin_filename LIKE ?", "%"+filter.Filename+"%")
+	}
+	if filter.LabelDetected != nil {
+		session = session.And("label_detected = ?", *filter.LabelDetected)
+	}
+	if filter.TextDetected != nil {
+		session = session.And("text_detected = ?", *filter.TextDetected)
+	}
+	if len(filter.ModerationStatuses) > 0 {
+		session = session.In("moderation_status", filter.ModerationStatuses)
+	}
+	return session
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"blog-fanchiikawa-service/db"
+)
+
+type imageCaptionRepository struct{}
+
+func NewImageCaptionRepository() ImageCaptionRepository {
+	return &imageCaptionRepository{}
+}
+
+func (r *imageCaptionRepository) SaveGenerated(imageID int64, captions []*db.ImageCaption) error {
+	for _, caption := range captions {
+		caption.ImageID = imageID
+		existing, err := r.GetByImageAndLanguage(imageID, caption.Language)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			if _, err := db.Engine.Insert(caption); err != nil {
+				return err
+			}
+			continue
+		}
+		caption.ID = existing.ID
+		_, err = db.Engine.ID(existing.ID).Cols("caption", "description", "alt_text", "model", "generated_at").Update(caption)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *imageCaptionRepository) SaveOverrides(caption *db.ImageCaption) error {
+	if caption.ID == 0 {
+		_, err := db.Engine.Insert(caption)
+		return err
+	}
+	_, err := db.Engine.ID(caption.ID).Cols("caption_override", "description_override", "alt_text_override").Update(caption)
+	return err
+}
+
+func (r *imageCaptionRepository) GetByImageAndLanguage(imageID int64, language string) (*db.ImageCaption, error) {
+	var caption db.ImageCaption
+	has, err := db.Engine.Where("image_id = ? AND language = ?", imageID, language).Get(&caption)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, nil
+	}
+	return &caption, nil
+}
+
+func (r *imageCaptionRepository) GetByImageIDs(imageIDs []int64) (map[int64][]*db.ImageCaption, error) {
+	result := make(map[int64][]*db.ImageCaption)
+	if len(imageIDs) == 0 {
+		return result, nil
+	}
+
+	var captions []*db.ImageCaption
+	err := db.Engine.In("image_id", imageIDs).OrderBy("language ASC").Find(&captions)
+	if err != nil {
+		return nil, err
+	}
+	for _, caption := range captions {
+		result[caption.ImageID] = append(result[caption.ImageID], caption)
+	}
+	return result, nil
+}
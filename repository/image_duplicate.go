@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"blog-fanchiikawa-service/db"
+)
+
+func (r *imageRepository) GetByContentHash(contentHash string) (*db.Image, error) {
+	var image db.Image
+	has, err := db.Engine.Where("content_hash = ?", contentHash).Asc("id").Get(&image)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, nil
+	}
+	return &image, nil
+}
+
+func (r *imageRepository) GetByIDs(ids []int64) ([]*db.Image, error) {
+	var images []*db.Image
+	if len(ids) == 0 {
+		return images, nil
+	}
+	err := db.Engine.In("id", ids).Find(&images)
+	return images, err
+}
+
+func (r *imageRepository) GetPerceptualHashes() ([]*db.Image, error) {
+	var images []*db.Image
+	err := db.Engine.Cols("id", "perceptual_hash", "cluster_id", "moderation_status").
+		Where("perceptual_hash <> ''").
+		Find(&images)
+	return images, err
+}
+
+func (r *imageRepository) UpdateClusterID(ids []int64, clusterID int64) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	return db.Engine.In("id", ids).Cols("cluster_id").Update(&db.Image{ClusterID: clusterID})
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"blog-fanchiikawa-service/db"
+	"time"
+)
+
+type imageEmbeddingRepository struct{}
+
+func NewImageEmbeddingRepository() ImageEmbeddingRepository {
+	return &imageEmbeddingRepository{}
+}
+
+func (r *imageEmbeddingRepository) Save(embedding *db.ImageEmbedding) error {
+	existing, err := r.GetByImageAndModel(embedding.ImageID, embedding.Model)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		_, err = db.Engine.Insert(embedding)
+		return err
+	}
+	embedding.ID = existing.ID
+	_, err = db.Engine.ID(existing.ID).Cols("dimensions", "vector", "source_hash").Update(embedding)
+	return err
+}
+
+func (r *imageEmbeddingRepository) GetByImageAndModel(imageID int64, model string) (*db.ImageEmbedding, error) {
+	var embedding db.ImageEmbedding
+	has, err := db.Engine.Where("image_id = ? AND model = ?", imageID, model).Get(&embedding)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, nil
+	}
+	return &embedding, nil
+}
+
+func (r *imageEmbeddingRepository) GetUpdatedSince(model string, since time.Time) ([]*db.ImageEmbedding, error) {
+	var embeddings []*db.ImageEmbedding
+	query := db.Engine.Where("model = ?", model)
+	if !since.IsZero() {
+		query = query.And("updated_at >= ?", since)
+	}
+	err := query.OrderBy("updated_at ASC, id ASC").Find(&embeddings)
+	return embeddings, err
+}
@@ -1,6 +1,9 @@
 package repository
 
-import "blog-fanchiikawa-service/db"
+import (
+	"blog-fanchiikawa-service/db"
+	"xorm.io/xorm"
+)
 
 type imageLabelRepository struct{}
 
@@ -8,16 +11,39 @@ func NewImageLabelRepository() ImageLabelRepository {
 	return &imageLabelRepository{}
 }
 
-func (r *imageLabelRepository) Create(imageLabel *db.ImageLabel) error {
-	_, err := db.Engine.Insert(imageLabel)
+func (r *imageLabelRepository) Create(session *xorm.Session, imageLabel *db.ImageLabel) error {
+	_, err := querier(session).Insert(imageLabel)
 	return err
 }
 
-func (r *imageLabelRepository) GetByImageAndLabel(imageID, labelID int64) (*db.ImageLabel, error) {
+func (r *imageLabelRepository) GetByImageAndLabel(session *xorm.Session, imageID, labelID int64) (*db.ImageLabel, error) {
 	var imageLabel db.ImageLabel
-	has, err := db.Engine.Where("image_id = ? AND label_id = ?", imageID, labelID).Get(&imageLabel)
+	has, err := querier(session).Where("image_id = ? AND label_id = ?", imageID, labelID).Get(&imageLabel)
 	if !has {
 		return nil, nil
 	}
 	return &imageLabel, err
 }
+
+func (r *imageLabelRepository) UpdateConfidence(session *xorm.Session, id int64, confidence float64) (int64, error) {
+	affected, err := querier(session).ID(id).Cols("confidence").Update(&db.ImageLabel{Confidence: confidence})
+	return affected, err
+}
+
+func (r *imageLabelRepository) GetByImageIDs(imageIDs []int64) (map[int64][]*db.ImageLabel, error) {
+	result := make(map[int64][]*db.ImageLabel)
+	if len(imageIDs) == 0 {
+		return result, nil
+	}
+
+	var imageLabels []*db.ImageLabel
+	err := db.Engine.In("image_id", imageIDs).OrderBy("confidence DESC").Find(&imageLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, imageLabel := range imageLabels {
+		result[imageLabel.ImageID] = append(result[imageLabel.ImageID], imageLabel)
+	}
+	return result, nil
+}
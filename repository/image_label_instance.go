@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"blog-fanchiikawa-service/db"
+	"xorm.io/xorm"
+)
+
+type imageLabelInstanceRepository struct{}
+
+func NewImageLabelInstanceRepository() ImageLabelInstanceRepository {
+	return &imageLabelInstanceRepository{}
+}
+
+func (r *imageLabelInstanceRepository) Create(session *xorm.Session, instance *db.ImageLabelInstance) error {
+	_, err := querier(session).Insert(instance)
+	return err
+}
+
+func (r *imageLabelInstanceRepository) DeleteByImageAndLabel(session *xorm.Session, imageID, labelID int64) error {
+	_, err := querier(session).Where("image_id = ? AND label_id = ?", imageID, labelID).Delete(&db.ImageLabelInstance{})
+	return err
+}
+
+func (r *imageLabelInstanceRepository) GetByImageIDs(imageIDs []int64) (map[int64][]*db.ImageLabelInstance, error) {
+	result := make(map[int64][]*db.ImageLabelInstance)
+	if len(imageIDs) == 0 {
+		return result, nil
+	}
+
+	var instances []*db.ImageLabelInstance
+	err := db.Engine.In("image_id", imageIDs).OrderBy("confidence DESC").Find(&instances)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, instance := range instances {
+		result[instance.ImageID] = append(result[instance.ImageID], instance)
+	}
+	return result, nil
+}
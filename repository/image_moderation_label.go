@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"blog-fanchiikawa-service/db"
+)
+
+type imageModerationLabelRepository struct{}
+
+func NewImageModerationLabelRepository() ImageModerationLabelRepository {
+	return &imageModerationLabelRepository{}
+}
+
+func (r *imageModerationLabelRepository) ReplaceForImage(imageID int64, labels []*db.ImageModerationLabel) error {
+	if _, err := db.Engine.Where("image_id = ?", imageID).Delete(&db.ImageModerationLabel{}); err != nil {
+		return err
+	}
+	if len(labels) == 0 {
+		return nil
+	}
+	_, err := db.Engine.Insert(labels)
+	return err
+}
+
+func (r *imageModerationLabelRepository) GetByImageIDs(imageIDs []int64) (map[int64][]*db.ImageModerationLabel, error) {
+	result := make(map[int64][]*db.ImageModerationLabel)
+	if len(imageIDs) == 0 {
+		return result, nil
+	}
+
+	var labels []*db.ImageModerationLabel
+	err := db.Engine.In("image_id", imageIDs).OrderBy("confidence DESC").Find(&labels)
+	if err != nil {
+		return nil, err
+	}
+	for _, label := range labels {
+		result[label.ImageID] = append(result[label.ImageID], label)
+	}
+	return result, nil
+}
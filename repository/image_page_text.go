@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"blog-fanchiikawa-service/db"
+	"xorm.io/xorm"
+)
+
+// Page texts can be large, so fewer rows go into each insert than for text blocks
+const pageTextInsertBatch = 50
+
+type imagePageTextRepository struct{}
+
+func NewImagePageTextRepository() ImagePageTextRepository {
+	return &imagePageTextRepository{}
+}
+
+func (r *imagePageTextRepository) ReplaceForImage(session *xorm.Session, imageID int64, pages []*db.ImagePageText) error {
+	if _, err := querier(session).Where("image_id = ?", imageID).Delete(&db.ImagePageText{}); err != nil {
+		return err
+	}
+	for start := 0; start < len(pages); start += pageTextInsertBatch {
+		end := start + pageTextInsertBatch
+		if end > len(pages) {
+			end = len(pages)
+		}
+		if _, err := querier(session).Insert(pages[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *imagePageTextRepository) GetByImageID(imageID int64) ([]*db.ImagePageText, error) {
+	var pages []*db.ImagePageText
+	err := db.Engine.Where("image_id = ?", imageID).OrderBy("page ASC").Find(&pages)
+	return pages, err
+}
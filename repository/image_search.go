@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"blog-fanchiikawa-service/db"
+	"blog-fanchiikawa-service/search"
+	"fmt"
+	"strings"
+)
+
+// FacetCount is the number of matching images carrying a label or keyword
+type FacetCount struct {
+	Value string `xorm:"'value'"`
+	Count int64  `xorm:"'count'"`
+}
+
+func (r *imageRepository) Search(expr search.Expr, beforeID int64, limit int) ([]*db.Image, error) {
+	where, args, err := compileSearch(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	var images []*db.Image
+	session := db.Engine.Where(where, args...)
+	if beforeID > 0 {
+		session = session.And("id < ?", beforeID)
+	}
+	err = session.OrderBy("id DESC").Limit(limit).Find(&images)
+	return images, err
+}
+
+func (r *imageRepository) CountSearch(expr search.Expr) (int64, error) {
+	where, args, err := compileSearch(expr)
+	if err != nil {
+		return 0, err
+	}
+	return db.Engine.Where(where, args...).Count(new(db.Image))
+}
+
+func (r *imageRepository) LabelFacets(expr search.Expr, limit int) ([]*FacetCount, error) {
+	return facetCounts(expr, limit,
+		"SELECT l.name AS value, COUNT(*) AS count FROM image_label il INNER JOIN label l ON l.id = il.label_id AND l.hidden = 0 "+
+			"WHERE il.image_id IN (SELECT id FROM image WHERE %s) GROUP BY l.name ORDER BY count DESC, l.name ASC LIMIT ?")
+}
+
+func (r *imageRepository) KeywordFacets(expr search.Expr, limit int) ([]*FacetCount, error) {
+	return facetCounts(expr, limit,
+		"SELECT tk.keyword AS value, COUNT(*) AS count FROM image_text_keyword itk INNER JOIN text_keyword tk ON tk.id = itk.text_keyword_id AND tk.hidden = 0 "+
+			"WHERE itk.image_id IN (SELECT id FROM image WHERE %s) GROUP BY tk.keyword ORDER BY count DESC, tk.keyword ASC LIMIT ?")
+}
+
+func facetCounts(expr search.Expr, limit int, query string) ([]*FacetCount, error) {
+	where, args, err := compileSearch(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	var facets []*FacetCount
+	args = append(args, limit)
+	err = db.Engine.SQL(fmt.Sprintf(query, where), args...).Find(&facets)
+	return facets, err
+}
+
+// compileSearch translates a search expression into a WHERE clause over the image table,
+// restricted to images that passed moderation or were approved
+func compileSearch(expr search.Expr) (string, []interface{}, error) {
+	where, args, err := compileExpr(expr)
+	if err != nil {
+		return "", nil, err
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(db.ModerationVisibleStatuses)), ", ")
+	for _, status := range db.ModerationVisibleStatuses {
+		args = append(args, status)
+	}
+	return "(" + where + ") AND moderation_status IN (" + placeholders + ")", args, nil
+}
+
+func compileExpr(expr search.Expr) (string, []interface{}, error) {
+	if expr == nil {
+		return "1 = 1", nil, nil
+	}
+
+	switch e := expr.(type) {
+	case *search.Term:
+		switch e.Field {
+		case search.FieldLabel:
+			where, args := labelCondition([]string{e.Value})
+			return where, args, nil
+		case search.FieldKeyword:
+			where, args := keywordCondition(e.Value)
+			return where, args, nil
+		case search.FieldText:
+			return "full_text LIKE ?", []interface{}{"%" + escapeLike(e.Value) + "%"}, nil
+		case search.FieldAfter:
+			return "created_at >= ?", []interface{}{e.Time}, nil
+		case search.FieldBefore:
+			return "created_at < ?", []interface{}{e.Time}, nil
+		}
+		return "", nil, fmt.Errorf("unsupported search field %q", e.Field)
+
+	case search.And:
+		return compileGroup(e, " AND ")
+
+	case search.Or:
+		return compileGroup(e, " OR ")
+
+	case *search.Not:
+		where, args, err := compileExpr(e.Expr)
+		if err != nil {
+			return "", nil, err
+		}
+		return "NOT (" + where + ")", args, nil
+	}
+
+	return "", nil, fmt.Errorf("unsupported search expression %T", expr)
+}
+
+// labelCondition matches images carrying any of the named labels that is not hidden
+func labelCondition(names []string) (string, []interface{}) {
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", ")
+	args := make([]interface{}, 0, len(names)+1)
+	for _, name := range names {
+		args = append(args, name)
+	}
+	args = append(args, false)
+	return "id IN (SELECT il.image_id FROM image_label il INNER JOIN label l ON l.id = il.label_id WHERE l.name IN (" + placeholders + ") AND l.hidden = ?)", args
+}
+
+// keywordCondition matches images carrying the keyword unless it is hidden
+func keywordCondition(keyword string) (string, []interface{}) {
+	return "id IN (SELECT itk.image_id FROM image_text_keyword itk INNER JOIN text_keyword tk ON tk.id = itk.text_keyword_id WHERE tk.keyword = ? AND tk.hidden = ?)",
+		[]interface{}{keyword, false}
+}
+
+func compileGroup(exprs []search.Expr, sep string) (string, []interface{}, error) {
+	clauses := make([]string, 0, len(exprs))
+	var args []interface{}
+	for _, e := range exprs {
+		where, operandArgs, err := compileExpr(e)
+		if err != nil {
+			return "", nil, err
+		}
+		clauses = append(clauses, "("+where+")")
+		args = append(args, operandArgs...)
+	}
+	return strings.Join(clauses, sep), args, nil
+}
+
+// escapeLike escapes the LIKE wildcards in a user supplied phrase
+func escapeLike(value string) string {
+	return strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(value)
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"blog-fanchiikawa-service/db"
+	"xorm.io/xorm"
+)
+
+const textBlockInsertBatch = 500
+
+type imageTextBlockRepository struct{}
+
+func NewImageTextBlockRepository() ImageTextBlockRepository {
+	return &imageTextBlockRepository{}
+}
+
+func (r *imageTextBlockRepository) ReplaceForImage(session *xorm.Session, imageID int64, blocks []*db.ImageTextBlock) error {
+	if _, err := querier(session).Where("image_id = ?", imageID).Delete(&db.ImageTextBlock{}); err != nil {
+		return err
+	}
+
+	// Insert in batches to stay below the placeholder limit of a single statement
+	for start := 0; start < len(blocks); start += textBlockInsertBatch {
+		end := start + textBlockInsertBatch
+		if end > len(blocks) {
+			end = len(blocks)
+		}
+		if _, err := querier(session).Insert(blocks[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *imageTextBlockRepository) GetByImageID(imageID int64, page int, blockType string) ([]*db.ImageTextBlock, error) {
+	var blocks []*db.ImageTextBlock
+	session := db.Engine.Where("image_id = ?", imageID)
+	if page > 0 {
+		session = session.And("page = ?", page)
+	}
+	if blockType != "" {
+		session = session.And("block_type = ?", blockType)
+	}
+	err := session.OrderBy("page ASC, block_index ASC").Find(&blocks)
+	return blocks, err
+}
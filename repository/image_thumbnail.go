@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"blog-fanchiikawa-service/db"
+)
+
+type imageThumbnailRepository struct{}
+
+func NewImageThumbnailRepository() ImageThumbnailRepository {
+	return &imageThumbnailRepository{}
+}
+
+func (r *imageThumbnailRepository) ReplaceForImage(imageID int64, thumbnails []*db.ImageThumbnail) error {
+	if _, err := db.Engine.Where("image_id = ?", imageID).Delete(&db.ImageThumbnail{}); err != nil {
+		return err
+	}
+	if len(thumbnails) == 0 {
+		return nil
+	}
+	for _, thumbnail := range thumbnails {
+		thumbnail.ImageID = imageID
+	}
+	_, err := db.Engine.Insert(thumbnails)
+	return err
+}
+
+func (r *imageThumbnailRepository) GetByImageIDs(imageIDs []int64) (map[int64][]*db.ImageThumbnail, error) {
+	result := make(map[int64][]*db.ImageThumbnail)
+	if len(imageIDs) == 0 {
+		return result, nil
+	}
+
+	var thumbnails []*db.ImageThumbnail
+	err := db.Engine.In("image_id", imageIDs).OrderBy("size ASC").Find(&thumbnails)
+	if err != nil {
+		return nil, err
+	}
+	for _, thumbnail := range thumbnails {
+		result[thumbnail.ImageID] = append(result[thumbnail.ImageID], thumbnail)
+	}
+	return result, nil
+}
@@ -1,6 +1,11 @@
 package repository
 
-import "blog-fanchiikawa-service/db"
+import (
+	"blog-fanchiikawa-service/db"
+	"blog-fanchiikawa-service/search"
+	"time"
+	"xorm.io/xorm"
+)
 
 // UserRepository defines the interface for user data access
 type UserRepository interface {
@@ -11,60 +16,318 @@ type UserRepository interface {
 	GetByID(id int64) (*db.User, error)
 
 	// Create creates a new user
-	Create(user *db.User) error
+	Create(session *xorm.Session, user *db.User) error
 
 	// List retrieves users with pagination
 	List(limit int, offset int) ([]*db.User, error)
+
+	// UpdatePassword replaces a user's password hash
+	UpdatePassword(id int64, passwordHash string) error
 }
 
 // UserDeviceRepository defines the interface for user device data access
 type UserDeviceRepository interface {
 	// Create creates a new user device
-	Create(device *db.UserDevice) error
+	Create(session *xorm.Session, device *db.UserDevice) error
 
 	// GetByUserID retrieves devices for a user
 	GetByUserID(userID int64) ([]*db.UserDevice, error)
 }
 
+// UserSessionRepository defines the interface for session token data access
+type UserSessionRepository interface {
+	// Create creates a new session
+	Create(session *db.UserSession) error
+
+	// GetByToken retrieves a session by its token
+	GetByToken(token string) (*db.UserSession, error)
+
+	// Revoke marks the session with the given token as revoked
+	Revoke(token string) (int64, error)
+}
+
 type ImageRepository interface {
 	Create(image *db.Image) error
 
 	GetByID(id int64) (*db.Image, error)
 
-	UpdateLabelDetected(id int64, labelDetected bool) (int64, error)
+	// GetByObjectKey retrieves the image stored at the S3 location, or nil
+	GetByObjectKey(bucket, objectKey string) (*db.Image, error)
+
+	// GetExistingObjectKeys returns which of the keys in the bucket already belong to an image
+	GetExistingObjectKeys(bucket string, objectKeys []string) (map[string]bool, error)
+
+	// GetUncaptioned retrieves the images with thumbnails but no generated captions
+	GetUncaptioned() ([]*db.Image, error)
+
+	// GetUnembedded retrieves the images without an embedding from the model
+	GetUnembedded(model string) ([]*db.Image, error)
+
+	// UpdateVideoMetadata records the duration and frame size Rekognition Video reported for a video
+	UpdateVideoMetadata(id int64, durationMs int64, width, height int) (int64, error)
+
+	UpdateLabelDetected(session *xorm.Session, id int64, labelDetected bool) (int64, error)
 
 	GetByLabelDetected(labelDetected bool) ([]*db.Image, error)
 
-	UpdateTextDetected(id int64, textDetected bool) (int64, error)
+	UpdateTextDetected(session *xorm.Session, id int64, textDetected bool) (int64, error)
 
 	GetByTextDetected(textDetected bool) ([]*db.Image, error)
+
+	GetByModerationStatus(status string) ([]*db.Image, error)
+
+	// GetByContentHash retrieves the oldest image with the given SHA-256, or nil
+	GetByContentHash(contentHash string) (*db.Image, error)
+
+	GetByIDs(ids []int64) ([]*db.Image, error)
+
+	// GetPerceptualHashes retrieves the ID, perceptual hash, cluster and moderation status of every image that has a perceptual hash
+	GetPerceptualHashes() ([]*db.Image, error)
+
+	UpdateClusterID(ids []int64, clusterID int64) (int64, error)
+
+	// UpdateModerationStatus sets the status, only while the current one is in fromStatuses when any are given
+	UpdateModerationStatus(id int64, status string, fromStatuses ...string) (int64, error)
+
+	UpdateFullText(session *xorm.Session, id int64, fullText string) (int64, error)
+
+	// List retrieves images matching filter with an ID below beforeID (0 for the first page), newest first
+	List(filter *ImageFilter, beforeID int64, limit int) ([]*db.Image, error)
+
+	// Count counts images matching filter
+	Count(filter *ImageFilter) (int64, error)
+
+	// Search retrieves images matching a boolean search expression, newest first; a nil expression matches all
+	Search(expr search.Expr, beforeID int64, limit int) ([]*db.Image, error)
+
+	// CountSearch counts images matching a search expression
+	CountSearch(expr search.Expr) (int64, error)
+
+	// LabelFacets counts matching images per label, most frequent first
+	LabelFacets(expr search.Expr, limit int) ([]*FacetCount, error)
+
+	// KeywordFacets counts matching images per text keyword, most frequent first
+	KeywordFacets(expr search.Expr, limit int) ([]*FacetCount, error)
 }
 
 type LabelRepository interface {
-	Create(label *db.Label) error
+	Create(session *xorm.Session, label *db.Label) error
+
+	GetByName(session *xorm.Session, labelName string) (*db.Label, error)
+
+	GetByIDs(ids []int64) ([]*db.Label, error)
+
+	// List retrieves every label ordered by name
+	List() ([]*db.Label, error)
+
+	// GetByImageIDs retrieves the labels of each image, keyed by image ID
+	GetByImageIDs(imageIDs []int64) (map[int64][]*db.Label, error)
+
+	UpdateCategory(session *xorm.Session, id int64, category string) (int64, error)
+
+	// GetParentsByLabelIDs retrieves the parent labels of each label, keyed by label ID, leaving out removed links
+	GetParentsByLabelIDs(labelIDs []int64) (map[int64][]*db.Label, error)
+
+	GetByID(id int64) (*db.Label, error)
+
+	UpdateHidden(id int64, hidden bool) (int64, error)
+
+	// GetByPattern retrieves the labels whose names match a blocklist pattern
+	GetByPattern(pattern string) ([]*db.Label, error)
+
+	// Merge moves the images, instances, video segments, hierarchy links and aliases of the source label to the
+	// target, deletes the source and adds alias as an alias of the target, in one transaction
+	Merge(sourceID, targetID int64, alias string) error
+
+	// Delete removes the labels together with their image links, instances, video segments, hierarchy links and aliases
+	Delete(ids []int64) error
+}
+
+type LabelParentRepository interface {
+	Create(session *xorm.Session, labelParent *db.LabelParent) error
+
+	// GetByLabelAndParent retrieves the link between two labels, including a removed one
+	GetByLabelAndParent(session *xorm.Session, labelID, parentLabelID int64) (*db.LabelParent, error)
+
+	UpdateRemoved(id int64, removed bool) (int64, error)
+
+	// List retrieves every link that has not been removed
+	List() ([]*db.LabelParent, error)
+}
+
+type LabelAliasRepository interface {
+	Create(alias *db.LabelAlias) error
+
+	GetByAlias(alias string) (*db.LabelAlias, error)
+
+	Delete(alias string) (int64, error)
+
+	// List retrieves every alias ordered by alias
+	List() ([]*db.LabelAlias, error)
+
+	// GetByLabelIDs retrieves the aliases of each label ordered by alias, keyed by label ID
+	GetByLabelIDs(labelIDs []int64) (map[int64][]*db.LabelAlias, error)
+}
+
+type TaxonomyBlockRepository interface {
+	Create(block *db.TaxonomyBlock) error
+
+	GetByKindAndPattern(kind, pattern string) (*db.TaxonomyBlock, error)
+
+	Delete(id int64) (int64, error)
 
-	GetByName(labelName string) (*db.Label, error)
+	// List retrieves the blocklist entries of a kind, or of every kind when kind is empty
+	List(kind string) ([]*db.TaxonomyBlock, error)
 }
 
 type ImageLabelRepository interface {
-	Create(imageLabel *db.ImageLabel) error
-	GetByImageAndLabel(imageID, labelID int64) (*db.ImageLabel, error)
+	Create(session *xorm.Session, imageLabel *db.ImageLabel) error
+	GetByImageAndLabel(session *xorm.Session, imageID, labelID int64) (*db.ImageLabel, error)
+	UpdateConfidence(session *xorm.Session, id int64, confidence float64) (int64, error)
+
+	// GetByImageIDs retrieves the label links of each image, keyed by image ID
+	GetByImageIDs(imageIDs []int64) (map[int64][]*db.ImageLabel, error)
+}
+
+type ImageLabelInstanceRepository interface {
+	Create(session *xorm.Session, instance *db.ImageLabelInstance) error
+
+	DeleteByImageAndLabel(session *xorm.Session, imageID, labelID int64) error
+
+	// GetByImageIDs retrieves the label instances of each image, keyed by image ID
+	GetByImageIDs(imageIDs []int64) (map[int64][]*db.ImageLabelInstance, error)
 }
 
 type TextKeywordRepository interface {
-	Create(textKeyword *db.TextKeyword) error
+	Create(session *xorm.Session, textKeyword *db.TextKeyword) error
+
+	GetByKeyword(session *xorm.Session, keyword string) (*db.TextKeyword, error)
 
-	GetByKeyword(keyword string) (*db.TextKeyword, error)
+	// GetByImageIDs retrieves the text keywords of each image, keyed by image ID
+	GetByImageIDs(imageIDs []int64) (map[int64][]*db.TextKeyword, error)
+
+	UpdateHidden(id int64, hidden bool) (int64, error)
+
+	// DeleteByPattern removes the keywords matching a blocklist pattern and their image links
+	DeleteByPattern(pattern string) (int64, error)
 }
 
 type ImageTextKeywordRepository interface {
-	Create(imageTextKeyword *db.ImageTextKeyword) error
+	Create(session *xorm.Session, imageTextKeyword *db.ImageTextKeyword) error
+
+	GetByImageAndKeyword(session *xorm.Session, imageID, textKeywordID int64) (*db.ImageTextKeyword, error)
+}
+
+type ImageTextBlockRepository interface {
+	// ReplaceForImage deletes the image's blocks and inserts the given ones
+	ReplaceForImage(session *xorm.Session, imageID int64, blocks []*db.ImageTextBlock) error
+
+	// GetByImageID retrieves the image's blocks in reading order; page 0 means every page and blockType "" every type
+	GetByImageID(imageID int64, page int, blockType string) ([]*db.ImageTextBlock, error)
+}
+
+type ImageModerationLabelRepository interface {
+	// ReplaceForImage deletes the image's moderation labels and inserts the given ones
+	ReplaceForImage(imageID int64, labels []*db.ImageModerationLabel) error
+
+	// GetByImageIDs retrieves the moderation labels of each image by descending confidence, keyed by image ID
+	GetByImageIDs(imageIDs []int64) (map[int64][]*db.ImageModerationLabel, error)
+}
+
+type ImageThumbnailRepository interface {
+	// ReplaceForImage deletes the image's thumbnails and inserts the given ones
+	ReplaceForImage(imageID int64, thumbnails []*db.ImageThumbnail) error
+
+	// GetByImageIDs retrieves the thumbnails of each image from smallest to largest, keyed by image ID
+	GetByImageIDs(imageIDs []int64) (map[int64][]*db.ImageThumbnail, error)
+}
+
+type ImageCaptionRepository interface {
+	// SaveGenerated stores generated captions by language, keeping the overrides of existing ones
+	SaveGenerated(imageID int64, captions []*db.ImageCaption) error
+
+	// SaveOverrides inserts a new caption or updates only the override columns of an existing one
+	SaveOverrides(caption *db.ImageCaption) error
+
+	// GetByImageAndLanguage retrieves the image's caption in the language, or nil
+	GetByImageAndLanguage(imageID int64, language string) (*db.ImageCaption, error)
+
+	// GetByImageIDs retrieves the captions of each image ordered by language, keyed by image ID
+	GetByImageIDs(imageIDs []int64) (map[int64][]*db.ImageCaption, error)
+}
+
+type ImageEmbeddingRepository interface {
+	// Save inserts the image's embedding for its model or replaces the stored vector
+	Save(embedding *db.ImageEmbedding) error
+
+	GetByImageAndModel(imageID int64, model string) (*db.ImageEmbedding, error)
+
+	// GetUpdatedSince retrieves the model's embeddings saved at or after since, oldest first; a zero since returns all of them
+	GetUpdatedSince(model string, since time.Time) ([]*db.ImageEmbedding, error)
+}
+
+type VideoSegmentRepository interface {
+	// ReplaceLabelSegments deletes the video's label segments and inserts the given ones
+	ReplaceLabelSegments(imageID int64, segments []*db.VideoLabelSegment) error
+
+	// ReplaceTextSegments deletes the video's text segments and inserts the given ones
+	ReplaceTextSegments(imageID int64, segments []*db.VideoTextSegment) error
+
+	// GetLabelSegments retrieves the video's label segments of at least minConfidence in time order
+	GetLabelSegments(imageID int64, minConfidence float64) ([]*db.VideoLabelSegment, error)
+
+	// GetTextSegments retrieves the video's text segments of at least minConfidence in time order
+	GetTextSegments(imageID int64, minConfidence float64) ([]*db.VideoTextSegment, error)
+}
+
+type ImagePageTextRepository interface {
+	// ReplaceForImage deletes the image's page texts and inserts the given ones
+	ReplaceForImage(session *xorm.Session, imageID int64, pages []*db.ImagePageText) error
+
+	// GetByImageID retrieves the image's page texts in page order
+	GetByImageID(imageID int64) ([]*db.ImagePageText, error)
+}
+
+type DocumentAnalysisRepository interface {
+	// ReplaceForImage deletes the image's form fields and tables and inserts the given ones; cells[i] belongs to tables[i]
+	ReplaceForImage(session *xorm.Session, imageID int64, fields []*db.DocumentField, tables []*db.DocumentTable, cells [][]*db.DocumentTableCell) error
+
+	GetFieldsByImageID(imageID int64) ([]*db.DocumentField, error)
+
+	GetTablesByImageID(imageID int64) ([]*db.DocumentTable, error)
+
+	GetTableByID(id int64) (*db.DocumentTable, error)
+
+	// GetCellsByTableIDs retrieves the cells of each table in row-major order, keyed by table ID
+	GetCellsByTableIDs(tableIDs []int64) (map[int64][]*db.DocumentTableCell, error)
+}
+
+type MediaJobRepository interface {
+	Create(job *db.MediaJob) error
+
+	GetByID(id int64) (*db.MediaJob, error)
+
+	GetByImageAndType(imageID int64, jobType string) (*db.MediaJob, error)
+
+	// GetDue retrieves pending jobs whose next run time has passed, oldest first
+	GetDue(now time.Time, limit int) ([]*db.MediaJob, error)
+
+	// List retrieves jobs newest first; status "" and imageID 0 match every job
+	List(status string, imageID int64, limit int) ([]*db.MediaJob, error)
+
+	// Transition writes the job's status, attempts, last error and its category, next run time, external job ID
+	// and waiting time
+	// only while the stored status is one of fromStatuses, so concurrent workers cannot both claim a job
+	Transition(job *db.MediaJob, fromStatuses ...string) (int64, error)
 
-	GetByImageAndKeyword(imageID, textKeywordID int64) (*db.ImageTextKeyword, error)
+	// ResetStale returns jobs left running since before the given time to pending
+	ResetStale(before time.Time) (int64, error)
 }
 
 // TransactionManager defines the interface for transaction management
 type TransactionManager interface {
-	// WithTransaction executes a function within a database transaction
-	WithTransaction(fn func() error) error
+	// WithTransaction executes a function within a database transaction, committing it when fn returns nil.
+	// Repository methods that take a session run their statements in the transaction when given fn's session,
+	// and straight on the engine when given nil.
+	WithTransaction(fn func(session *xorm.Session) error) error
 }
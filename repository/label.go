@@ -2,6 +2,7 @@ package repository
 
 import (
 	"blog-fanchiikawa-service/db"
+	"xorm.io/xorm"
 )
 
 type labelRepository struct{}
@@ -10,14 +11,14 @@ func NewLabelRepository() LabelRepository {
 	return &labelRepository{}
 }
 
-func (r *labelRepository) Create(label *db.Label) error {
-	_, err := db.Engine.Insert(label)
+func (r *labelRepository) Create(session *xorm.Session, label *db.Label) error {
+	_, err := querier(session).Insert(label)
 	return err
 }
 
-func (r *labelRepository) GetByName(labelName string) (*db.Label, error) {
+func (r *labelRepository) GetByName(session *xorm.Session, labelName string) (*db.Label, error) {
 	var result db.Label
-	has, err := db.Engine.Where("name = ?", labelName).Get(&result)
+	has, err := querier(session).Where("name = ?", labelName).Get(&result)
 	if err != nil {
 		return nil, err
 	}
@@ -26,3 +27,82 @@ func (r *labelRepository) GetByName(labelName string) (*db.Label, error) {
 	}
 	return &result, err
 }
+
+func (r *labelRepository) GetByIDs(ids []int64) ([]*db.Label, error) {
+	var labels []*db.Label
+	if len(ids) == 0 {
+		return labels, nil
+	}
+	err := db.Engine.In("id", ids).Find(&labels)
+	return labels, err
+}
+
+func (r *labelRepository) List() ([]*db.Label, error) {
+	var labels []*db.Label
+	err := db.Engine.OrderBy("name ASC").Find(&labels)
+	return labels, err
+}
+
+type imageLabelRow struct {
+	ImageID  int64 `xorm:"'image_id'"`
+	db.Label `xorm:"extends"`
+}
+
+func (r *labelRepository) GetByImageIDs(imageIDs []int64) (map[int64][]*db.Label, error) {
+	result := make(map[int64][]*db.Label)
+	if len(imageIDs) == 0 {
+		return result, nil
+	}
+
+	var rows []*imageLabelRow
+	err := db.Engine.Table("image_label").
+		Select("image_label.image_id, label.*").
+		Join("INNER", "label", "label.id = image_label.label_id").
+		In("image_label.image_id", imageIDs).
+		OrderBy("label.name ASC").
+		Find(&rows)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		label := row.Label
+		result[row.ImageID] = append(result[row.ImageID], &label)
+	}
+	return result, nil
+}
+
+func (r *labelRepository) UpdateCategory(session *xorm.Session, id int64, category string) (int64, error) {
+	affected, err := querier(session).ID(id).Cols("category").Update(&db.Label{Category: category})
+	return affected, err
+}
+
+type labelParentRow struct {
+	ChildID  int64 `xorm:"'child_id'"`
+	db.Label `xorm:"extends"`
+}
+
+func (r *labelRepository) GetParentsByLabelIDs(labelIDs []int64) (map[int64][]*db.Label, error) {
+	result := make(map[int64][]*db.Label)
+	if len(labelIDs) == 0 {
+		return result, nil
+	}
+
+	var rows []*labelParentRow
+	err := db.Engine.Table("label_parent").
+		Select("label_parent.label_id AS child_id, label.*").
+		Join("INNER", "label", "label.id = label_parent.parent_label_id").
+		In("label_parent.label_id", labelIDs).
+		And("label_parent.removed = ?", false).
+		OrderBy("label.name ASC").
+		Find(&rows)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		label := row.Label
+		result[row.ChildID] = append(result[row.ChildID], &label)
+	}
+	return result, nil
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"blog-fanchiikawa-service/db"
+)
+
+type labelAliasRepository struct{}
+
+func NewLabelAliasRepository() LabelAliasRepository {
+	return &labelAliasRepository{}
+}
+
+func (r *labelAliasRepository) Create(alias *db.LabelAlias) error {
+	_, err := db.Engine.Insert(alias)
+	return err
+}
+
+func (r *labelAliasRepository) GetByAlias(alias string) (*db.LabelAlias, error) {
+	var result db.LabelAlias
+	has, err := db.Engine.Where("alias = ?", alias).Get(&result)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, nil
+	}
+	return &result, nil
+}
+
+func (r *labelAliasRepository) Delete(alias string) (int64, error) {
+	return db.Engine.Where("alias = ?", alias).Delete(&db.LabelAlias{})
+}
+
+func (r *labelAliasRepository) List() ([]*db.LabelAlias, error) {
+	var aliases []*db.LabelAlias
+	err := db.Engine.OrderBy("alias ASC").Find(&aliases)
+	return aliases, err
+}
+
+func (r *labelAliasRepository) GetByLabelIDs(labelIDs []int64) (map[int64][]*db.LabelAlias, error) {
+	result := make(map[int64][]*db.LabelAlias)
+	if len(labelIDs) == 0 {
+		return result, nil
+	}
+
+	var aliases []*db.LabelAlias
+	if err := db.Engine.In("label_id", labelIDs).OrderBy("alias ASC").Find(&aliases); err != nil {
+		return nil, err
+	}
+	for _, alias := range aliases {
+		result[alias.LabelID] = append(result[alias.LabelID], alias)
+	}
+	return result, nil
+}
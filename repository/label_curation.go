@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"blog-fanchiikawa-service/db"
+	"strings"
+)
+
+// likePattern turns a blocklist pattern, where "*" is any run of characters and "?" one character, into a LIKE pattern
+func likePattern(pattern string) string {
+	return strings.NewReplacer("*", "%", "?", "_").Replace(escapeLike(pattern))
+}
+
+func (r *labelRepository) GetByID(id int64) (*db.Label, error) {
+	var label db.Label
+	has, err := db.Engine.ID(id).Get(&label)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, nil
+	}
+	return &label, nil
+}
+
+func (r *labelRepository) UpdateHidden(id int64, hidden bool) (int64, error) {
+	return db.Engine.ID(id).Cols("hidden").Update(&db.Label{Hidden: hidden})
+}
+
+func (r *labelRepository) GetByPattern(pattern string) ([]*db.Label, error) {
+	var labels []*db.Label
+	err := db.Engine.Where("name LIKE ?", likePattern(pattern)).OrderBy("name ASC").Find(&labels)
+	return labels, err
+}
+
+func (r *labelRepository) Merge(sourceID, targetID int64, alias string) error {
+	session := db.Engine.NewSession()
+	defer session.Close()
+	if err := session.Begin(); err != nil {
+		return err
+	}
+
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		// Images carrying both labels keep the higher confidence on the target
+		{"UPDATE image_label t INNER JOIN image_label s ON s.image_id = t.image_id AND s.label_id = ? " +
+			"SET t.confidence = GREATEST(t.confidence, s.confidence) WHERE t.label_id = ?", []interface{}{sourceID, targetID}},
+		{"DELETE FROM image_label WHERE label_id = ? AND image_id IN " +
+			"(SELECT image_id FROM (SELECT image_id FROM image_label WHERE label_id = ?) AS target)", []interface{}{sourceID, targetID}},
+		{"UPDATE image_label SET label_id = ? WHERE label_id = ?", []interface{}{targetID, sourceID}},
+		{"UPDATE image_label_instance SET label_id = ? WHERE label_id = ?", []interface{}{targetID, sourceID}},
+		{"UPDATE video_label_segment SET label_id = ? WHERE label_id = ?", []interface{}{targetID, sourceID}},
+
+		// Parent links move to the target unless the target already has them or they would link it to itself
+		{"DELETE FROM label_parent WHERE label_id = ? AND (parent_label_id = ? OR parent_label_id IN " +
+			"(SELECT parent_label_id FROM (SELECT parent_label_id FROM label_parent WHERE label_id = ?) AS target))",
+			[]interface{}{sourceID, targetID, targetID}},
+		{"UPDATE label_parent SET label_id = ? WHERE label_id = ?", []interface{}{targetID, sourceID}},
+		{"DELETE FROM label_parent WHERE parent_label_id = ? AND (label_id = ? OR label_id IN " +
+			"(SELECT label_id FROM (SELECT label_id FROM label_parent WHERE parent_label_id = ?) AS target))",
+			[]interface{}{sourceID, targetID, targetID}},
+		{"UPDATE label_parent SET parent_label_id = ? WHERE parent_label_id = ?", []interface{}{targetID, sourceID}},
+
+		{"UPDATE label_alias SET label_id = ? WHERE label_id = ?", []interface{}{targetID, sourceID}},
+		{"DELETE FROM label WHERE id = ?", []interface{}{sourceID}},
+	}
+	for _, statement := range statements {
+		if _, err := session.Exec(append([]interface{}{statement.query}, statement.args...)...); err != nil {
+			session.Rollback()
+			return err
+		}
+	}
+
+	// The merged label's name keeps resolving to the target
+	if _, err := session.Insert(&db.LabelAlias{Alias: alias, LabelID: targetID}); err != nil {
+		session.Rollback()
+		return err
+	}
+	return session.Commit()
+}
+
+func (r *labelRepository) Delete(ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	session := db.Engine.NewSession()
+	defer session.Close()
+	if err := session.Begin(); err != nil {
+		return err
+	}
+
+	deletes := []struct {
+		bean   interface{}
+		column string
+	}{
+		{&db.ImageLabel{}, "label_id"},
+		{&db.ImageLabelInstance{}, "label_id"},
+		{&db.VideoLabelSegment{}, "label_id"},
+		{&db.LabelParent{}, "label_id"},
+		{&db.LabelParent{}, "parent_label_id"},
+		{&db.LabelAlias{}, "label_id"},
+		{&db.Label{}, "id"},
+	}
+	for _, d := range deletes {
+		if _, err := session.In(d.column, ids).Delete(d.bean); err != nil {
+			session.Rollback()
+			return err
+		}
+	}
+	return session.Commit()
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"blog-fanchiikawa-service/db"
+	"xorm.io/xorm"
+)
+
+type labelParentRepository struct{}
+
+func NewLabelParentRepository() LabelParentRepository {
+	return &labelParentRepository{}
+}
+
+func (r *labelParentRepository) Create(session *xorm.Session, labelParent *db.LabelParent) error {
+	_, err := querier(session).Insert(labelParent)
+	return err
+}
+
+func (r *labelParentRepository) GetByLabelAndParent(session *xorm.Session, labelID, parentLabelID int64) (*db.LabelParent, error) {
+	var labelParent db.LabelParent
+	has, err := querier(session).Where("label_id = ? AND parent_label_id = ?", labelID, parentLabelID).Get(&labelParent)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, nil
+	}
+	return &labelParent, nil
+}
+
+func (r *labelParentRepository) UpdateRemoved(id int64, removed bool) (int64, error) {
+	return db.Engine.ID(id).Cols("removed").Update(&db.LabelParent{Removed: removed})
+}
+
+func (r *labelParentRepository) List() ([]*db.LabelParent, error) {
+	var links []*db.LabelParent
+	err := db.Engine.Where("removed = ?", false).Find(&links)
+	return links, err
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"blog-fanchiikawa-service/db"
+	"time"
+)
+
+type mediaJobRepository struct{}
+
+func NewMediaJobRepository() MediaJobRepository {
+	return &mediaJobRepository{}
+}
+
+func (r *mediaJobRepository) Create(job *db.MediaJob) error {
+	_, err := db.Engine.Insert(job)
+	return err
+}
+
+func (r *mediaJobRepository) GetByID(id int64) (*db.MediaJob, error) {
+	var job db.MediaJob
+	has, err := db.Engine.ID(id).Get(&job)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, nil
+	}
+	return &job, nil
+}
+
+func (r *mediaJobRepository) GetByImageAndType(imageID int64, jobType string) (*db.MediaJob, error) {
+	var job db.MediaJob
+	has, err := db.Engine.Where("image_id = ? AND job_type = ?", imageID, jobType).Get(&job)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, nil
+	}
+	return &job, nil
+}
+
+func (r *mediaJobRepository) GetDue(now time.Time, limit int) ([]*db.MediaJob, error) {
+	var jobs []*db.MediaJob
+	err := db.Engine.Where("status = ? AND next_run_at <= ?", db.MediaJobPending, now).
+		OrderBy("next_run_at ASC, id ASC").
+		Limit(limit).
+		Find(&jobs)
+	return jobs, err
+}
+
+func (r *mediaJobRepository) List(status string, imageID int64, limit int) ([]*db.MediaJob, error) {
+	var jobs []*db.MediaJob
+	session := db.Engine.NewSession()
+	defer session.Close()
+	if status != "" {
+		session = session.Where("status = ?", status)
+	}
+	if imageID != 0 {
+		session = session.And("image_id = ?", imageID)
+	}
+	err := session.OrderBy("id DESC").Limit(limit).Find(&jobs)
+	return jobs, err
+}
+
+func (r *mediaJobRepository) Transition(job *db.MediaJob, fromStatuses ...string) (int64, error) {
+	return db.Engine.ID(job.ID).
+		In("status", fromStatuses).
+		Cols("status", "attempts", "max_attempts", "last_error", "error_category", "next_run_at", "external_job_id", "waiting_since").
+		Update(job)
+}
+
+func (r *mediaJobRepository) ResetStale(before time.Time) (int64, error) {
+	return db.Engine.Where("status = ? AND updated_at < ?", db.MediaJobRunning, before).
+		Cols("status").
+		Update(&db.MediaJob{Status: db.MediaJobPending})
+}
@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"blog-fanchiikawa-service/db"
+)
+
+type taxonomyBlockRepository struct{}
+
+func NewTaxonomyBlockRepository() TaxonomyBlockRepository {
+	return &taxonomyBlockRepository{}
+}
+
+func (r *taxonomyBlockRepository) Create(block *db.TaxonomyBlock) error {
+	_, err := db.Engine.Insert(block)
+	return err
+}
+
+func (r *taxonomyBlockRepository) GetByKindAndPattern(kind, pattern string) (*db.TaxonomyBlock, error) {
+	var block db.TaxonomyBlock
+	has, err := db.Engine.Where("kind = ? AND pattern = ?", kind, pattern).Get(&block)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, nil
+	}
+	return &block, nil
+}
+
+func (r *taxonomyBlockRepository) Delete(id int64) (int64, error) {
+	return db.Engine.ID(id).Delete(&db.TaxonomyBlock{})
+}
+
+func (r *taxonomyBlockRepository) List(kind string) ([]*db.TaxonomyBlock, error) {
+	var blocks []*db.TaxonomyBlock
+	session := db.Engine.OrderBy("kind ASC, pattern ASC")
+	if kind != "" {
+		session = session.Where("kind = ?", kind)
+	}
+	err := session.Find(&blocks)
+	return blocks, err
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"blog-fanchiikawa-service/db"
+	"xorm.io/xorm"
 )
 
 type textKeywordRepository struct{}
@@ -10,14 +11,14 @@ func NewTextKeywordRepository() TextKeywordRepository {
 	return &textKeywordRepository{}
 }
 
-func (r *textKeywordRepository) Create(textKeyword *db.TextKeyword) error {
-	_, err := db.Engine.Insert(textKeyword)
+func (r *textKeywordRepository) Create(session *xorm.Session, textKeyword *db.TextKeyword) error {
+	_, err := querier(session).Insert(textKeyword)
 	return err
 }
 
-func (r *textKeywordRepository) GetByKeyword(keyword string) (*db.TextKeyword, error) {
+func (r *textKeywordRepository) GetByKeyword(session *xorm.Session, keyword string) (*db.TextKeyword, error) {
 	var result db.TextKeyword
-	has, err := db.Engine.Where("keyword = ?", keyword).Get(&result)
+	has, err := querier(session).Where("keyword = ?", keyword).Get(&result)
 	if err != nil {
 		return nil, err
 	}
@@ -27,22 +28,75 @@ func (r *textKeywordRepository) GetByKeyword(keyword string) (*db.TextKeyword, e
 	return &result, err
 }
 
+type imageTextKeywordRow struct {
+	ImageID        int64 `xorm:"'image_id'"`
+	db.TextKeyword `xorm:"extends"`
+}
+
+func (r *textKeywordRepository) GetByImageIDs(imageIDs []int64) (map[int64][]*db.TextKeyword, error) {
+	result := make(map[int64][]*db.TextKeyword)
+	if len(imageIDs) == 0 {
+		return result, nil
+	}
+
+	var rows []*imageTextKeywordRow
+	err := db.Engine.Table("image_text_keyword").
+		Select("image_text_keyword.image_id, text_keyword.*").
+		Join("INNER", "text_keyword", "text_keyword.id = image_text_keyword.text_keyword_id").
+		In("image_text_keyword.image_id", imageIDs).
+		OrderBy("text_keyword.keyword ASC").
+		Find(&rows)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		keyword := row.TextKeyword
+		result[row.ImageID] = append(result[row.ImageID], &keyword)
+	}
+	return result, nil
+}
+
 type imageTextKeywordRepository struct{}
 
 func NewImageTextKeywordRepository() ImageTextKeywordRepository {
 	return &imageTextKeywordRepository{}
 }
 
-func (r *imageTextKeywordRepository) Create(imageLabel *db.ImageTextKeyword) error {
-	_, err := db.Engine.Insert(imageLabel)
+func (r *imageTextKeywordRepository) Create(session *xorm.Session, imageLabel *db.ImageTextKeyword) error {
+	_, err := querier(session).Insert(imageLabel)
 	return err
 }
 
-func (r *imageTextKeywordRepository) GetByImageAndKeyword(imageID, textKeywordID int64) (*db.ImageTextKeyword, error) {
+func (r *imageTextKeywordRepository) GetByImageAndKeyword(session *xorm.Session, imageID, textKeywordID int64) (*db.ImageTextKeyword, error) {
 	var imageTextKeyword db.ImageTextKeyword
-	has, err := db.Engine.Where("image_id = ? AND text_keyword_id = ?", imageID, textKeywordID).Get(&imageTextKeyword)
+	has, err := querier(session).Where("image_id = ? AND text_keyword_id = ?", imageID, textKeywordID).Get(&imageTextKeyword)
 	if !has {
 		return nil, nil
 	}
 	return &imageTextKeyword, err
 }
+
+func (r *textKeywordRepository) UpdateHidden(id int64, hidden bool) (int64, error) {
+	return db.Engine.ID(id).Cols("hidden").Update(&db.TextKeyword{Hidden: hidden})
+}
+
+func (r *textKeywordRepository) DeleteByPattern(pattern string) (int64, error) {
+	session := db.Engine.NewSession()
+	defer session.Close()
+	if err := session.Begin(); err != nil {
+		return 0, err
+	}
+
+	like := likePattern(pattern)
+	if _, err := session.Exec("DELETE FROM image_text_keyword WHERE text_keyword_id IN (SELECT id FROM text_keyword WHERE keyword LIKE ?)", like); err != nil {
+		session.Rollback()
+		return 0, err
+	}
+	deleted, err := session.Where("keyword LIKE ?", like).Delete(&db.TextKeyword{})
+	if err != nil {
+		session.Rollback()
+		return 0, err
+	}
+	return deleted, session.Commit()
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"blog-fanchiikawa-service/db"
+	"xorm.io/xorm"
 )
 
 // transactionManager implements TransactionManager interface
@@ -13,7 +14,7 @@ func NewTransactionManager() TransactionManager {
 }
 
 // WithTransaction executes a function within a database transaction
-func (tm *transactionManager) WithTransaction(fn func() error) error {
+func (tm *transactionManager) WithTransaction(fn func(session *xorm.Session) error) error {
 	session := db.Engine.NewSession()
 	defer session.Close()
 
@@ -21,10 +22,18 @@ func (tm *transactionManager) WithTransaction(fn func() error) error {
 		return err
 	}
 
-	if err := fn(); err != nil {
+	if err := fn(session); err != nil {
 		session.Rollback()
 		return err
 	}
 
 	return session.Commit()
-}
\ No newline at end of file
+}
+
+// querier returns the session of the caller's transaction, or the engine when there is none
+func querier(session *xorm.Session) xorm.Interface {
+	if session == nil {
+		return db.Engine
+	}
+	return session
+}
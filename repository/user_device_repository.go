@@ -2,6 +2,7 @@ package repository
 
 import (
 	"blog-fanchiikawa-service/db"
+	"xorm.io/xorm"
 )
 
 // userDeviceRepository implements UserDeviceRepository interface
@@ -13,8 +14,8 @@ func NewUserDeviceRepository() UserDeviceRepository {
 }
 
 // Create creates a new user device
-func (r *userDeviceRepository) Create(device *db.UserDevice) error {
-	_, err := db.Engine.Insert(device)
+func (r *userDeviceRepository) Create(session *xorm.Session, device *db.UserDevice) error {
+	_, err := querier(session).Insert(device)
 	return err
 }
 
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"blog-fanchiikawa-service/db"
+	"xorm.io/xorm"
 )
 
 // userRepository implements UserRepository interface
@@ -39,8 +40,8 @@ func (r *userRepository) GetByID(id int64) (*db.User, error) {
 }
 
 // Create creates a new user
-func (r *userRepository) Create(user *db.User) error {
-	_, err := db.Engine.Insert(user)
+func (r *userRepository) Create(session *xorm.Session, user *db.User) error {
+	_, err := querier(session).Insert(user)
 	return err
 }
 
@@ -49,4 +50,9 @@ func (r *userRepository) List(limit int, offset int) ([]*db.User, error) {
 	var users []*db.User
 	err := db.Engine.Limit(limit, offset).Find(&users)
 	return users, err
-}
\ No newline at end of file
+}
+// UpdatePassword replaces a user's password hash
+func (r *userRepository) UpdatePassword(id int64, passwordHash string) error {
+	_, err := db.Engine.ID(id).Cols("password_hash").Update(&db.User{PasswordHash: passwordHash})
+	return err
+}
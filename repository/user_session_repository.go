@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"blog-fanchiikawa-service/db"
+)
+
+// userSessionRepository implements UserSessionRepository interface
+type userSessionRepository struct{}
+
+// NewUserSessionRepository creates a new UserSessionRepository instance
+func NewUserSessionRepository() UserSessionRepository {
+	return &userSessionRepository{}
+}
+
+// Create creates a new session
+func (r *userSessionRepository) Create(session *db.UserSession) error {
+	_, err := db.Engine.Insert(session)
+	return err
+}
+
+// GetByToken retrieves a session by its token
+func (r *userSessionRepository) GetByToken(token string) (*db.UserSession, error) {
+	var session db.UserSession
+	has, err := db.Engine.Where("token = ?", token).Get(&session)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, nil // Session not found
+	}
+	return &session, nil
+}
+
+// Revoke marks the session with the given token as revoked
+func (r *userSessionRepository) Revoke(token string) (int64, error) {
+	return db.Engine.Where("token = ?", token).Cols("revoked").Update(&db.UserSession{Revoked: true})
+}
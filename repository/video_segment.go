@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"blog-fanchiikawa-service/db"
+)
+
+type videoSegmentRepository struct{}
+
+func NewVideoSegmentRepository() VideoSegmentRepository {
+	return &videoSegmentRepository{}
+}
+
+func (r *videoSegmentRepository) ReplaceLabelSegments(imageID int64, segments []*db.VideoLabelSegment) error {
+	if _, err := db.Engine.Where("image_id = ?", imageID).Delete(&db.VideoLabelSegment{}); err != nil {
+		return err
+	}
+	if len(segments) == 0 {
+		return nil
+	}
+	for _, segment := range segments {
+		segment.ImageID = imageID
+	}
+	_, err := db.Engine.Insert(segments)
+	return err
+}
+
+func (r *videoSegmentRepository) ReplaceTextSegments(imageID int64, segments []*db.VideoTextSegment) error {
+	if _, err := db.Engine.Where("image_id = ?", imageID).Delete(&db.VideoTextSegment{}); err != nil {
+		return err
+	}
+	if len(segments) == 0 {
+		return nil
+	}
+	for _, segment := range segments {
+		segment.ImageID = imageID
+	}
+	_, err := db.Engine.Insert(segments)
+	return err
+}
+
+func (r *videoSegmentRepository) GetLabelSegments(imageID int64, minConfidence float64) ([]*db.VideoLabelSegment, error) {
+	var segments []*db.VideoLabelSegment
+	err := db.Engine.Where("image_id = ? AND confidence >= ?", imageID, minConfidence).
+		OrderBy("start_ms ASC, id ASC").
+		Find(&segments)
+	return segments, err
+}
+
+func (r *videoSegmentRepository) GetTextSegments(imageID int64, minConfidence float64) ([]*db.VideoTextSegment, error) {
+	var segments []*db.VideoTextSegment
+	err := db.Engine.Where("image_id = ? AND confidence >= ?", imageID, minConfidence).
+		OrderBy("start_ms ASC, id ASC").
+		Find(&segments)
+	return segments, err
+}
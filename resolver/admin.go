@@ -0,0 +1,34 @@
+package resolver
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"os"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// adminTokenHeader carries the ADMIN_API_TOKEN on admin-only operations
+const adminTokenHeader = "X-Admin-Token"
+
+var (
+	errAdminDisabled     = errors.New("admin operations are disabled: ADMIN_API_TOKEN is not set")
+	errAdminUnauthorized = errors.New("admin token required")
+)
+
+// requireAdmin rejects the operation unless its request presented the configured admin token
+func requireAdmin(ctx context.Context) error {
+	expected := os.Getenv("ADMIN_API_TOKEN")
+	if expected == "" {
+		return errAdminDisabled
+	}
+	if !graphql.HasOperationContext(ctx) {
+		return errAdminUnauthorized
+	}
+	token := graphql.GetOperationContext(ctx).Headers.Get(adminTokenHeader)
+	if subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+		return errAdminUnauthorized
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package resolver
+
+import (
+	"blog-fanchiikawa-service/db"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+)
+
+// subscriptionSessionCheck is how often a running subscription re-validates its session token
+const subscriptionSessionCheck = 30 * time.Second
+
+var (
+	errUnauthenticated = errors.New("session token required")
+	errForbidden       = errors.New("not allowed to access this resource")
+)
+
+type sessionContextKey struct{}
+
+// requireUser returns the ID of the user whose session token the request presented as "Authorization: Bearer <token>",
+// or, on a websocket, in the connection_init payload
+func (r *Resolver) requireUser(ctx context.Context) (int64, error) {
+	session, err := r.requireSession(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return session.UserID, nil
+}
+
+func (r *Resolver) requireSession(ctx context.Context) (*db.UserSession, error) {
+	var token string
+	if session, ok := ctx.Value(sessionContextKey{}).(*db.UserSession); ok {
+		token = session.Token
+	} else if graphql.HasOperationContext(ctx) {
+		token = bearerToken(graphql.GetOperationContext(ctx).Headers.Get("Authorization"))
+	}
+	if token == "" {
+		return nil, errUnauthenticated
+	}
+	return r.SessionService.ValidateToken(token)
+}
+
+func bearerToken(value string) string {
+	value = strings.TrimSpace(value)
+	if token, ok := strings.CutPrefix(value, "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return value
+}
+
+// WebsocketInit authenticates a GraphQL websocket connection from the "Authorization" entry of its
+// connection_init payload and keeps the session on the connection's context for its subscriptions
+func (r *Resolver) WebsocketInit(ctx context.Context, payload transport.InitPayload) (context.Context, *transport.InitPayload, error) {
+	token := bearerToken(payload.Authorization())
+	if token == "" {
+		return ctx, nil, errUnauthenticated
+	}
+	session, err := r.SessionService.ValidateToken(token)
+	if err != nil {
+		return ctx, nil, err
+	}
+	return context.WithValue(ctx, sessionContextKey{}, session), nil, nil
+}
+
+// sessionContext returns a context that is cancelled once the subscription's session expires or is revoked,
+// so long-lived streams stop like /ws connections do
+func (r *Resolver) sessionContext(ctx context.Context) (context.Context, *db.UserSession, error) {
+	session, err := r.requireSession(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		defer cancel()
+		ticker := time.NewTicker(subscriptionSessionCheck)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := r.SessionService.ValidateToken(session.Token); err != nil {
+					log.Printf("Ending subscription of user %d: %v", session.UserID, err)
+					return
+				}
+			}
+		}
+	}()
+	return ctx, session, nil
+}
@@ -94,13 +94,14 @@ func (r *Resolver) UserChats(ctx context.Context, userID int64) ([]*model.Chat,
 		updatedAt, _ := time.Parse(time.RFC3339, chat.UpdatedAt)
 
 		result[i] = &model.Chat{
-			ID:        chat.ID,
-			UserID:    chat.UserID,
-			Title:     chat.Title,
-			BotName:   chat.BotName,
-			SessionID: chat.SessionId,
-			CreatedAt: createdAt,
-			UpdatedAt: updatedAt,
+			ID:          chat.ID,
+			UserID:      chat.UserID,
+			Title:       chat.Title,
+			BotName:     chat.BotName,
+			SessionID:   chat.SessionId,
+			UnreadCount: int32(chat.UnreadCount),
+			CreatedAt:   createdAt,
+			UpdatedAt:   updatedAt,
 		}
 	}
 
@@ -117,13 +118,14 @@ func (r *Resolver) ChatHistory(ctx context.Context, chatID int64) (*model.ChatHi
 	updatedAt, _ := time.Parse(time.RFC3339, history.Chat.UpdatedAt)
 
 	chat := &model.Chat{
-		ID:        history.Chat.ID,
-		UserID:    history.Chat.UserID,
-		Title:     history.Chat.Title,
-		BotName:   history.Chat.BotName,
-		SessionID: history.Chat.SessionId,
-		CreatedAt: createdAt,
-		UpdatedAt: updatedAt,
+		ID:          history.Chat.ID,
+		UserID:      history.Chat.UserID,
+		Title:       history.Chat.Title,
+		BotName:     history.Chat.BotName,
+		SessionID:   history.Chat.SessionId,
+		UnreadCount: int32(history.Chat.UnreadCount),
+		CreatedAt:   createdAt,
+		UpdatedAt:   updatedAt,
 	}
 
 	messages := make([]*model.ChatMessage, len(history.Messages))
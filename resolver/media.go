@@ -0,0 +1,160 @@
+package resolver
+
+import (
+	"blog-fanchiikawa-service/graph/model"
+	"context"
+)
+
+// Images handles the images query
+func (r *Resolver) Images(ctx context.Context, filter *model.ImageFilter, first *int32, after *string) (*model.ImageConnection, error) {
+	var limit int
+	if first != nil {
+		limit = int(*first)
+	}
+	var cursor string
+	if after != nil {
+		cursor = *after
+	}
+	return r.MediaLibraryService.ListImages(filter, limit, cursor)
+}
+
+// Image handles the image query
+func (r *Resolver) Image(ctx context.Context, id int64) (*model.Image, error) {
+	return r.MediaLibraryService.GetImage(id)
+}
+
+// Labels handles the labels query
+func (r *Resolver) Labels(ctx context.Context, includeHidden *bool) ([]*model.Label, error) {
+	hidden := includeHidden != nil && *includeHidden
+	if hidden {
+		if err := requireAdmin(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return r.MediaLibraryService.ListLabels(hidden)
+}
+
+// SearchImages handles the searchImages query
+func (r *Resolver) SearchImages(ctx context.Context, query *string, filter *model.ImageSearchFilter, first *int32, after *string, facetLimit *int32) (*model.ImageSearchResult, error) {
+	var q string
+	if query != nil {
+		q = *query
+	}
+	var limit int
+	if first != nil {
+		limit = int(*first)
+	}
+	var cursor string
+	if after != nil {
+		cursor = *after
+	}
+	var facets int
+	if facetLimit != nil {
+		facets = int(*facetLimit)
+	}
+	return r.MediaLibraryService.SearchImages(q, filter, limit, cursor, facets)
+}
+
+// ImageTextBlocks handles the imageTextBlocks query
+func (r *Resolver) ImageTextBlocks(ctx context.Context, imageID int64, page *int32, typeArg *model.TextBlockType) ([]*model.TextBlock, error) {
+	var pageNumber int
+	if page != nil {
+		pageNumber = int(*page)
+	}
+	var blockType string
+	if typeArg != nil {
+		blockType = typeArg.String()
+	}
+	return r.MediaLibraryService.GetTextBlocks(imageID, pageNumber, blockType)
+}
+
+// ImagePages handles the imagePages query
+func (r *Resolver) ImagePages(ctx context.Context, imageID int64) ([]*model.PageText, error) {
+	return r.MediaLibraryService.GetPageTexts(imageID)
+}
+
+// SemanticSearchImages handles the semanticSearchImages query
+func (r *Resolver) SemanticSearchImages(ctx context.Context, query string, k *int32) ([]*model.SemanticSearchHit, error) {
+	var limit int
+	if k != nil {
+		limit = int(*k)
+	}
+	return r.MediaLibraryService.SemanticSearchImages(query, limit)
+}
+
+// SimilarImages handles the similarImages query
+func (r *Resolver) SimilarImages(ctx context.Context, imageID int64, maxDistance *int32, first *int32) ([]*model.SimilarImage, error) {
+	distance := -1
+	if maxDistance != nil {
+		distance = int(*maxDistance)
+	}
+	var limit int
+	if first != nil {
+		limit = int(*first)
+	}
+	return r.MediaLibraryService.SimilarImages(imageID, distance, limit)
+}
+
+// VideoTimeline handles the videoTimeline query
+func (r *Resolver) VideoTimeline(ctx context.Context, imageID int64, minConfidence *float64) (*model.VideoTimeline, error) {
+	var confidence float64
+	if minConfidence != nil {
+		confidence = *minConfidence
+	}
+	return r.MediaLibraryService.VideoTimeline(imageID, confidence)
+}
+
+// DocumentFields handles the documentFields query
+func (r *Resolver) DocumentFields(ctx context.Context, imageID int64) ([]*model.DocumentField, error) {
+	return r.MediaLibraryService.GetDocumentFields(imageID)
+}
+
+// DocumentTables handles the documentTables query
+func (r *Resolver) DocumentTables(ctx context.Context, imageID int64) ([]*model.DocumentTable, error) {
+	return r.MediaLibraryService.GetDocumentTables(imageID)
+}
+
+// DocumentTable handles the documentTable query
+func (r *Resolver) DocumentTable(ctx context.Context, id int64) (*model.DocumentTable, error) {
+	return r.MediaLibraryService.GetDocumentTable(id)
+}
+
+// ModerationQueue handles the moderationQueue query
+func (r *Resolver) ModerationQueue(ctx context.Context, first *int32, after *string) (*model.ImageConnection, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	var limit int
+	if first != nil {
+		limit = int(*first)
+	}
+	var cursor string
+	if after != nil {
+		cursor = *after
+	}
+	return r.MediaLibraryService.ModerationQueue(limit, cursor)
+}
+
+// ApproveImage handles the approveImage mutation
+func (r *Resolver) ApproveImage(ctx context.Context, id int64) (*model.Image, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	return r.MediaLibraryService.ReviewImage(id, true)
+}
+
+// RejectImage handles the rejectImage mutation
+func (r *Resolver) RejectImage(ctx context.Context, id int64) (*model.Image, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	return r.MediaLibraryService.ReviewImage(id, false)
+}
+
+// UpdateImageCaption handles the updateImageCaption mutation
+func (r *Resolver) UpdateImageCaption(ctx context.Context, imageID int64, language string, input model.ImageCaptionInput) (*model.ImageCaption, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	return r.MediaLibraryService.UpdateImageCaption(imageID, language, input)
+}
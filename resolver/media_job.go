@@ -0,0 +1,88 @@
+package resolver
+
+import (
+	"blog-fanchiikawa-service/db"
+	"blog-fanchiikawa-service/graph/model"
+	"blog-fanchiikawa-service/service"
+	"context"
+	"strings"
+)
+
+// MediaJobs handles the mediaJobs query
+func (r *Resolver) MediaJobs(ctx context.Context, status *model.MediaJobStatus, imageID *int64, first *int32) ([]*model.MediaJob, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	var statusFilter string
+	if status != nil {
+		statusFilter = strings.ToLower(status.String())
+	}
+	var image int64
+	if imageID != nil {
+		image = *imageID
+	}
+	var limit int
+	if first != nil {
+		limit = int(*first)
+	}
+
+	jobs, err := r.MediaService.ListJobs(statusFilter, image, limit)
+	if err != nil {
+		return nil, err
+	}
+	return toMediaJobModels(jobs), nil
+}
+
+// RetryJob handles the retryJob mutation
+func (r *Resolver) RetryJob(ctx context.Context, id int64) (*model.MediaJob, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	job, err := r.MediaService.RetryJob(id)
+	if err != nil {
+		return nil, err
+	}
+	return toMediaJobModel(job), nil
+}
+
+// CancelJob handles the cancelJob mutation
+func (r *Resolver) CancelJob(ctx context.Context, id int64) (*model.MediaJob, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	job, err := r.MediaService.CancelJob(id)
+	if err != nil {
+		return nil, err
+	}
+	return toMediaJobModel(job), nil
+}
+
+func toMediaJobModel(job *db.MediaJob) *model.MediaJob {
+	result := &model.MediaJob{
+		ID:          job.ID,
+		ImageID:     job.ImageID,
+		Type:        model.MediaJobType(strings.ToUpper(job.JobType)),
+		Status:      model.MediaJobStatus(strings.ToUpper(job.Status)),
+		Attempts:    int32(job.Attempts),
+		MaxAttempts: int32(job.MaxAttempts),
+		NextRunAt:   job.NextRunAt,
+		CreatedAt:   job.CreatedAt,
+		UpdatedAt:   job.UpdatedAt,
+	}
+	if job.LastError != "" {
+		result.LastError = &job.LastError
+	}
+	return result
+}
+
+// toOwnerMediaJobModel shows the image's owner only the error category; the full error text can name
+// buckets, object keys and database errors and stays in the admin job list
+func toOwnerMediaJobModel(job *db.MediaJob) *model.MediaJob {
+	result := toMediaJobModel(job)
+	result.LastError = nil
+	if category := service.JobErrorCategory(job); category != "" {
+		result.LastError = &category
+	}
+	return result
+}
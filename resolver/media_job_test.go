@@ -0,0 +1,25 @@
+package resolver
+
+import (
+	"blog-fanchiikawa-service/db"
+	"testing"
+)
+
+func TestOwnerMediaJobHidesErrorText(t *testing.T) {
+	job := &db.MediaJob{
+		ID:            1,
+		Status:        db.MediaJobDead,
+		LastError:     "failed to detect labels: AccessDenied: s3://media-bucket/uploads/1.jpg",
+		ErrorCategory: "processing failed",
+	}
+
+	if admin := toMediaJobModel(job); admin.LastError == nil || *admin.LastError != job.LastError {
+		t.Fatalf("admin model has error %v, want the full text", admin.LastError)
+	}
+	if owner := toOwnerMediaJobModel(job); owner.LastError == nil || *owner.LastError != job.ErrorCategory {
+		t.Fatalf("owner model has error %v, want only the category", owner.LastError)
+	}
+	if owner := toOwnerMediaJobModel(&db.MediaJob{ID: 2, Status: db.MediaJobSucceeded}); owner.LastError != nil {
+		t.Fatalf("owner model of a succeeded job has error %q", *owner.LastError)
+	}
+}
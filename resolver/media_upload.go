@@ -0,0 +1,146 @@
+package resolver
+
+import (
+	"blog-fanchiikawa-service/db"
+	"blog-fanchiikawa-service/graph/model"
+	"blog-fanchiikawa-service/service"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// UploadMedia handles the uploadMedia mutation
+func (r *Resolver) UploadMedia(ctx context.Context, files []*graphql.Upload) ([]*model.MediaUpload, error) {
+	ownerID, err := r.requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no file provided")
+	}
+
+	result := make([]*model.MediaUpload, 0, len(files))
+	for _, file := range files {
+		if file == nil || file.File == nil {
+			continue
+		}
+		ingested, err := r.MediaService.IngestUpload(file.File, file.Filename, ownerID)
+		if err != nil {
+			log.Printf("Failed to ingest upload %s from user %d: %v", file.Filename, ownerID, err)
+			message := uploadErrorMessage(err)
+			result = append(result, &model.MediaUpload{Filename: file.Filename, Jobs: []*model.MediaJob{}, Error: &message})
+			continue
+		}
+		result = append(result, toMediaUploadModel(file.Filename, ingested, ownerID))
+	}
+	return result, nil
+}
+
+// RequestMediaUpload handles the requestMediaUpload mutation
+func (r *Resolver) RequestMediaUpload(ctx context.Context, filename string) (*model.MediaUploadTicket, error) {
+	ownerID, err := r.requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ticket, err := r.MediaService.CreateUploadURL(filename, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.MediaUploadTicket{
+		UploadURL: ticket.UploadURL,
+		Key:       ticket.Key,
+		ExpiresAt: ticket.ExpiresAt,
+	}, nil
+}
+
+// CompleteMediaUpload handles the completeMediaUpload mutation
+func (r *Resolver) CompleteMediaUpload(ctx context.Context, key string, filename *string) (*model.MediaUpload, error) {
+	ownerID, err := r.requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var name string
+	if filename != nil {
+		name = *filename
+	}
+	ingested, err := r.MediaService.CompleteUpload(key, name, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = path.Base(key)
+	}
+	return toMediaUploadModel(name, ingested, ownerID), nil
+}
+
+// MediaJob handles the mediaJob query
+func (r *Resolver) MediaJob(ctx context.Context, id int64) (*model.MediaJob, error) {
+	ownerID, err := r.requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	job, err := r.MediaService.GetOwnedJob(id, ownerID)
+	if errors.Is(err, service.ErrMediaJobNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toOwnerMediaJobModel(job), nil
+}
+
+// ImageJobs handles the imageJobs query
+func (r *Resolver) ImageJobs(ctx context.Context, imageID int64) ([]*model.MediaJob, error) {
+	ownerID, err := r.requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := r.MediaService.ListOwnedImageJobs(imageID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	return toOwnerMediaJobModels(jobs), nil
+}
+
+// uploadErrorMessage keeps internal failure details out of per-file upload errors
+func uploadErrorMessage(err error) string {
+	if errors.Is(err, service.ErrUploadTooLarge) {
+		return err.Error()
+	}
+	return "failed to ingest file"
+}
+
+// toMediaUploadModel reports an ingested file. A duplicate of a file someone else uploaded only says so,
+// without the other image's ID or jobs.
+func toMediaUploadModel(filename string, ingested *service.IngestResult, ownerID int64) *model.MediaUpload {
+	if ingested.Image.OwnerID != ownerID {
+		return &model.MediaUpload{Filename: filename, Duplicate: true, Jobs: []*model.MediaJob{}}
+	}
+	imageID := ingested.Image.ID
+	return &model.MediaUpload{
+		Filename:  filename,
+		ImageID:   &imageID,
+		Duplicate: ingested.Duplicate,
+		Jobs:      toOwnerMediaJobModels(ingested.Jobs),
+	}
+}
+
+func toMediaJobModels(jobs []*db.MediaJob) []*model.MediaJob {
+	result := make([]*model.MediaJob, len(jobs))
+	for i, job := range jobs {
+		result[i] = toMediaJobModel(job)
+	}
+	return result
+}
+
+func toOwnerMediaJobModels(jobs []*db.MediaJob) []*model.MediaJob {
+	result := make([]*model.MediaJob, len(jobs))
+	for i, job := range jobs {
+		result[i] = toOwnerMediaJobModel(job)
+	}
+	return result
+}
@@ -7,6 +7,7 @@ import (
 // Resolver holds all the services needed for GraphQL resolvers
 type Resolver struct {
 	UserService         service.UserService
+	SessionService      service.SessionService
 	LanguageService     service.LanguageService
 	TranslateService    service.TranslateService
 	SpeechService       service.SpeechService
@@ -15,11 +16,16 @@ type Resolver struct {
 	ConfigService       service.ConfigService
 	CustomLabelsService service.CustomLabelsService
 	CommentReplyService service.CommentReplyService
+	EventPublisher      service.EventPublisher
+	MediaLibraryService service.MediaLibraryService
+	MediaService        service.MediaService
+	TaxonomyService     service.TaxonomyService
 }
 
 // NewResolver creates a new Resolver instance with all services
 func NewResolver(
 	userService service.UserService,
+	sessionService service.SessionService,
 	languageService service.LanguageService,
 	translateService service.TranslateService,
 	speechService service.SpeechService,
@@ -28,9 +34,14 @@ func NewResolver(
 	configService service.ConfigService,
 	customLabelsService service.CustomLabelsService,
 	commentReplyService service.CommentReplyService,
+	eventPublisher service.EventPublisher,
+	mediaLibraryService service.MediaLibraryService,
+	mediaService service.MediaService,
+	taxonomyService service.TaxonomyService,
 ) *Resolver {
 	return &Resolver{
 		UserService:         userService,
+		SessionService:      sessionService,
 		LanguageService:     languageService,
 		TranslateService:    translateService,
 		SpeechService:       speechService,
@@ -39,5 +50,9 @@ func NewResolver(
 		ConfigService:       configService,
 		CustomLabelsService: customLabelsService,
 		CommentReplyService: commentReplyService,
+		EventPublisher:      eventPublisher,
+		MediaLibraryService: mediaLibraryService,
+		MediaService:        mediaService,
+		TaxonomyService:     taxonomyService,
 	}
 }
\ No newline at end of file
@@ -0,0 +1,119 @@
+package resolver
+
+import (
+	"blog-fanchiikawa-service/graph/model"
+	"blog-fanchiikawa-service/service"
+	"context"
+	"time"
+)
+
+// MessageAdded streams messages persisted for the given chat, which must belong to the session user
+func (r *Resolver) MessageAdded(ctx context.Context, chatID int64) (<-chan *model.ChatMessage, error) {
+	ctx, session, err := r.sessionContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.ChatService.CheckChatOwner(session.UserID, chatID); err != nil {
+		return nil, err
+	}
+
+	events := r.EventPublisher.Subscribe(ctx, func(e *service.Event) bool {
+		return e.Type == service.EventMessageAdded && e.ChatID == chatID
+	})
+
+	out := make(chan *model.ChatMessage, 1)
+	go func() {
+		defer close(out)
+		for e := range events {
+			sentAt, _ := time.Parse(time.RFC3339, e.Message.SentAt)
+			msg := &model.ChatMessage{
+				ID:      e.Message.ID,
+				ChatID:  e.Message.ChatID,
+				Content: e.Message.Content,
+				IsUser:  e.Message.IsUser,
+				Intent:  &e.Message.Intent,
+				SentAt:  sentAt,
+			}
+			select {
+			case out <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// ChatUpdated streams chat changes for the given user, who must be the session user
+func (r *Resolver) ChatUpdated(ctx context.Context, userID int64) (<-chan *model.Chat, error) {
+	ctx, session, err := r.sessionContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if session.UserID != userID {
+		return nil, errForbidden
+	}
+
+	events := r.EventPublisher.Subscribe(ctx, func(e *service.Event) bool {
+		return e.Type == service.EventChatUpdated && e.UserID == userID
+	})
+
+	out := make(chan *model.Chat, 1)
+	go func() {
+		defer close(out)
+		for e := range events {
+			createdAt, _ := time.Parse(time.RFC3339, e.Chat.CreatedAt)
+			updatedAt, _ := time.Parse(time.RFC3339, e.Chat.UpdatedAt)
+			chat := &model.Chat{
+				ID:          e.Chat.ID,
+				UserID:      e.Chat.UserID,
+				Title:       e.Chat.Title,
+				BotName:     e.Chat.BotName,
+				SessionID:   e.Chat.SessionId,
+				UnreadCount: int32(e.Chat.UnreadCount),
+				CreatedAt:   createdAt,
+				UpdatedAt:   updatedAt,
+			}
+			select {
+			case out <- chat:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// MediaProcessed streams finished processing stages of the session user's images
+func (r *Resolver) MediaProcessed(ctx context.Context) (<-chan *model.MediaProcessedEvent, error) {
+	ctx, session, err := r.sessionContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	events := r.EventPublisher.Subscribe(ctx, func(e *service.Event) bool {
+		return e.Type == service.EventMediaProcessed && e.Media.OwnerID != 0 && e.Media.OwnerID == session.UserID
+	})
+
+	out := make(chan *model.MediaProcessedEvent, 1)
+	go func() {
+		defer close(out)
+		for e := range events {
+			processedAt, _ := time.Parse(time.RFC3339, e.Media.ProcessedAt)
+			event := &model.MediaProcessedEvent{
+				ImageID:     e.Media.ImageID,
+				Stage:       e.Media.Stage,
+				ProcessedAt: processedAt,
+			}
+			select {
+			case out <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
@@ -0,0 +1,67 @@
+package resolver
+
+import (
+	"blog-fanchiikawa-service/db"
+	"blog-fanchiikawa-service/service"
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeSessions is a SessionService that knows fixed tokens
+type fakeSessions struct {
+	service.SessionService
+	sessions map[string]*db.UserSession
+}
+
+func (f *fakeSessions) ValidateToken(token string) (*db.UserSession, error) {
+	if session, ok := f.sessions[token]; ok {
+		return session, nil
+	}
+	return nil, service.ErrInvalidSession
+}
+
+func TestMediaProcessedStreamsOnlyOwnImages(t *testing.T) {
+	session := &db.UserSession{UserID: 1, Token: "token-1"}
+	publisher := service.NewEventPublisher()
+	r := &Resolver{
+		SessionService: &fakeSessions{sessions: map[string]*db.UserSession{session.Token: session}},
+		EventPublisher: publisher,
+	}
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), sessionContextKey{}, session))
+	defer cancel()
+	events, err := r.MediaProcessed(ctx)
+	if err != nil {
+		t.Fatalf("MediaProcessed: %v", err)
+	}
+
+	for _, media := range []*service.MediaProcessedEvent{
+		{ImageID: 10, OwnerID: 2, Stage: service.MediaStageLabels},
+		{ImageID: 11, Stage: service.MediaStageLabels},
+		{ImageID: 12, OwnerID: 1, Stage: service.MediaStageText},
+	} {
+		publisher.Publish(&service.Event{Type: service.EventMediaProcessed, Media: media})
+	}
+
+	select {
+	case event := <-events:
+		if event.ImageID != 12 {
+			t.Fatalf("got image %d, want only the session user's image 12", event.ImageID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the session user's own image was not streamed")
+	}
+	select {
+	case event := <-events:
+		t.Fatalf("unexpected event for image %d", event.ImageID)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestMediaProcessedRequiresSession(t *testing.T) {
+	r := &Resolver{SessionService: &fakeSessions{}, EventPublisher: service.NewEventPublisher()}
+	if _, err := r.MediaProcessed(context.Background()); err != errUnauthenticated {
+		t.Fatalf("got %v, want errUnauthenticated", err)
+	}
+}
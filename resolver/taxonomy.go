@@ -0,0 +1,86 @@
+package resolver
+
+import (
+	"blog-fanchiikawa-service/graph/model"
+	"context"
+)
+
+// TaxonomyBlocks handles the taxonomyBlocks query
+func (r *Resolver) TaxonomyBlocks(ctx context.Context) ([]*model.TaxonomyBlock, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	return r.TaxonomyService.ListBlocks()
+}
+
+// AddLabelAlias handles the addLabelAlias mutation
+func (r *Resolver) AddLabelAlias(ctx context.Context, labelID int64, alias string) (*model.Label, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	return r.TaxonomyService.AddLabelAlias(labelID, alias)
+}
+
+// RemoveLabelAlias handles the removeLabelAlias mutation
+func (r *Resolver) RemoveLabelAlias(ctx context.Context, alias string) (bool, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return false, err
+	}
+	return r.TaxonomyService.RemoveLabelAlias(alias)
+}
+
+// MergeLabels handles the mergeLabels mutation
+func (r *Resolver) MergeLabels(ctx context.Context, sourceIds []int64, targetID int64) (*model.Label, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	return r.TaxonomyService.MergeLabels(sourceIds, targetID)
+}
+
+// SetLabelParent handles the setLabelParent mutation
+func (r *Resolver) SetLabelParent(ctx context.Context, labelID int64, parentID int64) (*model.Label, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	return r.TaxonomyService.SetLabelParent(labelID, parentID)
+}
+
+// RemoveLabelParent handles the removeLabelParent mutation
+func (r *Resolver) RemoveLabelParent(ctx context.Context, labelID int64, parentID int64) (*model.Label, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	return r.TaxonomyService.RemoveLabelParent(labelID, parentID)
+}
+
+// SetLabelHidden handles the setLabelHidden mutation
+func (r *Resolver) SetLabelHidden(ctx context.Context, id int64, hidden bool) (*model.Label, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	return r.TaxonomyService.SetLabelHidden(id, hidden)
+}
+
+// SetKeywordHidden handles the setKeywordHidden mutation
+func (r *Resolver) SetKeywordHidden(ctx context.Context, keyword string, hidden bool) (*model.TextKeyword, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	return r.TaxonomyService.SetKeywordHidden(keyword, hidden)
+}
+
+// AddTaxonomyBlock handles the addTaxonomyBlock mutation
+func (r *Resolver) AddTaxonomyBlock(ctx context.Context, kind model.TaxonomyKind, pattern string) (*model.TaxonomyBlock, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	return r.TaxonomyService.AddBlock(kind, pattern)
+}
+
+// RemoveTaxonomyBlock handles the removeTaxonomyBlock mutation
+func (r *Resolver) RemoveTaxonomyBlock(ctx context.Context, id int64) (bool, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return false, err
+	}
+	return r.TaxonomyService.RemoveBlock(id)
+}
@@ -3,8 +3,11 @@ package resolver
 import (
 	"blog-fanchiikawa-service/graph/model"
 	"context"
+	"errors"
 )
 
+var errUserNotFound = errors.New("user not found")
+
 // Login handles the login mutation
 func (r *Resolver) Login(ctx context.Context, input model.LoginUser) (*model.User, error) {
 	return r.UserService.Login(input.Nickname, input.Email, input.DeviceID)
@@ -14,3 +17,77 @@ func (r *Resolver) Login(ctx context.Context, input model.LoginUser) (*model.Use
 func (r *Resolver) Users(ctx context.Context) ([]*model.User, error) {
 	return r.UserService.GetUsers(10) // Default limit of 10
 }
+
+
+// Register handles the register mutation and signs the new user in
+func (r *Resolver) Register(ctx context.Context, input model.RegisterUser) (*model.Session, error) {
+	user, err := r.UserService.Register(input.Nickname, input.Email, input.Password, input.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+	return r.newSession(user)
+}
+
+// SignIn handles the signIn mutation, the way users get a session token
+func (r *Resolver) SignIn(ctx context.Context, email string, password string) (*model.Session, error) {
+	user, err := r.UserService.Authenticate(email, password)
+	if err != nil {
+		return nil, err
+	}
+	return r.newSession(user)
+}
+
+// SetPassword handles the setPassword mutation for the session user
+func (r *Resolver) SetPassword(ctx context.Context, currentPassword *string, newPassword string) (bool, error) {
+	userID, err := r.requireUser(ctx)
+	if err != nil {
+		return false, err
+	}
+	var current string
+	if currentPassword != nil {
+		current = *currentPassword
+	}
+	if err := r.UserService.SetPassword(userID, current, newPassword); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// CreateSession handles the createSession mutation. Login only matches the email, so sessions are
+// issued to admins, never in exchange for login's fields; users sign in with a password instead.
+func (r *Resolver) CreateSession(ctx context.Context, userID int64) (*model.Session, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	user, err := r.UserService.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errUserNotFound
+	}
+	return r.newSession(user)
+}
+
+func (r *Resolver) newSession(user *model.User) (*model.Session, error) {
+	session, err := r.SessionService.CreateSession(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.Session{
+		Token:     session.Token,
+		UserID:    session.UserID,
+		ExpiresAt: session.ExpiresAt,
+		User:      user,
+	}, nil
+}
+
+// RevokeSession handles the revokeSession mutation
+func (r *Resolver) RevokeSession(ctx context.Context, token string) (bool, error) {
+	if err := r.SessionService.RevokeSession(token); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,165 @@
+package scheduler
+
+import (
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	defaultImageStableFor         = 3 * time.Second
+	defaultImageReconcileInterval = 5 * time.Minute
+	// defaultImageExclude skips hidden files and the partial files of common copy tools
+	defaultImageExclude = ".*,*.tmp,*.part,*.partial,*.crdownload,*.swp"
+)
+
+// imageSyncConfig configures which directories are watched and which of their files are ingested
+type imageSyncConfig struct {
+	roots []string
+	// stableFor is how long a file's size and modification time must stay unchanged before it is ingested
+	stableFor         time.Duration
+	reconcileInterval time.Duration
+	filter            pathFilter
+	completion        string
+}
+
+// imageSyncConfigFromEnv reads the watched roots from IMAGE_DIRS, a comma-separated list, and the older single IMAGE_DIR
+func imageSyncConfigFromEnv() imageSyncConfig {
+	config := imageSyncConfig{
+		stableFor:         defaultImageStableFor,
+		reconcileInterval: defaultImageReconcileInterval,
+		completion:        ingestCompletionFromEnv(),
+	}
+
+	seen := make(map[string]bool)
+	var roots []string
+	for _, root := range append(splitList(os.Getenv("IMAGE_DIRS")), os.Getenv("IMAGE_DIR")) {
+		if root == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(root); err == nil {
+			root = abs
+		}
+		root = filepath.Clean(root)
+		if !seen[root] {
+			seen[root] = true
+			roots = append(roots, root)
+		}
+	}
+	config.roots = withoutNestedRoots(roots)
+
+	durations := []struct {
+		name   string
+		target *time.Duration
+	}{
+		{"IMAGE_STABLE_FOR", &config.stableFor},
+		{"IMAGE_RECONCILE_INTERVAL", &config.reconcileInterval},
+	}
+	for _, d := range durations {
+		value := os.Getenv(d.name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			log.Printf("Invalid %s %q, using default %s", d.name, value, *d.target)
+			continue
+		}
+		*d.target = parsed
+	}
+
+	exclude, ok := os.LookupEnv("IMAGE_EXCLUDE")
+	if !ok {
+		exclude = defaultImageExclude
+	}
+	config.filter = pathFilter{
+		include: validPatterns("IMAGE_INCLUDE", splitList(os.Getenv("IMAGE_INCLUDE"))),
+		exclude: validPatterns("IMAGE_EXCLUDE", splitList(exclude)),
+	}
+	return config
+}
+
+// withoutNestedRoots drops roots inside another root, whose files the outer root would ingest a second time
+func withoutNestedRoots(roots []string) []string {
+	var result []string
+	for _, root := range roots {
+		nested := false
+		for _, other := range roots {
+			if other != root && isWithin(other, root) {
+				log.Printf("Ignoring image directory %s inside %s, which already watches it", root, other)
+				nested = true
+				break
+			}
+		}
+		if !nested {
+			result = append(result, root)
+		}
+	}
+	return result
+}
+
+// isWithin reports whether path is dir or inside it
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func validPatterns(name string, patterns []string) []string {
+	var valid []string
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			log.Printf("Invalid %s pattern %q, ignoring it", name, pattern)
+			continue
+		}
+		valid = append(valid, pattern)
+	}
+	return valid
+}
+
+// pathFilter selects files by glob patterns on their slash-separated path relative to the watched root.
+// A pattern without a slash matches the base name at any depth, one with a slash matches the whole relative path.
+type pathFilter struct {
+	// include, when not empty, limits ingestion to matching files
+	include []string
+	// exclude skips matching files and directories and wins over include
+	exclude []string
+}
+
+func (f pathFilter) excluded(rel string) bool {
+	return matchAny(f.exclude, rel)
+}
+
+// includes reports whether a file should be ingested
+func (f pathFilter) includes(rel string) bool {
+	if f.excluded(rel) {
+		return false
+	}
+	return len(f.include) == 0 || matchAny(f.include, rel)
+}
+
+func matchAny(patterns []string, rel string) bool {
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range patterns {
+		target := rel
+		if !strings.Contains(pattern, "/") {
+			target = path.Base(rel)
+		}
+		if matched, _ := path.Match(pattern, target); matched {
+			return true
+		}
+	}
+	return false
+}
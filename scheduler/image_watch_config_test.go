@@ -0,0 +1,63 @@
+package scheduler
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestPathFilter(t *testing.T) {
+	filter := pathFilter{
+		include: []string{"*.jpg", "*.png", "scans/*.pdf"},
+		exclude: []string{".*", "*.part", "raw", "trips/private/*"},
+	}
+	for _, tc := range []struct {
+		rel  string
+		want bool
+	}{
+		{"a.jpg", true},
+		{"trips/2024/a.png", true},
+		{"a.gif", false},
+		// A pattern with a slash matches the whole relative path
+		{"scans/a.pdf", true},
+		{"trips/scans/a.pdf", false},
+		{"a.pdf", false},
+		// Exclusions win over inclusions at any depth
+		{".hidden.jpg", false},
+		{"trips/a.jpg.part", false},
+		{"trips/private/a.jpg", false},
+	} {
+		if got := filter.includes(filepath.FromSlash(tc.rel)); got != tc.want {
+			t.Errorf("includes(%q) = %v, want %v", tc.rel, got, tc.want)
+		}
+	}
+
+	// Excluded directories are not descended into
+	if !filter.excluded("raw") || !filter.excluded(filepath.FromSlash("trips/raw")) || !filter.excluded(filepath.FromSlash("trips/private/nested")) || filter.excluded("trips") {
+		t.Error("directory exclusion does not match the base name")
+	}
+
+	// Without include patterns every file that is not excluded is ingested
+	if !(pathFilter{exclude: []string{".*"}}).includes("a.heic") {
+		t.Error("an empty include list rejected a file")
+	}
+}
+
+func TestImageSyncConfigRejectsNestedRoots(t *testing.T) {
+	base := t.TempDir()
+	photos := filepath.Join(base, "photos")
+	t.Setenv("IMAGE_DIRS", photos+","+filepath.Join(photos, "trips")+","+filepath.Join(base, "photos-2")+","+photos+string(filepath.Separator))
+	t.Setenv("IMAGE_DIR", filepath.Join(photos, "trips", ".."))
+
+	config := imageSyncConfigFromEnv()
+	if want := []string{photos, filepath.Join(base, "photos-2")}; !slices.Equal(config.roots, want) {
+		t.Fatalf("roots %v, want %v", config.roots, want)
+	}
+}
+
+func TestWithoutNestedRoots(t *testing.T) {
+	roots := withoutNestedRoots([]string{"/srv/a/b", "/srv/a", "/srv/ab", "/srv/c"})
+	if want := []string{"/srv/a", "/srv/ab", "/srv/c"}; !slices.Equal(roots, want) {
+		t.Fatalf("got %v, want %v", roots, want)
+	}
+}
@@ -0,0 +1,263 @@
+package scheduler
+
+import (
+	"blog-fanchiikawa-service/service"
+	"context"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// imageStabilityCheckInterval is how often files waiting to settle are checked
+	imageStabilityCheckInterval = time.Second
+	// imageWatchEventBuffer absorbs bursts of events while a file is being ingested
+	imageWatchEventBuffer = 4096
+)
+
+// candidateFile is a file seen in a watched root that waits for its size to stop changing
+type candidateFile struct {
+	staging     *ingestStaging
+	size        int64
+	modTime     time.Time
+	stableSince time.Time
+}
+
+// imageWatcher ingests files from the watched roots once they are complete. Filesystem notifications
+// report new files straight away and a periodic reconciliation walk catches anything they missed.
+// Everything runs on one goroutine, so files are ingested one at a time.
+type imageWatcher struct {
+	config       imageSyncConfig
+	mediaService service.MediaService
+	stagings     []*ingestStaging
+	watcher      *fsnotify.Watcher
+	candidates   map[string]*candidateFile
+}
+
+func newImageWatcher(config imageSyncConfig, mediaService service.MediaService) *imageWatcher {
+	w := &imageWatcher{
+		config:       config,
+		mediaService: mediaService,
+		candidates:   make(map[string]*candidateFile),
+	}
+	for _, root := range config.roots {
+		w.stagings = append(w.stagings, newIngestStaging(root, config.completion))
+	}
+	return w
+}
+
+// run watches the roots until ctx is done
+func (w *imageWatcher) run(ctx context.Context) {
+	watcher, err := fsnotify.NewBufferedWatcher(imageWatchEventBuffer)
+	if err != nil {
+		log.Printf("Failed to start file watcher, relying on reconciliation every %s: %v", w.config.reconcileInterval, err)
+	} else {
+		w.watcher = watcher
+		defer watcher.Close()
+	}
+
+	// Watches are added during reconciliation, so roots created later are picked up by the next walk
+	w.reconcile()
+
+	stabilityTicker := time.NewTicker(imageStabilityCheckInterval)
+	defer stabilityTicker.Stop()
+	reconcileTicker := time.NewTicker(w.config.reconcileInterval)
+	defer reconcileTicker.Stop()
+
+	var events chan fsnotify.Event
+	var watchErrors chan error
+	if w.watcher != nil {
+		events = w.watcher.Events
+		watchErrors = w.watcher.Errors
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			w.handleEvent(event)
+		case err, ok := <-watchErrors:
+			if !ok {
+				watchErrors = nil
+				continue
+			}
+			log.Printf("File watcher error: %v", err)
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				// Events were dropped, so walk the roots to find the files they announced
+				w.reconcile()
+			}
+		case <-stabilityTicker.C:
+			w.ingestStable()
+		case <-reconcileTicker.C:
+			w.reconcile()
+		}
+	}
+}
+
+// reconcile finishes interrupted ingestions, watches every directory and queues every file of the roots
+func (w *imageWatcher) reconcile() {
+	log.Println("Image reconciliation starting...")
+	for _, staging := range w.stagings {
+		if _, err := os.Stat(staging.root); err != nil {
+			log.Printf("Cannot read watched directory %s: %v", staging.root, err)
+			continue
+		}
+		if err := staging.prepare(); err != nil {
+			log.Printf("Failed to create staging directories in %s: %v", staging.root, err)
+			continue
+		}
+
+		// Files left in processing/ by an interrupted run were already stable when they were staged
+		for _, rel := range staging.interrupted() {
+			log.Printf("Resuming interrupted ingestion of %s", filepath.Join(staging.root, rel))
+			staging.ingest(w.mediaService, rel)
+		}
+
+		w.scan(staging, staging.root)
+	}
+	log.Println("Image reconciliation finished...")
+}
+
+// scan watches dir and its subdirectories and queues their files
+func (w *imageWatcher) scan(staging *ingestStaging, dir string) {
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			log.Printf("Error accessing %s: %v", path, err)
+			return nil // continue traversing other files
+		}
+		rel, relErr := filepath.Rel(staging.root, path)
+		if relErr != nil {
+			return nil
+		}
+
+		if d.IsDir() {
+			if staging.isStagingDir(path) || (rel != "." && w.config.filter.excluded(rel)) {
+				return filepath.SkipDir
+			}
+			w.watch(path)
+			return nil
+		}
+		if d.Type().IsRegular() && w.config.filter.includes(rel) {
+			w.queue(staging, path)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to traverse %s: %v", dir, err)
+	}
+}
+
+func (w *imageWatcher) watch(dir string) {
+	if w.watcher == nil {
+		return
+	}
+	if err := w.watcher.Add(dir); err != nil {
+		log.Printf("Failed to watch %s: %v", dir, err)
+	}
+}
+
+// handleEvent queues created or written files and scans new directories
+func (w *imageWatcher) handleEvent(event fsnotify.Event) {
+	if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) {
+		return
+	}
+	staging := w.stagingFor(event.Name)
+	if staging == nil || staging.isStagingDir(event.Name) {
+		return
+	}
+
+	info, err := os.Lstat(event.Name)
+	if err != nil {
+		return
+	}
+	rel, err := filepath.Rel(staging.root, event.Name)
+	if err != nil {
+		return
+	}
+	if info.IsDir() {
+		// Files may already be inside a directory that was moved in or created before it was watched
+		if event.Has(fsnotify.Create) && !w.config.filter.excluded(rel) {
+			w.scan(staging, event.Name)
+		}
+		return
+	}
+	if info.Mode().IsRegular() && w.config.filter.includes(rel) {
+		w.queue(staging, event.Name)
+	}
+}
+
+// stagingFor returns the staging of the watched root containing path; roots never overlap, so there is at most one
+func (w *imageWatcher) stagingFor(path string) *ingestStaging {
+	for _, staging := range w.stagings {
+		if !isWithin(staging.root, path) {
+			continue
+		}
+		// Files inside the root's staging directories are not new files
+		for _, dir := range []string{processingDir, failedDir, archiveDir} {
+			if isWithin(staging.dir(dir), path) && filepath.Clean(path) != staging.dir(dir) {
+				return nil
+			}
+		}
+		return staging
+	}
+	return nil
+}
+
+func (w *imageWatcher) queue(staging *ingestStaging, path string) {
+	if _, ok := w.candidates[path]; ok {
+		return
+	}
+	w.candidates[path] = &candidateFile{staging: staging, size: -1}
+}
+
+// ingestStable ingests the queued files whose size and modification time have not changed for stableFor
+func (w *imageWatcher) ingestStable() {
+	if len(w.candidates) == 0 {
+		return
+	}
+
+	now := time.Now()
+	var stable []string
+	for path, candidate := range w.candidates {
+		info, err := os.Lstat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			// Removed or replaced before it settled
+			delete(w.candidates, path)
+			continue
+		}
+		if info.Size() != candidate.size || !info.ModTime().Equal(candidate.modTime) {
+			candidate.size = info.Size()
+			candidate.modTime = info.ModTime()
+			candidate.stableSince = now
+			continue
+		}
+		if now.Sub(candidate.stableSince) >= w.config.stableFor {
+			stable = append(stable, path)
+		}
+	}
+
+	sort.Strings(stable)
+	for _, path := range stable {
+		candidate := w.candidates[path]
+		delete(w.candidates, path)
+
+		log.Printf("📄 File: %s (Size: %d bytes)", path, candidate.size)
+		rel, err := candidate.staging.stage(path)
+		if err != nil {
+			log.Printf("Failed to stage %s, leaving it for the next reconciliation: %v", path, err)
+			continue
+		}
+		candidate.staging.ingest(w.mediaService, rel)
+	}
+}
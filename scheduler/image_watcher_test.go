@@ -0,0 +1,120 @@
+package scheduler
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestStagingFor(t *testing.T) {
+	base := t.TempDir()
+	photos := filepath.Join(base, "photos")
+	scans := filepath.Join(base, "photos-scans")
+	w := newImageWatcher(imageSyncConfig{roots: []string{photos, scans}}, &fakeFileIngest{})
+
+	for _, tc := range []struct {
+		path string
+		want string
+	}{
+		{filepath.Join(photos, "a.jpg"), photos},
+		{filepath.Join(photos, "trips", "a.jpg"), photos},
+		{filepath.Join(scans, "a.pdf"), scans},
+		{filepath.Join(base, "a.jpg"), ""},
+		// Staged files are not new files, but the staging directories themselves belong to their root
+		{filepath.Join(photos, processingDir, "a.jpg"), ""},
+		{filepath.Join(photos, archiveDir, "trips", "a.jpg"), ""},
+		{filepath.Join(scans, failedDir, "a.pdf"), ""},
+		{filepath.Join(photos, processingDir), photos},
+		{filepath.Join(photos, "processing-old", "a.jpg"), photos},
+	} {
+		var got string
+		if staging := w.stagingFor(tc.path); staging != nil {
+			got = staging.root
+		}
+		if got != tc.want {
+			t.Errorf("stagingFor(%s) = %q, want %q", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestIngestStableWaitsForFilesToSettle(t *testing.T) {
+	root := t.TempDir()
+	media := &fakeFileIngest{}
+	w := newImageWatcher(imageSyncConfig{roots: []string{root}, stableFor: time.Hour, completion: completionDelete}, media)
+	staging := w.stagings[0]
+	if err := staging.prepare(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(root, "a.jpg")
+	writeFile(t, path, "partial")
+	w.queue(staging, path)
+
+	// The first check only records the size
+	w.ingestStable()
+	candidate := w.candidates[path]
+	if candidate == nil || candidate.size != int64(len("partial")) || len(media.ingested) > 0 {
+		t.Fatalf("candidate %+v after the first check, ingested %v", candidate, media.ingested)
+	}
+
+	// A file that grows starts its stability window again
+	candidate.stableSince = time.Now().Add(-2 * time.Hour)
+	writeFile(t, path, "partial and the rest")
+	w.ingestStable()
+	if len(media.ingested) > 0 || time.Since(candidate.stableSince) > time.Minute {
+		t.Fatalf("a changing file was ingested or kept its window: %+v", candidate)
+	}
+
+	// An unchanged file inside the window waits
+	w.ingestStable()
+	if len(media.ingested) > 0 {
+		t.Fatal("a file was ingested before it was stable for long enough")
+	}
+
+	// Once the window has passed it is ingested
+	candidate.stableSince = time.Now().Add(-2 * time.Hour)
+	w.ingestStable()
+	if !slices.Equal(media.ingested, []string{path}) || !slices.Equal(media.contents, []string{"partial and the rest"}) {
+		t.Fatalf("ingested %v with %v", media.ingested, media.contents)
+	}
+	if len(w.candidates) > 0 {
+		t.Fatalf("candidates %v left after ingestion", w.candidates)
+	}
+	assertMissing(t, path)
+}
+
+func TestIngestStableDropsRemovedFiles(t *testing.T) {
+	root := t.TempDir()
+	w := newImageWatcher(imageSyncConfig{roots: []string{root}, stableFor: time.Hour}, &fakeFileIngest{})
+	path := filepath.Join(root, "a.jpg")
+	writeFile(t, path, "a")
+	w.queue(w.stagings[0], path)
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+
+	w.ingestStable()
+	if len(w.candidates) > 0 {
+		t.Fatalf("a removed file is still queued: %v", w.candidates)
+	}
+}
+
+func TestReconcileSkipsStagingDirectories(t *testing.T) {
+	root := t.TempDir()
+	w := newImageWatcher(imageSyncConfig{roots: []string{root}, stableFor: time.Hour, filter: pathFilter{exclude: []string{".*"}}}, &fakeFileIngest{})
+	staging := w.stagings[0]
+	writeFile(t, filepath.Join(root, "trips", "a.jpg"), "a")
+	writeFile(t, filepath.Join(root, ".hidden.jpg"), "hidden")
+	writeFile(t, filepath.Join(staging.dir(archiveDir), "b.jpg"), "b")
+	writeFile(t, filepath.Join(staging.dir(failedDir), "c.jpg"), "c")
+
+	w.reconcile()
+	var queued []string
+	for path := range w.candidates {
+		queued = append(queued, path)
+	}
+	if want := []string{filepath.Join(root, "trips", "a.jpg")}; !slices.Equal(queued, want) {
+		t.Fatalf("queued %v, want %v", queued, want)
+	}
+}
@@ -0,0 +1,191 @@
+package scheduler
+
+import (
+	"blog-fanchiikawa-service/service"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Staging directories inside IMAGE_DIR; the sync never picks up new files from them
+const (
+	processingDir = "processing"
+	failedDir     = "failed"
+	archiveDir    = "archive"
+)
+
+// Ingest completion policies for IMAGE_SYNC_COMPLETED
+const (
+	completionDelete  = "delete"
+	completionArchive = "archive"
+)
+
+// failureSidecarSuffix is appended to a failed file's name for the JSON file describing the failure
+const failureSidecarSuffix = ".error.json"
+
+func ingestCompletionFromEnv() string {
+	switch completion := os.Getenv("IMAGE_SYNC_COMPLETED"); completion {
+	case "", completionDelete:
+		return completionDelete
+	case completionArchive:
+		return completionArchive
+	default:
+		log.Printf("Invalid IMAGE_SYNC_COMPLETED %q, using default %s", completion, completionDelete)
+		return completionDelete
+	}
+}
+
+// ingestStaging moves files of one root directory through processing/ into archive/ or failed/,
+// so a file is only removed from disk once its image row exists and never lost on failure
+type ingestStaging struct {
+	root       string
+	completion string
+}
+
+// ingestFailure is the sidecar written next to a file in failed/
+type ingestFailure struct {
+	File     string    `json:"file"`
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failedAt"`
+}
+
+func newIngestStaging(root, completion string) *ingestStaging {
+	return &ingestStaging{root: filepath.Clean(root), completion: completion}
+}
+
+func (s *ingestStaging) dir(name string) string {
+	return filepath.Join(s.root, name)
+}
+
+// prepare creates the staging directories
+func (s *ingestStaging) prepare() error {
+	for _, name := range []string{processingDir, failedDir, archiveDir} {
+		if err := os.MkdirAll(s.dir(name), 0o755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ingestStaging) isStagingDir(path string) bool {
+	path = filepath.Clean(path)
+	return path == s.dir(processingDir) || path == s.dir(failedDir) || path == s.dir(archiveDir)
+}
+
+// interrupted lists the files still in processing/, relative to it
+func (s *ingestStaging) interrupted() []string {
+	var files []string
+	processing := s.dir(processingDir)
+	err := filepath.WalkDir(processing, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			log.Printf("Error accessing %s: %v\n", path, err)
+			return nil
+		}
+		if d.Type().IsRegular() {
+			rel, err := filepath.Rel(processing, path)
+			if err == nil {
+				files = append(files, rel)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to traverse %s: %v\n", processing, err)
+	}
+	return files
+}
+
+// stage moves a file from the root into processing/, keeping its relative path, and returns that path
+func (s *ingestStaging) stage(path string) (string, error) {
+	rel, err := filepath.Rel(s.root, path)
+	if err != nil {
+		return "", err
+	}
+	target := filepath.Join(s.dir(processingDir), rel)
+	if _, err := os.Lstat(target); err == nil {
+		return "", fmt.Errorf("%s is already being processed", rel)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.Rename(path, target); err != nil {
+		return "", err
+	}
+	return rel, nil
+}
+
+// ingest ingests a staged file, then archives or deletes it, or moves it to failed/ with an error sidecar
+func (s *ingestStaging) ingest(mediaService service.MediaService, rel string) {
+	staged := filepath.Join(s.dir(processingDir), rel)
+	origin := filepath.Join(s.root, rel)
+
+	result, err := mediaService.IngestFile(staged, origin, 0)
+	if err != nil {
+		log.Printf("Failed to ingest %s: %v\n", origin, err)
+		s.fail(rel, err)
+		return
+	}
+	if result.Duplicate {
+		log.Printf("%s has the same content as image ID %d\n", origin, result.Image.ID)
+	}
+
+	if s.completion == completionArchive {
+		if _, err := s.move(staged, filepath.Join(s.dir(archiveDir), rel)); err != nil {
+			log.Printf("Failed to archive %s: %v\n", origin, err)
+		}
+		return
+	}
+	if err := os.Remove(staged); err != nil {
+		log.Printf("Failed to delete ingested %s: %v\n", staged, err)
+	}
+}
+
+// fail moves a staged file into failed/ and writes the error next to it
+func (s *ingestStaging) fail(rel string, cause error) {
+	staged := filepath.Join(s.dir(processingDir), rel)
+	// The sidecar goes next to the file under the name it was moved to, so an earlier failure keeps its own
+	target, err := s.move(staged, filepath.Join(s.dir(failedDir), rel))
+	if err != nil {
+		// The file stays in processing/ and is retried by the next sync
+		log.Printf("Failed to move %s to %s: %v\n", staged, failedDir, err)
+		return
+	}
+
+	content, err := json.MarshalIndent(ingestFailure{
+		File:     filepath.Join(s.root, rel),
+		Error:    cause.Error(),
+		FailedAt: time.Now(),
+	}, "", "  ")
+	if err != nil {
+		log.Printf("Failed to encode failure of %s: %v\n", rel, err)
+		return
+	}
+	sidecar := target + failureSidecarSuffix
+	if err := os.WriteFile(sidecar+".tmp", content, 0o644); err != nil {
+		log.Printf("Failed to write %s: %v\n", sidecar, err)
+		return
+	}
+	if err := os.Rename(sidecar+".tmp", sidecar); err != nil {
+		log.Printf("Failed to write %s: %v\n", sidecar, err)
+	}
+}
+
+// move renames a file into place, adding a timestamp to its name when the target already exists,
+// and returns the path it was moved to
+func (s *ingestStaging) move(from, to string) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(to), 0o755); err != nil {
+		return "", err
+	}
+	if _, err := os.Lstat(to); err == nil {
+		ext := filepath.Ext(to)
+		to = fmt.Sprintf("%s.%d%s", to[:len(to)-len(ext)], time.Now().UnixNano(), ext)
+	}
+	if err := os.Rename(from, to); err != nil {
+		return "", err
+	}
+	return to, nil
+}
@@ -0,0 +1,232 @@
+package scheduler
+
+import (
+	"blog-fanchiikawa-service/db"
+	"blog-fanchiikawa-service/service"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fakeFileIngest is a MediaService whose IngestFile fails for the origins in failures
+type fakeFileIngest struct {
+	service.MediaService
+	failures map[string]error
+	// ingested are the origins handed to IngestFile, and contents what was read from the staged file
+	ingested []string
+	contents []string
+}
+
+func (f *fakeFileIngest) IngestFile(path, originFilename string, ownerID int64) (*service.IngestResult, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f.ingested = append(f.ingested, originFilename)
+	f.contents = append(f.contents, string(content))
+	if err := f.failures[originFilename]; err != nil {
+		return nil, err
+	}
+	return &service.IngestResult{Image: &db.Image{ID: int64(len(f.ingested))}}, nil
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertMissing(t *testing.T, path string) {
+	t.Helper()
+	if _, err := os.Lstat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("%s still exists: %v", path, err)
+	}
+}
+
+func assertContent(t *testing.T, path, want string) {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Errorf("read %s: %v", path, err)
+		return
+	}
+	if string(content) != want {
+		t.Errorf("%s holds %q, want %q", path, content, want)
+	}
+}
+
+// filesIn lists the regular files under dir relative to it
+func filesIn(t *testing.T, dir string) []string {
+	t.Helper()
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			rel, _ := filepath.Rel(dir, path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// stagedIngest stages and ingests one file like the watcher does once it is stable
+func stagedIngest(t *testing.T, staging *ingestStaging, media service.MediaService, path string) {
+	t.Helper()
+	rel, err := staging.stage(path)
+	if err != nil {
+		t.Fatalf("stage %s: %v", path, err)
+	}
+	staging.ingest(media, rel)
+}
+
+func TestIngestStagingDeletesIngestedFiles(t *testing.T) {
+	root := t.TempDir()
+	staging := newIngestStaging(root, completionDelete)
+	if err := staging.prepare(); err != nil {
+		t.Fatal(err)
+	}
+	source := filepath.Join(root, "trips", "a.jpg")
+	writeFile(t, source, "a")
+
+	media := &fakeFileIngest{}
+	stagedIngest(t, staging, media, source)
+
+	if !slices.Equal(media.ingested, []string{source}) || !slices.Equal(media.contents, []string{"a"}) {
+		t.Fatalf("ingested %v with %v, want the file under its original path", media.ingested, media.contents)
+	}
+	assertMissing(t, source)
+	for _, dir := range []string{processingDir, failedDir, archiveDir} {
+		if files := filesIn(t, staging.dir(dir)); len(files) > 0 {
+			t.Errorf("%s holds %v, want it empty", dir, files)
+		}
+	}
+}
+
+func TestIngestStagingArchivesWithoutOverwriting(t *testing.T) {
+	root := t.TempDir()
+	staging := newIngestStaging(root, completionArchive)
+	if err := staging.prepare(); err != nil {
+		t.Fatal(err)
+	}
+	// The same name was archived by an earlier run
+	archived := filepath.Join(staging.dir(archiveDir), "trips", "a.jpg")
+	writeFile(t, archived, "old")
+	source := filepath.Join(root, "trips", "a.jpg")
+	writeFile(t, source, "new")
+
+	stagedIngest(t, staging, &fakeFileIngest{}, source)
+
+	assertMissing(t, source)
+	assertContent(t, archived, "old")
+	files := filesIn(t, staging.dir(archiveDir))
+	if len(files) != 2 {
+		t.Fatalf("archive holds %v, want both files", files)
+	}
+	for _, file := range files {
+		if file != "trips/a.jpg" {
+			if !strings.HasPrefix(file, "trips/a.") || !strings.HasSuffix(file, ".jpg") {
+				t.Fatalf("renamed archive file %s, want a timestamp before the extension", file)
+			}
+			assertContent(t, filepath.Join(staging.dir(archiveDir), file), "new")
+		}
+	}
+	if files := filesIn(t, staging.dir(processingDir)); len(files) > 0 {
+		t.Errorf("processing holds %v, want it empty", files)
+	}
+}
+
+func TestIngestStagingKeepsFailedFiles(t *testing.T) {
+	root := t.TempDir()
+	staging := newIngestStaging(root, completionDelete)
+	if err := staging.prepare(); err != nil {
+		t.Fatal(err)
+	}
+	source := filepath.Join(root, "trips", "a.jpg")
+	writeFile(t, source, "a")
+
+	media := &fakeFileIngest{failures: map[string]error{source: errors.New("failed to upload to S3: RequestTimeout")}}
+	stagedIngest(t, staging, media, source)
+
+	failed := filepath.Join(staging.dir(failedDir), "trips", "a.jpg")
+	assertMissing(t, source)
+	assertContent(t, failed, "a")
+
+	content, err := os.ReadFile(failed + failureSidecarSuffix)
+	if err != nil {
+		t.Fatalf("read sidecar: %v", err)
+	}
+	var failure ingestFailure
+	if err := json.Unmarshal(content, &failure); err != nil {
+		t.Fatalf("decode sidecar: %v", err)
+	}
+	if failure.File != source || !strings.Contains(failure.Error, "RequestTimeout") || failure.FailedAt.IsZero() {
+		t.Fatalf("unexpected sidecar %+v", failure)
+	}
+
+	// A second failure of the same name keeps the first file and its sidecar
+	writeFile(t, source, "b")
+	stagedIngest(t, staging, media, source)
+	assertContent(t, failed, "a")
+	if files := filesIn(t, staging.dir(failedDir)); len(files) != 4 {
+		t.Fatalf("failed holds %v, want both files and both sidecars", files)
+	}
+}
+
+func TestIngestStagingRefusesNameAlreadyProcessing(t *testing.T) {
+	root := t.TempDir()
+	staging := newIngestStaging(root, completionDelete)
+	if err := staging.prepare(); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(staging.dir(processingDir), "a.jpg"), "interrupted")
+	source := filepath.Join(root, "a.jpg")
+	writeFile(t, source, "new")
+
+	if _, err := staging.stage(source); err == nil {
+		t.Fatal("staged a file over one still being processed")
+	}
+	assertContent(t, source, "new")
+	assertContent(t, filepath.Join(staging.dir(processingDir), "a.jpg"), "interrupted")
+}
+
+func TestReconcileResumesInterruptedIngestion(t *testing.T) {
+	root := t.TempDir()
+	// A previous run stopped after staging these files
+	staging := newIngestStaging(root, completionArchive)
+	writeFile(t, filepath.Join(staging.dir(processingDir), "a.jpg"), "a")
+	writeFile(t, filepath.Join(staging.dir(processingDir), "trips", "b.jpg"), "b")
+	writeFile(t, filepath.Join(root, "c.jpg"), "c")
+
+	media := &fakeFileIngest{failures: map[string]error{filepath.Join(root, "trips", "b.jpg"): errors.New("upload failed")}}
+	w := newImageWatcher(imageSyncConfig{roots: []string{root}, completion: completionArchive}, media)
+	w.reconcile()
+
+	slices.Sort(media.ingested)
+	if want := []string{filepath.Join(root, "a.jpg"), filepath.Join(root, "trips", "b.jpg")}; !slices.Equal(media.ingested, want) {
+		t.Fatalf("ingested %v, want the interrupted files %v", media.ingested, want)
+	}
+	if files := filesIn(t, staging.dir(processingDir)); len(files) > 0 {
+		t.Errorf("processing holds %v, want it empty", files)
+	}
+	assertContent(t, filepath.Join(staging.dir(archiveDir), "a.jpg"), "a")
+	assertContent(t, filepath.Join(staging.dir(failedDir), "trips", "b.jpg"), "b")
+
+	// New files wait in the root until they are stable
+	if _, ok := w.candidates[filepath.Join(root, "c.jpg")]; !ok || len(w.candidates) != 1 {
+		t.Errorf("queued %v, want only c.jpg", w.candidates)
+	}
+}
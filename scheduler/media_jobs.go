@@ -0,0 +1,21 @@
+package scheduler
+
+import (
+	"log"
+	"time"
+)
+
+// MediaJobs runs due label and text detection jobs, first queueing jobs for images processed before jobs existed
+func (scheduler *Scheduler) MediaJobs() {
+	if err := scheduler.mediaService.EnqueueMissingJobs(); err != nil {
+		log.Printf("Failed to enqueue missing media jobs: %v", err)
+	}
+
+	scheduler.ScheduleAtFixedRate("mediaJobs", func() {
+		log.Println("Media jobs starting...")
+		if err := scheduler.mediaService.ProcessDueJobs(); err != nil {
+			log.Printf("Failed to process media jobs: %v", err)
+		}
+		log.Println("Media jobs finished...")
+	}, 10*time.Second)
+}
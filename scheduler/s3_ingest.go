@@ -0,0 +1,218 @@
+package scheduler
+
+import (
+	"blog-fanchiikawa-service/sdk"
+	"blog-fanchiikawa-service/service"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultS3IngestPrefix            = "warehouse/image/"
+	defaultS3IngestReconcileInterval = 10 * time.Minute
+	// s3IngestMaxMessages and s3IngestWaitSeconds are the SQS limits for one long poll
+	s3IngestMaxMessages = 10
+	s3IngestWaitSeconds = 20
+	// s3IngestRetryDelay is how long to wait after the queue could not be read
+	s3IngestRetryDelay = 5 * time.Second
+)
+
+// s3IngestConfig configures the registration of objects uploaded straight to S3
+type s3IngestConfig struct {
+	bucket string
+	prefix string
+	// queueURL is the SQS queue receiving the bucket's event notifications; without it only the reconciler runs
+	queueURL          string
+	reconcileInterval time.Duration
+}
+
+func s3IngestConfigFromEnv() s3IngestConfig {
+	config := s3IngestConfig{
+		bucket:            os.Getenv("S3_INGEST_BUCKET"),
+		prefix:            defaultS3IngestPrefix,
+		queueURL:          os.Getenv("S3_INGEST_QUEUE_URL"),
+		reconcileInterval: defaultS3IngestReconcileInterval,
+	}
+	if config.bucket == "" {
+		// generateS3UploadUrl issues its URLs for this bucket
+		config.bucket = os.Getenv("REKOGNITION_S3_BUCKET")
+	}
+	if prefix, ok := os.LookupEnv("S3_INGEST_PREFIX"); ok {
+		config.prefix = prefix
+	}
+	if value := os.Getenv("S3_INGEST_RECONCILE_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval <= 0 {
+			log.Printf("Invalid S3_INGEST_RECONCILE_INTERVAL %q, using default %s", value, config.reconcileInterval)
+		} else {
+			config.reconcileInterval = interval
+		}
+	}
+	return config
+}
+
+// S3Ingest registers objects that browsers upload straight to S3 as images, from the bucket's event
+// notifications and from a periodic listing of the upload prefix that catches anything the events missed
+func (scheduler *Scheduler) S3Ingest() {
+	config := s3IngestConfigFromEnv()
+	if config.bucket == "" {
+		log.Println("S3 ingestion disabled: no S3_INGEST_BUCKET or REKOGNITION_S3_BUCKET")
+		return
+	}
+	if config.queueURL == "" {
+		log.Printf("S3_INGEST_QUEUE_URL not set, registering s3://%s/%s every %s only", config.bucket, config.prefix, config.reconcileInterval)
+	}
+	go newS3Ingester(config, scheduler.mediaService).run(scheduler.ctx)
+}
+
+// s3Ingester handles events and reconciliation on one goroutine, so an object is never registered twice at once
+type s3Ingester struct {
+	config       s3IngestConfig
+	mediaService service.MediaService
+	// rejected holds keys left unregistered for being too large or duplicates, so the reconciler does not download
+	// them again; an event for the key, or a restart, checks it once more
+	rejected map[string]bool
+}
+
+func newS3Ingester(config s3IngestConfig, mediaService service.MediaService) *s3Ingester {
+	return &s3Ingester{config: config, mediaService: mediaService, rejected: make(map[string]bool)}
+}
+
+func (i *s3Ingester) run(ctx context.Context) {
+	i.reconcile(ctx)
+
+	reconcileTicker := time.NewTicker(i.config.reconcileInterval)
+	defer reconcileTicker.Stop()
+
+	for {
+		if i.config.queueURL == "" {
+			select {
+			case <-ctx.Done():
+				return
+			case <-reconcileTicker.C:
+				i.reconcile(ctx)
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-reconcileTicker.C:
+			i.reconcile(ctx)
+		default:
+		}
+		i.poll(ctx)
+	}
+}
+
+// poll waits for one batch of event notifications and handles it
+func (i *s3Ingester) poll(ctx context.Context) {
+	messages, err := sdk.ReceiveMessages(ctx, i.config.queueURL, s3IngestMaxMessages, s3IngestWaitSeconds)
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Failed to read S3 events from %s: %v", i.config.queueURL, err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(s3IngestRetryDelay):
+		}
+		return
+	}
+
+	for _, message := range messages {
+		if i.handleMessage(message) {
+			sdk.DeleteMessage(i.config.queueURL, message.ReceiptHandle)
+		}
+	}
+}
+
+// handleMessage registers the objects of one notification and reports whether the message can be deleted.
+// A message whose objects could not be registered reappears after the queue's visibility timeout.
+func (i *s3Ingester) handleMessage(message sdk.QueueMessage) bool {
+	events, err := sdk.ParseS3Events(message.Body)
+	if err != nil {
+		// Retrying cannot fix a malformed message
+		log.Printf("Dropping S3 event message %s: %v", message.ID, err)
+		return true
+	}
+
+	handled := true
+	for _, event := range events {
+		if !event.Created() || event.Bucket != i.config.bucket || !i.matches(event.Key) {
+			continue
+		}
+		if !i.ingest(event.Key) {
+			handled = false
+		}
+	}
+	return handled
+}
+
+// reconcile registers every object under the prefix that no image refers to yet
+func (i *s3Ingester) reconcile(ctx context.Context) {
+	log.Printf("S3 reconciliation of s3://%s/%s starting...", i.config.bucket, i.config.prefix)
+	registered := 0
+	err := sdk.ListObjects(i.config.bucket, i.config.prefix, func(objects []sdk.ObjectInfo) error {
+		keys := make([]string, 0, len(objects))
+		for _, object := range objects {
+			if i.matches(object.Key) {
+				keys = append(keys, object.Key)
+			}
+		}
+		unregistered, err := i.mediaService.GetUnregisteredObjectKeys(i.config.bucket, keys)
+		if err != nil {
+			return err
+		}
+		for _, key := range unregistered {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if i.rejected[key] {
+				continue
+			}
+			if i.ingest(key) {
+				registered++
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Printf("Failed to reconcile s3://%s/%s: %v", i.config.bucket, i.config.prefix, err)
+	}
+	log.Printf("S3 reconciliation finished, %d objects registered...", registered)
+}
+
+// matches reports whether a key is an uploaded file under the prefix rather than a folder placeholder
+func (i *s3Ingester) matches(key string) bool {
+	return strings.HasPrefix(key, i.config.prefix) && !strings.HasSuffix(key, "/")
+}
+
+// ingest registers one object and reports whether it is done with, including objects that no longer exist
+// or were rejected, so only transient failures are retried. Rejected objects are not the service's and stay in S3.
+func (i *s3Ingester) ingest(key string) bool {
+	delete(i.rejected, key)
+	result, err := i.mediaService.IngestObject(i.config.bucket, key)
+	switch {
+	case errors.Is(err, service.ErrUploadNotFound):
+		log.Printf("s3://%s/%s no longer exists, skipping it", i.config.bucket, key)
+		return true
+	case errors.Is(err, service.ErrUploadTooLarge):
+		log.Printf("s3://%s/%s exceeds the upload size limit, skipping it", i.config.bucket, key)
+		i.rejected[key] = true
+		return true
+	case err != nil:
+		log.Printf("Failed to register s3://%s/%s: %v", i.config.bucket, key, err)
+		return false
+	}
+	if result.Duplicate {
+		log.Printf("s3://%s/%s has the same content as image ID %d, skipping it", i.config.bucket, key, result.Image.ID)
+		i.rejected[key] = true
+	}
+	return true
+}
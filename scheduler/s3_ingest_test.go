@@ -0,0 +1,127 @@
+package scheduler
+
+import (
+	"blog-fanchiikawa-service/db"
+	"blog-fanchiikawa-service/sdk"
+	"blog-fanchiikawa-service/service"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// fakeIngest is a MediaService whose IngestObject answers from a table of keys
+type fakeIngest struct {
+	service.MediaService
+	results  map[string]error
+	ingested []string
+}
+
+var errDuplicate = errors.New("duplicate")
+
+func (f *fakeIngest) IngestObject(bucket, key string) (*service.IngestResult, error) {
+	f.ingested = append(f.ingested, key)
+	err := f.results[key]
+	if err == errDuplicate {
+		return &service.IngestResult{Image: &db.Image{ID: 7}, Duplicate: true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &service.IngestResult{Image: &db.Image{ID: 8}}, nil
+}
+
+func (f *fakeIngest) GetUnregisteredObjectKeys(bucket string, keys []string) ([]string, error) {
+	return keys, nil
+}
+
+func eventMessage(bucket string, eventName string, keys ...string) sdk.QueueMessage {
+	body := `{"Records":[`
+	for i, key := range keys {
+		if i > 0 {
+			body += ","
+		}
+		body += fmt.Sprintf(`{"eventName":%q,"s3":{"bucket":{"name":%q},"object":{"key":%q}}}`, eventName, bucket, key)
+	}
+	return sdk.QueueMessage{ID: "m1", Body: body + "]}"}
+}
+
+func TestHandleMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		message sdk.QueueMessage
+		results map[string]error
+		want    bool
+		// ingested are the keys handed to IngestObject
+		ingested []string
+	}{
+		{
+			name:     "registered",
+			message:  eventMessage("uploads", "ObjectCreated:Put", "warehouse/image/a.jpg"),
+			want:     true,
+			ingested: []string{"warehouse/image/a.jpg"},
+		},
+		{
+			name:     "gone, too large and duplicate objects are done with",
+			message:  eventMessage("uploads", "ObjectCreated:Put", "warehouse/image/gone.jpg", "warehouse/image/big.jpg", "warehouse/image/dup.jpg"),
+			results:  map[string]error{"warehouse/image/gone.jpg": service.ErrUploadNotFound, "warehouse/image/big.jpg": service.ErrUploadTooLarge, "warehouse/image/dup.jpg": errDuplicate},
+			want:     true,
+			ingested: []string{"warehouse/image/gone.jpg", "warehouse/image/big.jpg", "warehouse/image/dup.jpg"},
+		},
+		{
+			name:     "a transient failure keeps the message for a retry",
+			message:  eventMessage("uploads", "ObjectCreated:Put", "warehouse/image/a.jpg", "warehouse/image/b.jpg"),
+			results:  map[string]error{"warehouse/image/a.jpg": errors.New("S3 unavailable")},
+			want:     false,
+			ingested: []string{"warehouse/image/a.jpg", "warehouse/image/b.jpg"},
+		},
+		{
+			name:    "malformed messages are dropped",
+			message: sdk.QueueMessage{ID: "m1", Body: "not json"},
+			want:    true,
+		},
+		{
+			name:    "other buckets are ignored",
+			message: eventMessage("other", "ObjectCreated:Put", "warehouse/image/a.jpg"),
+			want:    true,
+		},
+		{
+			name:    "objects outside the prefix are ignored",
+			message: eventMessage("uploads", "ObjectCreated:Put", "warehouse/thumbnails/256/a.jpg", "warehouse/image/"),
+			want:    true,
+		},
+		{
+			name:    "removals are ignored",
+			message: eventMessage("uploads", "ObjectRemoved:Delete", "warehouse/image/a.jpg"),
+			want:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			media := &fakeIngest{results: tt.results}
+			ingester := newS3Ingester(s3IngestConfig{bucket: "uploads", prefix: defaultS3IngestPrefix}, media)
+			if got := ingester.handleMessage(tt.message); got != tt.want {
+				t.Errorf("handleMessage = %v, want %v", got, tt.want)
+			}
+			if fmt.Sprint(media.ingested) != fmt.Sprint(tt.ingested) {
+				t.Errorf("ingested %v, want %v", media.ingested, tt.ingested)
+			}
+		})
+	}
+}
+
+func TestRejectedObjectsAreNotIngestedAgain(t *testing.T) {
+	media := &fakeIngest{results: map[string]error{"warehouse/image/big.jpg": service.ErrUploadTooLarge, "warehouse/image/dup.jpg": errDuplicate}}
+	ingester := newS3Ingester(s3IngestConfig{bucket: "uploads", prefix: defaultS3IngestPrefix}, media)
+	ingester.handleMessage(eventMessage("uploads", "ObjectCreated:Put", "warehouse/image/big.jpg", "warehouse/image/dup.jpg"))
+	if !ingester.rejected["warehouse/image/big.jpg"] || !ingester.rejected["warehouse/image/dup.jpg"] {
+		t.Fatalf("rejected = %v", ingester.rejected)
+	}
+
+	// A new event for the key checks the object again, as it may have been overwritten
+	delete(media.results, "warehouse/image/dup.jpg")
+	ingester.handleMessage(eventMessage("uploads", "ObjectCreated:Put", "warehouse/image/dup.jpg"))
+	if ingester.rejected["warehouse/image/dup.jpg"] {
+		t.Fatal("a registered object is still remembered as rejected")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/anthropics/anthropic-sdk-go/option"
@@ -141,6 +142,91 @@ Format your response as a JSON object with this structure:
 	return &parsedResponse, nil
 }
 
+// DefaultCaptionModel describes images when CAPTION_MODEL is unset
+const DefaultCaptionModel = string(anthropic.ModelClaudeSonnet4_0)
+
+// ImageDescriptionRequest is an image with what detection already found in it
+type ImageDescriptionRequest struct {
+	ImageData []byte
+	// Labels are the names of the labels detected in the image
+	Labels []string
+	// Text is the text read from the image by OCR
+	Text string
+	// Languages are the language codes to describe the image in, such as "en" or "ja"
+	Languages []string
+	Model     string
+}
+
+// ImageDescription is the caption, long description and alt text of an image in one language
+type ImageDescription struct {
+	Language    string `json:"language"`
+	Caption     string `json:"caption"`
+	Description string `json:"description"`
+	AltText     string `json:"altText"`
+}
+
+// ImageDescriptionResponse holds one description per requested language
+type ImageDescriptionResponse struct {
+	Descriptions []ImageDescription `json:"descriptions"`
+}
+
+// DescribeImage writes a caption, a long description and alt text for an image in every requested language
+func (a *AnthropicService) DescribeImage(ctx context.Context, req ImageDescriptionRequest) (*ImageDescriptionResponse, error) {
+	systemPrompt := `You write accessible image descriptions for a blog. For each requested language write:
+- caption: one short sentence suitable under the image
+- description: two to four sentences describing the subject, setting and any visible text
+- altText: at most 125 characters, describing what matters for someone who cannot see the image, without starting with "Image of" or "Picture of"
+
+Use the detected labels and text only where the image confirms them. Do not guess names of people.
+
+Format your response as a JSON object with this structure and nothing else:
+{
+  "descriptions": [
+    {"language": "en", "caption": "...", "description": "...", "altText": "..."}
+  ]
+}`
+
+	userPrompt := fmt.Sprintf("Languages: %s", strings.Join(req.Languages, ", "))
+	if len(req.Labels) > 0 {
+		userPrompt += fmt.Sprintf("\n\nDetected labels: %s", strings.Join(req.Labels, ", "))
+	}
+	if req.Text != "" {
+		userPrompt += fmt.Sprintf("\n\nText found in the image:\n%s", req.Text)
+	}
+
+	imageBlock := anthropic.NewImageBlockBase64(detectImageFormat(req.ImageData), base64.StdEncoding.EncodeToString(req.ImageData))
+	message := anthropic.NewUserMessage(imageBlock, anthropic.NewTextBlock(userPrompt))
+
+	model := req.Model
+	if model == "" {
+		model = DefaultCaptionModel
+	}
+	response, err := a.client.Messages.New(ctx, anthropic.MessageNewParams{
+		Model:     anthropic.Model(model),
+		MaxTokens: int64(600 * len(req.Languages)),
+		Messages:  []anthropic.MessageParam{message},
+		System:    []anthropic.TextBlockParam{{Text: systemPrompt}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe image: %w", err)
+	}
+	if len(response.Content) == 0 || response.Content[0].Type != "text" {
+		return nil, fmt.Errorf("no text content in response")
+	}
+
+	// Models sometimes wrap the JSON in a code fence
+	responseText := strings.TrimSpace(response.Content[0].AsText().Text)
+	if start, end := strings.Index(responseText, "{"), strings.LastIndex(responseText, "}"); start >= 0 && end > start {
+		responseText = responseText[start : end+1]
+	}
+
+	var parsed ImageDescriptionResponse
+	if err := json.Unmarshal([]byte(responseText), &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse image descriptions: %w", err)
+	}
+	return &parsed, nil
+}
+
 // EncodeImageToBase64 encodes an image file to base64
 func EncodeImageToBase64(imageData []byte) string {
 	return base64.StdEncoding.EncodeToString(imageData)
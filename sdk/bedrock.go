@@ -0,0 +1,63 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/bedrockruntime"
+)
+
+var Bedrock *bedrockruntime.BedrockRuntime
+
+func InitBedrock() {
+	Bedrock = bedrockruntime.New(AWSSession)
+}
+
+// DefaultEmbeddingModel is Amazon Titan Text Embeddings V2, which accepts 256, 512 or 1024 dimensions
+const DefaultEmbeddingModel = "amazon.titan-embed-text-v2:0"
+
+type titanEmbeddingRequest struct {
+	InputText  string `json:"inputText"`
+	Dimensions int    `json:"dimensions"`
+	Normalize  bool   `json:"normalize"`
+}
+
+type titanEmbeddingResponse struct {
+	Embedding           []float32 `json:"embedding"`
+	InputTextTokenCount int       `json:"inputTextTokenCount"`
+}
+
+// EmbedText returns the unit-length embedding of text from a Titan text embedding model
+func EmbedText(ctx context.Context, modelID, text string, dimensions int) ([]float32, error) {
+	body, err := json.Marshal(titanEmbeddingRequest{
+		InputText:  text,
+		Dimensions: dimensions,
+		Normalize:  true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode embedding request: %w", err)
+	}
+
+	output, err := Bedrock.InvokeModelWithContext(ctx, &bedrockruntime.InvokeModelInput{
+		ModelId:     aws.String(modelID),
+		ContentType: aws.String("application/json"),
+		Accept:      aws.String("application/json"),
+		Body:        body,
+	})
+	if err != nil {
+		log.Printf("Failed to call InvokeModel on %s: %v", modelID, err)
+		return nil, fmt.Errorf("failed to embed text: %w", err)
+	}
+
+	var response titanEmbeddingResponse
+	if err := json.Unmarshal(output.Body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse embedding response: %w", err)
+	}
+	if len(response.Embedding) != dimensions {
+		return nil, fmt.Errorf("embedding model %s returned %d dimensions, expected %d", modelID, len(response.Embedding), dimensions)
+	}
+	return response.Embedding, nil
+}
@@ -0,0 +1,129 @@
+package sdk
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rekognition"
+)
+
+// ModerationLabel is an unsafe-content label from Rekognition's moderation taxonomy.
+// ParentName is empty for top-level categories such as "Explicit Nudity" or "Violence".
+type ModerationLabel struct {
+	Name       string  `json:"name"`
+	ParentName string  `json:"parentName"`
+	Confidence float64 `json:"confidence"`
+}
+
+// DetectModerationLabels detects unsafe content in S3 images; Rekognition supports JPEG and PNG
+func DetectModerationLabels(bucketName, objectKey string, minConfidence float64) ([]ModerationLabel, error) {
+	input := &rekognition.DetectModerationLabelsInput{
+		Image: &rekognition.Image{
+			S3Object: &rekognition.S3Object{
+				Bucket: aws.String(bucketName),
+				Name:   aws.String(objectKey),
+			},
+		},
+		MinConfidence: aws.Float64(minConfidence),
+	}
+
+	result, err := Rekognition.DetectModerationLabels(input)
+	if err != nil {
+		log.Printf("Failed to call DetectModerationLabels: %v", err)
+		return nil, fmt.Errorf("failed to call detect moderation labels: %w", err)
+	}
+
+	log.Printf("Image: s3://%s/%s has %d moderation labels", bucketName, objectKey, len(result.ModerationLabels))
+
+	labels := make([]ModerationLabel, 0, len(result.ModerationLabels))
+	for _, label := range result.ModerationLabels {
+		labels = append(labels, ModerationLabel{
+			Name:       aws.StringValue(label.Name),
+			ParentName: aws.StringValue(label.ParentName),
+			Confidence: aws.Float64Value(label.Confidence),
+		})
+	}
+	return labels, nil
+}
+
+// VideoModerationJob is the state of an asynchronous content moderation job
+type VideoModerationJob struct {
+	Status        string
+	StatusMessage string
+	Metadata      VideoMetadata
+	// Labels holds every moderation label seen in the video with its highest confidence once Status is SUCCEEDED
+	Labels []ModerationLabel
+}
+
+// StartVideoContentModeration starts an asynchronous content moderation job for a video in S3
+func StartVideoContentModeration(bucketName, objectKey string, minConfidence float64) (string, error) {
+	output, err := Rekognition.StartContentModeration(&rekognition.StartContentModerationInput{
+		Video:         rekognitionVideo(bucketName, objectKey),
+		MinConfidence: aws.Float64(minConfidence),
+	})
+	if err != nil {
+		log.Printf("Failed to call StartContentModeration: %v", err)
+		return "", videoStartError("content moderation", err)
+	}
+	log.Printf("Started Rekognition Video content moderation %s for s3://%s/%s", aws.StringValue(output.JobId), bucketName, objectKey)
+	return aws.StringValue(output.JobId), nil
+}
+
+// GetVideoContentModeration returns the status of a content moderation job and, once it has succeeded,
+// the moderation labels gathered from all result pages
+func GetVideoContentModeration(jobID string) (*VideoModerationJob, error) {
+	job := &VideoModerationJob{}
+	index := make(map[ModerationLabel]int)
+
+	var nextToken *string
+	for {
+		output, err := Rekognition.GetContentModeration(&rekognition.GetContentModerationInput{
+			JobId:       aws.String(jobID),
+			AggregateBy: aws.String(rekognition.ContentModerationAggregateBySegments),
+			SortBy:      aws.String(rekognition.ContentModerationSortByTimestamp),
+			MaxResults:  aws.Int64(videoResultPageSize),
+			NextToken:   nextToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get content moderation: %w", err)
+		}
+
+		job.Status = aws.StringValue(output.JobStatus)
+		job.StatusMessage = aws.StringValue(output.StatusMessage)
+		job.Metadata = toVideoMetadata(output.VideoMetadata)
+		if job.Status != VideoJobSucceeded {
+			if err := videoJobFailure(job.Status, job.StatusMessage); err != nil {
+				return nil, err
+			}
+			return job, nil
+		}
+
+		for _, detection := range output.ModerationLabels {
+			if detection.ModerationLabel == nil {
+				continue
+			}
+			// A label is usually seen in many segments; keep it once with its highest confidence
+			key := ModerationLabel{
+				Name:       aws.StringValue(detection.ModerationLabel.Name),
+				ParentName: aws.StringValue(detection.ModerationLabel.ParentName),
+			}
+			confidence := aws.Float64Value(detection.ModerationLabel.Confidence)
+			if i, ok := index[key]; ok {
+				job.Labels[i].Confidence = max(job.Labels[i].Confidence, confidence)
+				continue
+			}
+			index[key] = len(job.Labels)
+			key.Confidence = confidence
+			job.Labels = append(job.Labels, key)
+		}
+
+		nextToken = output.NextToken
+		if aws.StringValue(nextToken) == "" {
+			break
+		}
+	}
+
+	log.Printf("Rekognition Video job %s returned %d moderation labels over %d ms", jobID, len(job.Labels), job.Metadata.DurationMillis)
+	return job, nil
+}
@@ -14,8 +14,43 @@ func InitRekognition() {
 	Rekognition = rekognition.New(AWSSession)
 }
 
+// DetectLabelsOptions bounds the labels returned by DetectLabels
+type DetectLabelsOptions struct {
+	MinConfidence float64
+	MaxLabels     int64
+}
+
+// DefaultDetectLabelsOptions keeps labels with at least 75% confidence, at most 20 per image
+var DefaultDetectLabelsOptions = DetectLabelsOptions{
+	MinConfidence: 75.0,
+	MaxLabels:     20,
+}
+
+// BoundingBox is a box in ratios of the image width and height
+type BoundingBox struct {
+	Left   float64 `json:"left"`
+	Top    float64 `json:"top"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// LabelInstance is one occurrence of a label located in the image
+type LabelInstance struct {
+	Confidence  float64     `json:"confidence"`
+	BoundingBox BoundingBox `json:"boundingBox"`
+}
+
+// DetectedLabel is a label found in an image with its place in Rekognition's taxonomy
+type DetectedLabel struct {
+	Name       string          `json:"name"`
+	Confidence float64         `json:"confidence"`
+	Parents    []string        `json:"parents"`
+	Categories []string        `json:"categories"`
+	Instances  []LabelInstance `json:"instances"`
+}
+
 // DetectLabels detects labels in S3 images
-func DetectLabels(bucketName, objectKey string) ([]string, error) {
+func DetectLabels(bucketName, objectKey string, options DetectLabelsOptions) ([]DetectedLabel, error) {
 	// Build detect labels request
 	input := &rekognition.DetectLabelsInput{
 		Image: &rekognition.Image{
@@ -24,8 +59,8 @@ func DetectLabels(bucketName, objectKey string) ([]string, error) {
 				Name:   aws.String(objectKey),
 			},
 		},
-		MaxLabels:     aws.Int64(20),     // Return maximum 20 labels
-		MinConfidence: aws.Float64(75.0), // Minimum confidence 75%
+		MaxLabels:     aws.Int64(options.MaxLabels),
+		MinConfidence: aws.Float64(options.MinConfidence),
 	}
 
 	// Call API
@@ -39,45 +74,51 @@ func DetectLabels(bucketName, objectKey string) ([]string, error) {
 	log.Printf("Image: s3://%s/%s\n", bucketName, objectKey)
 	log.Printf("Detected %d labels:\n\n", len(result.Labels))
 
-	var results []string
+	var results []DetectedLabel
 	for i, label := range result.Labels {
-		log.Printf("%d. %s (Confidence: %.2f%%)\n",
-			i+1, *label.Name, *label.Confidence)
-
-		results = append(results, *label.Name)
+		detected := DetectedLabel{
+			Name:       aws.StringValue(label.Name),
+			Confidence: aws.Float64Value(label.Confidence),
+		}
+		log.Printf("%d. %s (Confidence: %.2f%%)\n", i+1, detected.Name, detected.Confidence)
 
-		// If there are subcategories, print them too
-		if len(label.Categories) > 0 {
-			log.Printf("   Category: ")
-			for j, category := range label.Categories {
-				if j > 0 {
-					log.Printf(", ")
-				}
-				log.Printf("%s", *category.Name)
-				results = append(results, *category.Name)
-			}
-			log.Println()
+		for _, parent := range label.Parents {
+			detected.Parents = append(detected.Parents, aws.StringValue(parent.Name))
+		}
+		for _, category := range label.Categories {
+			detected.Categories = append(detected.Categories, aws.StringValue(category.Name))
+		}
+		if len(detected.Parents) > 0 || len(detected.Categories) > 0 {
+			log.Printf("   Parents: %v, Categories: %v", detected.Parents, detected.Categories)
 		}
 
-		// If there are instance information, print them too
-		if len(label.Instances) > 0 {
-			log.Printf("   Instance count: %d\n", len(label.Instances))
-			for _, instance := range label.Instances {
-				if instance.BoundingBox != nil {
-					bbox := instance.BoundingBox
-					log.Printf("   - Bounding box: (%.3f, %.3f, %.3f, %.3f) Confidence: %.2f%%\n",
-						*bbox.Left, *bbox.Top, *bbox.Width, *bbox.Height,
-						*instance.Confidence)
-				}
+		for _, instance := range label.Instances {
+			if instance.BoundingBox == nil {
+				continue
 			}
+			bbox := instance.BoundingBox
+			detected.Instances = append(detected.Instances, LabelInstance{
+				Confidence: aws.Float64Value(instance.Confidence),
+				BoundingBox: BoundingBox{
+					Left:   aws.Float64Value(bbox.Left),
+					Top:    aws.Float64Value(bbox.Top),
+					Width:  aws.Float64Value(bbox.Width),
+					Height: aws.Float64Value(bbox.Height),
+				},
+			})
+		}
+		if len(detected.Instances) > 0 {
+			log.Printf("   Instance count: %d\n", len(detected.Instances))
 		}
-		log.Println()
+
+		results = append(results, detected)
 	}
 
 	return results, nil
 }
 
-func DetectText(bucketName, objectKey string) ([]string, error) {
+// DetectText detects lines and words of text in S3 images
+func DetectText(bucketName, objectKey string) (*TextDetectionResult, error) {
 	// Build detect text request
 	input := &rekognition.DetectTextInput{
 		Image: &rekognition.Image{
@@ -99,23 +140,13 @@ func DetectText(bucketName, objectKey string) ([]string, error) {
 	log.Printf("Image: s3://%s/%s\n", bucketName, objectKey)
 	log.Printf("Detected %d text detections:\n\n", len(result.TextDetections))
 
-	var results []string
+	results := &TextDetectionResult{}
 	for i, textDetection := range result.TextDetections {
-		// Only process word-level detections, skip line-level
-		if *textDetection.Type == "WORD" {
-			log.Printf("%d. Text: %s (Confidence: %.2f%%)\n",
-				i+1, *textDetection.DetectedText, *textDetection.Confidence)
-
-			results = append(results, *textDetection.DetectedText)
-
-			// If there are bounding box information, print them too
-			if textDetection.Geometry != nil && textDetection.Geometry.BoundingBox != nil {
-				bbox := textDetection.Geometry.BoundingBox
-				log.Printf("   - Bounding box: (%.3f, %.3f, %.3f, %.3f)\n",
-					*bbox.Left, *bbox.Top, *bbox.Width, *bbox.Height)
-			}
+		block := rekognitionTextBlock(textDetection)
+		if block.Type == TextBlockWord {
+			log.Printf("%d. Text: %s (Confidence: %.2f%%)\n", i+1, block.Text, block.Confidence)
 		}
-		log.Println()
+		results.Blocks = append(results.Blocks, block)
 	}
 
 	return results, nil
@@ -138,8 +169,8 @@ func DetectCustomLabels(bucketName, objectKey, projectVersionArn string) ([]Cust
 			},
 		},
 		ProjectVersionArn: aws.String(projectVersionArn),
-		MaxResults:        aws.Int64(10),       // Return maximum 10 labels
-		MinConfidence:     aws.Float64(50.0),  // Minimum confidence 50%
+		MaxResults:        aws.Int64(10),     // Return maximum 10 labels
+		MinConfidence:     aws.Float64(50.0), // Minimum confidence 50%
 	}
 
 	// Call API
@@ -0,0 +1,244 @@
+package sdk
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/rekognition"
+)
+
+// Rekognition Video job statuses
+const (
+	VideoJobInProgress = rekognition.VideoJobStatusInProgress
+	VideoJobSucceeded  = rekognition.VideoJobStatusSucceeded
+	VideoJobFailed     = rekognition.VideoJobStatusFailed
+)
+
+// videoResultPageSize is the largest page the Rekognition Video Get operations return
+const videoResultPageSize = 1000
+
+// ErrUnsupportedVideo is returned when Rekognition Video cannot read the video, for example an unsupported codec or size
+var ErrUnsupportedVideo = errors.New("video not supported by Rekognition Video")
+
+// VideoMetadata describes the analyzed video
+type VideoMetadata struct {
+	DurationMillis int64
+	FrameWidth     int
+	FrameHeight    int
+	FrameRate      float64
+	Codec          string
+	Format         string
+}
+
+// VideoLabelSegment is a time range in which a label is visible in a video
+type VideoLabelSegment struct {
+	Label           DetectedLabel
+	StartMillis     int64
+	EndMillis       int64
+	TimestampMillis int64
+}
+
+// VideoLabelJob is the state of an asynchronous label detection job
+type VideoLabelJob struct {
+	Status        string
+	StatusMessage string
+	Metadata      VideoMetadata
+	// Segments holds every label segment in time order once Status is SUCCEEDED
+	Segments []VideoLabelSegment
+}
+
+// VideoTextDetection is a line or word of text seen in the frame at TimestampMillis
+type VideoTextDetection struct {
+	TimestampMillis int64
+	Block           TextBlock
+}
+
+// VideoTextJob is the state of an asynchronous text detection job
+type VideoTextJob struct {
+	Status        string
+	StatusMessage string
+	Metadata      VideoMetadata
+	// Detections holds every detection in time order once Status is SUCCEEDED
+	Detections []VideoTextDetection
+}
+
+func rekognitionVideo(bucketName, objectKey string) *rekognition.Video {
+	return &rekognition.Video{
+		S3Object: &rekognition.S3Object{
+			Bucket: aws.String(bucketName),
+			Name:   aws.String(objectKey),
+		},
+	}
+}
+
+// videoStartError maps the errors of a Start operation that retrying cannot fix to ErrUnsupportedVideo
+func videoStartError(operation string, err error) error {
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == rekognition.ErrCodeVideoTooLargeException {
+		return fmt.Errorf("%w: %s", ErrUnsupportedVideo, aerr.Message())
+	}
+	return fmt.Errorf("failed to start %s: %w", operation, err)
+}
+
+// videoJobFailure reports a failed job caused by the video itself as ErrUnsupportedVideo
+func videoJobFailure(status, message string) error {
+	if status == VideoJobFailed && strings.Contains(strings.ToLower(message), "unsupported") {
+		return fmt.Errorf("%w: %s", ErrUnsupportedVideo, message)
+	}
+	return nil
+}
+
+func toVideoMetadata(metadata *rekognition.VideoMetadata) VideoMetadata {
+	if metadata == nil {
+		return VideoMetadata{}
+	}
+	return VideoMetadata{
+		DurationMillis: aws.Int64Value(metadata.DurationMillis),
+		FrameWidth:     int(aws.Int64Value(metadata.FrameWidth)),
+		FrameHeight:    int(aws.Int64Value(metadata.FrameHeight)),
+		FrameRate:      aws.Float64Value(metadata.FrameRate),
+		Codec:          aws.StringValue(metadata.Codec),
+		Format:         aws.StringValue(metadata.Format),
+	}
+}
+
+// StartVideoLabelDetection starts an asynchronous label detection job for a video in S3
+func StartVideoLabelDetection(bucketName, objectKey string, minConfidence float64) (string, error) {
+	output, err := Rekognition.StartLabelDetection(&rekognition.StartLabelDetectionInput{
+		Video:         rekognitionVideo(bucketName, objectKey),
+		MinConfidence: aws.Float64(minConfidence),
+	})
+	if err != nil {
+		log.Printf("Failed to call StartLabelDetection: %v", err)
+		return "", videoStartError("label detection", err)
+	}
+	log.Printf("Started Rekognition Video label detection %s for s3://%s/%s", aws.StringValue(output.JobId), bucketName, objectKey)
+	return aws.StringValue(output.JobId), nil
+}
+
+// GetVideoLabelDetection returns the status of a label detection job and, once it has succeeded,
+// the label segments gathered from all result pages
+func GetVideoLabelDetection(jobID string) (*VideoLabelJob, error) {
+	job := &VideoLabelJob{}
+
+	var nextToken *string
+	for {
+		output, err := Rekognition.GetLabelDetection(&rekognition.GetLabelDetectionInput{
+			JobId:       aws.String(jobID),
+			AggregateBy: aws.String(rekognition.LabelDetectionAggregateBySegments),
+			SortBy:      aws.String(rekognition.LabelDetectionSortByTimestamp),
+			MaxResults:  aws.Int64(videoResultPageSize),
+			NextToken:   nextToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get label detection: %w", err)
+		}
+
+		job.Status = aws.StringValue(output.JobStatus)
+		job.StatusMessage = aws.StringValue(output.StatusMessage)
+		job.Metadata = toVideoMetadata(output.VideoMetadata)
+		if job.Status != VideoJobSucceeded {
+			if err := videoJobFailure(job.Status, job.StatusMessage); err != nil {
+				return nil, err
+			}
+			return job, nil
+		}
+
+		for _, detection := range output.Labels {
+			if detection.Label == nil {
+				continue
+			}
+			segment := VideoLabelSegment{
+				Label: DetectedLabel{
+					Name:       aws.StringValue(detection.Label.Name),
+					Confidence: aws.Float64Value(detection.Label.Confidence),
+				},
+				StartMillis:     aws.Int64Value(detection.StartTimestampMillis),
+				EndMillis:       aws.Int64Value(detection.EndTimestampMillis),
+				TimestampMillis: aws.Int64Value(detection.Timestamp),
+			}
+			if segment.EndMillis < segment.StartMillis {
+				// A label seen in a single frame has no range
+				segment.StartMillis = segment.TimestampMillis
+				segment.EndMillis = segment.TimestampMillis
+			}
+			for _, parent := range detection.Label.Parents {
+				segment.Label.Parents = append(segment.Label.Parents, aws.StringValue(parent.Name))
+			}
+			for _, category := range detection.Label.Categories {
+				segment.Label.Categories = append(segment.Label.Categories, aws.StringValue(category.Name))
+			}
+			job.Segments = append(job.Segments, segment)
+		}
+
+		nextToken = output.NextToken
+		if aws.StringValue(nextToken) == "" {
+			break
+		}
+	}
+
+	log.Printf("Rekognition Video job %s returned %d label segments over %d ms", jobID, len(job.Segments), job.Metadata.DurationMillis)
+	return job, nil
+}
+
+// StartVideoTextDetection starts an asynchronous text detection job for a video in S3
+func StartVideoTextDetection(bucketName, objectKey string) (string, error) {
+	output, err := Rekognition.StartTextDetection(&rekognition.StartTextDetectionInput{
+		Video: rekognitionVideo(bucketName, objectKey),
+	})
+	if err != nil {
+		log.Printf("Failed to call StartTextDetection: %v", err)
+		return "", videoStartError("text detection", err)
+	}
+	log.Printf("Started Rekognition Video text detection %s for s3://%s/%s", aws.StringValue(output.JobId), bucketName, objectKey)
+	return aws.StringValue(output.JobId), nil
+}
+
+// GetVideoTextDetection returns the status of a text detection job and, once it has succeeded,
+// the lines and words seen in every sampled frame gathered from all result pages
+func GetVideoTextDetection(jobID string) (*VideoTextJob, error) {
+	job := &VideoTextJob{}
+
+	var nextToken *string
+	for {
+		output, err := Rekognition.GetTextDetection(&rekognition.GetTextDetectionInput{
+			JobId:      aws.String(jobID),
+			MaxResults: aws.Int64(videoResultPageSize),
+			NextToken:  nextToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get text detection: %w", err)
+		}
+
+		job.Status = aws.StringValue(output.JobStatus)
+		job.StatusMessage = aws.StringValue(output.StatusMessage)
+		job.Metadata = toVideoMetadata(output.VideoMetadata)
+		if job.Status != VideoJobSucceeded {
+			if err := videoJobFailure(job.Status, job.StatusMessage); err != nil {
+				return nil, err
+			}
+			return job, nil
+		}
+
+		for _, detection := range output.TextDetections {
+			if detection.TextDetection == nil {
+				continue
+			}
+			job.Detections = append(job.Detections, VideoTextDetection{
+				TimestampMillis: aws.Int64Value(detection.Timestamp),
+				Block:           rekognitionTextBlock(detection.TextDetection),
+			})
+		}
+
+		nextToken = output.NextToken
+		if aws.StringValue(nextToken) == "" {
+			break
+		}
+	}
+
+	log.Printf("Rekognition Video job %s returned %d text detections over %d ms", jobID, len(job.Detections), job.Metadata.DurationMillis)
+	return job, nil
+}
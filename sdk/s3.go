@@ -2,10 +2,15 @@ package sdk
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"mime/multipart"
+	"net/http"
 	"os"
 	"path/filepath"
 	"sort"
@@ -14,6 +19,8 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 )
@@ -132,6 +139,265 @@ func UploadFile(key string, filename string) error {
 	return err
 }
 
+// WarehouseBucket returns the bucket ingested media is stored in
+func WarehouseBucket() string {
+	return bucket
+}
+
+// WarehouseKey returns the key of an object named name in the warehouse bucket
+func WarehouseKey(name string) string {
+	return prefix + name
+}
+
+// ErrObjectNotFound is returned when an S3 object does not exist
+var ErrObjectNotFound = errors.New("object not found")
+
+// ObjectSize returns the size of an S3 object, or ErrObjectNotFound
+func ObjectSize(bucketName, key string) (int64, error) {
+	output, err := S3.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.RequestFailure); ok && aerr.StatusCode() == http.StatusNotFound {
+			return 0, ErrObjectNotFound
+		}
+		return 0, fmt.Errorf("failed to head object: %w", err)
+	}
+	return aws.Int64Value(output.ContentLength), nil
+}
+
+// ObjectInfo describes an object found by ListObjects
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// ListObjects calls fn with each page of the objects under prefix until the listing ends or fn returns an error
+func ListObjects(bucketName, keyPrefix string, fn func([]ObjectInfo) error) error {
+	var fnErr error
+	err := S3.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(bucketName),
+		Prefix: aws.String(keyPrefix),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		objects := make([]ObjectInfo, 0, len(page.Contents))
+		for _, object := range page.Contents {
+			objects = append(objects, ObjectInfo{
+				Key:          aws.StringValue(object.Key),
+				Size:         aws.Int64Value(object.Size),
+				LastModified: aws.TimeValue(object.LastModified),
+			})
+		}
+		fnErr = fn(objects)
+		return fnErr == nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list objects under %s: %w", keyPrefix, err)
+	}
+	return fnErr
+}
+
+// DownloadObject writes an S3 object to a local file
+func DownloadObject(bucketName, key, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	output, err := S3.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get object: %w", err)
+	}
+	defer output.Body.Close()
+
+	if _, err := io.Copy(file, output.Body); err != nil {
+		return fmt.Errorf("failed to download object: %w", err)
+	}
+	return nil
+}
+
+// DownloadBytes reads a small S3 object into memory
+func DownloadBytes(bucketName, key string) ([]byte, error) {
+	output, err := S3.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+	defer output.Body.Close()
+
+	data, err := io.ReadAll(output.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download object: %w", err)
+	}
+	return data, nil
+}
+
+// DeleteObject removes an S3 object
+func DeleteObject(bucketName, key string) error {
+	_, err := S3.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+	return nil
+}
+
+// GeneratePresignedPutURL returns a URL that uploads one object to the given key with an HTTP PUT
+func GeneratePresignedPutURL(bucketName, key string, expiry time.Duration) (string, error) {
+	req, _ := S3.PutObjectRequest(&s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+		ACL:    aws.String("private"),
+	})
+	url, err := req.Presign(expiry)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate presigned upload URL: %w", err)
+	}
+	return url, nil
+}
+
+// ThumbnailKey returns the S3 key of the thumbnail with the given longest edge of an uploaded object
+func ThumbnailKey(objectKey string, size int, ext string) string {
+	name := strings.TrimSuffix(filepath.Base(objectKey), filepath.Ext(objectKey))
+	return fmt.Sprintf("%sthumbnails/%d/%s%s", prefix, size, name, ext)
+}
+
+// UploadBytes uploads in-memory content, such as a generated thumbnail, to the warehouse bucket
+func UploadBytes(key string, data []byte, contentType string) UploadResult {
+	_, err := S3.PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		log.Printf("Upload result: %s for key: %s", err, key)
+	}
+	return UploadResult{
+		Filename: key,
+		S3Bucket: bucket,
+		S3Key:    key,
+		Error:    err,
+	}
+}
+
+// ErrChecksumMismatch is returned when the object S3 stored does not have the uploaded file's SHA-256
+var ErrChecksumMismatch = errors.New("uploaded object checksum does not match the file")
+
+// UploadFileVerified uploads a file with S3Uploader, which switches to a multipart upload above its part size, so
+// files beyond the 5 GB limit of a single PutObject work too. S3 checks the SHA-256 of every part, or of the whole
+// file when it fits in one part, and the stored checksum is read back to confirm the object is complete.
+func UploadFileVerified(key string, filename string, sha256Hex string) error {
+	sum, err := hex.DecodeString(sha256Hex)
+	if err != nil {
+		return fmt.Errorf("invalid SHA-256 %q: %w", sha256Hex, err)
+	}
+	checksum := base64.StdEncoding.EncodeToString(sum)
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	parts := &partChecksums{sums: make(map[int64][]byte)}
+	_, err = S3Uploader.Upload(&s3manager.UploadInput{
+		Bucket:            aws.String(bucket),
+		Key:               aws.String(key),
+		Body:              file,
+		ChecksumAlgorithm: aws.String(s3.ChecksumAlgorithmSha256),
+		// Only sent when the file fits in one part; multipart uploads use the part checksums
+		ChecksumSHA256: aws.String(checksum),
+	}, func(u *s3manager.Uploader) {
+		u.RequestOptions = append(u.RequestOptions, parts.apply)
+	})
+	if err != nil {
+		log.Printf("Upload result: %s for key: %s", err, key)
+		return err
+	}
+
+	output, err := S3.HeadObject(&s3.HeadObjectInput{
+		Bucket:       aws.String(bucket),
+		Key:          aws.String(key),
+		ChecksumMode: aws.String(s3.ChecksumModeEnabled),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to verify upload: %w", err)
+	}
+	if expected := parts.composite(checksum); aws.StringValue(output.ChecksumSHA256) != expected {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
+
+// partChecksums adds a SHA-256 to every part of a multipart upload. s3manager leaves the checksums of parts out,
+// which S3 requires once the upload was created with a checksum algorithm.
+type partChecksums struct {
+	mu   sync.Mutex
+	sums map[int64][]byte
+}
+
+// apply is a request option run on every request S3Uploader sends
+func (p *partChecksums) apply(r *request.Request) {
+	switch params := r.Params.(type) {
+	case *s3.UploadPartInput:
+		hash := sha256.New()
+		if _, err := io.Copy(hash, params.Body); err != nil {
+			r.Error = fmt.Errorf("failed to hash part %d: %w", aws.Int64Value(params.PartNumber), err)
+			return
+		}
+		if _, err := params.Body.Seek(0, io.SeekStart); err != nil {
+			r.Error = fmt.Errorf("failed to rewind part %d: %w", aws.Int64Value(params.PartNumber), err)
+			return
+		}
+		sum := hash.Sum(nil)
+		params.ChecksumSHA256 = aws.String(base64.StdEncoding.EncodeToString(sum))
+
+		p.mu.Lock()
+		p.sums[aws.Int64Value(params.PartNumber)] = sum
+		p.mu.Unlock()
+
+	case *s3.CompleteMultipartUploadInput:
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		for _, part := range params.MultipartUpload.Parts {
+			if sum, ok := p.sums[aws.Int64Value(part.PartNumber)]; ok {
+				part.ChecksumSHA256 = aws.String(base64.StdEncoding.EncodeToString(sum))
+			}
+		}
+	}
+}
+
+// composite returns the checksum S3 reports for the object: the file's own for a single part, and for a multipart
+// upload the SHA-256 of the part checksums in order followed by the number of parts
+func (p *partChecksums) composite(fileChecksum string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.sums) == 0 {
+		return fileChecksum
+	}
+
+	numbers := make([]int64, 0, len(p.sums))
+	for number := range p.sums {
+		numbers = append(numbers, number)
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	hash := sha256.New()
+	for _, number := range numbers {
+		hash.Write(p.sums[number])
+	}
+	return fmt.Sprintf("%s-%d", base64.StdEncoding.EncodeToString(hash.Sum(nil)), len(numbers))
+}
+
 // CustomLabelsUploadResult represents the result of a custom labels upload
 type CustomLabelsUploadResult struct {
 	Key      string `json:"key"`
@@ -0,0 +1,133 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+var SQS *sqs.SQS
+
+// InitSQS connects to SQS, or to the SQS-compatible endpoint in SQS_ENDPOINT such as ElasticMQ or LocalStack
+func InitSQS() {
+	config := aws.NewConfig()
+	if endpoint := os.Getenv("SQS_ENDPOINT"); endpoint != "" {
+		config = config.WithEndpoint(endpoint)
+	}
+	SQS = sqs.New(AWSSession, config)
+}
+
+// QueueMessage is a message received from a queue; ReceiptHandle deletes it once handled
+type QueueMessage struct {
+	ID            string
+	Body          string
+	ReceiptHandle string
+}
+
+// ReceiveMessages long-polls a queue for up to waitSeconds and returns at most maxMessages messages
+func ReceiveMessages(ctx context.Context, queueURL string, maxMessages, waitSeconds int64) ([]QueueMessage, error) {
+	result, err := SQS.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(queueURL),
+		MaxNumberOfMessages: aws.Int64(maxMessages),
+		WaitTimeSeconds:     aws.Int64(waitSeconds),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to receive messages: %w", err)
+	}
+
+	messages := make([]QueueMessage, 0, len(result.Messages))
+	for _, message := range result.Messages {
+		messages = append(messages, QueueMessage{
+			ID:            aws.StringValue(message.MessageId),
+			Body:          aws.StringValue(message.Body),
+			ReceiptHandle: aws.StringValue(message.ReceiptHandle),
+		})
+	}
+	return messages, nil
+}
+
+// DeleteMessage removes a handled message from a queue
+func DeleteMessage(queueURL, receiptHandle string) error {
+	_, err := SQS.DeleteMessage(&sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(queueURL),
+		ReceiptHandle: aws.String(receiptHandle),
+	})
+	if err != nil {
+		log.Printf("Failed to delete message from %s: %v", queueURL, err)
+		return fmt.Errorf("failed to delete message: %w", err)
+	}
+	return nil
+}
+
+// S3ObjectEvent is one record of an S3 event notification
+type S3ObjectEvent struct {
+	EventName string
+	Bucket    string
+	Key       string
+	Size      int64
+}
+
+// Created reports whether the event announces a new or overwritten object
+func (e S3ObjectEvent) Created() bool {
+	return strings.HasPrefix(e.EventName, "ObjectCreated:")
+}
+
+type s3EventNotification struct {
+	// Event is only set on the s3:TestEvent sent when notifications are configured
+	Event   string `json:"Event"`
+	Records []struct {
+		EventName string `json:"eventName"`
+		S3        struct {
+			Bucket struct {
+				Name string `json:"name"`
+			} `json:"bucket"`
+			Object struct {
+				Key  string `json:"key"`
+				Size int64  `json:"size"`
+			} `json:"object"`
+		} `json:"s3"`
+	} `json:"Records"`
+}
+
+// snsEnvelope wraps notifications that reach the queue through an SNS topic
+type snsEnvelope struct {
+	Type    string `json:"Type"`
+	Message string `json:"Message"`
+}
+
+// ParseS3Events decodes the records of an S3 event notification delivered directly or through SNS.
+// The test event S3 sends when notifications are configured has no records.
+func ParseS3Events(body string) ([]S3ObjectEvent, error) {
+	var envelope snsEnvelope
+	if err := json.Unmarshal([]byte(body), &envelope); err == nil && envelope.Type == "Notification" {
+		body = envelope.Message
+	}
+
+	var notification s3EventNotification
+	if err := json.Unmarshal([]byte(body), &notification); err != nil {
+		return nil, fmt.Errorf("failed to decode S3 event notification: %w", err)
+	}
+
+	events := make([]S3ObjectEvent, 0, len(notification.Records))
+	for _, record := range notification.Records {
+		// Keys are URL-encoded in notifications, with spaces as "+"
+		key, err := url.QueryUnescape(record.S3.Object.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode object key %q: %w", record.S3.Object.Key, err)
+		}
+		events = append(events, S3ObjectEvent{
+			EventName: record.EventName,
+			Bucket:    record.S3.Bucket.Name,
+			Key:       key,
+			Size:      record.S3.Object.Size,
+		})
+	}
+	return events, nil
+}
@@ -0,0 +1,70 @@
+package sdk
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const s3EventBody = `{"Records":[{"eventName":"ObjectCreated:Put","s3":{"bucket":{"name":"uploads"},"object":{"key":"warehouse/image/1700000000_my+cat%282%29.jpg","size":2048}}},{"eventName":"ObjectRemoved:Delete","s3":{"bucket":{"name":"uploads"},"object":{"key":"warehouse/image/old.jpg","size":0}}}]}`
+
+func TestParseS3Events(t *testing.T) {
+	events, err := ParseS3Events(s3EventBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events", len(events))
+	}
+
+	created := events[0]
+	if created.Key != "warehouse/image/1700000000_my cat(2).jpg" {
+		t.Errorf("key = %q, want spaces and escapes decoded", created.Key)
+	}
+	if !created.Created() || created.Bucket != "uploads" || created.Size != 2048 {
+		t.Errorf("created event = %+v", created)
+	}
+	if events[1].Created() {
+		t.Errorf("%s counts as created", events[1].EventName)
+	}
+}
+
+func TestParseS3EventsThroughSNS(t *testing.T) {
+	body, err := json.Marshal(map[string]string{
+		"Type":     "Notification",
+		"TopicArn": "arn:aws:sns:us-east-1:123456789012:uploads",
+		"Message":  s3EventBody,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := ParseS3Events(string(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Key != "warehouse/image/1700000000_my cat(2).jpg" {
+		t.Fatalf("events = %+v", events)
+	}
+}
+
+func TestParseS3EventsTestEvent(t *testing.T) {
+	events, err := ParseS3Events(`{"Service":"Amazon S3","Event":"s3:TestEvent","Time":"2024-05-10T12:00:00.000Z","Bucket":"uploads","RequestId":"1","HostId":"2"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("test event produced %+v", events)
+	}
+}
+
+func TestParseS3EventsMalformed(t *testing.T) {
+	for _, body := range []string{
+		"not json",
+		`{"Type":"Notification","Message":"not json"}`,
+		`{"Records":[{"eventName":"ObjectCreated:Put","s3":{"bucket":{"name":"uploads"},"object":{"key":"bad%zzkey"}}}]}`,
+	} {
+		if _, err := ParseS3Events(body); err == nil {
+			t.Errorf("ParseS3Events(%q) succeeded", body)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package sdk
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rekognition"
+	"github.com/aws/aws-sdk-go/service/textract"
+)
+
+// Text block types shared by Rekognition and Textract output
+const (
+	TextBlockLine = "LINE"
+	TextBlockWord = "WORD"
+)
+
+// Point is a polygon vertex in ratios of the page width and height
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// TextBlock is a line or word of OCR output
+type TextBlock struct {
+	Type       string  `json:"type"`
+	Text       string  `json:"text"`
+	Confidence float64 `json:"confidence"`
+	// Page is 1-based; images have a single page
+	Page        int         `json:"page"`
+	BoundingBox BoundingBox `json:"boundingBox"`
+	Polygon     []Point     `json:"polygon"`
+}
+
+// TextDetectionResult holds OCR blocks in reading order
+type TextDetectionResult struct {
+	Blocks []TextBlock `json:"blocks"`
+}
+
+// Words returns the text of every word block in reading order
+func (r *TextDetectionResult) Words() []string {
+	var words []string
+	for _, block := range r.Blocks {
+		if block.Type == TextBlockWord {
+			words = append(words, block.Text)
+		}
+	}
+	return words
+}
+
+// PageText is the reconstructed text of one page
+type PageText struct {
+	Page int    `json:"page"`
+	Text string `json:"text"`
+}
+
+// Pages joins the lines of each page with newlines, in page order
+func (r *TextDetectionResult) Pages() []PageText {
+	var pages []PageText
+	var lines []string
+	page := 0
+	for _, block := range r.Blocks {
+		if block.Type != TextBlockLine {
+			continue
+		}
+		if block.Page != page && len(lines) > 0 {
+			pages = append(pages, PageText{Page: page, Text: strings.Join(lines, "\n")})
+			lines = nil
+		}
+		page = block.Page
+		lines = append(lines, block.Text)
+	}
+	if len(lines) > 0 {
+		pages = append(pages, PageText{Page: page, Text: strings.Join(lines, "\n")})
+	}
+	return pages
+}
+
+// FullText joins the text of every page, separating pages with a blank line
+func (r *TextDetectionResult) FullText() string {
+	var texts []string
+	for _, page := range r.Pages() {
+		texts = append(texts, page.Text)
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+func rekognitionTextBlock(detection *rekognition.TextDetection) TextBlock {
+	block := TextBlock{
+		Type:       aws.StringValue(detection.Type),
+		Text:       aws.StringValue(detection.DetectedText),
+		Confidence: aws.Float64Value(detection.Confidence),
+		Page:       1,
+	}
+	if geometry := detection.Geometry; geometry != nil {
+		if bbox := geometry.BoundingBox; bbox != nil {
+			block.BoundingBox = BoundingBox{
+				Left:   aws.Float64Value(bbox.Left),
+				Top:    aws.Float64Value(bbox.Top),
+				Width:  aws.Float64Value(bbox.Width),
+				Height: aws.Float64Value(bbox.Height),
+			}
+		}
+		for _, point := range geometry.Polygon {
+			block.Polygon = append(block.Polygon, Point{X: aws.Float64Value(point.X), Y: aws.Float64Value(point.Y)})
+		}
+	}
+	return block
+}
+
+func textractTextBlock(b *textract.Block) TextBlock {
+	block := TextBlock{
+		Type:       aws.StringValue(b.BlockType),
+		Text:       aws.StringValue(b.Text),
+		Confidence: aws.Float64Value(b.Confidence),
+		Page:       int(aws.Int64Value(b.Page)),
+	}
+	if block.Page == 0 {
+		block.Page = 1 // synchronous detection leaves Page unset for single-page documents
+	}
+	if geometry := b.Geometry; geometry != nil {
+		if bbox := geometry.BoundingBox; bbox != nil {
+			block.BoundingBox = BoundingBox{
+				Left:   aws.Float64Value(bbox.Left),
+				Top:    aws.Float64Value(bbox.Top),
+				Width:  aws.Float64Value(bbox.Width),
+				Height: aws.Float64Value(bbox.Height),
+			}
+		}
+		for _, point := range geometry.Polygon {
+			block.Polygon = append(block.Polygon, Point{X: aws.Float64Value(point.X), Y: aws.Float64Value(point.Y)})
+		}
+	}
+	return block
+}
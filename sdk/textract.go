@@ -23,7 +23,7 @@ func InitTextract() {
 }
 
 // DetectDocumentText extracts text from PDF documents in S3
-func DetectDocumentText(bucketName, objectKey string) ([]string, error) {
+func DetectDocumentText(bucketName, objectKey string) (*TextDetectionResult, error) {
 	// Get the original S3 region and Textract region
 	originalRegion := os.Getenv("AWS_DEFAULT_REGION")
 	if originalRegion == "" {
@@ -95,76 +95,38 @@ func DetectDocumentText(bucketName, objectKey string) ([]string, error) {
 	return processTextractResult(result, bucketName, objectKey)
 }
 
-// processTextractResult processes Textract result and returns text array
-func processTextractResult(result *textract.DetectDocumentTextOutput, bucketName, objectKey string) ([]string, error) {
+// processTextractResult converts the LINE and WORD blocks of a Textract result in reading order
+func processTextractResult(result *textract.DetectDocumentTextOutput, bucketName, objectKey string) (*TextDetectionResult, error) {
 	// Output results
 	log.Printf("Document: s3://%s/%s\n", bucketName, objectKey)
 	log.Printf("Detected %d text blocks:\n\n", len(result.Blocks))
 
-	var results []string
+	results := &TextDetectionResult{}
 	for i, block := range result.Blocks {
-		// Only process WORD type blocks, skip LINE and PAGE blocks
-		if *block.BlockType == "WORD" {
-			log.Printf("%d. Text: %s (Confidence: %.2f%%)\n",
-				i+1, *block.Text, *block.Confidence)
-
-			results = append(results, *block.Text)
+		// PAGE blocks carry no text of their own
+		blockType := aws.StringValue(block.BlockType)
+		if blockType != TextBlockLine && blockType != TextBlockWord {
+			continue
+		}
 
-			// If there are bounding box information, print them too
-			if block.Geometry != nil && block.Geometry.BoundingBox != nil {
-				bbox := block.Geometry.BoundingBox
-				log.Printf("   - Bounding box: (%.3f, %.3f, %.3f, %.3f)\n",
-					*bbox.Left, *bbox.Top, *bbox.Width, *bbox.Height)
-			}
+		textBlock := textractTextBlock(block)
+		if blockType == TextBlockWord {
+			log.Printf("%d. Text: %s (Confidence: %.2f%%)\n", i+1, textBlock.Text, textBlock.Confidence)
 		}
-		log.Println()
+		results.Blocks = append(results.Blocks, textBlock)
 	}
 
 	return results, nil
 }
 
 // detectDocumentTextWithCopy handles cross-region access by copying file temporarily
-func detectDocumentTextWithCopy(bucketName, objectKey, sourceRegion, targetRegion string) ([]string, error) {
-	log.Printf("Attempting cross-region file copy from %s to %s", sourceRegion, targetRegion)
-
-	// Create S3 clients for both regions
-	targetS3Session := AWSSession.Copy(&aws.Config{Region: aws.String(targetRegion)})
-
-	targetS3 := s3.New(targetS3Session)
-
-	// Create temporary bucket name in target region
-	tempBucketName := bucketName + "-textract-temp"
-	tempObjectKey := objectKey
-
-	// Check if temp bucket exists, if not create it
-	_, err := targetS3.HeadBucket(&s3.HeadBucketInput{
-		Bucket: aws.String(tempBucketName),
-	})
-	if err != nil {
-		log.Printf("Temp bucket doesn't exist, creating: %s", tempBucketName)
-		_, err = targetS3.CreateBucket(&s3.CreateBucketInput{
-			Bucket: aws.String(tempBucketName),
-			CreateBucketConfiguration: &s3.CreateBucketConfiguration{
-				LocationConstraint: aws.String(targetRegion),
-			},
-		})
-		if err != nil {
-			return nil, fmt.Errorf("failed to create temp bucket: %w", err)
-		}
-	}
-
-	// Copy object from source to target region
-	copySource := fmt.Sprintf("%s/%s", bucketName, objectKey)
-	_, err = targetS3.CopyObject(&s3.CopyObjectInput{
-		Bucket:     aws.String(tempBucketName),
-		Key:        aws.String(tempObjectKey),
-		CopySource: aws.String(copySource),
-	})
+func detectDocumentTextWithCopy(bucketName, objectKey, sourceRegion, targetRegion string) (*TextDetectionResult, error) {
+	tempBucketName, err := copyForTextract(bucketName, objectKey, sourceRegion, targetRegion)
 	if err != nil {
-		return nil, fmt.Errorf("failed to copy object: %w", err)
+		return nil, err
 	}
-
-	log.Printf("Successfully copied file to temp location: s3://%s/%s", tempBucketName, tempObjectKey)
+	tempObjectKey := objectKey
+	targetS3 := s3.New(AWSSession.Copy(&aws.Config{Region: aws.String(targetRegion)}))
 
 	// Now process with Textract
 	input := &textract.DetectDocumentTextInput{
@@ -205,8 +167,58 @@ func detectDocumentTextWithCopy(bucketName, objectKey, sourceRegion, targetRegio
 	return processTextractResult(result, bucketName, objectKey)
 }
 
+// copyForTextract copies an object into a temporary bucket in the Textract region and returns that bucket
+func copyForTextract(bucketName, objectKey, sourceRegion, targetRegion string) (string, error) {
+	log.Printf("Attempting cross-region file copy from %s to %s", sourceRegion, targetRegion)
+
+	// Create S3 clients for both regions
+	targetS3Session := AWSSession.Copy(&aws.Config{Region: aws.String(targetRegion)})
+
+	targetS3 := s3.New(targetS3Session)
+
+	// Create temporary bucket name in target region
+	tempBucketName := textractTempBucket(bucketName)
+	tempObjectKey := objectKey
+
+	// Check if temp bucket exists, if not create it
+	_, err := targetS3.HeadBucket(&s3.HeadBucketInput{
+		Bucket: aws.String(tempBucketName),
+	})
+	if err != nil {
+		log.Printf("Temp bucket doesn't exist, creating: %s", tempBucketName)
+		_, err = targetS3.CreateBucket(&s3.CreateBucketInput{
+			Bucket: aws.String(tempBucketName),
+			CreateBucketConfiguration: &s3.CreateBucketConfiguration{
+				LocationConstraint: aws.String(targetRegion),
+			},
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to create temp bucket: %w", err)
+		}
+	}
+
+	// Copy object from source to target region
+	copySource := fmt.Sprintf("%s/%s", bucketName, objectKey)
+	_, err = targetS3.CopyObject(&s3.CopyObjectInput{
+		Bucket:     aws.String(tempBucketName),
+		Key:        aws.String(tempObjectKey),
+		CopySource: aws.String(copySource),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to copy object: %w", err)
+	}
+
+	log.Printf("Successfully copied file to temp location: s3://%s/%s", tempBucketName, tempObjectKey)
+	return tempBucketName, nil
+}
+
+// textractTempBucket names the bucket holding copies of objects for cross-region Textract calls
+func textractTempBucket(bucketName string) string {
+	return bucketName + "-textract-temp"
+}
+
 // handleUnsupportedPDF handles PDFs that Textract cannot process directly
-func handleUnsupportedPDF(bucketName, objectKey string) ([]string, error) {
+func handleUnsupportedPDF(bucketName, objectKey string) (*TextDetectionResult, error) {
 	log.Printf("Handling unsupported PDF format for: s3://%s/%s", bucketName, objectKey)
 	
 	log.Printf("PDF format not supported - possible reasons:")
@@ -215,7 +227,193 @@ func handleUnsupportedPDF(bucketName, objectKey string) ([]string, error) {
 	log.Printf("3. PDF format version is unsupported")
 	log.Printf("4. PDF file is corrupted")
 	
-	// For now, return empty array to mark as processed but indicate no text found
+	// For now, return an empty result to mark as processed but indicate no text found
 	log.Printf("Skipping text extraction for unsupported PDF: %s", objectKey)
-	return []string{}, nil
+	return &TextDetectionResult{}, nil
+}
+
+// KeyValuePair is a form field found by AnalyzeDocument
+type KeyValuePair struct {
+	Key             string  `json:"key"`
+	Value           string  `json:"value"`
+	KeyConfidence   float64 `json:"keyConfidence"`
+	ValueConfidence float64 `json:"valueConfidence"`
+	Page            int     `json:"page"`
+}
+
+// TableCell is a cell of a table found by AnalyzeDocument; Row and Column are 1-based
+type TableCell struct {
+	Row        int     `json:"row"`
+	Column     int     `json:"column"`
+	RowSpan    int     `json:"rowSpan"`
+	ColumnSpan int     `json:"columnSpan"`
+	Text       string  `json:"text"`
+	Confidence float64 `json:"confidence"`
+	Header     bool    `json:"header"`
+}
+
+// Table is a table found by AnalyzeDocument
+type Table struct {
+	Page        int         `json:"page"`
+	Rows        int         `json:"rows"`
+	Columns     int         `json:"columns"`
+	Confidence  float64     `json:"confidence"`
+	BoundingBox BoundingBox `json:"boundingBox"`
+	Cells       []TableCell `json:"cells"`
+}
+
+// DocumentAnalysis holds the form fields and tables of a document in reading order
+type DocumentAnalysis struct {
+	KeyValues []KeyValuePair `json:"keyValues"`
+	Tables    []Table        `json:"tables"`
+}
+
+// analyzeFeatureTypes are the AnalyzeDocument features the service extracts
+var analyzeFeatureTypes = aws.StringSlice([]string{textract.FeatureTypeForms, textract.FeatureTypeTables})
+
+// AnalyzeDocument extracts form fields and tables from a single-page document or image in S3.
+// Multi-page PDFs need StartDocumentAnalysis instead.
+func AnalyzeDocument(bucketName, objectKey string) (*DocumentAnalysis, error) {
+	source, err := textractSource(bucketName, objectKey)
+	if err != nil {
+		return nil, err
+	}
+	defer DeleteTextractCopy(bucketName, objectKey)
+
+	result, err := Textract.AnalyzeDocument(&textract.AnalyzeDocumentInput{
+		Document:     &textract.Document{S3Object: source},
+		FeatureTypes: analyzeFeatureTypes,
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), textract.ErrCodeUnsupportedDocumentException) {
+			return nil, ErrUnsupportedDocument
+		}
+		return nil, fmt.Errorf("failed to analyze document: %w", err)
+	}
+
+	analysis := parseDocumentAnalysis(result.Blocks)
+	log.Printf("Document s3://%s/%s: %d form fields, %d tables", bucketName, objectKey, len(analysis.KeyValues), len(analysis.Tables))
+	return analysis, nil
+}
+
+// parseDocumentAnalysis resolves the KEY_VALUE_SET and TABLE blocks of an AnalyzeDocument result
+// through their relationships into form fields and tables
+func parseDocumentAnalysis(blocks []*textract.Block) *DocumentAnalysis {
+	byID := make(map[string]*textract.Block, len(blocks))
+	for _, block := range blocks {
+		byID[aws.StringValue(block.Id)] = block
+	}
+
+	analysis := &DocumentAnalysis{}
+	for _, block := range blocks {
+		switch aws.StringValue(block.BlockType) {
+		case textract.BlockTypeKeyValueSet:
+			if !hasEntityType(block, textract.EntityTypeKey) {
+				continue
+			}
+			pair := KeyValuePair{
+				Key:           childText(block, byID),
+				KeyConfidence: aws.Float64Value(block.Confidence),
+				Page:          blockPage(block),
+			}
+			for _, valueID := range relatedIDs(block, textract.RelationshipTypeValue) {
+				if value, ok := byID[valueID]; ok {
+					pair.Value = childText(value, byID)
+					pair.ValueConfidence = aws.Float64Value(value.Confidence)
+				}
+			}
+			analysis.KeyValues = append(analysis.KeyValues, pair)
+		case textract.BlockTypeTable:
+			table := Table{
+				Page:       blockPage(block),
+				Confidence: aws.Float64Value(block.Confidence),
+			}
+			if block.Geometry != nil && block.Geometry.BoundingBox != nil {
+				bbox := block.Geometry.BoundingBox
+				table.BoundingBox = BoundingBox{
+					Left:   aws.Float64Value(bbox.Left),
+					Top:    aws.Float64Value(bbox.Top),
+					Width:  aws.Float64Value(bbox.Width),
+					Height: aws.Float64Value(bbox.Height),
+				}
+			}
+			for _, cellID := range relatedIDs(block, textract.RelationshipTypeChild) {
+				cell, ok := byID[cellID]
+				if !ok || aws.StringValue(cell.BlockType) != textract.BlockTypeCell {
+					continue
+				}
+				tableCell := TableCell{
+					Row:        int(aws.Int64Value(cell.RowIndex)),
+					Column:     int(aws.Int64Value(cell.ColumnIndex)),
+					RowSpan:    int(aws.Int64Value(cell.RowSpan)),
+					ColumnSpan: int(aws.Int64Value(cell.ColumnSpan)),
+					Text:       childText(cell, byID),
+					Confidence: aws.Float64Value(cell.Confidence),
+					Header:     hasEntityType(cell, textract.EntityTypeColumnHeader),
+				}
+				if tableCell.RowSpan < 1 {
+					tableCell.RowSpan = 1
+				}
+				if tableCell.ColumnSpan < 1 {
+					tableCell.ColumnSpan = 1
+				}
+				if last := tableCell.Row + tableCell.RowSpan - 1; last > table.Rows {
+					table.Rows = last
+				}
+				if last := tableCell.Column + tableCell.ColumnSpan - 1; last > table.Columns {
+					table.Columns = last
+				}
+				table.Cells = append(table.Cells, tableCell)
+			}
+			analysis.Tables = append(analysis.Tables, table)
+		}
+	}
+	return analysis
+}
+
+// childText joins the words of a block's children, rendering selected checkboxes as [X]
+func childText(block *textract.Block, byID map[string]*textract.Block) string {
+	var words []string
+	for _, childID := range relatedIDs(block, textract.RelationshipTypeChild) {
+		child, ok := byID[childID]
+		if !ok {
+			continue
+		}
+		switch aws.StringValue(child.BlockType) {
+		case textract.BlockTypeWord:
+			words = append(words, aws.StringValue(child.Text))
+		case textract.BlockTypeSelectionElement:
+			if aws.StringValue(child.SelectionStatus) == textract.SelectionStatusSelected {
+				words = append(words, "[X]")
+			}
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func relatedIDs(block *textract.Block, relationshipType string) []string {
+	var ids []string
+	for _, relationship := range block.Relationships {
+		if aws.StringValue(relationship.Type) == relationshipType {
+			ids = append(ids, aws.StringValueSlice(relationship.Ids)...)
+		}
+	}
+	return ids
+}
+
+func hasEntityType(block *textract.Block, entityType string) bool {
+	for _, t := range block.EntityTypes {
+		if aws.StringValue(t) == entityType {
+			return true
+		}
+	}
+	return false
+}
+
+// blockPage returns the block's 1-based page; synchronous calls leave it unset
+func blockPage(block *textract.Block) int {
+	if page := int(aws.Int64Value(block.Page)); page > 0 {
+		return page
+	}
+	return 1
 }
@@ -0,0 +1,232 @@
+package sdk
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/textract"
+)
+
+// Textract job statuses reported by GetDocumentTextDetection
+const (
+	TextractJobInProgress     = textract.JobStatusInProgress
+	TextractJobSucceeded      = textract.JobStatusSucceeded
+	TextractJobFailed         = textract.JobStatusFailed
+	TextractJobPartialSuccess = textract.JobStatusPartialSuccess
+)
+
+// textractResultPageSize is the largest page GetDocumentTextDetection returns
+const textractResultPageSize = 1000
+
+// ErrUnsupportedDocument is returned when Textract cannot read the document format
+var ErrUnsupportedDocument = errors.New("document format not supported by Textract")
+
+// DocumentTextJob is the state of an asynchronous Textract text detection job
+type DocumentTextJob struct {
+	Status        string
+	StatusMessage string
+	// Pages is the page count Textract reported for the document
+	Pages int
+	// Result holds every page's blocks once Status is SUCCEEDED or PARTIAL_SUCCESS
+	Result *TextDetectionResult
+}
+
+// textractRegions returns the region of the source bucket and the region Textract runs in
+func textractRegions() (string, string) {
+	originalRegion := os.Getenv("AWS_DEFAULT_REGION")
+	if originalRegion == "" {
+		originalRegion = "ap-northeast-1"
+	}
+	return originalRegion, aws.StringValue(Textract.Config.Region)
+}
+
+// textractSource returns the S3 object Textract should read, copying cross-region objects
+// into the Textract region first; such copies must be removed with DeleteTextractCopy once the job finishes
+func textractSource(bucketName, objectKey string) (*textract.S3Object, error) {
+	sourceRegion, textractRegion := textractRegions()
+
+	bucket := bucketName
+	if sourceRegion != textractRegion {
+		log.Printf("Cross-region detected: S3 bucket in %s, Textract in %s", sourceRegion, textractRegion)
+		tempBucketName, err := copyForTextract(bucketName, objectKey, sourceRegion, textractRegion)
+		if err != nil {
+			return nil, err
+		}
+		bucket = tempBucketName
+	}
+	return &textract.S3Object{Bucket: aws.String(bucket), Name: aws.String(objectKey)}, nil
+}
+
+// startTextractJob starts an asynchronous Textract job on the document and returns its job ID
+func startTextractJob(bucketName, objectKey string, start func(*textract.DocumentLocation) (*string, error)) (string, error) {
+	source, err := textractSource(bucketName, objectKey)
+	if err != nil {
+		return "", err
+	}
+
+	jobID, err := start(&textract.DocumentLocation{S3Object: source})
+	if err != nil {
+		DeleteTextractCopy(bucketName, objectKey)
+		if strings.Contains(err.Error(), textract.ErrCodeUnsupportedDocumentException) {
+			return "", ErrUnsupportedDocument
+		}
+		return "", err
+	}
+
+	log.Printf("Started Textract job %s for s3://%s/%s", aws.StringValue(jobID), aws.StringValue(source.Bucket), objectKey)
+	return aws.StringValue(jobID), nil
+}
+
+// StartDocumentTextDetection starts an asynchronous text detection job for a multi-page document in S3
+func StartDocumentTextDetection(bucketName, objectKey string) (string, error) {
+	return startTextractJob(bucketName, objectKey, func(location *textract.DocumentLocation) (*string, error) {
+		output, err := Textract.StartDocumentTextDetection(&textract.StartDocumentTextDetectionInput{
+			DocumentLocation: location,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to start document text detection: %w", err)
+		}
+		return output.JobId, nil
+	})
+}
+
+// GetDocumentTextDetection returns the status of a text detection job and, once it has finished,
+// the LINE and WORD blocks of every page gathered from all result pages
+func GetDocumentTextDetection(jobID string) (*DocumentTextJob, error) {
+	job := &DocumentTextJob{}
+	result := &TextDetectionResult{}
+
+	var nextToken *string
+	for {
+		output, err := Textract.GetDocumentTextDetection(&textract.GetDocumentTextDetectionInput{
+			JobId:      aws.String(jobID),
+			MaxResults: aws.Int64(textractResultPageSize),
+			NextToken:  nextToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get document text detection: %w", err)
+		}
+
+		job.Status = aws.StringValue(output.JobStatus)
+		job.StatusMessage = aws.StringValue(output.StatusMessage)
+		if output.DocumentMetadata != nil {
+			job.Pages = int(aws.Int64Value(output.DocumentMetadata.Pages))
+		}
+		if job.Status != TextractJobSucceeded && job.Status != TextractJobPartialSuccess {
+			if job.Status == TextractJobFailed && strings.Contains(strings.ToUpper(job.StatusMessage), "UNSUPPORTED") {
+				return nil, ErrUnsupportedDocument
+			}
+			return job, nil
+		}
+
+		for _, block := range output.Blocks {
+			blockType := aws.StringValue(block.BlockType)
+			if blockType == TextBlockLine || blockType == TextBlockWord {
+				result.Blocks = append(result.Blocks, textractTextBlock(block))
+			}
+		}
+
+		nextToken = output.NextToken
+		if aws.StringValue(nextToken) == "" {
+			break
+		}
+	}
+
+	if job.StatusMessage != "" {
+		log.Printf("Textract job %s finished with %s: %s", jobID, job.Status, job.StatusMessage)
+	}
+	log.Printf("Textract job %s returned %d text blocks over %d pages", jobID, len(result.Blocks), job.Pages)
+	job.Result = result
+	return job, nil
+}
+
+// DocumentAnalysisJob is the state of an asynchronous Textract document analysis job
+type DocumentAnalysisJob struct {
+	Status        string
+	StatusMessage string
+	Pages         int
+	// Result holds the form fields and tables of every page once Status is SUCCEEDED or PARTIAL_SUCCESS
+	Result *DocumentAnalysis
+}
+
+// StartDocumentAnalysis starts an asynchronous FORMS and TABLES analysis job for a multi-page document in S3
+func StartDocumentAnalysis(bucketName, objectKey string) (string, error) {
+	return startTextractJob(bucketName, objectKey, func(location *textract.DocumentLocation) (*string, error) {
+		output, err := Textract.StartDocumentAnalysis(&textract.StartDocumentAnalysisInput{
+			DocumentLocation: location,
+			FeatureTypes:     analyzeFeatureTypes,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to start document analysis: %w", err)
+		}
+		return output.JobId, nil
+	})
+}
+
+// GetDocumentAnalysis returns the status of a document analysis job and, once it has finished,
+// the form fields and tables resolved from all result pages
+func GetDocumentAnalysis(jobID string) (*DocumentAnalysisJob, error) {
+	job := &DocumentAnalysisJob{}
+	var blocks []*textract.Block
+
+	var nextToken *string
+	for {
+		output, err := Textract.GetDocumentAnalysis(&textract.GetDocumentAnalysisInput{
+			JobId:      aws.String(jobID),
+			MaxResults: aws.Int64(textractResultPageSize),
+			NextToken:  nextToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get document analysis: %w", err)
+		}
+
+		job.Status = aws.StringValue(output.JobStatus)
+		job.StatusMessage = aws.StringValue(output.StatusMessage)
+		if output.DocumentMetadata != nil {
+			job.Pages = int(aws.Int64Value(output.DocumentMetadata.Pages))
+		}
+		if job.Status != TextractJobSucceeded && job.Status != TextractJobPartialSuccess {
+			if job.Status == TextractJobFailed && strings.Contains(strings.ToUpper(job.StatusMessage), "UNSUPPORTED") {
+				return nil, ErrUnsupportedDocument
+			}
+			return job, nil
+		}
+
+		// Relationships can point at blocks on other result pages, so resolve them once everything is fetched
+		blocks = append(blocks, output.Blocks...)
+
+		nextToken = output.NextToken
+		if aws.StringValue(nextToken) == "" {
+			break
+		}
+	}
+
+	if job.StatusMessage != "" {
+		log.Printf("Textract job %s finished with %s: %s", jobID, job.Status, job.StatusMessage)
+	}
+	job.Result = parseDocumentAnalysis(blocks)
+	log.Printf("Textract job %s returned %d form fields and %d tables over %d pages", jobID, len(job.Result.KeyValues), len(job.Result.Tables), job.Pages)
+	return job, nil
+}
+
+// DeleteTextractCopy removes the cross-region copy made by StartDocumentTextDetection, if any
+func DeleteTextractCopy(bucketName, objectKey string) {
+	sourceRegion, textractRegion := textractRegions()
+	if sourceRegion == textractRegion {
+		return
+	}
+
+	targetS3 := s3.New(AWSSession.Copy(&aws.Config{Region: aws.String(textractRegion)}))
+	_, err := targetS3.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(textractTempBucket(bucketName)),
+		Key:    aws.String(objectKey),
+	})
+	if err != nil {
+		log.Printf("Warning: failed to delete temp file: %v", err)
+	}
+}
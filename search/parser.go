@@ -0,0 +1,290 @@
+package search
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// maxQueryLength bounds the query in bytes
+	maxQueryLength = 1024
+	// maxQueryDepth bounds how deeply parentheses and NOT may nest, as the parser recurses for each level
+	maxQueryDepth = 32
+)
+
+// now is the reference point for relative dates such as "after:7d"
+var now = time.Now
+
+// Parse parses a query such as
+//
+//	label:Cat AND keyword:'sale' NOT label:Person after:7d
+//
+// Terms are label:<value>, keyword:<value>, text:<phrase>, after:<date> and before:<date>; values with spaces are quoted.
+// Dates are YYYY-MM-DD, RFC 3339 or a relative age like 7d or 12h. A bare word matches a label or a keyword.
+// Terms are joined by AND (implicit when omitted), OR, and NOT or a leading "-", with parentheses for grouping.
+// AND binds tighter than OR. An empty query returns nil, which matches every image.
+// Queries longer than 1024 bytes or nesting parentheses and NOT more than 32 deep are rejected.
+func Parse(query string) (Expr, error) {
+	if len(query) > maxQueryLength {
+		return nil, fmt.Errorf("query exceeds %d bytes", maxQueryLength)
+	}
+	tokens, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	p := &parser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %s", p.tokens[p.pos])
+	}
+	return expr, nil
+}
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenLParen
+	tokenRParen
+)
+
+type token struct {
+	kind  tokenKind
+	field string
+	value string
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenAnd:
+		return "AND"
+	case tokenOr:
+		return "OR"
+	case tokenNot:
+		return "NOT"
+	case tokenLParen:
+		return `"("`
+	case tokenRParen:
+		return `")"`
+	}
+	if t.field != "" {
+		return fmt.Sprintf("%q", t.field+":"+t.value)
+	}
+	return fmt.Sprintf("%q", t.value)
+}
+
+func lex(query string) ([]token, error) {
+	var tokens []token
+	runes := []rune(query)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenLParen})
+			i++
+			continue
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenRParen})
+			i++
+			continue
+		case r == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]):
+			tokens = append(tokens, token{kind: tokenNot})
+			i++
+			continue
+		}
+
+		var t token
+		var buf strings.Builder
+		quoted := false
+		for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != '(' && runes[i] != ')' {
+			switch c := runes[i]; {
+			case c == '"' || c == '\'':
+				end := i + 1
+				for end < len(runes) && runes[end] != c {
+					end++
+				}
+				if end == len(runes) {
+					return nil, fmt.Errorf("unterminated quote in %q", query)
+				}
+				buf.WriteString(string(runes[i+1 : end]))
+				quoted = true
+				i = end + 1
+			case c == ':' && t.field == "" && !quoted:
+				t.field = strings.ToLower(buf.String())
+				buf.Reset()
+				i++
+			default:
+				buf.WriteRune(c)
+				i++
+			}
+		}
+		t.value = buf.String()
+
+		if t.field == "" && !quoted {
+			switch t.value {
+			case "AND":
+				t.kind = tokenAnd
+			case "OR":
+				t.kind = tokenOr
+			case "NOT":
+				t.kind = tokenNot
+			}
+		}
+		tokens = append(tokens, t)
+	}
+
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	depth  int
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.pos >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	operands := Or{left}
+	for {
+		t, ok := p.peek()
+		if !ok || t.kind != tokenOr {
+			break
+		}
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, right)
+	}
+	return AnyOf(operands...), nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	operands := And{left}
+	for {
+		t, ok := p.peek()
+		if !ok || t.kind == tokenOr || t.kind == tokenRParen {
+			break
+		}
+		if t.kind == tokenAnd {
+			p.pos++
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, right)
+	}
+	return AllOf(operands...), nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	t, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("unexpected end of query")
+	}
+
+	if t.kind == tokenNot || t.kind == tokenLParen {
+		p.depth++
+		defer func() { p.depth-- }()
+		if p.depth > maxQueryDepth {
+			return nil, fmt.Errorf("query nests more than %d levels deep", maxQueryDepth)
+		}
+	}
+
+	switch t.kind {
+	case tokenNot:
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Expr: operand}, nil
+	case tokenLParen:
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing, ok := p.peek(); !ok || closing.kind != tokenRParen {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		p.pos++
+		return expr, nil
+	case tokenWord:
+		p.pos++
+		return parseTerm(t)
+	}
+	return nil, fmt.Errorf("unexpected %s", t)
+}
+
+func parseTerm(t token) (Expr, error) {
+	if t.value == "" {
+		return nil, fmt.Errorf("empty value in %s", t)
+	}
+
+	switch Field(t.field) {
+	case "":
+		return Or{&Term{Field: FieldLabel, Value: t.value}, &Term{Field: FieldKeyword, Value: t.value}}, nil
+	case FieldLabel, FieldKeyword, FieldText:
+		return &Term{Field: Field(t.field), Value: t.value}, nil
+	case FieldAfter, FieldBefore:
+		at, err := ParseTime(t.value)
+		if err != nil {
+			return nil, err
+		}
+		return &Term{Field: Field(t.field), Value: t.value, Time: at}, nil
+	}
+	return nil, fmt.Errorf("unknown field %q", t.field)
+}
+
+// ParseTime parses YYYY-MM-DD, RFC 3339, or an age such as 7d, 12h or 30m counted back from now
+func ParseTime(value string) (time.Time, error) {
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return at, nil
+	}
+	if at, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return at, nil
+	}
+	if strings.HasSuffix(value, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(value, "d")); err == nil && days >= 0 {
+			return now().AddDate(0, 0, -days), nil
+		}
+	}
+	if age, err := time.ParseDuration(value); err == nil && age >= 0 {
+		return now().Add(-age), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
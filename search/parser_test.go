@@ -0,0 +1,85 @@
+package search
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	now = func() time.Time { return time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", "<nil>"},
+		{"label:Cat", `label:"Cat"`},
+		{"cat", `(label:"cat" OR keyword:"cat")`},
+		{"label:Cat keyword:'sale'", `(label:"Cat" AND keyword:"sale")`},
+		{"label:Cat OR label:Dog label:Pet", `(label:"Cat" OR (label:"Dog" AND label:"Pet"))`},
+		{"(label:Cat OR label:Dog) label:Pet", `((label:"Cat" OR label:"Dog") AND label:"Pet")`},
+		{"label:Cat NOT label:Person", `(label:"Cat" AND NOT label:"Person")`},
+		{"-label:Person", `NOT label:"Person"`},
+		{`text:"for sale"`, `text:"for sale"`},
+		{"after:7d", "after:2024-05-03T12:00:00Z"},
+		{"before:2024-05-01T00:00:00Z", "before:2024-05-01T00:00:00Z"},
+	}
+	for _, tt := range tests {
+		expr, err := Parse(tt.query)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.query, err)
+			continue
+		}
+		got := "<nil>"
+		if expr != nil {
+			got = expr.String()
+		}
+		if got != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, query := range []string{
+		"(label:Cat",
+		"label:Cat)",
+		"label:Cat AND",
+		"NOT",
+		"color:red",
+		"after:yesterday",
+		"label:''",
+	} {
+		if _, err := Parse(query); err == nil {
+			t.Errorf("Parse(%q) succeeded", query)
+		}
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	if _, err := Parse(strings.Repeat("a ", maxQueryLength)); err == nil {
+		t.Error("accepted a query longer than the limit")
+	}
+
+	nested := strings.Repeat("(", maxQueryDepth) + "cat" + strings.Repeat(")", maxQueryDepth)
+	if _, err := Parse(nested); err != nil {
+		t.Errorf("rejected %d levels of parentheses: %v", maxQueryDepth, err)
+	}
+	if _, err := Parse("(" + nested + ")"); err == nil {
+		t.Error("accepted parentheses nested deeper than the limit")
+	}
+	if _, err := Parse(strings.Repeat("NOT ", maxQueryDepth+1) + "cat"); err == nil {
+		t.Error("accepted NOT nested deeper than the limit")
+	}
+	if _, err := Parse(strings.Repeat("-", maxQueryLength/2) + "cat"); err == nil {
+		t.Error("accepted a long run of negations")
+	}
+
+	// A long flat query is fine; only nesting is bounded
+	flat := strings.TrimSuffix(strings.Repeat("cat OR ", maxQueryDepth*4), " OR ")
+	if _, err := Parse(flat); err != nil {
+		t.Errorf("rejected a flat query: %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Field names a searchable attribute of an image
+type Field string
+
+const (
+	FieldLabel   Field = "label"
+	FieldKeyword Field = "keyword"
+	// FieldText matches a phrase anywhere in the image's OCR full text
+	FieldText Field = "text"
+	// FieldAfter and FieldBefore bound the image's creation time
+	FieldAfter  Field = "after"
+	FieldBefore Field = "before"
+)
+
+// Expr is a node of a boolean image query
+type Expr interface {
+	String() string
+}
+
+// Term matches images with a label, keyword or text phrase, or created after/before a time
+type Term struct {
+	Field Field
+	Value string
+	// Time is set for FieldAfter and FieldBefore
+	Time time.Time
+}
+
+// And matches images matching every operand
+type And []Expr
+
+// Or matches images matching at least one operand
+type Or []Expr
+
+// Not matches images not matching Expr
+type Not struct {
+	Expr Expr
+}
+
+func (t *Term) String() string {
+	if t.Field == FieldAfter || t.Field == FieldBefore {
+		return fmt.Sprintf("%s:%s", t.Field, t.Time.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("%s:%q", t.Field, t.Value)
+}
+
+func (a And) String() string {
+	return join(a, " AND ")
+}
+
+func (o Or) String() string {
+	return join(o, " OR ")
+}
+
+func (n *Not) String() string {
+	return "NOT " + n.Expr.String()
+}
+
+func join(exprs []Expr, sep string) string {
+	parts := make([]string, 0, len(exprs))
+	for _, e := range exprs {
+		parts = append(parts, e.String())
+	}
+	return "(" + strings.Join(parts, sep) + ")"
+}
+
+// AllOf combines expressions with AND, skipping nil ones; it returns nil when nothing is left
+func AllOf(exprs ...Expr) Expr {
+	var operands And
+	for _, e := range exprs {
+		if e != nil {
+			operands = append(operands, e)
+		}
+	}
+	switch len(operands) {
+	case 0:
+		return nil
+	case 1:
+		return operands[0]
+	}
+	return operands
+}
+
+// AnyOf combines expressions with OR, skipping nil ones; it returns nil when nothing is left
+func AnyOf(exprs ...Expr) Expr {
+	var operands Or
+	for _, e := range exprs {
+		if e != nil {
+			operands = append(operands, e)
+		}
+	}
+	switch len(operands) {
+	case 0:
+		return nil
+	case 1:
+		return operands[0]
+	}
+	return operands
+}
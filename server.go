@@ -9,15 +9,21 @@ import (
 	"blog-fanchiikawa-service/sdk"
 	"blog-fanchiikawa-service/service"
 	"blog-fanchiikawa-service/websocket"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/99designs/gqlgen/graphql/handler"
 	"github.com/99designs/gqlgen/graphql/handler/extension"
 	"github.com/99designs/gqlgen/graphql/handler/lru"
 	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
+	gorillaws "github.com/gorilla/websocket"
 	"github.com/joho/godotenv"
 	"github.com/vektah/gqlparser/v2/ast"
 )
@@ -39,39 +45,68 @@ func main() {
 	sdk.InitPolly()
 	sdk.InitRekognition()
 	sdk.InitTextract()
+	sdk.InitSQS()
+	sdk.InitBedrock()
 
 	// Initialize repositories
 	userRepo := repository.NewUserRepository()
 	deviceRepo := repository.NewUserDeviceRepository()
+	sessionRepo := repository.NewUserSessionRepository()
 	imageRepo := repository.NewImageReposity()
 	labelRepo := repository.NewLabelRepository()
+	labelParentRepo := repository.NewLabelParentRepository()
+	labelAliasRepo := repository.NewLabelAliasRepository()
+	taxonomyBlockRepo := repository.NewTaxonomyBlockRepository()
 	imageLabelRepo := repository.NewImageLabelRepository()
+	imageLabelInstanceRepo := repository.NewImageLabelInstanceRepository()
 	textKeywordRepo := repository.NewTextKeywordRepository()
 	imageTextKeywordRepo := repository.NewImageTextKeywordRepository()
+	imageTextBlockRepo := repository.NewImageTextBlockRepository()
+	imagePageTextRepo := repository.NewImagePageTextRepository()
+	documentAnalysisRepo := repository.NewDocumentAnalysisRepository()
+	imageModerationLabelRepo := repository.NewImageModerationLabelRepository()
+	imageThumbnailRepo := repository.NewImageThumbnailRepository()
+	videoSegmentRepo := repository.NewVideoSegmentRepository()
+	imageCaptionRepo := repository.NewImageCaptionRepository()
+	imageEmbeddingRepo := repository.NewImageEmbeddingRepository()
+	mediaJobRepo := repository.NewMediaJobRepository()
 	transactionMgr := repository.NewTransactionManager()
 	chatRepo := repository.NewChatRepository(db.GetEngine())
 	chatMessageRepo := repository.NewChatMessageRepository(db.GetEngine())
+	chatReadStateRepo := repository.NewChatReadStateRepository(db.GetEngine())
 
 	// Initialize services
+	eventPublisher := service.NewEventPublisher()
 	languageService := service.NewLanguageService()
 	translateService := service.NewTranslateService()
 	speechService := service.NewSpeechService(languageService)
 	storageService := service.NewStorageService()
 	userService := service.NewUserService(userRepo, deviceRepo, transactionMgr)
-	mediaService := service.NewMediaService(imageRepo, labelRepo, imageLabelRepo, textKeywordRepo, imageTextKeywordRepo, transactionMgr)
+	sessionService := service.NewSessionService(sessionRepo)
+	mediaService := service.NewMediaService(imageRepo, labelRepo, labelParentRepo, labelAliasRepo, taxonomyBlockRepo, imageLabelRepo, imageLabelInstanceRepo, textKeywordRepo, imageTextKeywordRepo, imageTextBlockRepo, imagePageTextRepo, documentAnalysisRepo, imageModerationLabelRepo, imageThumbnailRepo, videoSegmentRepo, imageCaptionRepo, imageEmbeddingRepo, mediaJobRepo, transactionMgr, eventPublisher)
+	mediaLibraryService := service.NewMediaLibraryService(imageRepo, labelRepo, labelAliasRepo, imageLabelRepo, imageLabelInstanceRepo, textKeywordRepo, imageTextBlockRepo, imagePageTextRepo, documentAnalysisRepo, imageModerationLabelRepo, imageThumbnailRepo, videoSegmentRepo, imageCaptionRepo, imageEmbeddingRepo)
+	taxonomyService := service.NewTaxonomyService(labelRepo, labelParentRepo, labelAliasRepo, textKeywordRepo, taxonomyBlockRepo)
 	lexService := sdk.NewLexService()
-	chatService := service.NewChatService(chatRepo, chatMessageRepo, lexService)
+	chatService := service.NewChatService(chatRepo, chatMessageRepo, chatReadStateRepo, lexService, eventPublisher)
 	configService := service.NewConfigService()
 	customLabelsService := service.NewCustomLabelsService()
 	commentReplyService := service.NewCommentReplyService()
 
 	// Initialize WebSocket hub
-	hub := websocket.NewHub(chatService)
-	go hub.Run()
+	broker, err := websocket.NewBrokerFromEnv()
+	if err != nil {
+		log.Fatal("Failed to initialize WebSocket broker:", err)
+	}
+	defer broker.Close()
+	hub := websocket.NewHub(chatService, sessionService, eventPublisher, broker)
+	if err := hub.Start(); err != nil {
+		log.Fatal("Failed to start WebSocket hub:", err)
+	}
 
 	// Initialize resolver
 	resolverInstance := resolver.NewResolver(
 		userService,
+		sessionService,
 		languageService,
 		translateService,
 		speechService,
@@ -80,14 +115,18 @@ func main() {
 		configService,
 		customLabelsService,
 		commentReplyService,
+		eventPublisher,
+		mediaLibraryService,
+		mediaService,
+		taxonomyService,
 	)
 
 	// Initialize Scheduler
 	scheduler := scheduler.NewScheduler(mediaService)
 	defer scheduler.Shutdown()
 	scheduler.ImageSync()
-	scheduler.ImageLabelDetect()
-	scheduler.ImageTextDetect()
+	scheduler.S3Ingest()
+	scheduler.MediaJobs()
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -101,7 +140,16 @@ func main() {
 	srv.AddTransport(transport.Options{})
 	srv.AddTransport(transport.GET{})
 	srv.AddTransport(transport.POST{})
-	srv.AddTransport(transport.MultipartForm{})
+	// uploadMedia requests are capped at the size of one file; bigger batches must be split up
+	srv.AddTransport(transport.MultipartForm{MaxUploadSize: service.MediaUploadMaxBytesFromEnv()})
+	srv.AddTransport(transport.Websocket{
+		KeepAlivePingInterval: 10 * time.Second,
+		// Subscriptions send the session token as "Authorization" in the connection_init payload
+		InitFunc: resolverInstance.WebsocketInit,
+		Upgrader: gorillaws.Upgrader{
+			CheckOrigin: websocket.NewOriginChecker(os.Getenv("WS_ALLOWED_ORIGINS")),
+		},
+	})
 
 	srv.SetQueryCache(lru.New[*ast.QueryDocument](1000))
 
@@ -136,6 +184,8 @@ func main() {
 	http.Handle("/playground/", playground.Handler("GraphQL playground", "/query"))
 	http.Handle("/query", srv)
 	http.HandleFunc("/ws", hub.ServeWS)
+	http.HandleFunc("/ws/schema", hub.ServeSchema)
+	http.HandleFunc("/documents/tables/", serveDocumentTableCSV(mediaLibraryService))
 	
 	// Default route to navigation page
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -153,5 +203,34 @@ func main() {
 	log.Printf("Comment Reply Generator available at http://localhost:%s/comment-reply/", port)
 	log.Printf("API Test page available at http://localhost:%s/test/", port)
 	log.Printf("WebSocket endpoint available at ws://localhost:%s/ws", port)
+	log.Printf("GraphQL subscriptions available at ws://localhost:%s/query", port)
+	log.Printf("WebSocket frame schema available at http://localhost:%s/ws/schema", port)
 	log.Fatal(http.ListenAndServe(":"+port, nil))
 }
+
+// serveDocumentTableCSV downloads an extracted document table as CSV from /documents/tables/{id}.csv
+func serveDocumentTableCSV(mediaLibraryService service.MediaLibraryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/documents/tables/")
+		id, err := strconv.ParseInt(strings.TrimSuffix(name, ".csv"), 10, 64)
+		if err != nil || !strings.HasSuffix(name, ".csv") {
+			http.NotFound(w, r)
+			return
+		}
+
+		content, err := mediaLibraryService.ExportDocumentTableCSV(id)
+		if errors.Is(err, service.ErrDocumentTableNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to export document table %d: %v", id, err)
+			http.Error(w, "failed to export table", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"table-%d.csv\"", id))
+		w.Write(content)
+	}
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"blog-fanchiikawa-service/db"
+	"blog-fanchiikawa-service/sdk"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultCaptionLanguages = "en"
+	// maxCaptionTextLength bounds the OCR text sent with an image, in characters
+	maxCaptionTextLength  = 4000
+	captionRequestTimeout = 2 * time.Minute
+	// maxCaptionLanguageLength matches the size of the image_caption language column
+	maxCaptionLanguageLength = 16
+)
+
+// captionOptions controls the captions written for every image
+type captionOptions struct {
+	languages []string
+	model     string
+}
+
+func captionOptionsFromEnv() captionOptions {
+	options := captionOptions{model: os.Getenv("CAPTION_MODEL")}
+	if options.model == "" {
+		options.model = sdk.DefaultCaptionModel
+	}
+
+	// CAPTION_LANGUAGES is a comma-separated list of language codes such as "en,ja"
+	value := os.Getenv("CAPTION_LANGUAGES")
+	if value == "" {
+		value = defaultCaptionLanguages
+	}
+	for _, language := range strings.Split(value, ",") {
+		language = normalizeCaptionLanguage(language)
+		if !validCaptionLanguage(language) {
+			log.Printf("Invalid CAPTION_LANGUAGES entry %q, ignoring it", language)
+			continue
+		}
+		options.languages = append(options.languages, language)
+	}
+	if len(options.languages) == 0 {
+		options.languages = []string{defaultCaptionLanguages}
+	}
+	return options
+}
+
+func normalizeCaptionLanguage(language string) string {
+	return strings.ToLower(strings.TrimSpace(language))
+}
+
+// validCaptionLanguage accepts language codes such as "en", "ja" or "zh-hant"
+func validCaptionLanguage(language string) bool {
+	if language == "" || len(language) > maxCaptionLanguageLength {
+		return false
+	}
+	for _, c := range language {
+		if (c < 'a' || c > 'z') && c != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// captionImage describes an image with its largest thumbnail, stored labels and OCR text once moderation,
+// label and text detection are done. Rejected images are skipped; edited overrides are kept.
+func (s *mediaService) captionImage(image *db.Image) error {
+	ok, err := s.awaitModeration(image)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		log.Printf("Skipping captions of rejected image ID %d", image.ID)
+		return nil
+	}
+	if err := s.waitForJobs(image.ID, db.MediaJobLabelDetection, db.MediaJobTextDetection); err != nil {
+		return err
+	}
+
+	thumbnails, err := s.thumbnailRepo.GetByImageIDs([]int64{image.ID})
+	if err != nil {
+		return fmt.Errorf("failed to load thumbnails: %w", err)
+	}
+	if len(thumbnails[image.ID]) == 0 {
+		return fmt.Errorf("%w: no thumbnail to describe", ErrUnsupportedMedia)
+	}
+	// Thumbnails are ordered from smallest to largest
+	thumbnail := thumbnails[image.ID][len(thumbnails[image.ID])-1]
+	data, err := sdk.DownloadBytes(thumbnail.Bucket, thumbnail.ObjectKey)
+	if err != nil {
+		return fmt.Errorf("failed to download thumbnail: %w", err)
+	}
+
+	labels, err := s.labelRepo.GetByImageIDs([]int64{image.ID})
+	if err != nil {
+		return fmt.Errorf("failed to load labels: %w", err)
+	}
+	labelNames := make([]string, 0, len(labels[image.ID]))
+	for _, label := range labels[image.ID] {
+		labelNames = append(labelNames, label.Name)
+	}
+	text := image.FullText
+	if runes := []rune(text); len(runes) > maxCaptionTextLength {
+		text = string(runes[:maxCaptionTextLength])
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), captionRequestTimeout)
+	defer cancel()
+	response, err := s.describer.DescribeImage(ctx, sdk.ImageDescriptionRequest{
+		ImageData: data,
+		Labels:    labelNames,
+		Text:      text,
+		Languages: s.captionOptions.languages,
+		Model:     s.captionOptions.model,
+	})
+	if err != nil {
+		return err
+	}
+
+	byLanguage := make(map[string]sdk.ImageDescription)
+	for _, description := range response.Descriptions {
+		byLanguage[normalizeCaptionLanguage(description.Language)] = description
+	}
+	now := time.Now()
+	captions := make([]*db.ImageCaption, 0, len(s.captionOptions.languages))
+	for _, language := range s.captionOptions.languages {
+		description, ok := byLanguage[language]
+		if !ok {
+			return fmt.Errorf("no description in %s returned", language)
+		}
+		captions = append(captions, &db.ImageCaption{
+			Language:    language,
+			Caption:     strings.TrimSpace(description.Caption),
+			Description: strings.TrimSpace(description.Description),
+			AltText:     strings.TrimSpace(description.AltText),
+			Model:       s.captionOptions.model,
+			GeneratedAt: &now,
+		})
+	}
+
+	if err := s.captionRepo.SaveGenerated(image.ID, captions); err != nil {
+		return fmt.Errorf("failed to save captions: %w", err)
+	}
+	log.Printf("Captioned image ID %d in %s", image.ID, strings.Join(s.captionOptions.languages, ", "))
+	s.publishMediaProcessed(image, MediaStageCaption)
+	return nil
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"blog-fanchiikawa-service/db"
+	"blog-fanchiikawa-service/graph/model"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidCaptionLanguage is returned for language codes that cannot be stored
+var ErrInvalidCaptionLanguage = errors.New("invalid caption language")
+
+func (s *mediaLibraryService) UpdateImageCaption(imageID int64, language string, input model.ImageCaptionInput) (*model.ImageCaption, error) {
+	language = normalizeCaptionLanguage(language)
+	if !validCaptionLanguage(language) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidCaptionLanguage, language)
+	}
+	// Reviewers may fix the captions of quarantined images too, so only existence is checked
+	image, err := s.imageRepo.GetByID(imageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image: %w", err)
+	}
+	if image == nil {
+		return nil, ErrImageNotFound
+	}
+
+	caption, err := s.captionRepo.GetByImageAndLanguage(imageID, language)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caption: %w", err)
+	}
+	if caption == nil {
+		// A language the model has not written yet starts with overrides only
+		caption = &db.ImageCaption{ImageID: imageID, Language: language}
+	}
+	if input.Caption != nil {
+		caption.CaptionOverride = strings.TrimSpace(*input.Caption)
+	}
+	if input.Description != nil {
+		caption.DescriptionOverride = strings.TrimSpace(*input.Description)
+	}
+	if input.AltText != nil {
+		caption.AltTextOverride = strings.TrimSpace(*input.AltText)
+	}
+	if err := s.captionRepo.SaveOverrides(caption); err != nil {
+		return nil, fmt.Errorf("failed to save caption: %w", err)
+	}
+
+	// Read back for the updated_at the database set
+	saved, err := s.captionRepo.GetByImageAndLanguage(imageID, language)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caption: %w", err)
+	}
+	return toImageCaptionModel(saved), nil
+}
+
+func toImageCaptionModels(captions []*db.ImageCaption) []*model.ImageCaption {
+	result := make([]*model.ImageCaption, 0, len(captions))
+	for _, caption := range captions {
+		result = append(result, toImageCaptionModel(caption))
+	}
+	return result
+}
+
+// toImageCaptionModel resolves each field to its override when one is set
+func toImageCaptionModel(caption *db.ImageCaption) *model.ImageCaption {
+	return &model.ImageCaption{
+		Language:    caption.Language,
+		Caption:     overrideOr(caption.CaptionOverride, caption.Caption),
+		Description: overrideOr(caption.DescriptionOverride, caption.Description),
+		AltText:     overrideOr(caption.AltTextOverride, caption.AltText),
+		Generated: &model.ImageCaptionText{
+			Caption:     caption.Caption,
+			Description: caption.Description,
+			AltText:     caption.AltText,
+		},
+		Overridden:  caption.CaptionOverride != "" || caption.DescriptionOverride != "" || caption.AltTextOverride != "",
+		Model:       nonEmpty(caption.Model),
+		GeneratedAt: caption.GeneratedAt,
+		UpdatedAt:   caption.UpdatedAt,
+	}
+}
+
+func overrideOr(override, generated string) string {
+	if override != "" {
+		return override
+	}
+	return generated
+}
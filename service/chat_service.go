@@ -20,17 +20,23 @@ type ChatService interface {
 	GetChatHistory(chatID int64) (*ChatHistoryResponse, error)
 	GetUserChats(userID int64) ([]*ChatResponse, error)
 	DeleteChat(chatID int64) error
+	MarkRead(userID, chatID, messageID int64) (*ChatResponse, error)
+	GetMessagesAfter(userID, chatID, afterMessageID int64) ([]*MessageResponse, error)
+	// CheckChatOwner returns an error unless the chat exists and belongs to the user
+	CheckChatOwner(userID, chatID int64) error
 }
 
 // chatService implements ChatService interface
 type chatService struct {
 	chatRepo        repository.ChatRepository
 	chatMessageRepo repository.ChatMessageRepository
+	readStateRepo   repository.ChatReadStateRepository
 	lexService      *sdk.LexService
+	publisher       EventPublisher
 	snowflakeNode   *snowflake.Node
 }
 
-func NewChatService(chatRepo repository.ChatRepository, chatMessageRepo repository.ChatMessageRepository, lexService *sdk.LexService) ChatService {
+func NewChatService(chatRepo repository.ChatRepository, chatMessageRepo repository.ChatMessageRepository, readStateRepo repository.ChatReadStateRepository, lexService *sdk.LexService, publisher EventPublisher) ChatService {
 	node, err := snowflake.NewNode(1)
 	if err != nil {
 		log.Fatal("Failed to create snowflake node:", err)
@@ -39,7 +45,9 @@ func NewChatService(chatRepo repository.ChatRepository, chatMessageRepo reposito
 	return &chatService{
 		chatRepo:        chatRepo,
 		chatMessageRepo: chatMessageRepo,
+		readStateRepo:   readStateRepo,
 		lexService:      lexService,
+		publisher:       publisher,
 		snowflakeNode:   node,
 	}
 }
@@ -54,27 +62,30 @@ type CreateChatRequest struct {
 }
 
 type ChatResponse struct {
-	ID        int64  `json:"id"`
-	UserID    int64  `json:"userId"`
-	Title     string `json:"title"`
-	BotName   string `json:"botName"`
-	SessionId string `json:"sessionId"`
-	CreatedAt string `json:"createdAt"`
-	UpdatedAt string `json:"updatedAt"`
+	ID          int64  `json:"id"`
+	UserID      int64  `json:"userId"`
+	Title       string `json:"title"`
+	BotName     string `json:"botName"`
+	SessionId   string `json:"sessionId"`
+	UnreadCount int64  `json:"unreadCount"`
+	CreatedAt   string `json:"createdAt"`
+	UpdatedAt   string `json:"updatedAt"`
 }
 
 type SendMessageRequest struct {
 	ChatID  int64  `json:"chatId"`
 	Message string `json:"message"`
+	// UserID restricts sending to chats owned by this user when non-zero
+	UserID int64 `json:"userId,omitempty"`
 }
 
 type MessageResponse struct {
-	ID       int64  `json:"id"`
-	ChatID   int64  `json:"chatId"`
-	Content  string `json:"content"`
-	IsUser   bool   `json:"isUser"`
-	Intent   string `json:"intent"`
-	SentAt   string `json:"sentAt"`
+	ID      int64  `json:"id"`
+	ChatID  int64  `json:"chatId"`
+	Content string `json:"content"`
+	IsUser  bool   `json:"isUser"`
+	Intent  string `json:"intent"`
+	SentAt  string `json:"sentAt"`
 }
 
 type ChatHistoryResponse struct {
@@ -126,7 +137,7 @@ func (s *chatService) CreateChat(req *CreateChatRequest) (*ChatResponse, error)
 		return nil, fmt.Errorf("failed to create chat: %w", err)
 	}
 
-	return &ChatResponse{
+	chatResp := &ChatResponse{
 		ID:        chat.ID,
 		UserID:    chat.UserID,
 		Title:     chat.Title,
@@ -134,7 +145,16 @@ func (s *chatService) CreateChat(req *CreateChatRequest) (*ChatResponse, error)
 		SessionId: chat.SessionId,
 		CreatedAt: chat.CreatedAt.Format(time.RFC3339),
 		UpdatedAt: chat.UpdatedAt.Format(time.RFC3339),
-	}, nil
+	}
+
+	s.publisher.Publish(&Event{
+		Type:   EventChatUpdated,
+		ChatID: chat.ID,
+		UserID: chat.UserID,
+		Chat:   chatResp,
+	})
+
+	return chatResp, nil
 }
 
 func (s *chatService) SendMessage(ctx context.Context, req *SendMessageRequest) (*MessageResponse, error) {
@@ -142,7 +162,7 @@ func (s *chatService) SendMessage(ctx context.Context, req *SendMessageRequest)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get chat: %w", err)
 	}
-	if chat == nil {
+	if chat == nil || (req.UserID != 0 && chat.UserID != req.UserID) {
 		return nil, fmt.Errorf("chat not found")
 	}
 
@@ -155,6 +175,7 @@ func (s *chatService) SendMessage(ctx context.Context, req *SendMessageRequest)
 	if err := s.chatMessageRepo.CreateMessage(userMessage); err != nil {
 		return nil, fmt.Errorf("failed to save user message: %w", err)
 	}
+	s.publishMessageAdded(chat, userMessage)
 
 	lexReq := &sdk.LexRequest{
 		BotId:      chat.BotId,
@@ -164,7 +185,10 @@ func (s *chatService) SendMessage(ctx context.Context, req *SendMessageRequest)
 		Text:       req.Message,
 	}
 
+	// Let clients show the bot as typing while Lex is answering
+	s.publishTyping(chat, true)
 	lexResp, err := s.lexService.RecognizeText(ctx, lexReq)
+	s.publishTyping(chat, false)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get Lex response: %w", err)
 	}
@@ -185,16 +209,92 @@ func (s *chatService) SendMessage(ctx context.Context, req *SendMessageRequest)
 		return nil, fmt.Errorf("failed to save bot message: %w", err)
 	}
 
-	return &MessageResponse{
-		ID:      botMessage.ID,
-		ChatID:  botMessage.ChatID,
-		Content: botMessage.Content,
-		IsUser:  botMessage.IsUser,
-		Intent:  botMessage.Intent,
-		SentAt:  botMessage.CreatedAt.Format(time.RFC3339),
+	resp := s.publishMessageAdded(chat, botMessage)
+	s.publishChatUpdated(chat)
+
+	return resp, nil
+}
+
+// publishTyping notifies subscribers that the bot started or stopped typing in a chat
+func (s *chatService) publishTyping(chat *db.Chat, active bool) {
+	s.publisher.Publish(&Event{
+		Type:   EventTyping,
+		ChatID: chat.ID,
+		UserID: chat.UserID,
+		Typing: &TypingEvent{
+			ChatID: chat.ID,
+			UserID: chat.UserID,
+			IsBot:  true,
+			Active: active,
+		},
+	})
+}
+
+// publishChatUpdated notifies subscribers with the chat's current state and unread count
+func (s *chatService) publishChatUpdated(chat *db.Chat) {
+	chatResp, err := s.toChatResponse(chat)
+	if err != nil {
+		log.Printf("Failed to build chat update for chat %d: %v", chat.ID, err)
+		return
+	}
+
+	s.publisher.Publish(&Event{
+		Type:   EventChatUpdated,
+		ChatID: chat.ID,
+		UserID: chat.UserID,
+		Chat:   chatResp,
+	})
+}
+
+// toChatResponse converts a chat and computes the owner's unread bot messages
+func (s *chatService) toChatResponse(chat *db.Chat) (*ChatResponse, error) {
+	var lastRead int64
+	state, err := s.readStateRepo.GetByUserAndChat(chat.UserID, chat.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get read state: %w", err)
+	}
+	if state != nil {
+		lastRead = state.LastReadMessageID
+	}
+
+	unread, err := s.chatMessageRepo.CountBotMessagesAfter(chat.ID, lastRead)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count unread messages: %w", err)
+	}
+
+	return &ChatResponse{
+		ID:          chat.ID,
+		UserID:      chat.UserID,
+		Title:       chat.Title,
+		BotName:     chat.BotName,
+		SessionId:   chat.SessionId,
+		UnreadCount: unread,
+		CreatedAt:   chat.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   chat.UpdatedAt.Format(time.RFC3339),
 	}, nil
 }
 
+// publishMessageAdded notifies subscribers about a persisted message and returns its response form
+func (s *chatService) publishMessageAdded(chat *db.Chat, msg *db.ChatMessage) *MessageResponse {
+	resp := &MessageResponse{
+		ID:      msg.ID,
+		ChatID:  msg.ChatID,
+		Content: msg.Content,
+		IsUser:  msg.IsUser,
+		Intent:  msg.Intent,
+		SentAt:  msg.CreatedAt.Format(time.RFC3339),
+	}
+
+	s.publisher.Publish(&Event{
+		Type:    EventMessageAdded,
+		ChatID:  chat.ID,
+		UserID:  chat.UserID,
+		Message: resp,
+	})
+
+	return resp
+}
+
 func (s *chatService) GetChatHistory(chatID int64) (*ChatHistoryResponse, error) {
 	chat, err := s.chatRepo.GetChatByID(chatID)
 	if err != nil {
@@ -209,14 +309,9 @@ func (s *chatService) GetChatHistory(chatID int64) (*ChatHistoryResponse, error)
 		return nil, fmt.Errorf("failed to get messages: %w", err)
 	}
 
-	chatResp := &ChatResponse{
-		ID:        chat.ID,
-		UserID:    chat.UserID,
-		Title:     chat.Title,
-		BotName:   chat.BotName,
-		SessionId: chat.SessionId,
-		CreatedAt: chat.CreatedAt.Format(time.RFC3339),
-		UpdatedAt: chat.UpdatedAt.Format(time.RFC3339),
+	chatResp, err := s.toChatResponse(chat)
+	if err != nil {
+		return nil, err
 	}
 
 	messageResps := make([]*MessageResponse, len(messages))
@@ -245,14 +340,9 @@ func (s *chatService) GetUserChats(userID int64) ([]*ChatResponse, error) {
 
 	responses := make([]*ChatResponse, len(chats))
 	for i, chat := range chats {
-		responses[i] = &ChatResponse{
-			ID:        chat.ID,
-			UserID:    chat.UserID,
-			Title:     chat.Title,
-			BotName:   chat.BotName,
-			SessionId: chat.SessionId,
-			CreatedAt: chat.CreatedAt.Format(time.RFC3339),
-			UpdatedAt: chat.UpdatedAt.Format(time.RFC3339),
+		responses[i], err = s.toChatResponse(chat)
+		if err != nil {
+			return nil, err
 		}
 	}
 
@@ -264,9 +354,83 @@ func (s *chatService) DeleteChat(chatID int64) error {
 		return fmt.Errorf("failed to delete chat messages: %w", err)
 	}
 
+	if err := s.readStateRepo.DeleteByChatID(chatID); err != nil {
+		return fmt.Errorf("failed to delete chat read state: %w", err)
+	}
+
 	if err := s.chatRepo.DeleteChat(chatID); err != nil {
 		return fmt.Errorf("failed to delete chat: %w", err)
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+func (s *chatService) MarkRead(userID, chatID, messageID int64) (*ChatResponse, error) {
+	chat, err := s.chatRepo.GetChatByID(chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat: %w", err)
+	}
+	if chat == nil || chat.UserID != userID {
+		return nil, fmt.Errorf("chat not found")
+	}
+
+	if _, err := s.readStateRepo.MarkRead(userID, chatID, messageID); err != nil {
+		return nil, fmt.Errorf("failed to save read state: %w", err)
+	}
+
+	chatResp, err := s.toChatResponse(chat)
+	if err != nil {
+		return nil, err
+	}
+
+	s.publisher.Publish(&Event{
+		Type:   EventChatUpdated,
+		ChatID: chat.ID,
+		UserID: chat.UserID,
+		Chat:   chatResp,
+	})
+
+	return chatResp, nil
+}
+
+// GetMessagesAfter returns the user's chat messages newer than afterMessageID in ID order
+func (s *chatService) GetMessagesAfter(userID, chatID, afterMessageID int64) ([]*MessageResponse, error) {
+	chat, err := s.chatRepo.GetChatByID(chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat: %w", err)
+	}
+	if chat == nil || chat.UserID != userID {
+		return nil, fmt.Errorf("chat not found")
+	}
+
+	messages, err := s.chatMessageRepo.GetMessagesAfter(chatID, afterMessageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+
+	responses := make([]*MessageResponse, len(messages))
+	for i, msg := range messages {
+		responses[i] = &MessageResponse{
+			ID:      msg.ID,
+			ChatID:  msg.ChatID,
+			Content: msg.Content,
+			IsUser:  msg.IsUser,
+			Intent:  msg.Intent,
+			SentAt:  msg.CreatedAt.Format(time.RFC3339),
+		}
+	}
+
+	return responses, nil
+}
+
+// CheckChatOwner returns an error unless the chat exists and belongs to the user
+func (s *chatService) CheckChatOwner(userID, chatID int64) error {
+	chat, err := s.chatRepo.GetChatByID(chatID)
+	if err != nil {
+		return fmt.Errorf("failed to get chat: %w", err)
+	}
+	if chat == nil || chat.UserID != userID {
+		return fmt.Errorf("chat not found")
+	}
+	return nil
+}
@@ -38,9 +38,9 @@ type customLabelsService struct{}
 
 // CustomLabelsResponse represents the response from custom labels detection
 type CustomLabelsResponse struct {
-	ImageURL string                      `json:"imageUrl"`
-	S3Key    string                      `json:"s3Key"`
-	Labels   []sdk.CustomLabelResult     `json:"labels"`
+	ImageURL string                  `json:"imageUrl"`
+	S3Key    string                  `json:"s3Key"`
+	Labels   []sdk.CustomLabelResult `json:"labels"`
 }
 
 // NewCustomLabelsService creates a new custom labels service
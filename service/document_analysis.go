@@ -0,0 +1,164 @@
+package service
+
+import (
+	"blog-fanchiikawa-service/db"
+	"blog-fanchiikawa-service/sdk"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"xorm.io/xorm"
+)
+
+// defaultDocumentAnalysisExtensions are the file types analyzed for forms and tables when DOCUMENT_ANALYSIS_EXTENSIONS is unset
+const defaultDocumentAnalysisExtensions = ".pdf"
+
+// documentAnalysisExtensionsFromEnv parses DOCUMENT_ANALYSIS_EXTENSIONS, a comma-separated list such as ".pdf,.png"
+func documentAnalysisExtensionsFromEnv() map[string]bool {
+	value := os.Getenv("DOCUMENT_ANALYSIS_EXTENSIONS")
+	if value == "" {
+		value = defaultDocumentAnalysisExtensions
+	}
+
+	extensions := make(map[string]bool)
+	for _, ext := range strings.Split(value, ",") {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		extensions[ext] = true
+	}
+	return extensions
+}
+
+// analyzeDocument extracts form fields and tables, asynchronously for PDFs and synchronously for single images
+func (s *mediaService) analyzeDocument(job *db.MediaJob, image *db.Image) error {
+	if !image.Uploaded {
+		return ErrMediaNotUploaded
+	}
+	log.Printf("Analyzing document ID: %d, Extension: %s, Bucket: %s, ObjectKey: %s",
+		image.ID, image.FileExtension, image.Bucket, image.ObjectKey)
+
+	var analysis *sdk.DocumentAnalysis
+	var err error
+
+	fileExt := strings.ToLower(image.FileExtension)
+	switch fileExt {
+	case ".pdf":
+		analysis, err = s.startOrCollectDocumentAnalysis(job, image)
+	case ".jpg", ".jpeg", ".png", ".tif", ".tiff":
+		analysis, err = sdk.AnalyzeDocument(image.Bucket, image.ObjectKey)
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedMedia, image.FileExtension)
+	}
+	if err != nil {
+		if errors.Is(err, errMediaJobWaiting) {
+			return err
+		}
+		if errors.Is(err, sdk.ErrUnsupportedDocument) {
+			return fmt.Errorf("%w: %v", ErrUnsupportedMedia, err)
+		}
+		return fmt.Errorf("failed to analyze document: %w", err)
+	}
+
+	if err := s.SaveDocumentAnalysis(image.ID, analysis); err != nil {
+		return fmt.Errorf("failed to save document analysis: %w", err)
+	}
+	s.publishMediaProcessed(image, MediaStageDocument)
+	return nil
+}
+
+// startOrCollectDocumentAnalysis starts a Textract analysis job on the first run and collects its result on later runs.
+// It returns errMediaJobWaiting while Textract is still working.
+func (s *mediaService) startOrCollectDocumentAnalysis(job *db.MediaJob, image *db.Image) (*sdk.DocumentAnalysis, error) {
+	if job.ExternalJobID == "" {
+		jobID, err := sdk.StartDocumentAnalysis(image.Bucket, image.ObjectKey)
+		if err != nil {
+			return nil, err
+		}
+		job.ExternalJobID = jobID
+		return nil, errMediaJobWaiting
+	}
+
+	analysisJob, err := sdk.GetDocumentAnalysis(job.ExternalJobID)
+	if err != nil {
+		if errors.Is(err, sdk.ErrUnsupportedDocument) {
+			sdk.DeleteTextractCopy(image.Bucket, image.ObjectKey)
+		}
+		return nil, err
+	}
+
+	switch analysisJob.Status {
+	case sdk.TextractJobInProgress:
+		return nil, errMediaJobWaiting
+	case sdk.TextractJobSucceeded, sdk.TextractJobPartialSuccess:
+		sdk.DeleteTextractCopy(image.Bucket, image.ObjectKey)
+		return analysisJob.Result, nil
+	default:
+		// Start a new Textract job on the next attempt
+		failedJobID := job.ExternalJobID
+		job.ExternalJobID = ""
+		sdk.DeleteTextractCopy(image.Bucket, image.ObjectKey)
+		return nil, fmt.Errorf("textract job %s %s: %s", failedJobID, strings.ToLower(analysisJob.Status), analysisJob.StatusMessage)
+	}
+}
+
+// SaveDocumentAnalysis replaces the image's form fields and tables
+func (s *mediaService) SaveDocumentAnalysis(id int64, analysis *sdk.DocumentAnalysis) error {
+	fields := make([]*db.DocumentField, 0, len(analysis.KeyValues))
+	for i, pair := range analysis.KeyValues {
+		fields = append(fields, &db.DocumentField{
+			ImageID:         id,
+			Page:            pair.Page,
+			FieldIndex:      i,
+			KeyText:         pair.Key,
+			ValueText:       pair.Value,
+			KeyConfidence:   pair.KeyConfidence,
+			ValueConfidence: pair.ValueConfidence,
+		})
+	}
+
+	tables := make([]*db.DocumentTable, 0, len(analysis.Tables))
+	cells := make([][]*db.DocumentTableCell, 0, len(analysis.Tables))
+	for i, table := range analysis.Tables {
+		tables = append(tables, &db.DocumentTable{
+			ImageID:     id,
+			Page:        table.Page,
+			TableIndex:  i,
+			RowCount:    table.Rows,
+			ColumnCount: table.Columns,
+			Confidence:  table.Confidence,
+			BoxLeft:     table.BoundingBox.Left,
+			BoxTop:      table.BoundingBox.Top,
+			BoxWidth:    table.BoundingBox.Width,
+			BoxHeight:   table.BoundingBox.Height,
+		})
+		tableCells := make([]*db.DocumentTableCell, 0, len(table.Cells))
+		for _, cell := range table.Cells {
+			tableCells = append(tableCells, &db.DocumentTableCell{
+				RowIndex:    cell.Row,
+				ColumnIndex: cell.Column,
+				RowSpan:     cell.RowSpan,
+				ColumnSpan:  cell.ColumnSpan,
+				Text:        cell.Text,
+				Confidence:  cell.Confidence,
+				IsHeader:    cell.Header,
+			})
+		}
+		cells = append(cells, tableCells)
+	}
+
+	return s.transactionMgr.WithTransaction(func(session *xorm.Session) error {
+		if err := s.documentRepo.ReplaceForImage(session, id, fields, tables, cells); err != nil {
+			log.Printf("Failed to save document analysis for image ID %d: %v", id, err)
+			return err
+		}
+		log.Printf("Saved %d form fields and %d tables for image ID: %d", len(fields), len(tables), id)
+		return nil
+	})
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"blog-fanchiikawa-service/db"
+	"blog-fanchiikawa-service/graph/model"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+)
+
+// ErrDocumentTableNotFound is returned when a table ID does not exist
+var ErrDocumentTableNotFound = errors.New("document table not found")
+
+// DocumentTableCSVPath is where a table's CSV can be downloaded; the table ID replaces %d
+const DocumentTableCSVPath = "/documents/tables/%d.csv"
+
+func (s *mediaLibraryService) GetDocumentFields(imageID int64) ([]*model.DocumentField, error) {
+	fields, err := s.documentRepo.GetFieldsByImageID(imageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document fields: %w", err)
+	}
+
+	result := make([]*model.DocumentField, 0, len(fields))
+	for _, field := range fields {
+		result = append(result, &model.DocumentField{
+			ID:              field.ID,
+			Page:            int32(field.Page),
+			Key:             field.KeyText,
+			Value:           field.ValueText,
+			KeyConfidence:   field.KeyConfidence,
+			ValueConfidence: field.ValueConfidence,
+		})
+	}
+	return result, nil
+}
+
+func (s *mediaLibraryService) GetDocumentTables(imageID int64) ([]*model.DocumentTable, error) {
+	tables, err := s.documentRepo.GetTablesByImageID(imageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document tables: %w", err)
+	}
+	return s.toDocumentTableModels(tables)
+}
+
+func (s *mediaLibraryService) GetDocumentTable(id int64) (*model.DocumentTable, error) {
+	table, err := s.documentRepo.GetTableByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document table: %w", err)
+	}
+	if table == nil {
+		return nil, nil
+	}
+
+	tables, err := s.toDocumentTableModels([]*db.DocumentTable{table})
+	if err != nil {
+		return nil, err
+	}
+	return tables[0], nil
+}
+
+func (s *mediaLibraryService) ExportDocumentTableCSV(id int64) ([]byte, error) {
+	table, err := s.documentRepo.GetTableByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document table: %w", err)
+	}
+	if table == nil {
+		return nil, ErrDocumentTableNotFound
+	}
+
+	cells, err := s.documentRepo.GetCellsByTableIDs([]int64{id})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get table cells: %w", err)
+	}
+	return tableCSV(table, cells[id])
+}
+
+// toDocumentTableModels converts tables, loading the cells of all of them in one query
+func (s *mediaLibraryService) toDocumentTableModels(tables []*db.DocumentTable) ([]*model.DocumentTable, error) {
+	tableIDs := make([]int64, len(tables))
+	for i, table := range tables {
+		tableIDs[i] = table.ID
+	}
+	cellsByTable, err := s.documentRepo.GetCellsByTableIDs(tableIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get table cells: %w", err)
+	}
+
+	result := make([]*model.DocumentTable, 0, len(tables))
+	for _, table := range tables {
+		cells := cellsByTable[table.ID]
+		content, err := tableCSV(table, cells)
+		if err != nil {
+			return nil, err
+		}
+
+		cellModels := make([]*model.TableCell, 0, len(cells))
+		for _, cell := range cells {
+			cellModels = append(cellModels, &model.TableCell{
+				Row:        int32(cell.RowIndex),
+				Column:     int32(cell.ColumnIndex),
+				RowSpan:    int32(cell.RowSpan),
+				ColumnSpan: int32(cell.ColumnSpan),
+				Text:       cell.Text,
+				Confidence: cell.Confidence,
+				Header:     cell.IsHeader,
+			})
+		}
+
+		result = append(result, &model.DocumentTable{
+			ID:          table.ID,
+			ImageID:     table.ImageID,
+			Page:        int32(table.Page),
+			Index:       int32(table.TableIndex),
+			RowCount:    int32(table.RowCount),
+			ColumnCount: int32(table.ColumnCount),
+			Confidence:  table.Confidence,
+			BoundingBox: &model.BoundingBox{
+				Left:   table.BoxLeft,
+				Top:    table.BoxTop,
+				Width:  table.BoxWidth,
+				Height: table.BoxHeight,
+			},
+			Cells:  cellModels,
+			CSV:    string(content),
+			CSVURL: fmt.Sprintf(DocumentTableCSVPath, table.ID),
+		})
+	}
+	return result, nil
+}
+
+// tableCSV lays the cells out on the table grid; a merged cell's text goes in its top-left position
+// and the positions it spans stay empty
+func tableCSV(table *db.DocumentTable, cells []*db.DocumentTableCell) ([]byte, error) {
+	grid := make([][]string, table.RowCount)
+	for i := range grid {
+		grid[i] = make([]string, table.ColumnCount)
+	}
+	for _, cell := range cells {
+		row, column := cell.RowIndex-1, cell.ColumnIndex-1
+		if row < 0 || row >= table.RowCount || column < 0 || column >= table.ColumnCount {
+			continue
+		}
+		grid[row][column] = cell.Text
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err := writer.WriteAll(grid); err != nil {
+		return nil, fmt.Errorf("failed to write table CSV: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"blog-fanchiikawa-service/db"
+	"blog-fanchiikawa-service/graph/model"
+	"blog-fanchiikawa-service/imagehash"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+)
+
+const (
+	// defaultDuplicateMaxDistance is the largest Hamming distance between dHashes of near-duplicates
+	defaultDuplicateMaxDistance = 10
+	defaultSimilarImagesLimit   = 20
+	maxSimilarImagesLimit       = 100
+)
+
+func duplicateMaxDistanceFromEnv() int {
+	distance := defaultDuplicateMaxDistance
+	if value := os.Getenv("DUPLICATE_MAX_DISTANCE"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 || parsed > 64 {
+			log.Printf("Invalid DUPLICATE_MAX_DISTANCE %q, using default %d", value, distance)
+		} else {
+			distance = parsed
+		}
+	}
+	return distance
+}
+
+// hashMatch is an image whose perceptual hash is near another one
+type hashMatch struct {
+	image    *db.Image
+	distance int
+}
+
+// nearImages returns the images within maxDistance of the hash, nearest first, skipping excludeID
+func nearImages(candidates []*db.Image, hash uint64, maxDistance int, excludeID int64) []hashMatch {
+	var matches []hashMatch
+	for _, candidate := range candidates {
+		if candidate.ID == excludeID {
+			continue
+		}
+		candidateHash, err := imagehash.Parse(candidate.PerceptualHash)
+		if err != nil {
+			log.Printf("Invalid perceptual hash %q for image ID %d", candidate.PerceptualHash, candidate.ID)
+			continue
+		}
+		if distance := imagehash.Distance(hash, candidateHash); distance <= maxDistance {
+			matches = append(matches, hashMatch{image: candidate, distance: distance})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].distance != matches[j].distance {
+			return matches[i].distance < matches[j].distance
+		}
+		return matches[i].image.ID < matches[j].image.ID
+	})
+	return matches
+}
+
+func (s *mediaService) FindDuplicate(contentHash string) (*db.Image, error) {
+	if contentHash == "" {
+		return nil, nil
+	}
+	image, err := s.imageRepo.GetByContentHash(contentHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up content hash: %w", err)
+	}
+	return image, nil
+}
+
+// assignCluster puts a new image in the cluster of its nearest near-duplicate,
+// starting a cluster named after that image when it had none
+func (s *mediaService) assignCluster(image *db.Image) error {
+	if image.PerceptualHash == "" {
+		return nil
+	}
+	hash, err := imagehash.Parse(image.PerceptualHash)
+	if err != nil {
+		return fmt.Errorf("invalid perceptual hash %q: %w", image.PerceptualHash, err)
+	}
+
+	candidates, err := s.imageRepo.GetPerceptualHashes()
+	if err != nil {
+		return fmt.Errorf("failed to load perceptual hashes: %w", err)
+	}
+	matches := nearImages(candidates, hash, s.duplicateMaxDistance, image.ID)
+	if len(matches) == 0 {
+		return nil
+	}
+
+	nearest := matches[0].image
+	clusterID := nearest.ClusterID
+	ids := []int64{image.ID}
+	if clusterID == 0 {
+		clusterID = nearest.ID
+		ids = append(ids, nearest.ID)
+	}
+	if _, err := s.imageRepo.UpdateClusterID(ids, clusterID); err != nil {
+		return fmt.Errorf("failed to update duplicate cluster: %w", err)
+	}
+	log.Printf("Image ID %d is a near-duplicate of image ID %d (distance %d), cluster %d", image.ID, nearest.ID, matches[0].distance, clusterID)
+	return nil
+}
+
+func (s *mediaLibraryService) SimilarImages(imageID int64, maxDistance int, first int) ([]*model.SimilarImage, error) {
+	if maxDistance < 0 {
+		maxDistance = s.duplicateMaxDistance
+	}
+	if first <= 0 {
+		first = defaultSimilarImagesLimit
+	}
+	if first > maxSimilarImagesLimit {
+		first = maxSimilarImagesLimit
+	}
+
+	image, err := s.imageRepo.GetByID(imageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image: %w", err)
+	}
+	if image == nil || !isVisible(image) {
+		return nil, ErrImageNotFound
+	}
+	if image.PerceptualHash == "" {
+		return []*model.SimilarImage{}, nil
+	}
+	hash, err := imagehash.Parse(image.PerceptualHash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid perceptual hash %q: %w", image.PerceptualHash, err)
+	}
+
+	candidates, err := s.imageRepo.GetPerceptualHashes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load perceptual hashes: %w", err)
+	}
+	var visible []*db.Image
+	for _, candidate := range candidates {
+		if isVisible(candidate) {
+			visible = append(visible, candidate)
+		}
+	}
+	matches := nearImages(visible, hash, maxDistance, image.ID)
+	if len(matches) > first {
+		matches = matches[:first]
+	}
+
+	ids := make([]int64, len(matches))
+	for i, match := range matches {
+		ids[i] = match.image.ID
+	}
+	images, err := s.imageRepo.GetByIDs(ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get similar images: %w", err)
+	}
+	nodes, err := s.toImageModels(images)
+	if err != nil {
+		return nil, err
+	}
+	nodesByID := make(map[int64]*model.Image, len(nodes))
+	for _, node := range nodes {
+		nodesByID[node.ID] = node
+	}
+
+	result := make([]*model.SimilarImage, 0, len(matches))
+	for _, match := range matches {
+		if node, ok := nodesByID[match.image.ID]; ok {
+			result = append(result, &model.SimilarImage{Image: node, Distance: int32(match.distance)})
+		}
+	}
+	return result, nil
+}
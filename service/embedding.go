@@ -0,0 +1,271 @@
+package service
+
+import (
+	"blog-fanchiikawa-service/db"
+	"blog-fanchiikawa-service/sdk"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	embeddingProviderBedrock = "bedrock"
+	embeddingProviderLocal   = "local"
+
+	defaultBedrockEmbeddingDimensions = 512
+	defaultLocalEmbeddingDimensions   = 256
+	// maxEmbeddingTextLength bounds the OCR text embedded with an image, in characters
+	maxEmbeddingTextLength  = 8000
+	embeddingRequestTimeout = time.Minute
+)
+
+// EmbeddingProvider turns text into vectors whose cosine similarity reflects how close the texts are in meaning
+type EmbeddingProvider interface {
+	// Model identifies the model and dimension; vectors are only compared with vectors from the same model
+	Model() string
+	Dimensions() int
+	Embed(ctx context.Context, text string) ([]float32, error)
+}
+
+// NewEmbeddingProvider returns the provider chosen by EMBEDDING_PROVIDER: "bedrock" (default) calls
+// EMBEDDING_MODEL on Amazon Bedrock and "local" hashes words without any network calls
+func NewEmbeddingProvider() EmbeddingProvider {
+	provider := strings.ToLower(os.Getenv("EMBEDDING_PROVIDER"))
+	switch provider {
+	case embeddingProviderLocal:
+		return NewLocalEmbeddingProvider(embeddingDimensionsFromEnv(defaultLocalEmbeddingDimensions))
+	case "", embeddingProviderBedrock:
+	default:
+		log.Printf("Invalid EMBEDDING_PROVIDER %q, using default %s", provider, embeddingProviderBedrock)
+	}
+
+	model := os.Getenv("EMBEDDING_MODEL")
+	if model == "" {
+		model = sdk.DefaultEmbeddingModel
+	}
+	return &bedrockEmbeddingProvider{
+		model:      model,
+		dimensions: embeddingDimensionsFromEnv(defaultBedrockEmbeddingDimensions),
+	}
+}
+
+func embeddingDimensionsFromEnv(dimensions int) int {
+	if value := os.Getenv("EMBEDDING_DIMENSIONS"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			log.Printf("Invalid EMBEDDING_DIMENSIONS %q, using default %d", value, dimensions)
+		} else {
+			dimensions = parsed
+		}
+	}
+	return dimensions
+}
+
+// bedrockEmbeddingProvider embeds text with a Titan text embedding model on Amazon Bedrock
+type bedrockEmbeddingProvider struct {
+	model      string
+	dimensions int
+}
+
+func (p *bedrockEmbeddingProvider) Model() string {
+	return fmt.Sprintf("%s:%s/%d", embeddingProviderBedrock, p.model, p.dimensions)
+}
+
+func (p *bedrockEmbeddingProvider) Dimensions() int {
+	return p.dimensions
+}
+
+func (p *bedrockEmbeddingProvider) Embed(ctx context.Context, text string) ([]float32, error) {
+	return sdk.EmbedText(ctx, p.model, text, p.dimensions)
+}
+
+// localEmbeddingProvider hashes words, adjacent word pairs and, for scripts written without spaces, character
+// pairs into a fixed number of signed buckets. The same text always gets the same vector, so it suits tests and
+// development; it only matches shared words and knows nothing about meaning.
+type localEmbeddingProvider struct {
+	dimensions int
+}
+
+// NewLocalEmbeddingProvider returns a deterministic provider that needs no network access
+func NewLocalEmbeddingProvider(dimensions int) EmbeddingProvider {
+	return &localEmbeddingProvider{dimensions: dimensions}
+}
+
+func (p *localEmbeddingProvider) Model() string {
+	return fmt.Sprintf("%s:hash/%d", embeddingProviderLocal, p.dimensions)
+}
+
+func (p *localEmbeddingProvider) Dimensions() int {
+	return p.dimensions
+}
+
+func (p *localEmbeddingProvider) Embed(ctx context.Context, text string) ([]float32, error) {
+	vector := make([]float32, p.dimensions)
+	add := func(feature string, weight float32) {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		// The top bit picks the sign so unrelated features cancel out instead of piling up
+		if sum>>63 == 1 {
+			weight = -weight
+		}
+		vector[sum%uint64(p.dimensions)] += weight
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for i, word := range words {
+		add(word, 1)
+		if i > 0 {
+			add(words[i-1]+" "+word, 0.5)
+		}
+		runes := []rune(word)
+		if len(runes) > 1 && !isSpaceSeparated(runes[0]) {
+			for j := 1; j < len(runes); j++ {
+				add(string(runes[j-1:j+1]), 0.5)
+			}
+		}
+	}
+
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 {
+		return vector, nil
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range vector {
+		vector[i] /= norm
+	}
+	return vector, nil
+}
+
+// isSpaceSeparated reports whether a script separates words with spaces; Chinese, Japanese and Thai do not
+func isSpaceSeparated(r rune) bool {
+	return !unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Thai)
+}
+
+// encodeVector stores a vector as little-endian float32 values
+func encodeVector(vector []float32) []byte {
+	data := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(v))
+	}
+	return data
+}
+
+func decodeVector(data []byte) []float32 {
+	vector := make([]float32, len(data)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	return vector
+}
+
+// embedImage stores the vector of an image's captions, labels and OCR text once the jobs that write them are done.
+// Rejected images are skipped, and an image whose text has not changed since it was last embedded is not sent again.
+func (s *mediaService) embedImage(image *db.Image) error {
+	ok, err := s.awaitModeration(image)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		log.Printf("Skipping embedding of rejected image ID %d", image.ID)
+		return nil
+	}
+	if err := s.waitForJobs(image.ID, db.MediaJobLabelDetection, db.MediaJobTextDetection, db.MediaJobCaption); err != nil {
+		return err
+	}
+
+	source, err := s.embeddingSource(image)
+	if err != nil {
+		return err
+	}
+	if source == "" {
+		log.Printf("Nothing to embed for image ID %d", image.ID)
+		return nil
+	}
+	sum := sha256.Sum256([]byte(source))
+	sourceHash := hex.EncodeToString(sum[:])
+
+	model := s.embedder.Model()
+	existing, err := s.embeddingRepo.GetByImageAndModel(image.ID, model)
+	if err != nil {
+		return fmt.Errorf("failed to get embedding: %w", err)
+	}
+	if existing != nil && existing.SourceHash == sourceHash {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), embeddingRequestTimeout)
+	defer cancel()
+	vector, err := s.embedder.Embed(ctx, source)
+	if err != nil {
+		return err
+	}
+	err = s.embeddingRepo.Save(&db.ImageEmbedding{
+		ImageID:    image.ID,
+		Model:      model,
+		Dimensions: len(vector),
+		Vector:     encodeVector(vector),
+		SourceHash: sourceHash,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save embedding: %w", err)
+	}
+	log.Printf("Embedded image ID %d with %s", image.ID, model)
+	s.publishMediaProcessed(image, MediaStageEmbedding)
+	return nil
+}
+
+// embeddingSource is the text embedded for an image: its captions with their edits, label names and OCR text
+func (s *mediaService) embeddingSource(image *db.Image) (string, error) {
+	var parts []string
+
+	captions, err := s.captionRepo.GetByImageIDs([]int64{image.ID})
+	if err != nil {
+		return "", fmt.Errorf("failed to load captions: %w", err)
+	}
+	for _, caption := range captions[image.ID] {
+		for _, text := range []string{
+			overrideOr(caption.CaptionOverride, caption.Caption),
+			overrideOr(caption.DescriptionOverride, caption.Description),
+		} {
+			if text != "" {
+				parts = append(parts, text)
+			}
+		}
+	}
+
+	labels, err := s.labelRepo.GetByImageIDs([]int64{image.ID})
+	if err != nil {
+		return "", fmt.Errorf("failed to load labels: %w", err)
+	}
+	if len(labels[image.ID]) > 0 {
+		names := make([]string, 0, len(labels[image.ID]))
+		for _, label := range labels[image.ID] {
+			names = append(names, label.Name)
+		}
+		parts = append(parts, "Labels: "+strings.Join(names, ", "))
+	}
+
+	if text := strings.TrimSpace(image.FullText); text != "" {
+		if runes := []rune(text); len(runes) > maxEmbeddingTextLength {
+			text = string(runes[:maxEmbeddingTextLength])
+		}
+		parts = append(parts, "Text: "+text)
+	}
+	return strings.Join(parts, "\n"), nil
+}
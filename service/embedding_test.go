@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func embed(t *testing.T, provider EmbeddingProvider, text string) []float32 {
+	t.Helper()
+	vector, err := provider.Embed(context.Background(), text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vector) != provider.Dimensions() {
+		t.Fatalf("vector has %d dimensions, provider has %d", len(vector), provider.Dimensions())
+	}
+	return vector
+}
+
+func cosine(a, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	return dot / math.Sqrt(normA*normB)
+}
+
+func TestLocalEmbeddingIsDeterministic(t *testing.T) {
+	text := "A grey cat sleeping on a red sofa"
+	first := embed(t, NewLocalEmbeddingProvider(256), text)
+	second := embed(t, NewLocalEmbeddingProvider(256), text)
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("dimension %d differs between runs: %v and %v", i, first[i], second[i])
+		}
+	}
+
+	var norm float64
+	for _, v := range first {
+		norm += float64(v) * float64(v)
+	}
+	if math.Abs(norm-1) > 1e-5 {
+		t.Errorf("vector length² = %v, want 1", norm)
+	}
+	if model := NewLocalEmbeddingProvider(256).Model(); model != "local:hash/256" {
+		t.Errorf("Model = %q", model)
+	}
+}
+
+func TestLocalEmbeddingRanksRelatedTextsCloser(t *testing.T) {
+	provider := NewLocalEmbeddingProvider(256)
+	tests := []struct {
+		query, related, unrelated string
+	}{
+		{"grey cat on a sofa", "a grey cat sleeping on the sofa", "invoice total due next month"},
+		{"receipt from the coffee shop", "coffee shop receipt with two lattes", "dog running on the beach"},
+		{"東京の夜景", "東京タワーの夜景", "海辺の犬"},
+	}
+	for _, tt := range tests {
+		query := embed(t, provider, tt.query)
+		related := cosine(query, embed(t, provider, tt.related))
+		unrelated := cosine(query, embed(t, provider, tt.unrelated))
+		if related <= unrelated {
+			t.Errorf("%q: related text scores %.3f, unrelated %.3f", tt.query, related, unrelated)
+		}
+	}
+}
+
+func TestLocalEmbeddingOfEmptyText(t *testing.T) {
+	vector := embed(t, NewLocalEmbeddingProvider(64), " ,. ")
+	for _, v := range vector {
+		if v != 0 {
+			t.Fatalf("text without words embeds as %v", vector)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// EventType identifies the kind of event flowing through the EventPublisher
+type EventType string
+
+const (
+	EventMessageAdded   EventType = "message_added"
+	EventChatUpdated    EventType = "chat_updated"
+	EventMediaProcessed EventType = "media_processed"
+	EventTyping         EventType = "typing"
+)
+
+// Media processing stages reported by EventMediaProcessed
+const (
+	MediaStageLabels     = "labels"
+	MediaStageText       = "text"
+	MediaStageDocument   = "document"
+	MediaStageModeration = "moderation"
+	MediaStageCaption    = "caption"
+	MediaStageEmbedding  = "embedding"
+)
+
+// Event is a single domain event published by the service layer
+type Event struct {
+	Type    EventType            `json:"type"`
+	ChatID  int64                `json:"chatId,omitempty"`
+	UserID  int64                `json:"userId,omitempty"`
+	Message *MessageResponse     `json:"message,omitempty"`
+	Chat    *ChatResponse        `json:"chat,omitempty"`
+	Media   *MediaProcessedEvent `json:"media,omitempty"`
+	Typing  *TypingEvent         `json:"typing,omitempty"`
+}
+
+// TypingEvent describes a typing indicator change in a chat
+type TypingEvent struct {
+	ChatID int64 `json:"chatId"`
+	UserID int64 `json:"userId"`
+	IsBot  bool  `json:"isBot"`
+	Active bool  `json:"active"`
+}
+
+// MediaProcessedEvent describes a finished processing stage for an image
+type MediaProcessedEvent struct {
+	ImageID int64 `json:"imageId"`
+	// OwnerID is the user who uploaded the image, 0 for images without an owner
+	OwnerID     int64  `json:"ownerId,omitempty"`
+	Stage       string `json:"stage"`
+	ProcessedAt string `json:"processedAt"`
+}
+
+// EventPublisher defines the interface for publishing and subscribing to domain events
+type EventPublisher interface {
+	// Publish delivers the event to every matching subscriber without blocking
+	Publish(event *Event)
+
+	// Subscribe returns a channel receiving events accepted by filter.
+	// The channel is closed once ctx is done.
+	Subscribe(ctx context.Context, filter func(*Event) bool) <-chan *Event
+}
+
+const subscriberBufferSize = 64
+
+type subscriber struct {
+	ch     chan *Event
+	filter func(*Event) bool
+}
+
+// eventPublisher implements EventPublisher with in-process fan-out
+type eventPublisher struct {
+	mutex       sync.RWMutex
+	subscribers map[*subscriber]struct{}
+}
+
+// NewEventPublisher creates a new in-process EventPublisher instance
+func NewEventPublisher() EventPublisher {
+	return &eventPublisher{
+		subscribers: make(map[*subscriber]struct{}),
+	}
+}
+
+func (p *eventPublisher) Publish(event *Event) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	for sub := range p.subscribers {
+		if sub.filter != nil && !sub.filter(event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			log.Printf("Dropping %s event for slow subscriber", event.Type)
+		}
+	}
+}
+
+func (p *eventPublisher) Subscribe(ctx context.Context, filter func(*Event) bool) <-chan *Event {
+	sub := &subscriber{
+		ch:     make(chan *Event, subscriberBufferSize),
+		filter: filter,
+	}
+
+	p.mutex.Lock()
+	p.subscribers[sub] = struct{}{}
+	p.mutex.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		p.mutex.Lock()
+		delete(p.subscribers, sub)
+		close(sub.ch)
+		p.mutex.Unlock()
+	}()
+
+	return sub.ch
+}
@@ -0,0 +1,368 @@
+package service
+
+import (
+	"blog-fanchiikawa-service/db"
+	"blog-fanchiikawa-service/imagehash"
+	"blog-fanchiikawa-service/imagemeta"
+	"blog-fanchiikawa-service/sdk"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMediaUploadMaxBytes = 100 << 20
+	mediaUploadURLExpiry       = 15 * time.Minute
+	// maxMediaExtensionLength matches the size of the file_extension column
+	maxMediaExtensionLength = 10
+)
+
+var (
+	// ErrInvalidUploadKey is returned when a presigned upload key was not issued to the completing user
+	ErrInvalidUploadKey = errors.New("upload key was not issued to this user")
+
+	// ErrUploadNotFound is returned when a presigned upload is completed before the file reached S3
+	ErrUploadNotFound = errors.New("uploaded file not found")
+
+	// ErrUploadTooLarge is returned for uploads above MEDIA_UPLOAD_MAX_BYTES
+	ErrUploadTooLarge = errors.New("file exceeds the upload size limit")
+)
+
+// IngestResult is the outcome of ingesting one file
+type IngestResult struct {
+	// Image is the new image, or the existing image with the same content when Duplicate is set
+	Image     *db.Image
+	Duplicate bool
+	// Jobs are the image's processing jobs
+	Jobs []*db.MediaJob
+}
+
+// MediaUploadTicket lets a client upload one file straight to S3 before calling CompleteUpload with Key
+type MediaUploadTicket struct {
+	UploadURL string
+	Key       string
+	ExpiresAt time.Time
+}
+
+// MediaUploadMaxBytesFromEnv reads MEDIA_UPLOAD_MAX_BYTES, the largest file accepted by uploads
+func MediaUploadMaxBytesFromEnv() int64 {
+	maxBytes := int64(defaultMediaUploadMaxBytes)
+	if value := os.Getenv("MEDIA_UPLOAD_MAX_BYTES"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed <= 0 {
+			log.Printf("Invalid MEDIA_UPLOAD_MAX_BYTES %q, using default %d", value, maxBytes)
+		} else {
+			maxBytes = parsed
+		}
+	}
+	return maxBytes
+}
+
+// mediaExtension returns the lower-case extension of a file name, or "" when it is too long
+// or has characters that do not belong in an S3 key
+func mediaExtension(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	if len(ext) > maxMediaExtensionLength {
+		return ""
+	}
+	for _, c := range ext[min(1, len(ext)):] {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') {
+			return ""
+		}
+	}
+	return ext
+}
+
+// uploadKeyPrefix is where presigned uploads of one owner wait for completion
+func uploadKeyPrefix(ownerID int64) string {
+	return sdk.WarehouseKey(fmt.Sprintf("uploads/%d/", ownerID))
+}
+
+func (s *mediaService) IngestFile(path, originFilename string, ownerID int64) (*IngestResult, error) {
+	image, duplicate, err := s.prepareImage(path, originFilename, ownerID)
+	if err != nil || duplicate != nil {
+		return duplicate, err
+	}
+
+	image.Filename = s.idNode.Generate().String() + mediaExtension(originFilename)
+	image.Bucket = sdk.WarehouseBucket()
+	image.ObjectKey = sdk.WarehouseKey(image.Filename)
+	if err := sdk.UploadFileVerified(image.ObjectKey, path, image.ContentHash); err != nil {
+		if errors.Is(err, sdk.ErrChecksumMismatch) {
+			s.deleteOrphanedObject(image.Bucket, image.ObjectKey)
+		}
+		return nil, fmt.Errorf("failed to upload %s: %w", originFilename, err)
+	}
+	image.Uploaded = true
+
+	result, err := s.createIngestedImage(image, path)
+	if err != nil && image.ID == 0 {
+		s.deleteOrphanedObject(image.Bucket, image.ObjectKey)
+	}
+	return result, err
+}
+
+func (s *mediaService) IngestUpload(r io.Reader, filename string, ownerID int64) (*IngestResult, error) {
+	path, err := s.writeTempFile(r, filename)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(path)
+
+	return s.IngestFile(path, filepath.Base(filename), ownerID)
+}
+
+func (s *mediaService) CreateUploadURL(filename string, ownerID int64) (*MediaUploadTicket, error) {
+	if filename == "" {
+		return nil, fmt.Errorf("filename is required")
+	}
+
+	key := uploadKeyPrefix(ownerID) + s.idNode.Generate().String() + mediaExtension(filename)
+	url, err := sdk.GeneratePresignedPutURL(sdk.WarehouseBucket(), key, mediaUploadURLExpiry)
+	if err != nil {
+		return nil, err
+	}
+	return &MediaUploadTicket{
+		UploadURL: url,
+		Key:       key,
+		ExpiresAt: time.Now().Add(mediaUploadURLExpiry),
+	}, nil
+}
+
+func (s *mediaService) CompleteUpload(key, filename string, ownerID int64) (*IngestResult, error) {
+	if !strings.HasPrefix(key, uploadKeyPrefix(ownerID)) || strings.Contains(key, "..") {
+		return nil, ErrInvalidUploadKey
+	}
+	if filename == "" {
+		filename = filepath.Base(key)
+	}
+
+	// The key was issued by CreateUploadURL, so an upload that is rejected or already in the library is removed
+	result, err := s.ingestObject(sdk.WarehouseBucket(), key, filepath.Base(filename), ownerID)
+	if errors.Is(err, ErrUploadTooLarge) || (err == nil && result.Duplicate) {
+		if err := sdk.DeleteObject(sdk.WarehouseBucket(), key); err != nil {
+			log.Printf("Failed to delete rejected upload %s: %v", key, err)
+		}
+	}
+	return result, err
+}
+
+func (s *mediaService) IngestObject(bucket, key string) (*IngestResult, error) {
+	return s.ingestObject(bucket, key, objectOriginFilename(key), 0)
+}
+
+func (s *mediaService) GetUnregisteredObjectKeys(bucket string, keys []string) ([]string, error) {
+	existing, err := s.imageRepo.GetExistingObjectKeys(bucket, keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up objects: %w", err)
+	}
+	var unregistered []string
+	for _, key := range keys {
+		if !existing[key] {
+			unregistered = append(unregistered, key)
+		}
+	}
+	return unregistered, nil
+}
+
+// objectOriginFilename recovers the uploaded file name from a key issued by GeneratePresignedUploadURL,
+// which prefixes it with the upload time
+func objectOriginFilename(key string) string {
+	name := filepath.Base(key)
+	if timestamp, rest, found := strings.Cut(name, "_"); found && rest != "" {
+		if _, err := strconv.ParseInt(timestamp, 10, 64); err == nil {
+			return rest
+		}
+	}
+	return name
+}
+
+// ingestObject creates the image of an object that is already in S3, keeping it where it is. Registering the same
+// object twice returns the image created the first time. Objects that are too large or already in the library are
+// left alone; only the caller knows whether the object is the service's to delete.
+func (s *mediaService) ingestObject(bucket, key, originFilename string, ownerID int64) (*IngestResult, error) {
+	existing, err := s.imageRepo.GetByObjectKey(bucket, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up upload: %w", err)
+	}
+	if existing != nil {
+		return s.ingestResult(existing, false)
+	}
+
+	size, err := sdk.ObjectSize(bucket, key)
+	if errors.Is(err, sdk.ErrObjectNotFound) {
+		return nil, ErrUploadNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if size > s.uploadMaxBytes {
+		return nil, ErrUploadTooLarge
+	}
+
+	path, err := s.tempFilePath(key)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(path)
+	if err := sdk.DownloadObject(bucket, key, path); err != nil {
+		return nil, err
+	}
+
+	image, duplicate, err := s.prepareImage(path, originFilename, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if duplicate != nil {
+		return duplicate, nil
+	}
+
+	image.Filename = filepath.Base(key)
+	image.Bucket = bucket
+	image.ObjectKey = key
+	image.Uploaded = true
+	return s.createIngestedImage(image, path)
+}
+
+// deleteOrphanedObject removes an upload that no image row refers to
+func (s *mediaService) deleteOrphanedObject(bucket, objectKey string) {
+	if err := sdk.DeleteObject(bucket, objectKey); err != nil {
+		log.Printf("Failed to delete orphaned object s3://%s/%s: %v", bucket, objectKey, err)
+	}
+}
+
+// prepareImage hashes and inspects a local file. It returns the result for the existing image when the content is
+// already in the library, and otherwise a new image carrying the hashes and metadata but no storage location yet.
+func (s *mediaService) prepareImage(path, originFilename string, ownerID int64) (*db.Image, *IngestResult, error) {
+	fingerprint, err := imagehash.FingerprintFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to hash %s: %w", originFilename, err)
+	}
+	duplicate, err := s.FindDuplicate(fingerprint.SHA256)
+	if err != nil {
+		return nil, nil, err
+	}
+	if duplicate != nil {
+		log.Printf("Skipping %s: same content as image ID %d", originFilename, duplicate.ID)
+		result, err := s.ingestResult(duplicate, true)
+		return nil, result, err
+	}
+
+	metadata, err := imagemeta.Read(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read metadata of %s: %w", originFilename, err)
+	}
+	return &db.Image{
+		OriginFilename: originFilename,
+		FileExtension:  mediaExtension(originFilename),
+		ContentHash:    fingerprint.SHA256,
+		PerceptualHash: fingerprint.Perceptual,
+		MimeType:       metadata.MimeType,
+		ByteSize:       metadata.ByteSize,
+		Width:          metadata.Width,
+		Height:         metadata.Height,
+		Orientation:    metadata.Orientation,
+		CapturedAt:     metadata.CapturedAt,
+		CameraMake:     metadata.CameraMake,
+		CameraModel:    metadata.CameraModel,
+		OwnerID:        ownerID,
+	}, nil, nil
+}
+
+// createIngestedImage uploads the thumbnails of an uploaded image and creates it with its processing jobs.
+// Once the image row exists the file counts as ingested; EnqueueMissingJobs re-queues its moderation, label and text
+// jobs at the next start if queueing failed.
+func (s *mediaService) createIngestedImage(image *db.Image, path string) (*IngestResult, error) {
+	thumbnails := s.uploadThumbnails(image, path)
+	jobs, err := s.CreateImage(image, thumbnails)
+	if err != nil && image.ID == 0 {
+		return nil, fmt.Errorf("failed to create image for %s: %w", image.OriginFilename, err)
+	}
+	if err != nil {
+		log.Printf("Failed to queue every job of image ID %d: %v", image.ID, err)
+	}
+	log.Printf("Ingested %s as image ID %d with %d jobs", image.OriginFilename, image.ID, len(jobs))
+	return &IngestResult{Image: image, Jobs: jobs}, nil
+}
+
+// ingestResult reports an image that was already ingested together with its jobs
+func (s *mediaService) ingestResult(image *db.Image, duplicate bool) (*IngestResult, error) {
+	jobs, err := s.mediaJobRepo.List("", image.ID, maxMediaJobListLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs of image %d: %w", image.ID, err)
+	}
+	return &IngestResult{Image: image, Duplicate: duplicate, Jobs: jobs}, nil
+}
+
+// uploadThumbnails renders the thumbnails of an uploaded image from its local file and stores them next to the original
+func (s *mediaService) uploadThumbnails(image *db.Image, path string) []*db.ImageThumbnail {
+	rendered, err := imagemeta.Thumbnails(path, image.Orientation, s.thumbnailOptions)
+	if err != nil {
+		// Documents and formats the standard decoders cannot read simply have no thumbnails
+		if !errors.Is(err, imagemeta.ErrUnsupportedImage) {
+			log.Printf("Failed to render thumbnails of %s: %v", image.OriginFilename, err)
+		}
+		return nil
+	}
+
+	thumbnails := make([]*db.ImageThumbnail, 0, len(rendered))
+	for _, thumbnail := range rendered {
+		key := sdk.ThumbnailKey(image.ObjectKey, thumbnail.Size, imagemeta.ThumbnailExtension)
+		result := sdk.UploadBytes(key, thumbnail.Data, imagemeta.ThumbnailMimeType)
+		if result.Error != nil {
+			continue
+		}
+		thumbnails = append(thumbnails, &db.ImageThumbnail{
+			Size:      thumbnail.Size,
+			Width:     thumbnail.Width,
+			Height:    thumbnail.Height,
+			MimeType:  imagemeta.ThumbnailMimeType,
+			ByteSize:  int64(len(thumbnail.Data)),
+			Bucket:    result.S3Bucket,
+			ObjectKey: result.S3Key,
+		})
+	}
+	return thumbnails
+}
+
+// writeTempFile copies an upload to a temporary file, rejecting content above the size limit
+func (s *mediaService) writeTempFile(r io.Reader, filename string) (string, error) {
+	path, err := s.tempFilePath(filename)
+	if err != nil {
+		return "", err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer file.Close()
+
+	written, err := io.Copy(file, io.LimitReader(r, s.uploadMaxBytes+1))
+	if err == nil && written > s.uploadMaxBytes {
+		err = ErrUploadTooLarge
+	}
+	if err != nil {
+		os.Remove(path)
+		if errors.Is(err, ErrUploadTooLarge) {
+			return "", err
+		}
+		return "", fmt.Errorf("failed to write temp file: %w", err)
+	}
+	return path, nil
+}
+
+// tempFilePath reserves a temporary file that keeps the extension of name, which metadata detection falls back on
+func (s *mediaService) tempFilePath(name string) (string, error) {
+	file, err := os.CreateTemp("", "media-upload-*"+mediaExtension(name))
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	file.Close()
+	return file.Name(), nil
+}
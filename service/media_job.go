@@ -0,0 +1,434 @@
+package service
+
+import (
+	"blog-fanchiikawa-service/db"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultMediaJobMaxAttempts = 5
+	defaultMediaJobBackoff     = 30 * time.Second
+	defaultMediaJobMaxBackoff  = time.Hour
+	defaultMediaJobTimeout     = 15 * time.Minute
+	defaultMediaJobPoll        = 15 * time.Second
+	defaultMediaJobMaxWait     = 7 * 24 * time.Hour
+	mediaJobBatchSize          = 20
+	defaultMediaJobListLimit   = 50
+	maxMediaJobListLimit       = 200
+)
+
+var (
+	// ErrMediaJobNotFound is returned when a job ID does not exist
+	ErrMediaJobNotFound = errors.New("media job not found")
+
+	// ErrUnsupportedMedia marks files no detector can process; such jobs are dead-lettered without retrying
+	ErrUnsupportedMedia = errors.New("unsupported file type")
+
+	// ErrMediaNotUploaded marks images whose upload to S3 failed; such jobs are dead-lettered without retrying
+	ErrMediaNotUploaded = errors.New("file was not uploaded to S3")
+
+	errMediaMissing = errors.New("image no longer exists")
+
+	// errMediaJobWaiting means the job handed work to an asynchronous AWS job and should be polled again
+	errMediaJobWaiting = errors.New("waiting for asynchronous job")
+
+	// errMediaJobWaitExceeded marks jobs that waited longer than maxWait; such jobs are dead-lettered without retrying
+	errMediaJobWaitExceeded = errors.New("waited too long")
+)
+
+// mediaJobOptions controls retries of media processing jobs
+type mediaJobOptions struct {
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	// timeout is how long a job may stay running before it is considered abandoned by a crashed worker
+	timeout time.Duration
+	// poll is how often a job waiting on an asynchronous AWS job checks its status
+	poll time.Duration
+	// maxWait is how long a job may keep waiting on an asynchronous AWS job or a review before it is dead-lettered
+	maxWait time.Duration
+}
+
+func mediaJobOptionsFromEnv() mediaJobOptions {
+	options := mediaJobOptions{
+		maxAttempts: defaultMediaJobMaxAttempts,
+		backoff:     defaultMediaJobBackoff,
+		maxBackoff:  defaultMediaJobMaxBackoff,
+		timeout:     defaultMediaJobTimeout,
+		poll:        defaultMediaJobPoll,
+		maxWait:     defaultMediaJobMaxWait,
+	}
+	if value := os.Getenv("MEDIA_JOB_MAX_ATTEMPTS"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			log.Printf("Invalid MEDIA_JOB_MAX_ATTEMPTS %q, using default %d", value, options.maxAttempts)
+		} else {
+			options.maxAttempts = parsed
+		}
+	}
+	durations := []struct {
+		name   string
+		target *time.Duration
+	}{
+		{"MEDIA_JOB_BACKOFF", &options.backoff},
+		{"MEDIA_JOB_MAX_BACKOFF", &options.maxBackoff},
+		{"MEDIA_JOB_TIMEOUT", &options.timeout},
+		{"MEDIA_JOB_POLL_INTERVAL", &options.poll},
+		{"MEDIA_JOB_MAX_WAIT", &options.maxWait},
+	}
+	for _, d := range durations {
+		value := os.Getenv(d.name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			log.Printf("Invalid %s %q, using default %s", d.name, value, *d.target)
+			continue
+		}
+		*d.target = parsed
+	}
+	return options
+}
+
+// retryDelay doubles the base backoff for every failed attempt, capped at maxBackoff
+func (o mediaJobOptions) retryDelay(attempts int) time.Duration {
+	delay := o.backoff
+	for i := 1; i < attempts && delay < o.maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > o.maxBackoff {
+		delay = o.maxBackoff
+	}
+	return delay
+}
+
+// Error categories shown to media owners instead of the error text, which can name buckets, object keys
+// and database errors
+const (
+	jobErrorUnsupported  = "unsupported file type"
+	jobErrorNotUploaded  = "file was not uploaded"
+	jobErrorMissing      = "file no longer exists"
+	jobErrorWaitExceeded = "waited too long for processing or review"
+	jobErrorFailed       = "processing failed"
+)
+
+// jobErrorCategory sorts a job error into one of the categories shown to media owners
+func jobErrorCategory(err error) string {
+	switch {
+	case errors.Is(err, ErrUnsupportedMedia):
+		return jobErrorUnsupported
+	case errors.Is(err, ErrMediaNotUploaded):
+		return jobErrorNotUploaded
+	case errors.Is(err, errMediaMissing):
+		return jobErrorMissing
+	case errors.Is(err, errMediaJobWaitExceeded):
+		return jobErrorWaitExceeded
+	}
+	return jobErrorFailed
+}
+
+// JobErrorCategory returns the error category of a failed job, or "" when its last attempt did not fail
+func JobErrorCategory(job *db.MediaJob) string {
+	if job.LastError == "" {
+		return ""
+	}
+	if job.ErrorCategory == "" {
+		// Jobs that failed before categories were stored
+		return jobErrorFailed
+	}
+	return job.ErrorCategory
+}
+
+// isPermanentJobError reports errors that retrying cannot fix
+func isPermanentJobError(err error) bool {
+	return errors.Is(err, ErrUnsupportedMedia) || errors.Is(err, ErrMediaNotUploaded) || errors.Is(err, errMediaMissing) || errors.Is(err, errMediaJobWaitExceeded)
+}
+
+// enqueueJob creates a pending job of the given type unless the image already has one, and returns the image's job
+func (s *mediaService) enqueueJob(imageID int64, jobType string) (*db.MediaJob, error) {
+	existing, err := s.mediaJobRepo.GetByImageAndType(imageID, jobType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up %s job for image %d: %w", jobType, imageID, err)
+	}
+	if existing != nil {
+		return existing, nil
+	}
+
+	job := &db.MediaJob{
+		ImageID:     imageID,
+		JobType:     jobType,
+		Status:      db.MediaJobPending,
+		MaxAttempts: s.jobOptions.maxAttempts,
+		NextRunAt:   time.Now(),
+	}
+	if err := s.mediaJobRepo.Create(job); err != nil {
+		return nil, fmt.Errorf("failed to create %s job for image %d: %w", jobType, imageID, err)
+	}
+	return job, nil
+}
+
+func (s *mediaService) EnqueueMissingJobs() error {
+	pending := []struct {
+		jobType string
+		fetch   func() ([]*db.Image, error)
+	}{
+		{db.MediaJobModeration, func() ([]*db.Image, error) { return s.imageRepo.GetByModerationStatus(db.ModerationPending) }},
+		{db.MediaJobLabelDetection, func() ([]*db.Image, error) { return s.imageRepo.GetByLabelDetected(false) }},
+		{db.MediaJobTextDetection, func() ([]*db.Image, error) { return s.imageRepo.GetByTextDetected(false) }},
+		{db.MediaJobCaption, s.imageRepo.GetUncaptioned},
+		{db.MediaJobEmbedding, func() ([]*db.Image, error) { return s.imageRepo.GetUnembedded(s.embedder.Model()) }},
+	}
+	for _, p := range pending {
+		images, err := p.fetch()
+		if err != nil {
+			return fmt.Errorf("failed to fetch images pending %s: %w", p.jobType, err)
+		}
+		for _, image := range images {
+			if _, err := s.enqueueJob(image.ID, p.jobType); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *mediaService) ProcessDueJobs() error {
+	now := time.Now()
+	if reset, err := s.mediaJobRepo.ResetStale(now.Add(-s.jobOptions.timeout)); err != nil {
+		log.Printf("Failed to reset stale media jobs: %v", err)
+	} else if reset > 0 {
+		log.Printf("Reset %d media jobs left running by a stopped worker", reset)
+	}
+
+	jobs, err := s.mediaJobRepo.GetDue(now, mediaJobBatchSize)
+	if err != nil {
+		return fmt.Errorf("failed to fetch due media jobs: %w", err)
+	}
+
+	log.Printf("Found %d due media jobs", len(jobs))
+	for _, job := range jobs {
+		s.processJob(job)
+	}
+	return nil
+}
+
+// processJob claims a due job, runs it and records the outcome
+func (s *mediaService) processJob(job *db.MediaJob) {
+	job.Status = db.MediaJobRunning
+	job.Attempts++
+	claimed, err := s.mediaJobRepo.Transition(job, db.MediaJobPending)
+	if err != nil {
+		log.Printf("Failed to claim media job %d: %v", job.ID, err)
+		return
+	}
+	if claimed == 0 {
+		// Another worker claimed it or it was cancelled meanwhile
+		return
+	}
+
+	runErr := s.runJob(job)
+	now := time.Now()
+	if !errors.Is(runErr, errMediaJobWaiting) {
+		job.WaitingSince = nil
+	} else if job.WaitingSince == nil {
+		job.WaitingSince = &now
+	} else if waited := now.Sub(*job.WaitingSince); waited > s.jobOptions.maxWait {
+		// Files nobody reviews and AWS jobs that never finish must not keep the job polling forever
+		runErr = fmt.Errorf("%w: gave up after waiting %s", errMediaJobWaitExceeded, waited.Round(time.Second))
+		job.WaitingSince = nil
+	}
+
+	if errors.Is(runErr, errMediaJobWaiting) {
+		// Polling an asynchronous job does not use up an attempt
+		job.Status = db.MediaJobPending
+		job.Attempts--
+		job.NextRunAt = now.Add(s.jobOptions.poll)
+		log.Printf("Media job %d (%s, image %d) waiting on %s", job.ID, job.JobType, job.ImageID, job.ExternalJobID)
+	} else if runErr == nil {
+		job.Status = db.MediaJobSucceeded
+		job.LastError = ""
+		job.ErrorCategory = ""
+		log.Printf("Media job %d (%s, image %d) succeeded", job.ID, job.JobType, job.ImageID)
+	} else {
+		job.LastError = runErr.Error()
+		job.ErrorCategory = jobErrorCategory(runErr)
+		if isPermanentJobError(runErr) || job.Attempts >= job.MaxAttempts {
+			job.Status = db.MediaJobDead
+			log.Printf("Media job %d (%s, image %d) dead-lettered after %d attempts: %v", job.ID, job.JobType, job.ImageID, job.Attempts, runErr)
+		} else {
+			job.Status = db.MediaJobPending
+			job.NextRunAt = now.Add(s.jobOptions.retryDelay(job.Attempts))
+			log.Printf("Media job %d (%s, image %d) failed attempt %d/%d, retrying at %s: %v",
+				job.ID, job.JobType, job.ImageID, job.Attempts, job.MaxAttempts, job.NextRunAt.Format(time.RFC3339), runErr)
+		}
+	}
+
+	updated, err := s.mediaJobRepo.Transition(job, db.MediaJobRunning)
+	if err != nil {
+		log.Printf("Failed to record outcome of media job %d: %v", job.ID, err)
+	} else if updated == 0 {
+		log.Printf("Media job %d was cancelled while running, discarding its status", job.ID)
+	}
+}
+
+func (s *mediaService) runJob(job *db.MediaJob) error {
+	image, err := s.imageRepo.GetByID(job.ImageID)
+	if err != nil {
+		return fmt.Errorf("failed to load image %d: %w", job.ImageID, err)
+	}
+	if image == nil {
+		return errMediaMissing
+	}
+
+	switch job.JobType {
+	case db.MediaJobLabelDetection, db.MediaJobTextDetection, db.MediaJobDocumentAnalysis:
+		// Nothing is sent to the detectors before moderation has cleared the file
+		ok, err := s.awaitModeration(image)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			log.Printf("Skipping %s of rejected image ID %d", job.JobType, image.ID)
+			return nil
+		}
+	}
+
+	switch job.JobType {
+	case db.MediaJobLabelDetection:
+		if isVideo(image) {
+			return s.detectVideoLabels(job, image)
+		}
+		return s.detectImageLabels(image)
+	case db.MediaJobTextDetection:
+		if isVideo(image) {
+			return s.detectVideoText(job, image)
+		}
+		return s.detectImageText(job, image)
+	case db.MediaJobModeration:
+		return s.moderateImage(job, image)
+	case db.MediaJobDocumentAnalysis:
+		return s.analyzeDocument(job, image)
+	case db.MediaJobCaption:
+		return s.captionImage(image)
+	case db.MediaJobEmbedding:
+		return s.embedImage(image)
+	default:
+		return fmt.Errorf("%w: unknown job type %q", ErrUnsupportedMedia, job.JobType)
+	}
+}
+
+// waitForJobs returns errMediaJobWaiting while any of the image's jobs of the given types is pending or running.
+// Dead or cancelled jobs are not waited for, so later jobs work with whatever was stored.
+func (s *mediaService) waitForJobs(imageID int64, jobTypes ...string) error {
+	for _, jobType := range jobTypes {
+		job, err := s.mediaJobRepo.GetByImageAndType(imageID, jobType)
+		if err != nil {
+			return fmt.Errorf("failed to look up %s job: %w", jobType, err)
+		}
+		if job != nil && (job.Status == db.MediaJobPending || job.Status == db.MediaJobRunning) {
+			return errMediaJobWaiting
+		}
+	}
+	return nil
+}
+
+func (s *mediaService) ListJobs(status string, imageID int64, limit int) ([]*db.MediaJob, error) {
+	if limit <= 0 {
+		limit = defaultMediaJobListLimit
+	}
+	if limit > maxMediaJobListLimit {
+		limit = maxMediaJobListLimit
+	}
+	jobs, err := s.mediaJobRepo.List(status, imageID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list media jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+func (s *mediaService) RetryJob(id int64) (*db.MediaJob, error) {
+	job, err := s.getJob(id)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := job.Status
+	job.Status = db.MediaJobPending
+	job.Attempts = 0
+	job.MaxAttempts = s.jobOptions.maxAttempts
+	job.NextRunAt = time.Now()
+	job.ExternalJobID = ""
+	job.WaitingSince = nil
+	updated, err := s.mediaJobRepo.Transition(job, db.MediaJobPending, db.MediaJobDead, db.MediaJobCancelled, db.MediaJobSucceeded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retry media job %d: %w", id, err)
+	}
+	if updated == 0 {
+		return nil, fmt.Errorf("media job %d is %s and cannot be retried", id, previous)
+	}
+	log.Printf("Media job %d (%s, image %d) queued for retry", job.ID, job.JobType, job.ImageID)
+	return s.getJob(id)
+}
+
+func (s *mediaService) CancelJob(id int64) (*db.MediaJob, error) {
+	job, err := s.getJob(id)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := job.Status
+	job.Status = db.MediaJobCancelled
+	updated, err := s.mediaJobRepo.Transition(job, db.MediaJobPending, db.MediaJobRunning)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel media job %d: %w", id, err)
+	}
+	if updated == 0 {
+		return nil, fmt.Errorf("media job %d is %s and cannot be cancelled", id, previous)
+	}
+	log.Printf("Media job %d (%s, image %d) cancelled", job.ID, job.JobType, job.ImageID)
+	return s.getJob(id)
+}
+
+func (s *mediaService) GetOwnedJob(id, ownerID int64) (*db.MediaJob, error) {
+	job, err := s.getJob(id)
+	if err != nil {
+		return nil, err
+	}
+	image, err := s.imageRepo.GetByID(job.ImageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image of media job %d: %w", id, err)
+	}
+	// Jobs of other users' images are reported as missing so their IDs reveal nothing
+	if image == nil || image.OwnerID == 0 || image.OwnerID != ownerID {
+		return nil, ErrMediaJobNotFound
+	}
+	return job, nil
+}
+
+func (s *mediaService) ListOwnedImageJobs(imageID, ownerID int64) ([]*db.MediaJob, error) {
+	image, err := s.imageRepo.GetByID(imageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image: %w", err)
+	}
+	if image == nil || image.OwnerID == 0 || image.OwnerID != ownerID {
+		return nil, ErrImageNotFound
+	}
+	return s.ListJobs("", imageID, maxMediaJobListLimit)
+}
+
+func (s *mediaService) getJob(id int64) (*db.MediaJob, error) {
+	job, err := s.mediaJobRepo.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get media job %d: %w", id, err)
+	}
+	if job == nil {
+		return nil, ErrMediaJobNotFound
+	}
+	return job, nil
+}
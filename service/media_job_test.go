@@ -0,0 +1,204 @@
+package service
+
+import (
+	"blog-fanchiikawa-service/db"
+	"blog-fanchiikawa-service/repository"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeImages is an ImageRepository over a map of images
+type fakeImages struct {
+	repository.ImageRepository
+	images map[int64]*db.Image
+}
+
+func (f *fakeImages) GetByID(id int64) (*db.Image, error) {
+	image, ok := f.images[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *image
+	return &copied, nil
+}
+
+func (f *fakeImages) UpdateModerationStatus(id int64, status string, fromStatuses ...string) (int64, error) {
+	image, ok := f.images[id]
+	if !ok || !slices.Contains(fromStatuses, image.ModerationStatus) {
+		return 0, nil
+	}
+	image.ModerationStatus = status
+	return 1, nil
+}
+
+// fakeJobs is a MediaJobRepository over a list of jobs whose transitions always apply
+type fakeJobs struct {
+	repository.MediaJobRepository
+	jobs []*db.MediaJob
+}
+
+func (f *fakeJobs) GetByImageAndType(imageID int64, jobType string) (*db.MediaJob, error) {
+	for _, job := range f.jobs {
+		if job.ImageID == imageID && job.JobType == jobType {
+			copied := *job
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeJobs) Transition(job *db.MediaJob, fromStatuses ...string) (int64, error) {
+	for i, stored := range f.jobs {
+		if stored.ID == job.ID {
+			copied := *job
+			f.jobs[i] = &copied
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (f *fakeJobs) get(id int64) *db.MediaJob {
+	for _, job := range f.jobs {
+		if job.ID == id {
+			return job
+		}
+	}
+	return nil
+}
+
+func newJobTestService(image *db.Image, jobs ...*db.MediaJob) (*mediaService, *fakeJobs) {
+	jobRepo := &fakeJobs{jobs: jobs}
+	return &mediaService{
+		imageRepo:    &fakeImages{images: map[int64]*db.Image{image.ID: image}},
+		mediaJobRepo: jobRepo,
+		jobOptions: mediaJobOptions{
+			maxAttempts: defaultMediaJobMaxAttempts,
+			backoff:     defaultMediaJobBackoff,
+			maxBackoff:  defaultMediaJobMaxBackoff,
+			timeout:     defaultMediaJobTimeout,
+			poll:        defaultMediaJobPoll,
+			maxWait:     defaultMediaJobMaxWait,
+		},
+		publisher: NewEventPublisher(),
+	}, jobRepo
+}
+
+func TestJobsOfRejectedImagesAreSkipped(t *testing.T) {
+	// No AWS client is set up, so a job that reached its detector would fail instead of succeeding
+	for _, jobType := range []string{db.MediaJobLabelDetection, db.MediaJobTextDetection, db.MediaJobDocumentAnalysis} {
+		t.Run(jobType, func(t *testing.T) {
+			image := &db.Image{ID: 1, FileExtension: ".pdf", Uploaded: true, ModerationStatus: db.ModerationRejected}
+			s, jobs := newJobTestService(image,
+				&db.MediaJob{ID: 1, ImageID: 1, JobType: db.MediaJobModeration, Status: db.MediaJobSucceeded},
+				&db.MediaJob{ID: 2, ImageID: 1, JobType: jobType, Status: db.MediaJobPending, MaxAttempts: 5},
+			)
+
+			s.processJob(jobs.get(2))
+			if job := jobs.get(2); job.Status != db.MediaJobSucceeded || job.LastError != "" {
+				t.Fatalf("job is %s with error %q, want it skipped as succeeded", job.Status, job.LastError)
+			}
+		})
+	}
+}
+
+func TestJobsWaitForModeration(t *testing.T) {
+	image := &db.Image{ID: 1, FileExtension: ".pdf", Uploaded: true, ModerationStatus: db.ModerationPending}
+	s, jobs := newJobTestService(image,
+		&db.MediaJob{ID: 1, ImageID: 1, JobType: db.MediaJobModeration, Status: db.MediaJobPending},
+		&db.MediaJob{ID: 2, ImageID: 1, JobType: db.MediaJobDocumentAnalysis, Status: db.MediaJobPending, MaxAttempts: 5},
+	)
+
+	before := time.Now()
+	s.processJob(jobs.get(2))
+	job := jobs.get(2)
+	if job.Status != db.MediaJobPending || job.Attempts != 0 || job.NextRunAt.Before(before.Add(s.jobOptions.poll)) {
+		t.Fatalf("job is %s after %d attempts, next run %s; want it polled again", job.Status, job.Attempts, job.NextRunAt)
+	}
+	if job.WaitingSince == nil || job.WaitingSince.Before(before) {
+		t.Fatalf("waiting since %v, want the time of the first poll", job.WaitingSince)
+	}
+
+	// Later polls keep the time the job started waiting
+	started := *job.WaitingSince
+	job.NextRunAt = time.Now()
+	s.processJob(job)
+	if job := jobs.get(2); job.Status != db.MediaJobPending || job.WaitingSince == nil || !job.WaitingSince.Equal(started) {
+		t.Fatalf("job is %s, waiting since %v; want it still waiting since %s", job.Status, job.WaitingSince, started)
+	}
+}
+
+func TestJobWaitIsCapped(t *testing.T) {
+	image := &db.Image{ID: 1, FileExtension: ".pdf", Uploaded: true, ModerationStatus: db.ModerationQuarantined}
+	s, jobs := newJobTestService(image,
+		&db.MediaJob{ID: 1, ImageID: 1, JobType: db.MediaJobModeration, Status: db.MediaJobSucceeded},
+		&db.MediaJob{ID: 2, ImageID: 1, JobType: db.MediaJobDocumentAnalysis, Status: db.MediaJobPending, MaxAttempts: 5},
+	)
+	waitingSince := time.Now().Add(-s.jobOptions.maxWait - time.Minute)
+	jobs.get(2).WaitingSince = &waitingSince
+
+	s.processJob(jobs.get(2))
+	job := jobs.get(2)
+	if job.Status != db.MediaJobDead || !strings.Contains(job.LastError, "gave up after waiting") {
+		t.Fatalf("job is %s with error %q, want it dead-lettered for waiting too long", job.Status, job.LastError)
+	}
+	if job.ErrorCategory != jobErrorWaitExceeded {
+		t.Fatalf("error category %q, want %q", job.ErrorCategory, jobErrorWaitExceeded)
+	}
+	if job.WaitingSince != nil {
+		t.Fatalf("dead job still waiting since %s", job.WaitingSince)
+	}
+}
+
+func TestDocumentModerationPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		quarantine bool
+		want       string
+	}{
+		{false, db.ModerationPassed},
+		{true, db.ModerationQuarantined},
+	} {
+		image := &db.Image{ID: 1, FileExtension: ".PDF", Uploaded: true, ModerationStatus: db.ModerationPending}
+		s, jobs := newJobTestService(image,
+			&db.MediaJob{ID: 1, ImageID: 1, JobType: db.MediaJobModeration, Status: db.MediaJobPending, MaxAttempts: 5},
+		)
+		s.moderationOptions.quarantineDocuments = tc.quarantine
+		s.moderationOptions.quarantineUnsupported = true
+
+		s.processJob(jobs.get(1))
+		if job := jobs.get(1); job.Status != db.MediaJobSucceeded {
+			t.Fatalf("quarantine documents %v: moderation job is %s with error %q", tc.quarantine, job.Status, job.LastError)
+		}
+		if image.ModerationStatus != tc.want {
+			t.Errorf("quarantine documents %v: document is %s, want %s", tc.quarantine, image.ModerationStatus, tc.want)
+		}
+	}
+}
+
+func TestJobErrorCategory(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("%w: .exe", ErrUnsupportedMedia), jobErrorUnsupported},
+		{ErrMediaNotUploaded, jobErrorNotUploaded},
+		{errMediaMissing, jobErrorMissing},
+		{fmt.Errorf("%w: gave up after waiting 1h", errMediaJobWaitExceeded), jobErrorWaitExceeded},
+		{errors.New("failed to save labels: Error 1062: Duplicate entry 'secret-bucket/key.jpg'"), jobErrorFailed},
+	} {
+		if got := jobErrorCategory(tc.err); got != tc.want {
+			t.Errorf("jobErrorCategory(%v) = %q, want %q", tc.err, got, tc.want)
+		}
+	}
+
+	if got := JobErrorCategory(&db.MediaJob{}); got != "" {
+		t.Errorf("a job without an error has category %q", got)
+	}
+	if got := JobErrorCategory(&db.MediaJob{LastError: "AccessDenied: s3://bucket/key"}); got != jobErrorFailed {
+		t.Errorf("a job failed before categories were stored has category %q", got)
+	}
+}
@@ -0,0 +1,647 @@
+package service
+
+import (
+	"blog-fanchiikawa-service/db"
+	"blog-fanchiikawa-service/graph/model"
+	"blog-fanchiikawa-service/repository"
+	"blog-fanchiikawa-service/sdk"
+	"blog-fanchiikawa-service/search"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultImagePageSize = 20
+	maxImagePageSize     = 100
+	imageCursorPrefix    = "image:"
+	defaultFacetLimit    = 20
+)
+
+// MediaLibraryService exposes ingested images with their labels and keywords
+type MediaLibraryService interface {
+	ListImages(filter *model.ImageFilter, first int, after string) (*model.ImageConnection, error)
+	GetImage(id int64) (*model.Image, error)
+	// ListLabels lists the curated labels; hidden ones only when includeHidden is set
+	ListLabels(includeHidden bool) ([]*model.Label, error)
+	SearchImages(query string, filter *model.ImageSearchFilter, first int, after string, facetLimit int) (*model.ImageSearchResult, error)
+	// SemanticSearchImages returns up to k visible images whose embedded text is closest in meaning to the query
+	SemanticSearchImages(query string, k int) ([]*model.SemanticSearchHit, error)
+	GetTextBlocks(imageID int64, page int, blockType string) ([]*model.TextBlock, error)
+	GetPageTexts(imageID int64) ([]*model.PageText, error)
+	GetDocumentFields(imageID int64) ([]*model.DocumentField, error)
+	GetDocumentTables(imageID int64) ([]*model.DocumentTable, error)
+	GetDocumentTable(id int64) (*model.DocumentTable, error)
+	// ModerationQueue lists quarantined images waiting for review
+	ModerationQueue(first int, after string) (*model.ImageConnection, error)
+	// ReviewImage approves or rejects a quarantined or automatically passed image
+	ReviewImage(id int64, approve bool) (*model.Image, error)
+	// SimilarImages lists visible images whose perceptual hash is within maxDistance of the image's, nearest first;
+	// a negative maxDistance uses DUPLICATE_MAX_DISTANCE
+	SimilarImages(imageID int64, maxDistance int, first int) ([]*model.SimilarImage, error)
+	// VideoTimeline returns the label and text tracks of a visible video with segments of at least minConfidence
+	VideoTimeline(imageID int64, minConfidence float64) (*model.VideoTimeline, error)
+	// UpdateImageCaption sets or clears the edited overrides of an image's caption in one language
+	UpdateImageCaption(imageID int64, language string, input model.ImageCaptionInput) (*model.ImageCaption, error)
+	// ExportDocumentTableCSV renders a table as CSV, returning ErrDocumentTableNotFound for unknown IDs
+	ExportDocumentTableCSV(id int64) ([]byte, error)
+}
+
+type mediaLibraryService struct {
+	imageRepo              repository.ImageRepository
+	labelRepo              repository.LabelRepository
+	labelAliasRepo         repository.LabelAliasRepository
+	imageLabelRepo         repository.ImageLabelRepository
+	imageLabelInstanceRepo repository.ImageLabelInstanceRepository
+	textKeywordRepo        repository.TextKeywordRepository
+	textBlockRepo          repository.ImageTextBlockRepository
+	pageTextRepo           repository.ImagePageTextRepository
+	documentRepo           repository.DocumentAnalysisRepository
+	moderationLabelRepo    repository.ImageModerationLabelRepository
+	thumbnailRepo          repository.ImageThumbnailRepository
+	videoSegmentRepo       repository.VideoSegmentRepository
+	captionRepo            repository.ImageCaptionRepository
+	semanticIndex          *semanticIndex
+	duplicateMaxDistance   int
+}
+
+func NewMediaLibraryService(imageRepo repository.ImageRepository, labelRepo repository.LabelRepository, labelAliasRepo repository.LabelAliasRepository, imageLabelRepo repository.ImageLabelRepository, imageLabelInstanceRepo repository.ImageLabelInstanceRepository, textKeywordRepo repository.TextKeywordRepository, textBlockRepo repository.ImageTextBlockRepository, pageTextRepo repository.ImagePageTextRepository, documentRepo repository.DocumentAnalysisRepository, moderationLabelRepo repository.ImageModerationLabelRepository, thumbnailRepo repository.ImageThumbnailRepository, videoSegmentRepo repository.VideoSegmentRepository, captionRepo repository.ImageCaptionRepository, embeddingRepo repository.ImageEmbeddingRepository) MediaLibraryService {
+	return &mediaLibraryService{
+		imageRepo:              imageRepo,
+		labelRepo:              labelRepo,
+		labelAliasRepo:         labelAliasRepo,
+		imageLabelRepo:         imageLabelRepo,
+		imageLabelInstanceRepo: imageLabelInstanceRepo,
+		textKeywordRepo:        textKeywordRepo,
+		textBlockRepo:          textBlockRepo,
+		pageTextRepo:           pageTextRepo,
+		documentRepo:           documentRepo,
+		moderationLabelRepo:    moderationLabelRepo,
+		thumbnailRepo:          thumbnailRepo,
+		videoSegmentRepo:       videoSegmentRepo,
+		captionRepo:            captionRepo,
+		semanticIndex:          newSemanticIndex(NewEmbeddingProvider(), embeddingRepo),
+		duplicateMaxDistance:   duplicateMaxDistanceFromEnv(),
+	}
+}
+
+func (s *mediaLibraryService) ListImages(filter *model.ImageFilter, first int, after string) (*model.ImageConnection, error) {
+	first, beforeID, err := pageArgs(first, after)
+	if err != nil {
+		return nil, err
+	}
+
+	repoFilter, err := s.toRepositoryImageFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	repoFilter.ModerationStatuses = db.ModerationVisibleStatuses
+
+	// Fetch one extra row to learn whether another page follows
+	images, err := s.imageRepo.List(repoFilter, beforeID, first+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+
+	total, err := s.imageRepo.Count(repoFilter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count images: %w", err)
+	}
+
+	return s.toImageConnection(images, first, total)
+}
+
+func (s *mediaLibraryService) GetImage(id int64) (*model.Image, error) {
+	image, err := s.imageRepo.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image: %w", err)
+	}
+	if image == nil || !isVisible(image) {
+		return nil, nil
+	}
+
+	nodes, err := s.toImageModels([]*db.Image{image})
+	if err != nil {
+		return nil, err
+	}
+	return nodes[0], nil
+}
+
+func (s *mediaLibraryService) ListLabels(includeHidden bool) ([]*model.Label, error) {
+	all, err := s.labelRepo.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list labels: %w", err)
+	}
+
+	labels := make([]*db.Label, 0, len(all))
+	labelIDs := make([]int64, 0, len(all))
+	for _, label := range all {
+		if label.Hidden && !includeHidden {
+			continue
+		}
+		labels = append(labels, label)
+		labelIDs = append(labelIDs, label.ID)
+	}
+	parents, err := s.labelRepo.GetParentsByLabelIDs(labelIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load label parents: %w", err)
+	}
+	aliases, err := s.labelAliasRepo.GetByLabelIDs(labelIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load label aliases: %w", err)
+	}
+
+	result := make([]*model.Label, 0, len(labels))
+	for _, label := range labels {
+		result = append(result, toLabelModel(label, parents[label.ID], aliases[label.ID]))
+	}
+	return result, nil
+}
+
+func (s *mediaLibraryService) SearchImages(query string, filter *model.ImageSearchFilter, first int, after string, facetLimit int) (*model.ImageSearchResult, error) {
+	first, beforeID, err := pageArgs(first, after)
+	if err != nil {
+		return nil, err
+	}
+	if facetLimit <= 0 {
+		facetLimit = defaultFacetLimit
+	}
+
+	parsed, err := search.Parse(query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid search query: %w", err)
+	}
+	structured, err := toSearchExpr(filter)
+	if err != nil {
+		return nil, err
+	}
+	expr := search.AllOf(parsed, structured)
+	// Label terms match the label a name or alias resolves to and every label below it in the hierarchy
+	if hasLabelTerm(expr) {
+		taxonomy, err := loadTaxonomy(s.labelRepo, s.labelAliasRepo)
+		if err != nil {
+			return nil, err
+		}
+		expr = taxonomy.expandSearch(expr)
+	}
+
+	images, err := s.imageRepo.Search(expr, beforeID, first+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search images: %w", err)
+	}
+	total, err := s.imageRepo.CountSearch(expr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count search results: %w", err)
+	}
+	connection, err := s.toImageConnection(images, first, total)
+	if err != nil {
+		return nil, err
+	}
+
+	labelFacets, err := s.imageRepo.LabelFacets(expr, facetLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count label facets: %w", err)
+	}
+	keywordFacets, err := s.imageRepo.KeywordFacets(expr, facetLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count keyword facets: %w", err)
+	}
+
+	return &model.ImageSearchResult{
+		Images:        connection,
+		LabelFacets:   toFacetModels(labelFacets),
+		KeywordFacets: toFacetModels(keywordFacets),
+	}, nil
+}
+
+func (s *mediaLibraryService) GetTextBlocks(imageID int64, page int, blockType string) ([]*model.TextBlock, error) {
+	blocks, err := s.textBlockRepo.GetByImageID(imageID, page, blockType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get text blocks: %w", err)
+	}
+
+	result := make([]*model.TextBlock, 0, len(blocks))
+	for _, block := range blocks {
+		polygon := []*model.Point{}
+		if block.Polygon != "" {
+			if err := json.Unmarshal([]byte(block.Polygon), &polygon); err != nil {
+				log.Printf("Invalid polygon for text block ID %d: %v", block.ID, err)
+			}
+		}
+		result = append(result, &model.TextBlock{
+			ID:         block.ID,
+			Type:       model.TextBlockType(block.BlockType),
+			Text:       block.Text,
+			Confidence: block.Confidence,
+			Page:       int32(block.Page),
+			Index:      int32(block.BlockIndex),
+			BoundingBox: &model.BoundingBox{
+				Left:   block.BoxLeft,
+				Top:    block.BoxTop,
+				Width:  block.BoxWidth,
+				Height: block.BoxHeight,
+			},
+			Polygon: polygon,
+		})
+	}
+	return result, nil
+}
+
+func (s *mediaLibraryService) GetPageTexts(imageID int64) ([]*model.PageText, error) {
+	pages, err := s.pageTextRepo.GetByImageID(imageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get page texts: %w", err)
+	}
+
+	result := make([]*model.PageText, 0, len(pages))
+	for _, page := range pages {
+		result = append(result, &model.PageText{
+			Page: int32(page.Page),
+			Text: page.Text,
+		})
+	}
+	return result, nil
+}
+
+// toThumbnailModels converts thumbnails to the GraphQL model with presigned view URLs
+func toThumbnailModels(thumbnails []*db.ImageThumbnail) []*model.Thumbnail {
+	result := make([]*model.Thumbnail, 0, len(thumbnails))
+	for _, thumbnail := range thumbnails {
+		node := &model.Thumbnail{
+			Size:     int32(thumbnail.Size),
+			Width:    int32(thumbnail.Width),
+			Height:   int32(thumbnail.Height),
+			MimeType: thumbnail.MimeType,
+			ByteSize: int(thumbnail.ByteSize),
+		}
+		url, err := sdk.GeneratePresignedURL(thumbnail.Bucket, thumbnail.ObjectKey)
+		if err != nil {
+			log.Printf("Failed to sign view URL for thumbnail ID %d: %v", thumbnail.ID, err)
+		} else {
+			node.URL = &url
+		}
+		result = append(result, node)
+	}
+	return result
+}
+
+// nonEmpty returns nil for an empty string so optional fields are null
+func nonEmpty(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// toImageConnection turns a page fetched with one extra row into a connection
+func (s *mediaLibraryService) toImageConnection(images []*db.Image, first int, total int64) (*model.ImageConnection, error) {
+	hasNextPage := len(images) > first
+	if hasNextPage {
+		images = images[:first]
+	}
+
+	nodes, err := s.toImageModels(images)
+	if err != nil {
+		return nil, err
+	}
+
+	connection := &model.ImageConnection{
+		Edges:      make([]*model.ImageEdge, 0, len(nodes)),
+		PageInfo:   &model.PageInfo{HasNextPage: hasNextPage},
+		TotalCount: int32(total),
+	}
+	for _, node := range nodes {
+		connection.Edges = append(connection.Edges, &model.ImageEdge{
+			Cursor: encodeImageCursor(node.ID),
+			Node:   node,
+		})
+	}
+	if len(connection.Edges) > 0 {
+		endCursor := connection.Edges[len(connection.Edges)-1].Cursor
+		connection.PageInfo.EndCursor = &endCursor
+	}
+
+	return connection, nil
+}
+
+// toImageModels loads labels, their details and keywords for a page of images with one query per table
+func (s *mediaLibraryService) toImageModels(images []*db.Image) ([]*model.Image, error) {
+	imageIDs := make([]int64, 0, len(images))
+	for _, image := range images {
+		imageIDs = append(imageIDs, image.ID)
+	}
+
+	labels, err := s.labelRepo.GetByImageIDs(imageIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load image labels: %w", err)
+	}
+	keywords, err := s.textKeywordRepo.GetByImageIDs(imageIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load image keywords: %w", err)
+	}
+	imageLabels, err := s.imageLabelRepo.GetByImageIDs(imageIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load image label details: %w", err)
+	}
+	instances, err := s.imageLabelInstanceRepo.GetByImageIDs(imageIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load label instances: %w", err)
+	}
+	moderationLabels, err := s.moderationLabelRepo.GetByImageIDs(imageIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load moderation labels: %w", err)
+	}
+	thumbnails, err := s.thumbnailRepo.GetByImageIDs(imageIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load thumbnails: %w", err)
+	}
+	captions, err := s.captionRepo.GetByImageIDs(imageIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load captions: %w", err)
+	}
+
+	labelIDs := make([]int64, 0)
+	seenLabels := make(map[int64]bool)
+	for _, imageID := range imageIDs {
+		for _, label := range labels[imageID] {
+			if !label.Hidden && !seenLabels[label.ID] {
+				seenLabels[label.ID] = true
+				labelIDs = append(labelIDs, label.ID)
+			}
+		}
+	}
+	parents, err := s.labelRepo.GetParentsByLabelIDs(labelIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load label parents: %w", err)
+	}
+	aliases, err := s.labelAliasRepo.GetByLabelIDs(labelIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load label aliases: %w", err)
+	}
+
+	result := make([]*model.Image, 0, len(images))
+	for _, image := range images {
+		node := &model.Image{
+			ID:               image.ID,
+			Filename:         image.Filename,
+			OriginFilename:   image.OriginFilename,
+			FileExtension:    image.FileExtension,
+			Bucket:           image.Bucket,
+			ObjectKey:        image.ObjectKey,
+			Uploaded:         image.Uploaded,
+			LabelDetected:    image.LabelDetected,
+			TextDetected:     image.TextDetected,
+			Labels:           make([]*model.Label, 0, len(labels[image.ID])),
+			LabelDetails:     make([]*model.ImageLabel, 0, len(imageLabels[image.ID])),
+			Keywords:         make([]*model.TextKeyword, 0, len(keywords[image.ID])),
+			ModerationStatus: toModerationStatusModel(image.ModerationStatus),
+			ModerationLabels: toModerationLabelModels(moderationLabels[image.ID]),
+			ContentHash:      nonEmpty(image.ContentHash),
+			PerceptualHash:   nonEmpty(image.PerceptualHash),
+			MimeType:         nonEmpty(image.MimeType),
+			Orientation:      int32(image.Orientation),
+			CapturedAt:       image.CapturedAt,
+			CameraMake:       nonEmpty(image.CameraMake),
+			CameraModel:      nonEmpty(image.CameraModel),
+			Thumbnails:       toThumbnailModels(thumbnails[image.ID]),
+			Captions:         toImageCaptionModels(captions[image.ID]),
+			CreatedAt:        image.CreatedAt,
+			UpdatedAt:        image.UpdatedAt,
+		}
+		if image.FullText != "" {
+			fullText := image.FullText
+			node.FullText = &fullText
+		}
+		if image.ClusterID != 0 {
+			clusterID := image.ClusterID
+			node.ClusterID = &clusterID
+		}
+		if image.OwnerID != 0 {
+			ownerID := image.OwnerID
+			node.OwnerID = &ownerID
+		}
+		if image.ByteSize > 0 {
+			byteSize := int(image.ByteSize)
+			node.ByteSize = &byteSize
+		}
+		if image.Width > 0 && image.Height > 0 {
+			width, height := int32(image.Width), int32(image.Height)
+			node.Width = &width
+			node.Height = &height
+		}
+		if image.DurationMs > 0 {
+			durationMs := int(image.DurationMs)
+			node.DurationMs = &durationMs
+		}
+
+		if image.Uploaded {
+			url, err := sdk.GeneratePresignedURL(image.Bucket, image.ObjectKey)
+			if err != nil {
+				log.Printf("Failed to sign view URL for image ID %d: %v", image.ID, err)
+			} else {
+				node.URL = &url
+			}
+		}
+
+		labelsByID := make(map[int64]*model.Label)
+		for _, label := range labels[image.ID] {
+			if label.Hidden {
+				continue
+			}
+			labelModel := toLabelModel(label, parents[label.ID], aliases[label.ID])
+			labelsByID[label.ID] = labelModel
+			node.Labels = append(node.Labels, labelModel)
+		}
+
+		instancesByLabel := make(map[int64][]*model.LabelInstance)
+		for _, instance := range instances[image.ID] {
+			instancesByLabel[instance.LabelID] = append(instancesByLabel[instance.LabelID], &model.LabelInstance{
+				Confidence: instance.Confidence,
+				BoundingBox: &model.BoundingBox{
+					Left:   instance.BoxLeft,
+					Top:    instance.BoxTop,
+					Width:  instance.BoxWidth,
+					Height: instance.BoxHeight,
+				},
+			})
+		}
+		for _, imageLabel := range imageLabels[image.ID] {
+			labelModel, ok := labelsByID[imageLabel.LabelID]
+			if !ok {
+				continue
+			}
+			detail := &model.ImageLabel{
+				Label:      labelModel,
+				Confidence: imageLabel.Confidence,
+				Instances:  instancesByLabel[imageLabel.LabelID],
+			}
+			if detail.Instances == nil {
+				detail.Instances = []*model.LabelInstance{}
+			}
+			node.LabelDetails = append(node.LabelDetails, detail)
+		}
+		for _, keyword := range keywords[image.ID] {
+			if keyword.Hidden {
+				continue
+			}
+			node.Keywords = append(node.Keywords, toTextKeywordModel(keyword))
+		}
+
+		result = append(result, node)
+	}
+	return result, nil
+}
+
+func toLabelModel(label *db.Label, parents []*db.Label, aliases []*db.LabelAlias) *model.Label {
+	result := &model.Label{
+		ID:      label.ID,
+		Name:    label.Name,
+		Parents: make([]string, 0, len(parents)),
+		Aliases: make([]string, 0, len(aliases)),
+		Hidden:  label.Hidden,
+	}
+	if label.Category != "" {
+		category := label.Category
+		result.Category = &category
+	}
+	for _, parent := range parents {
+		result.Parents = append(result.Parents, parent.Name)
+	}
+	for _, alias := range aliases {
+		result.Aliases = append(result.Aliases, alias.Alias)
+	}
+	return result
+}
+
+func toTextKeywordModel(keyword *db.TextKeyword) *model.TextKeyword {
+	return &model.TextKeyword{ID: keyword.ID, Keyword: keyword.Keyword, Hidden: keyword.Hidden}
+}
+
+// toRepositoryImageFilter resolves each requested label through the taxonomy to the names of the label and its descendants
+func (s *mediaLibraryService) toRepositoryImageFilter(filter *model.ImageFilter) (*repository.ImageFilter, error) {
+	if filter == nil {
+		return &repository.ImageFilter{}, nil
+	}
+	result := &repository.ImageFilter{
+		Keywords:      filter.Keywords,
+		LabelDetected: filter.LabelDetected,
+		TextDetected:  filter.TextDetected,
+	}
+	if len(filter.Labels) > 0 {
+		taxonomy, err := loadTaxonomy(s.labelRepo, s.labelAliasRepo)
+		if err != nil {
+			return nil, err
+		}
+		for _, label := range filter.Labels {
+			result.Labels = append(result.Labels, taxonomy.expand(label))
+		}
+	}
+	if filter.Filename != nil {
+		result.Filename = strings.TrimSpace(*filter.Filename)
+	}
+	return result, nil
+}
+
+// toSearchExpr converts the structured search filter into a search expression
+func toSearchExpr(filter *model.ImageSearchFilter) (search.Expr, error) {
+	if filter == nil {
+		return nil, nil
+	}
+
+	var must []search.Expr
+	for _, term := range filter.Must {
+		expr, err := toSearchTerm(term)
+		if err != nil {
+			return nil, err
+		}
+		must = append(must, expr)
+	}
+
+	var should []search.Expr
+	for _, term := range filter.Should {
+		expr, err := toSearchTerm(term)
+		if err != nil {
+			return nil, err
+		}
+		should = append(should, expr)
+	}
+	must = append(must, search.AnyOf(should...))
+
+	for _, term := range filter.MustNot {
+		expr, err := toSearchTerm(term)
+		if err != nil {
+			return nil, err
+		}
+		must = append(must, &search.Not{Expr: expr})
+	}
+
+	if filter.CreatedAfter != nil {
+		must = append(must, &search.Term{Field: search.FieldAfter, Time: *filter.CreatedAfter})
+	}
+	if filter.CreatedBefore != nil {
+		must = append(must, &search.Term{Field: search.FieldBefore, Time: *filter.CreatedBefore})
+	}
+
+	return search.AllOf(must...), nil
+}
+
+func toSearchTerm(term *model.SearchTerm) (search.Expr, error) {
+	var parts []search.Expr
+	if term.Label != nil && *term.Label != "" {
+		parts = append(parts, &search.Term{Field: search.FieldLabel, Value: *term.Label})
+	}
+	if term.Keyword != nil && *term.Keyword != "" {
+		parts = append(parts, &search.Term{Field: search.FieldKeyword, Value: *term.Keyword})
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("search term needs a label or a keyword")
+	}
+	return search.AllOf(parts...), nil
+}
+
+func toFacetModels(facets []*repository.FacetCount) []*model.Facet {
+	result := make([]*model.Facet, 0, len(facets))
+	for _, facet := range facets {
+		result = append(result, &model.Facet{Value: facet.Value, Count: int32(facet.Count)})
+	}
+	return result
+}
+
+// pageArgs applies the page size bounds and decodes the after cursor
+func pageArgs(first int, after string) (int, int64, error) {
+	if first <= 0 {
+		first = defaultImagePageSize
+	}
+	if first > maxImagePageSize {
+		first = maxImagePageSize
+	}
+
+	var beforeID int64
+	if after != "" {
+		id, err := decodeImageCursor(after)
+		if err != nil {
+			return 0, 0, err
+		}
+		beforeID = id
+	}
+	return first, beforeID, nil
+}
+
+func encodeImageCursor(id int64) string {
+	return base64.StdEncoding.EncodeToString([]byte(imageCursorPrefix + strconv.FormatInt(id, 10)))
+}
+
+func decodeImageCursor(cursor string) (int64, error) {
+	decoded, err := base64.StdEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(decoded), imageCursorPrefix) {
+		return 0, fmt.Errorf("invalid cursor: %s", cursor)
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(string(decoded), imageCursorPrefix), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cursor: %s", cursor)
+	}
+	return id, nil
+}
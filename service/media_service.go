@@ -2,151 +2,332 @@ package service
 
 import (
 	"blog-fanchiikawa-service/db"
+	"blog-fanchiikawa-service/imagemeta"
 	"blog-fanchiikawa-service/repository"
 	"blog-fanchiikawa-service/sdk"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	"xorm.io/xorm"
 )
 
 type MediaService interface {
-	CreateImage(filename, originFilename, fileExtension, bucket, objectKey string, uploaded bool) error
-	DetectAndSaveImageLabels() error
-	DetectAndSaveImageText() error
+	// CreateImage stores an ingested file with its hashes, metadata and uploaded thumbnails and queues its processing jobs
+	CreateImage(image *db.Image, thumbnails []*db.ImageThumbnail) ([]*db.MediaJob, error)
+
+	// IngestFile hashes, inspects and uploads a local file and creates its image; the file itself is left in place.
+	// Content that is already in the library is not ingested again and the existing image is returned as a duplicate.
+	IngestFile(path, originFilename string, ownerID int64) (*IngestResult, error)
+
+	// IngestUpload ingests a file uploaded through the API
+	IngestUpload(r io.Reader, filename string, ownerID int64) (*IngestResult, error)
+
+	// CreateUploadURL issues a presigned URL for uploading one file straight to S3
+	CreateUploadURL(filename string, ownerID int64) (*MediaUploadTicket, error)
+
+	// CompleteUpload ingests a file uploaded with a URL from CreateUploadURL
+	CompleteUpload(key, filename string, ownerID int64) (*IngestResult, error)
+
+	// IngestObject creates the image of an object uploaded straight to S3 without an owner
+	IngestObject(bucket, key string) (*IngestResult, error)
+
+	// GetUnregisteredObjectKeys returns the keys in the bucket that no image refers to yet
+	GetUnregisteredObjectKeys(bucket string, keys []string) ([]string, error)
+
+	// GetOwnedJob returns a processing job of one of the owner's images, or ErrMediaJobNotFound
+	GetOwnedJob(id, ownerID int64) (*db.MediaJob, error)
+
+	// ListOwnedImageJobs lists the processing jobs of one of the owner's images, or returns ErrImageNotFound
+	ListOwnedImageJobs(imageID, ownerID int64) ([]*db.MediaJob, error)
+
+	// FindDuplicate returns the image with the same content hash, or nil
+	FindDuplicate(contentHash string) (*db.Image, error)
+
+	// EnqueueMissingJobs creates processing jobs for undetected images that have none, such as rows from before jobs existed
+	EnqueueMissingJobs() error
+
+	// ProcessDueJobs runs the pending jobs whose next run time has passed
+	ProcessDueJobs() error
+
+	// ListJobs retrieves jobs newest first; status "" and imageID 0 match every job
+	ListJobs(status string, imageID int64, limit int) ([]*db.MediaJob, error)
+
+	// RetryJob resets a finished job's attempts and schedules it to run immediately
+	RetryJob(id int64) (*db.MediaJob, error)
+
+	// CancelJob stops a pending or running job from being run again
+	CancelJob(id int64) (*db.MediaJob, error)
 }
 
 type mediaService struct {
-	imageRepo            repository.ImageRepository
-	labelRepo            repository.LabelRepository
-	imageLabelRepo       repository.ImageLabelRepository
-	textKeywordRepo      repository.TextKeywordRepository
-	imageTextKeywordRepo repository.ImageTextKeywordRepository
-	transactionMgr       repository.TransactionManager
+	imageRepo              repository.ImageRepository
+	labelRepo              repository.LabelRepository
+	labelParentRepo        repository.LabelParentRepository
+	labelAliasRepo         repository.LabelAliasRepository
+	taxonomyBlockRepo      repository.TaxonomyBlockRepository
+	imageLabelRepo         repository.ImageLabelRepository
+	imageLabelInstanceRepo repository.ImageLabelInstanceRepository
+	textKeywordRepo        repository.TextKeywordRepository
+	imageTextKeywordRepo   repository.ImageTextKeywordRepository
+	textBlockRepo          repository.ImageTextBlockRepository
+	pageTextRepo           repository.ImagePageTextRepository
+	documentRepo           repository.DocumentAnalysisRepository
+	moderationLabelRepo    repository.ImageModerationLabelRepository
+	thumbnailRepo          repository.ImageThumbnailRepository
+	videoSegmentRepo       repository.VideoSegmentRepository
+	captionRepo            repository.ImageCaptionRepository
+	embeddingRepo          repository.ImageEmbeddingRepository
+	mediaJobRepo           repository.MediaJobRepository
+	transactionMgr         repository.TransactionManager
+	publisher              EventPublisher
+	describer              *sdk.AnthropicService
+	embedder               EmbeddingProvider
+	labelOptions           sdk.DetectLabelsOptions
+	jobOptions             mediaJobOptions
+	moderationOptions      moderationOptions
+	captionOptions         captionOptions
+	duplicateMaxDistance   int
+	// analysisExtensions are the lower-case file extensions that get a document analysis job
+	analysisExtensions map[string]bool
+	thumbnailOptions   imagemeta.ThumbnailOptions
+	uploadMaxBytes     int64
+	idNode             *snowflake.Node
 }
 
-func NewMediaService(imageRepo repository.ImageRepository, labelRepo repository.LabelRepository, imageLabelRepo repository.ImageLabelRepository, textKeywordRepo repository.TextKeywordRepository, imageTextKeywordRepo repository.ImageTextKeywordRepository, transactionMgr repository.TransactionManager) MediaService {
-	return &mediaService{
-		imageRepo:            imageRepo,
-		labelRepo:            labelRepo,
-		imageLabelRepo:       imageLabelRepo,
-		textKeywordRepo:      textKeywordRepo,
-		imageTextKeywordRepo: imageTextKeywordRepo,
-		transactionMgr:       transactionMgr,
+func NewMediaService(imageRepo repository.ImageRepository, labelRepo repository.LabelRepository, labelParentRepo repository.LabelParentRepository, labelAliasRepo repository.LabelAliasRepository, taxonomyBlockRepo repository.TaxonomyBlockRepository, imageLabelRepo repository.ImageLabelRepository, imageLabelInstanceRepo repository.ImageLabelInstanceRepository, textKeywordRepo repository.TextKeywordRepository, imageTextKeywordRepo repository.ImageTextKeywordRepository, textBlockRepo repository.ImageTextBlockRepository, pageTextRepo repository.ImagePageTextRepository, documentRepo repository.DocumentAnalysisRepository, moderationLabelRepo repository.ImageModerationLabelRepository, thumbnailRepo repository.ImageThumbnailRepository, videoSegmentRepo repository.VideoSegmentRepository, captionRepo repository.ImageCaptionRepository, embeddingRepo repository.ImageEmbeddingRepository, mediaJobRepo repository.MediaJobRepository, transactionMgr repository.TransactionManager, publisher EventPublisher) MediaService {
+	labelOptions := sdk.DefaultDetectLabelsOptions
+	if value := os.Getenv("REKOGNITION_MIN_CONFIDENCE"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < 0 || parsed > 100 {
+			log.Printf("Invalid REKOGNITION_MIN_CONFIDENCE %q, using default %.0f", value, labelOptions.MinConfidence)
+		} else {
+			labelOptions.MinConfidence = parsed
+		}
 	}
-}
-
-func (s *mediaService) CreateImage(filename, originFilename, fileExtension, bucket, objectKey string, uploaded bool) error {
-	newImage := &db.Image{
-		Filename:       filename,
-		OriginFilename: originFilename,
-		FileExtension:  fileExtension,
-		Bucket:         bucket,
-		ObjectKey:      objectKey,
-		Uploaded:       uploaded,
+	if value := os.Getenv("REKOGNITION_MAX_LABELS"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed < 1 {
+			log.Printf("Invalid REKOGNITION_MAX_LABELS %q, using default %d", value, labelOptions.MaxLabels)
+		} else {
+			labelOptions.MaxLabels = parsed
+		}
 	}
-	err := s.imageRepo.Create(newImage)
-	return err
-}
 
-func (s *mediaService) DetectAndSaveImageLabels() error {
-	undetectedImages, err := s.imageRepo.GetByLabelDetected(false)
+	node, err := snowflake.NewNode(1)
 	if err != nil {
-		return fmt.Errorf("failed to fetch undetected image")
+		log.Fatal("Failed to create snowflake node:", err)
 	}
 
-	log.Printf("Found %d undetected images to process", len(undetectedImages))
+	return &mediaService{
+		imageRepo:              imageRepo,
+		labelRepo:              labelRepo,
+		labelParentRepo:        labelParentRepo,
+		labelAliasRepo:         labelAliasRepo,
+		taxonomyBlockRepo:      taxonomyBlockRepo,
+		imageLabelRepo:         imageLabelRepo,
+		imageLabelInstanceRepo: imageLabelInstanceRepo,
+		textKeywordRepo:        textKeywordRepo,
+		imageTextKeywordRepo:   imageTextKeywordRepo,
+		textBlockRepo:          textBlockRepo,
+		pageTextRepo:           pageTextRepo,
+		documentRepo:           documentRepo,
+		moderationLabelRepo:    moderationLabelRepo,
+		thumbnailRepo:          thumbnailRepo,
+		videoSegmentRepo:       videoSegmentRepo,
+		captionRepo:            captionRepo,
+		embeddingRepo:          embeddingRepo,
+		mediaJobRepo:           mediaJobRepo,
+		transactionMgr:         transactionMgr,
+		publisher:              publisher,
+		describer:              sdk.NewAnthropicService(),
+		embedder:               NewEmbeddingProvider(),
+		labelOptions:           labelOptions,
+		jobOptions:             mediaJobOptionsFromEnv(),
+		moderationOptions:      moderationOptionsFromEnv(),
+		captionOptions:         captionOptionsFromEnv(),
+		duplicateMaxDistance:   duplicateMaxDistanceFromEnv(),
+		analysisExtensions:     documentAnalysisExtensionsFromEnv(),
+		thumbnailOptions:       imagemeta.ThumbnailOptionsFromEnv(),
+		uploadMaxBytes:         MediaUploadMaxBytesFromEnv(),
+		idNode:                 node,
+	}
+}
 
-	for _, image := range undetectedImages {
-		log.Printf("Processing image ID: %d, Bucket: %s, ObjectKey: %s", image.ID, image.Bucket, image.ObjectKey)
+func (s *mediaService) CreateImage(newImage *db.Image, thumbnails []*db.ImageThumbnail) ([]*db.MediaJob, error) {
+	// Images stay out of library queries until moderation passes them
+	newImage.ModerationStatus = db.ModerationPending
+	if newImage.Orientation == 0 {
+		newImage.Orientation = imagemeta.OrientationNormal
+	}
+	if err := s.imageRepo.Create(newImage); err != nil {
+		return nil, err
+	}
 
-		labels, err := sdk.DetectLabels(image.Bucket, image.ObjectKey)
-		if err != nil {
-			log.Printf("Failed to detect labels for image ID %d: %v", image.ID, err)
-			continue // Continue processing other images instead of returning error directly
-		}
+	if err := s.thumbnailRepo.ReplaceForImage(newImage.ID, thumbnails); err != nil {
+		log.Printf("Failed to save thumbnails for image ID %d: %v", newImage.ID, err)
+	}
+	if err := s.assignCluster(newImage); err != nil {
+		log.Printf("Failed to cluster image ID %d: %v", newImage.ID, err)
+	}
 
-		err = s.SaveImageLabels(image.ID, labels)
+	jobTypes := []string{db.MediaJobModeration, db.MediaJobLabelDetection, db.MediaJobTextDetection}
+	if s.analysisExtensions[strings.ToLower(newImage.FileExtension)] {
+		jobTypes = append(jobTypes, db.MediaJobDocumentAnalysis)
+	}
+	// Captions are written from the largest thumbnail, so files without one are not described
+	if len(thumbnails) > 0 {
+		jobTypes = append(jobTypes, db.MediaJobCaption)
+	}
+	jobTypes = append(jobTypes, db.MediaJobEmbedding)
+	jobs := make([]*db.MediaJob, 0, len(jobTypes))
+	for _, jobType := range jobTypes {
+		job, err := s.enqueueJob(newImage.ID, jobType)
 		if err != nil {
-			log.Printf("Failed to save labels for image ID %d: %v", image.ID, err)
-			continue // Continue processing other images
+			return jobs, err
 		}
+		jobs = append(jobs, job)
 	}
+	return jobs, nil
+}
+
+// detectImageLabels runs Rekognition label detection for one image and stores the result
+func (s *mediaService) detectImageLabels(image *db.Image) error {
+	if !image.Uploaded {
+		return ErrMediaNotUploaded
+	}
+	log.Printf("Processing image ID: %d, Bucket: %s, ObjectKey: %s", image.ID, image.Bucket, image.ObjectKey)
+
+	labels, err := sdk.DetectLabels(image.Bucket, image.ObjectKey, s.labelOptions)
+	if err != nil {
+		return fmt.Errorf("failed to detect labels: %w", err)
+	}
+
+	if err := s.SaveImageLabels(image.ID, labels); err != nil {
+		return fmt.Errorf("failed to save labels: %w", err)
+	}
+	s.publishMediaProcessed(image, MediaStageLabels)
 	return nil
 }
 
-func (s *mediaService) SaveImageLabels(id int64, labels []string) error {
-	log.Printf("Starting SaveImageLabels for image ID: %d with %d labels: %v", id, len(labels), labels)
+func (s *mediaService) SaveImageLabels(id int64, labels []sdk.DetectedLabel) error {
+	log.Printf("Starting SaveImageLabels for image ID: %d with %d labels", id, len(labels))
 
-	// Remove duplicate labels
-	uniqueLabels := make(map[string]bool)
-	var deduplicatedLabels []string
+	// Curated aliases rename detections before deduplication, so "Kitten" and "Cat" end up as one label
+	resolver, err := s.newLabelResolver()
+	if err != nil {
+		return err
+	}
+	labels, err = resolver.resolveLabels(labels)
+	if err != nil {
+		return err
+	}
+
+	// Remove duplicate labels, keeping the most confident detection
+	uniqueLabels := make(map[string]int)
+	var deduplicatedLabels []sdk.DetectedLabel
 	for _, label := range labels {
-		if !uniqueLabels[label] {
-			uniqueLabels[label] = true
-			deduplicatedLabels = append(deduplicatedLabels, label)
+		if i, ok := uniqueLabels[label.Name]; ok {
+			if label.Confidence > deduplicatedLabels[i].Confidence {
+				deduplicatedLabels[i] = label
+			}
+			continue
 		}
+		uniqueLabels[label.Name] = len(deduplicatedLabels)
+		deduplicatedLabels = append(deduplicatedLabels, label)
 	}
 	labels = deduplicatedLabels
-	log.Printf("After deduplication: %d unique labels: %v", len(labels), labels)
+	log.Printf("After deduplication: %d unique labels", len(labels))
 
 	if len(labels) == 0 {
 		log.Printf("No labels detected for image ID: %d, marking as detected", id)
-		affected, err := s.imageRepo.UpdateLabelDetected(id, true)
+		affected, err := s.imageRepo.UpdateLabelDetected(nil, id, true)
 		if affected == 0 {
 			log.Printf("Failed to update image ID: %d", id)
 		}
 		return err
 	}
 
-	err := s.transactionMgr.WithTransaction(func() error {
-		for _, labelName := range labels {
-			log.Printf("Processing label '%s' for image ID: %d", labelName, id)
+	err = s.transactionMgr.WithTransaction(func(session *xorm.Session) error {
+		for _, detected := range labels {
+			log.Printf("Processing label '%s' (%.2f%%) for image ID: %d", detected.Name, detected.Confidence, id)
 
-			// Check if label exists
-			label, err := s.labelRepo.GetByName(labelName)
+			label, err := s.getOrCreateLabel(session, detected.Name)
 			if err != nil {
-				log.Printf("Error getting label '%s': %v", labelName, err)
 				return err
 			}
 
-			// If label doesn't exist, create new label
-			if label == nil {
-				log.Printf("Label '%s' not found, creating new label", labelName)
-				newLabel := &db.Label{Name: labelName}
-				err = s.labelRepo.Create(newLabel)
-				if err != nil {
-					log.Printf("Failed to create label '%s': %v", labelName, err)
+			// Rekognition reports categories per label; the first one is the primary category
+			if len(detected.Categories) > 0 && label.Category != detected.Categories[0] {
+				if _, err := s.labelRepo.UpdateCategory(session, label.ID, detected.Categories[0]); err != nil {
+					log.Printf("Failed to update category of label '%s': %v", label.Name, err)
+					return err
+				}
+			}
+
+			for _, parentName := range detected.Parents {
+				if err := s.linkParentLabel(session, label, parentName); err != nil {
 					return err
 				}
-				label = newLabel
 			}
 
 			// Check if same image-label relationship already exists
-			existingRelation, err := s.imageLabelRepo.GetByImageAndLabel(id, label.ID)
+			existingRelation, err := s.imageLabelRepo.GetByImageAndLabel(session, id, label.ID)
 			if err != nil {
 				log.Printf("Error checking existing image-label relationship: %v", err)
 				return err
 			}
 			if existingRelation != nil {
-				log.Printf("Image-label relationship already exists: ImageID=%d, LabelID=%d, skipping", id, label.ID)
-				continue // Skip existing relationship
+				log.Printf("Image-label relationship already exists: ImageID=%d, LabelID=%d, updating confidence", id, label.ID)
+				if _, err := s.imageLabelRepo.UpdateConfidence(session, existingRelation.ID, detected.Confidence); err != nil {
+					log.Printf("Failed to update image-label confidence: %v", err)
+					return err
+				}
+			} else {
+				log.Printf("Creating image-label relationship: ImageID=%d, LabelID=%d", id, label.ID)
+				err = s.imageLabelRepo.Create(session, &db.ImageLabel{
+					ImageID:    id,
+					LabelID:    label.ID,
+					Confidence: detected.Confidence,
+				})
+				if err != nil {
+					log.Printf("Failed to create image-label relationship: %v", err)
+					return err
+				}
 			}
 
-			log.Printf("Creating image-label relationship: ImageID=%d, LabelID=%d", id, label.ID)
-			err = s.imageLabelRepo.Create(&db.ImageLabel{
-				ImageID: id,
-				LabelID: label.ID,
-			})
-			if err != nil {
-				log.Printf("Failed to create image-label relationship: %v", err)
+			// Replace the label's instances with the latest detection
+			if err := s.imageLabelInstanceRepo.DeleteByImageAndLabel(session, id, label.ID); err != nil {
+				log.Printf("Failed to clear label instances: %v", err)
 				return err
 			}
+			for _, instance := range detected.Instances {
+				err = s.imageLabelInstanceRepo.Create(session, &db.ImageLabelInstance{
+					ImageID:    id,
+					LabelID:    label.ID,
+					Confidence: instance.Confidence,
+					BoxLeft:    instance.BoundingBox.Left,
+					BoxTop:     instance.BoundingBox.Top,
+					BoxWidth:   instance.BoundingBox.Width,
+					BoxHeight:  instance.BoundingBox.Height,
+				})
+				if err != nil {
+					log.Printf("Failed to create label instance: %v", err)
+					return err
+				}
+			}
 		}
 
 		// After all labels are saved, mark image as detected
 		log.Printf("All labels saved, marking image ID: %d as label detected", id)
-		affected, err := s.imageRepo.UpdateLabelDetected(id, true)
+		affected, err := s.imageRepo.UpdateLabelDetected(session, id, true)
 		if affected == 0 {
 			log.Printf("Warning: UpdateLabelDetected affected 0 rows for image ID: %d", id)
 			return errors.New("failed to update image label_detected status")
@@ -169,53 +350,189 @@ func (s *mediaService) SaveImageLabels(id int64, labels []string) error {
 	return err
 }
 
-func (s *mediaService) DetectAndSaveImageText() error {
-	undetectedImages, err := s.imageRepo.GetByTextDetected(false)
+// getOrCreateLabel returns the label with the given name, creating it if needed
+func (s *mediaService) getOrCreateLabel(session *xorm.Session, name string) (*db.Label, error) {
+	label, err := s.labelRepo.GetByName(session, name)
 	if err != nil {
-		return fmt.Errorf("failed to fetch undetected image text")
-	}
-
-	log.Printf("Found %d undetected files to process text", len(undetectedImages))
-	for _, image := range undetectedImages {
-		log.Printf("Processing file ID: %d, Extension: %s, Bucket: %s, ObjectKey: %s", 
-			image.ID, image.FileExtension, image.Bucket, image.ObjectKey)
-
-		var textKeywords []string
-		var err error
-
-		// Determine processing method based on file extension
-		fileExt := strings.ToLower(image.FileExtension)
-		if fileExt == ".pdf" {
-			// Use Textract for PDF files
-			log.Printf("Using Textract for PDF file ID: %d", image.ID)
-			textKeywords, err = sdk.DetectDocumentText(image.Bucket, image.ObjectKey)
-		} else if fileExt == ".jpg" || fileExt == ".jpeg" || fileExt == ".png" || fileExt == ".gif" || fileExt == ".bmp" {
-			// Use Rekognition for image files
-			log.Printf("Using Rekognition for image file ID: %d", image.ID)
-			textKeywords, err = sdk.DetectText(image.Bucket, image.ObjectKey)
-		} else {
-			log.Printf("Unsupported file extension %s for file ID: %d, skipping", fileExt, image.ID)
-			// Mark as processed even though we skipped it to avoid reprocessing
-			_, updateErr := s.imageRepo.UpdateTextDetected(image.ID, true)
-			if updateErr != nil {
-				log.Printf("Failed to mark unsupported file as processed: %v", updateErr)
-			}
-			continue
+		log.Printf("Error getting label '%s': %v", name, err)
+		return nil, err
+	}
+	if label != nil {
+		return label, nil
+	}
+
+	log.Printf("Label '%s' not found, creating new label", name)
+	label = &db.Label{Name: name}
+	if err := s.labelRepo.Create(session, label); err != nil {
+		log.Printf("Failed to create label '%s': %v", name, err)
+		return nil, err
+	}
+	return label, nil
+}
+
+// linkParentLabel records parentName as a parent of label in the hierarchy
+func (s *mediaService) linkParentLabel(session *xorm.Session, label *db.Label, parentName string) error {
+	parent, err := s.getOrCreateLabel(session, parentName)
+	if err != nil {
+		return err
+	}
+
+	existing, err := s.labelParentRepo.GetByLabelAndParent(session, label.ID, parent.ID)
+	if err != nil {
+		log.Printf("Error checking parent of label '%s': %v", label.Name, err)
+		return err
+	}
+	if existing != nil {
+		return nil
+	}
+
+	if err := s.labelParentRepo.Create(session, &db.LabelParent{LabelID: label.ID, ParentLabelID: parent.ID}); err != nil {
+		log.Printf("Failed to link label '%s' to parent '%s': %v", label.Name, parent.Name, err)
+		return err
+	}
+	return nil
+}
+
+// detectImageText runs OCR for one file, asynchronous Textract for PDFs and Rekognition for images
+func (s *mediaService) detectImageText(job *db.MediaJob, image *db.Image) error {
+	if !image.Uploaded {
+		return ErrMediaNotUploaded
+	}
+	log.Printf("Processing file ID: %d, Extension: %s, Bucket: %s, ObjectKey: %s",
+		image.ID, image.FileExtension, image.Bucket, image.ObjectKey)
+
+	var textResult *sdk.TextDetectionResult
+	var err error
+
+	// Determine processing method based on file extension
+	fileExt := strings.ToLower(image.FileExtension)
+	if fileExt == ".pdf" {
+		// Use Textract for PDF files; its asynchronous API handles documents of any page count
+		log.Printf("Using Textract for PDF file ID: %d", image.ID)
+		textResult, err = s.detectDocumentText(job, image)
+	} else if fileExt == ".jpg" || fileExt == ".jpeg" || fileExt == ".png" || fileExt == ".gif" || fileExt == ".bmp" {
+		// Use Rekognition for image files
+		log.Printf("Using Rekognition for image file ID: %d", image.ID)
+		textResult, err = sdk.DetectText(image.Bucket, image.ObjectKey)
+	} else {
+		return fmt.Errorf("%w: %q", ErrUnsupportedMedia, image.FileExtension)
+	}
+
+	if err != nil {
+		if errors.Is(err, errMediaJobWaiting) {
+			return err
 		}
+		return fmt.Errorf("failed to detect text: %w", err)
+	}
 
+	if err := s.SaveImageTextBlocks(image.ID, textResult); err != nil {
+		return fmt.Errorf("failed to save text blocks: %w", err)
+	}
+
+	if err := s.SaveImageTextKeywords(image.ID, textResult.Words()); err != nil {
+		return fmt.Errorf("failed to save text keywords: %w", err)
+	}
+	s.publishMediaProcessed(image, MediaStageText)
+	return nil
+}
+
+// detectDocumentText starts a Textract job for the document on the first run and collects its result on later runs.
+// It returns errMediaJobWaiting while Textract is still working.
+func (s *mediaService) detectDocumentText(job *db.MediaJob, image *db.Image) (*sdk.TextDetectionResult, error) {
+	if job.ExternalJobID == "" {
+		jobID, err := sdk.StartDocumentTextDetection(image.Bucket, image.ObjectKey)
 		if err != nil {
-			log.Printf("Failed to detect text for file ID %d: %v", image.ID, err)
-			continue // Continue processing other files instead of returning error directly
+			if errors.Is(err, sdk.ErrUnsupportedDocument) {
+				return nil, fmt.Errorf("%w: %v", ErrUnsupportedMedia, err)
+			}
+			return nil, err
+		}
+		job.ExternalJobID = jobID
+		return nil, errMediaJobWaiting
+	}
+
+	textractJob, err := sdk.GetDocumentTextDetection(job.ExternalJobID)
+	if err != nil {
+		if errors.Is(err, sdk.ErrUnsupportedDocument) {
+			sdk.DeleteTextractCopy(image.Bucket, image.ObjectKey)
+			return nil, fmt.Errorf("%w: %v", ErrUnsupportedMedia, err)
 		}
+		return nil, err
+	}
 
-		err = s.SaveImageTextKeywords(image.ID, textKeywords)
+	switch textractJob.Status {
+	case sdk.TextractJobInProgress:
+		return nil, errMediaJobWaiting
+	case sdk.TextractJobSucceeded, sdk.TextractJobPartialSuccess:
+		sdk.DeleteTextractCopy(image.Bucket, image.ObjectKey)
+		log.Printf("Textract job %s for file ID %d finished with %d pages", job.ExternalJobID, image.ID, textractJob.Pages)
+		return textractJob.Result, nil
+	default:
+		// Start a new Textract job on the next attempt
+		failedJobID := job.ExternalJobID
+		job.ExternalJobID = ""
+		sdk.DeleteTextractCopy(image.Bucket, image.ObjectKey)
+		return nil, fmt.Errorf("textract job %s %s: %s", failedJobID, strings.ToLower(textractJob.Status), textractJob.StatusMessage)
+	}
+}
+
+// SaveImageTextBlocks replaces the image's OCR blocks, per-page text and reconstructed full text
+func (s *mediaService) SaveImageTextBlocks(id int64, result *sdk.TextDetectionResult) error {
+	blocks := make([]*db.ImageTextBlock, 0, len(result.Blocks))
+	for i, block := range result.Blocks {
+		polygon, err := json.Marshal(block.Polygon)
 		if err != nil {
-			log.Printf("Failed to save text for file ID %d: %v", image.ID, err)
-			continue // Continue processing other files
+			return fmt.Errorf("failed to encode polygon: %w", err)
 		}
+		blocks = append(blocks, &db.ImageTextBlock{
+			ImageID:    id,
+			Page:       block.Page,
+			BlockIndex: i,
+			BlockType:  block.Type,
+			Text:       block.Text,
+			Confidence: block.Confidence,
+			BoxLeft:    block.BoundingBox.Left,
+			BoxTop:     block.BoundingBox.Top,
+			BoxWidth:   block.BoundingBox.Width,
+			BoxHeight:  block.BoundingBox.Height,
+			Polygon:    string(polygon),
+		})
 	}
 
-	return nil
+	var pages []*db.ImagePageText
+	for _, page := range result.Pages() {
+		pages = append(pages, &db.ImagePageText{ImageID: id, Page: page.Page, Text: page.Text})
+	}
+
+	return s.transactionMgr.WithTransaction(func(session *xorm.Session) error {
+		if err := s.textBlockRepo.ReplaceForImage(session, id, blocks); err != nil {
+			log.Printf("Failed to save %d text blocks for image ID %d: %v", len(blocks), id, err)
+			return err
+		}
+		if err := s.pageTextRepo.ReplaceForImage(session, id, pages); err != nil {
+			log.Printf("Failed to save %d page texts for image ID %d: %v", len(pages), id, err)
+			return err
+		}
+		if _, err := s.imageRepo.UpdateFullText(session, id, result.FullText()); err != nil {
+			log.Printf("Failed to save full text for image ID %d: %v", id, err)
+			return err
+		}
+		log.Printf("Saved %d text blocks for image ID: %d", len(blocks), id)
+		return nil
+	})
+}
+
+// publishMediaProcessed notifies subscribers that a processing stage finished for an image
+func (s *mediaService) publishMediaProcessed(image *db.Image, stage string) {
+	s.publisher.Publish(&Event{
+		Type: EventMediaProcessed,
+		Media: &MediaProcessedEvent{
+			ImageID:     image.ID,
+			OwnerID:     image.OwnerID,
+			Stage:       stage,
+			ProcessedAt: time.Now().Format(time.RFC3339),
+		},
+	})
 }
 
 func (s *mediaService) SaveImageTextKeywords(id int64, textKeywords []string) error {
@@ -233,21 +550,33 @@ func (s *mediaService) SaveImageTextKeywords(id int64, textKeywords []string) er
 	textKeywords = deduplicatedLabels
 	log.Printf("After deduplication: %d unique textKeywords: %v", len(textKeywords), textKeywords)
 
+	blocked, err := loadBlocklist(s.taxonomyBlockRepo, db.TaxonomyBlockKeyword)
+	if err != nil {
+		return err
+	}
+	allowed := textKeywords[:0]
+	for _, keyword := range textKeywords {
+		if !blocked.blocks(keyword) {
+			allowed = append(allowed, keyword)
+		}
+	}
+	textKeywords = allowed
+
 	if len(textKeywords) == 0 {
 		log.Printf("No textKeywords detected for image ID: %d, marking as detected", id)
-		affected, err := s.imageRepo.UpdateTextDetected(id, true)
+		affected, err := s.imageRepo.UpdateTextDetected(nil, id, true)
 		if affected == 0 {
 			log.Printf("Failed to update image ID: %d", id)
 		}
 		return err
 	}
 
-	err := s.transactionMgr.WithTransaction(func() error {
+	err = s.transactionMgr.WithTransaction(func(session *xorm.Session) error {
 		for _, keyword := range textKeywords {
 			log.Printf("Processing textKeywords '%s' for image ID: %d", keyword, id)
 
 			// Check if keyword exists
-			k, err := s.textKeywordRepo.GetByKeyword(keyword)
+			k, err := s.textKeywordRepo.GetByKeyword(session, keyword)
 			if err != nil {
 				log.Printf("Error getting keyword '%s': %v", keyword, err)
 				return err
@@ -257,7 +586,7 @@ func (s *mediaService) SaveImageTextKeywords(id int64, textKeywords []string) er
 			if k == nil {
 				log.Printf("Keyword '%s' not found, creating new keyword", keyword)
 				newKeyword := &db.TextKeyword{Keyword: keyword}
-				err = s.textKeywordRepo.Create(newKeyword)
+				err = s.textKeywordRepo.Create(session, newKeyword)
 				if err != nil {
 					log.Printf("Failed to create label '%s': %v", keyword, err)
 					return err
@@ -266,7 +595,7 @@ func (s *mediaService) SaveImageTextKeywords(id int64, textKeywords []string) er
 			}
 
 			// Check if same image-keyword relationship already exists
-			existingRelation, err := s.imageTextKeywordRepo.GetByImageAndKeyword(id, k.ID)
+			existingRelation, err := s.imageTextKeywordRepo.GetByImageAndKeyword(session, id, k.ID)
 			if err != nil {
 				log.Printf("Error checking existing image-keyword relationship: %v", err)
 				return err
@@ -277,7 +606,7 @@ func (s *mediaService) SaveImageTextKeywords(id int64, textKeywords []string) er
 			}
 
 			log.Printf("Creating image-keyword relationship: ImageID=%d, KeyWordID=%d", id, k.ID)
-			err = s.imageTextKeywordRepo.Create(&db.ImageTextKeyword{
+			err = s.imageTextKeywordRepo.Create(session, &db.ImageTextKeyword{
 				ImageID:       id,
 				TextKeywordId: k.ID,
 			})
@@ -289,7 +618,7 @@ func (s *mediaService) SaveImageTextKeywords(id int64, textKeywords []string) er
 
 		// After all keywords are saved, mark image as detected
 		log.Printf("All textKeywords saved, marking image ID: %d as textKeywords detected", id)
-		affected, err := s.imageRepo.UpdateTextDetected(id, true)
+		affected, err := s.imageRepo.UpdateTextDetected(session, id, true)
 		if affected == 0 {
 			log.Printf("Warning: UpdateTextDetected affected 0 rows for image ID: %d", id)
 			return errors.New("failed to update image text_detected status")
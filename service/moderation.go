@@ -0,0 +1,234 @@
+package service
+
+import (
+	"blog-fanchiikawa-service/db"
+	"blog-fanchiikawa-service/sdk"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultModerationMinConfidence = 50
+	defaultModerationThreshold     = 80
+)
+
+// moderationOptions decides which Rekognition moderation labels are stored and which quarantine an image
+type moderationOptions struct {
+	// minConfidence is the lowest confidence of a stored moderation label
+	minConfidence float64
+	// threshold quarantines an image when any label reaches it
+	threshold float64
+	// categoryThresholds override threshold for labels with that name or parent name
+	categoryThresholds map[string]float64
+	// quarantineDocuments sends every PDF to the review queue; Rekognition cannot moderate PDFs, so they pass by default
+	quarantineDocuments bool
+	// quarantineUnsupported sends other files Rekognition cannot moderate to the review queue instead of passing them
+	quarantineUnsupported bool
+}
+
+func moderationOptionsFromEnv() moderationOptions {
+	options := moderationOptions{
+		minConfidence:         defaultModerationMinConfidence,
+		threshold:             defaultModerationThreshold,
+		categoryThresholds:    make(map[string]float64),
+		quarantineUnsupported: true,
+	}
+
+	confidences := []struct {
+		name   string
+		target *float64
+	}{
+		{"MODERATION_MIN_CONFIDENCE", &options.minConfidence},
+		{"MODERATION_THRESHOLD", &options.threshold},
+	}
+	for _, c := range confidences {
+		value := os.Getenv(c.name)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < 0 || parsed > 100 {
+			log.Printf("Invalid %s %q, using default %.0f", c.name, value, *c.target)
+			continue
+		}
+		*c.target = parsed
+	}
+
+	// MODERATION_CATEGORY_THRESHOLDS is a comma-separated list such as "Explicit Nudity=60,Violence=90"
+	if value := os.Getenv("MODERATION_CATEGORY_THRESHOLDS"); value != "" {
+		for _, entry := range strings.Split(value, ",") {
+			name, threshold, ok := strings.Cut(entry, "=")
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(threshold), 64)
+			if !ok || err != nil || parsed < 0 || parsed > 100 || strings.TrimSpace(name) == "" {
+				log.Printf("Invalid MODERATION_CATEGORY_THRESHOLDS entry %q, ignoring it", entry)
+				continue
+			}
+			options.categoryThresholds[strings.ToLower(strings.TrimSpace(name))] = parsed
+		}
+	}
+
+	switch policy := os.Getenv("MODERATION_UNSUPPORTED_POLICY"); policy {
+	case "", "quarantine":
+	case "pass":
+		options.quarantineUnsupported = false
+	default:
+		log.Printf("Invalid MODERATION_UNSUPPORTED_POLICY %q, using default quarantine", policy)
+	}
+
+	switch policy := os.Getenv("MODERATION_DOCUMENT_POLICY"); policy {
+	case "", "pass":
+	case "quarantine":
+		options.quarantineDocuments = true
+	default:
+		log.Printf("Invalid MODERATION_DOCUMENT_POLICY %q, using default pass", policy)
+	}
+	return options
+}
+
+// thresholdFor returns the quarantine threshold of a label, preferring the stricter category override
+func (o moderationOptions) thresholdFor(label sdk.ModerationLabel) float64 {
+	threshold := o.threshold
+	for _, name := range []string{label.Name, label.ParentName} {
+		if override, ok := o.categoryThresholds[strings.ToLower(name)]; ok && name != "" && override < threshold {
+			threshold = override
+		}
+	}
+	return threshold
+}
+
+// quarantineReason returns the first label that reaches its threshold, or "" when the image is safe
+func (o moderationOptions) quarantineReason(labels []sdk.ModerationLabel) string {
+	for _, label := range labels {
+		if label.Confidence >= o.thresholdFor(label) {
+			return fmt.Sprintf("%s (%.1f%%)", label.Name, label.Confidence)
+		}
+	}
+	return ""
+}
+
+// moderateImage checks an image or video for unsafe content, stores the moderation labels and
+// quarantines it when a label reaches its threshold. Review decisions are never overwritten.
+func (s *mediaService) moderateImage(job *db.MediaJob, image *db.Image) error {
+	if !image.Uploaded {
+		return ErrMediaNotUploaded
+	}
+
+	status := db.ModerationPassed
+	fileExt := strings.ToLower(image.FileExtension)
+	switch {
+	case fileExt == ".jpg" || fileExt == ".jpeg" || fileExt == ".png":
+		labels, err := sdk.DetectModerationLabels(image.Bucket, image.ObjectKey, s.moderationOptions.minConfidence)
+		if err != nil {
+			return fmt.Errorf("failed to detect moderation labels: %w", err)
+		}
+		if status, err = s.saveModerationLabels(image, labels); err != nil {
+			return err
+		}
+	case isVideo(image):
+		labels, err := s.moderateVideo(job, image)
+		if err != nil {
+			return err
+		}
+		if status, err = s.saveModerationLabels(image, labels); err != nil {
+			return err
+		}
+	case fileExt == ".pdf":
+		if s.moderationOptions.quarantineDocuments {
+			log.Printf("Sending document ID %d to review", image.ID)
+			status = db.ModerationQuarantined
+		}
+	case s.moderationOptions.quarantineUnsupported:
+		log.Printf("Cannot moderate %s file ID %d automatically, sending it to review", fileExt, image.ID)
+		status = db.ModerationQuarantined
+	}
+
+	if _, err := s.imageRepo.UpdateModerationStatus(image.ID, status, db.ModerationPending, db.ModerationPassed, db.ModerationQuarantined); err != nil {
+		return fmt.Errorf("failed to update moderation status: %w", err)
+	}
+	s.publishMediaProcessed(image, MediaStageModeration)
+	return nil
+}
+
+// moderateVideo starts a Rekognition Video content moderation job on the first run and returns
+// errMediaJobWaiting until it has finished
+func (s *mediaService) moderateVideo(job *db.MediaJob, image *db.Image) ([]sdk.ModerationLabel, error) {
+	if job.ExternalJobID == "" {
+		jobID, err := sdk.StartVideoContentModeration(image.Bucket, image.ObjectKey, s.moderationOptions.minConfidence)
+		if err != nil {
+			return nil, videoJobError("failed to start video content moderation", err)
+		}
+		job.ExternalJobID = jobID
+		return nil, errMediaJobWaiting
+	}
+
+	result, err := sdk.GetVideoContentModeration(job.ExternalJobID)
+	if err != nil {
+		return nil, videoJobError("failed to get video moderation labels", err)
+	}
+	switch result.Status {
+	case sdk.VideoJobInProgress:
+		return nil, errMediaJobWaiting
+	case sdk.VideoJobSucceeded:
+	default:
+		// Start a new Rekognition job on the next attempt
+		failedJobID := job.ExternalJobID
+		job.ExternalJobID = ""
+		return nil, fmt.Errorf("rekognition video job %s %s: %s", failedJobID, strings.ToLower(result.Status), result.StatusMessage)
+	}
+	s.saveVideoMetadata(image, result.Metadata)
+	return result.Labels, nil
+}
+
+// saveModerationLabels stores the moderation labels of an image and returns the moderation status they lead to
+func (s *mediaService) saveModerationLabels(image *db.Image, labels []sdk.ModerationLabel) (string, error) {
+	records := make([]*db.ImageModerationLabel, 0, len(labels))
+	for _, label := range labels {
+		records = append(records, &db.ImageModerationLabel{
+			ImageID:    image.ID,
+			Name:       label.Name,
+			ParentName: label.ParentName,
+			Confidence: label.Confidence,
+		})
+	}
+	if err := s.moderationLabelRepo.ReplaceForImage(image.ID, records); err != nil {
+		return "", fmt.Errorf("failed to save moderation labels: %w", err)
+	}
+
+	if reason := s.moderationOptions.quarantineReason(labels); reason != "" {
+		log.Printf("Quarantining image ID %d: %s", image.ID, reason)
+		return db.ModerationQuarantined, nil
+	}
+	return db.ModerationPassed, nil
+}
+
+// awaitModeration reports whether an image may be sent to the detectors that follow moderation. It returns
+// errMediaJobWaiting while the moderation job runs or the image waits for review, false for rejected images,
+// and an error that is retried when moderation ended without a verdict.
+func (s *mediaService) awaitModeration(image *db.Image) (bool, error) {
+	if err := s.waitForJobs(image.ID, db.MediaJobModeration); err != nil {
+		return false, err
+	}
+
+	// The image was loaded before its moderation job finished, so read the verdict again
+	current, err := s.imageRepo.GetByID(image.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to load image %d: %w", image.ID, err)
+	}
+	if current == nil {
+		return false, errMediaMissing
+	}
+	image.ModerationStatus = current.ModerationStatus
+
+	switch image.ModerationStatus {
+	case db.ModerationPassed, db.ModerationApproved:
+		return true, nil
+	case db.ModerationRejected:
+		return false, nil
+	case db.ModerationQuarantined:
+		return false, errMediaJobWaiting
+	}
+	return false, fmt.Errorf("image %d has no moderation verdict", image.ID)
+}
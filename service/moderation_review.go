@@ -0,0 +1,95 @@
+package service
+
+import (
+	"blog-fanchiikawa-service/db"
+	"blog-fanchiikawa-service/graph/model"
+	"blog-fanchiikawa-service/repository"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// ErrImageNotFound is returned when an image ID does not exist
+var ErrImageNotFound = errors.New("image not found")
+
+// isVisible reports whether moderation allows the image to be shown
+func isVisible(image *db.Image) bool {
+	for _, status := range db.ModerationVisibleStatuses {
+		if image.ModerationStatus == status {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *mediaLibraryService) ModerationQueue(first int, after string) (*model.ImageConnection, error) {
+	first, beforeID, err := pageArgs(first, after)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := &repository.ImageFilter{ModerationStatuses: []string{db.ModerationQuarantined}}
+	images, err := s.imageRepo.List(filter, beforeID, first+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list quarantined images: %w", err)
+	}
+	total, err := s.imageRepo.Count(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count quarantined images: %w", err)
+	}
+	return s.toImageConnection(images, first, total)
+}
+
+func (s *mediaLibraryService) ReviewImage(id int64, approve bool) (*model.Image, error) {
+	status := db.ModerationRejected
+	if approve {
+		status = db.ModerationApproved
+	}
+
+	// A pending image has not been checked yet, so it cannot be reviewed
+	affected, err := s.imageRepo.UpdateModerationStatus(id, status, db.ModerationQuarantined, db.ModerationPassed, db.ModerationApproved, db.ModerationRejected)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update moderation status: %w", err)
+	}
+
+	image, err := s.imageRepo.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image: %w", err)
+	}
+	if image == nil {
+		return nil, ErrImageNotFound
+	}
+	if affected == 0 && image.ModerationStatus != status {
+		return nil, fmt.Errorf("image %d is %s and cannot be reviewed yet", id, image.ModerationStatus)
+	}
+	log.Printf("Image ID %d %s by review", id, status)
+
+	nodes, err := s.toImageModels([]*db.Image{image})
+	if err != nil {
+		return nil, err
+	}
+	return nodes[0], nil
+}
+
+// toModerationLabelModels converts stored moderation labels
+func toModerationLabelModels(labels []*db.ImageModerationLabel) []*model.ModerationLabel {
+	result := make([]*model.ModerationLabel, 0, len(labels))
+	for _, label := range labels {
+		result = append(result, &model.ModerationLabel{
+			Name:       label.Name,
+			ParentName: label.ParentName,
+			Confidence: label.Confidence,
+		})
+	}
+	return result
+}
+
+// toModerationStatusModel maps a stored moderation status onto the GraphQL enum
+func toModerationStatusModel(status string) model.ModerationStatus {
+	result := model.ModerationStatus(strings.ToUpper(status))
+	if !result.IsValid() {
+		return model.ModerationStatusPending
+	}
+	return result
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"blog-fanchiikawa-service/db"
+	"blog-fanchiikawa-service/repository"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPasswordHash(t *testing.T) {
+	hash, err := hashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("hashPassword: %v", err)
+	}
+	if !strings.HasPrefix(hash, passwordHashScheme+"$") || strings.Contains(hash, "correct horse") {
+		t.Fatalf("unexpected hash %q", hash)
+	}
+	if !checkPassword("correct horse", hash) {
+		t.Fatal("the password does not match its own hash")
+	}
+	if checkPassword("correct horsE", hash) {
+		t.Fatal("a wrong password matched")
+	}
+
+	other, err := hashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("hashPassword: %v", err)
+	}
+	if other == hash {
+		t.Fatal("two hashes of the same password share a salt")
+	}
+
+	for _, malformed := range []string{"", "plain", "md5$1$c2FsdA$a2V5", "pbkdf2-sha256$x$c2FsdA$a2V5", "pbkdf2-sha256$1$!$a2V5", "pbkdf2-sha256$1$c2FsdA$"} {
+		if checkPassword("", malformed) {
+			t.Errorf("malformed hash %q matched", malformed)
+		}
+	}
+}
+
+// fakeUsers is a UserRepository holding users by email
+type fakeUsers struct {
+	repository.UserRepository
+	users map[string]*db.User
+}
+
+func (f *fakeUsers) GetByEmail(email string) (*db.User, error) {
+	return f.users[email], nil
+}
+
+func TestAuthenticate(t *testing.T) {
+	hash, err := hashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("hashPassword: %v", err)
+	}
+	s := &userService{userRepo: &fakeUsers{users: map[string]*db.User{
+		"john@example.com":   {ID: 1, Email: "john@example.com", PasswordHash: hash},
+		"legacy@example.com": {ID: 2, Email: "legacy@example.com"},
+	}}}
+
+	user, err := s.Authenticate(" john@example.com", "correct horse")
+	if err != nil || user.ID != 1 {
+		t.Fatalf("got %+v, %v", user, err)
+	}
+	for _, tc := range []struct{ email, password string }{
+		{"john@example.com", "wrong password"},
+		{"nobody@example.com", "correct horse"},
+		{"legacy@example.com", ""},
+	} {
+		if _, err := s.Authenticate(tc.email, tc.password); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("%s: got %v, want ErrInvalidCredentials", tc.email, err)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"blog-fanchiikawa-service/db"
+	"blog-fanchiikawa-service/graph/model"
+	"blog-fanchiikawa-service/repository"
+	"blog-fanchiikawa-service/vectorindex"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSemanticSearchLimit = 10
+	maxSemanticSearchLimit     = 100
+	semanticQueryTimeout       = 30 * time.Second
+	// semanticIndexSyncOverlap re-reads recently saved embeddings on every sync, so rows written by another
+	// replica whose clock is slightly behind, or committed just after the last sync, are not missed
+	semanticIndexSyncOverlap = time.Minute
+)
+
+// ErrEmptySemanticQuery is returned for a semantic search without any text
+var ErrEmptySemanticQuery = errors.New("semantic search query is empty")
+
+// semanticIndex keeps the embeddings of the current model in an in-process nearest-neighbour index.
+// It is filled from the database on the first search and catches up with newly saved embeddings on later ones,
+// so vectors written by the media job worker, in this process or another, become searchable without a restart.
+type semanticIndex struct {
+	mu            sync.Mutex
+	embedder      EmbeddingProvider
+	embeddingRepo repository.ImageEmbeddingRepository
+	index         *vectorindex.Index
+	syncedUntil   time.Time
+}
+
+func newSemanticIndex(embedder EmbeddingProvider, embeddingRepo repository.ImageEmbeddingRepository) *semanticIndex {
+	return &semanticIndex{
+		embedder:      embedder,
+		embeddingRepo: embeddingRepo,
+		index:         vectorindex.New(embedder.Dimensions(), vectorindex.Options{}),
+	}
+}
+
+// sync adds the embeddings saved since the last sync to the index
+func (x *semanticIndex) sync() error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	var since time.Time
+	if !x.syncedUntil.IsZero() {
+		since = x.syncedUntil.Add(-semanticIndexSyncOverlap)
+	}
+	embeddings, err := x.embeddingRepo.GetUpdatedSince(x.embedder.Model(), since)
+	if err != nil {
+		return fmt.Errorf("failed to load embeddings: %w", err)
+	}
+	for _, embedding := range embeddings {
+		if err := x.index.Add(embedding.ImageID, decodeVector(embedding.Vector)); err != nil {
+			log.Printf("Skipping embedding of image ID %d: %v", embedding.ImageID, err)
+		}
+		if embedding.UpdatedAt.After(x.syncedUntil) {
+			x.syncedUntil = embedding.UpdatedAt
+		}
+	}
+	if since.IsZero() {
+		log.Printf("Loaded %d %s embeddings into the semantic index", x.index.Len(), x.embedder.Model())
+	}
+	return nil
+}
+
+func (s *mediaLibraryService) SemanticSearchImages(query string, k int) ([]*model.SemanticSearchHit, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, ErrEmptySemanticQuery
+	}
+	if k <= 0 {
+		k = defaultSemanticSearchLimit
+	}
+	if k > maxSemanticSearchLimit {
+		k = maxSemanticSearchLimit
+	}
+
+	if err := s.semanticIndex.sync(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), semanticQueryTimeout)
+	defer cancel()
+	vector, err := s.semanticIndex.embedder.Embed(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+
+	// Hidden images are dropped after the search, so widen it until k visible images are found or the index runs out
+	var visible []*db.Image
+	var matches []vectorindex.Result
+	size := s.semanticIndex.index.Len()
+	for n := 2 * k; ; n *= 2 {
+		matches, err = s.semanticIndex.index.Search(vector, n)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search embeddings: %w", err)
+		}
+		ids := make([]int64, 0, len(matches))
+		for _, match := range matches {
+			ids = append(ids, match.ID)
+		}
+		images, err := s.imageRepo.GetByIDs(ids)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get images: %w", err)
+		}
+		visible = visible[:0]
+		for _, image := range images {
+			if isVisible(image) {
+				visible = append(visible, image)
+			}
+		}
+		if len(visible) >= k || len(matches) < n || n >= size {
+			break
+		}
+	}
+
+	nodes, err := s.toImageModels(visible)
+	if err != nil {
+		return nil, err
+	}
+	nodesByID := make(map[int64]*model.Image, len(nodes))
+	for _, node := range nodes {
+		nodesByID[node.ID] = node
+	}
+
+	result := make([]*model.SemanticSearchHit, 0, k)
+	for _, match := range matches {
+		if node, ok := nodesByID[match.ID]; ok && len(result) < k {
+			result = append(result, &model.SemanticSearchHit{Image: node, Score: match.Score})
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"blog-fanchiikawa-service/db"
+	"blog-fanchiikawa-service/repository"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+const defaultSessionTTL = 24 * time.Hour
+
+// ErrInvalidSession is returned when a token is unknown, expired or revoked
+var ErrInvalidSession = errors.New("invalid or expired session")
+
+// SessionService defines the interface for session token business logic
+type SessionService interface {
+	// CreateSession issues a new session token for the user
+	CreateSession(userID int64) (*db.UserSession, error)
+
+	// ValidateToken returns the active session for the token or ErrInvalidSession
+	ValidateToken(token string) (*db.UserSession, error)
+
+	// RevokeSession invalidates the given token
+	RevokeSession(token string) error
+}
+
+// sessionService implements SessionService interface
+type sessionService struct {
+	sessionRepo repository.UserSessionRepository
+	ttl         time.Duration
+}
+
+// NewSessionService creates a new SessionService instance
+func NewSessionService(sessionRepo repository.UserSessionRepository) SessionService {
+	ttl := defaultSessionTTL
+	if value := os.Getenv("SESSION_TTL"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			log.Printf("Invalid SESSION_TTL %q, using default %s", value, defaultSessionTTL)
+		} else {
+			ttl = parsed
+		}
+	}
+
+	return &sessionService{
+		sessionRepo: sessionRepo,
+		ttl:         ttl,
+	}
+}
+
+// CreateSession issues a new session token for the user
+func (s *sessionService) CreateSession(userID int64) (*db.UserSession, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("failed to generate session token: %w", err)
+	}
+
+	session := &db.UserSession{
+		UserID:    userID,
+		Token:     hex.EncodeToString(buf),
+		ExpiresAt: time.Now().Add(s.ttl),
+	}
+	if err := s.sessionRepo.Create(session); err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return session, nil
+}
+
+// ValidateToken returns the active session for the token or ErrInvalidSession
+func (s *sessionService) ValidateToken(token string) (*db.UserSession, error) {
+	if token == "" {
+		return nil, ErrInvalidSession
+	}
+
+	session, err := s.sessionRepo.GetByToken(token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if session == nil || session.Revoked || time.Now().After(session.ExpiresAt) {
+		return nil, ErrInvalidSession
+	}
+
+	return session, nil
+}
+
+// RevokeSession invalidates the given token
+func (s *sessionService) RevokeSession(token string) error {
+	affected, err := s.sessionRepo.Revoke(token)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	if affected == 0 {
+		return ErrInvalidSession
+	}
+	return nil
+}
@@ -0,0 +1,362 @@
+package service
+
+import (
+	"blog-fanchiikawa-service/db"
+	"blog-fanchiikawa-service/repository"
+	"blog-fanchiikawa-service/sdk"
+	"blog-fanchiikawa-service/search"
+	"fmt"
+	"strings"
+)
+
+// taxonomy is a snapshot of the curated labels, their aliases and the hierarchy, used to resolve label names
+type taxonomy struct {
+	byID   map[int64]*db.Label
+	byName map[string]*db.Label
+	// aliases maps a lower-case alias to its label ID
+	aliases  map[string]int64
+	children map[int64][]int64
+	parents  map[int64][]int64
+}
+
+func loadTaxonomy(labelRepo repository.LabelRepository, labelAliasRepo repository.LabelAliasRepository) (*taxonomy, error) {
+	labels, err := labelRepo.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list labels: %w", err)
+	}
+	aliases, err := labelAliasRepo.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list label aliases: %w", err)
+	}
+
+	t := &taxonomy{
+		byID:     make(map[int64]*db.Label, len(labels)),
+		byName:   make(map[string]*db.Label, len(labels)),
+		aliases:  make(map[string]int64, len(aliases)),
+		children: make(map[int64][]int64),
+		parents:  make(map[int64][]int64),
+	}
+	labelIDs := make([]int64, 0, len(labels))
+	for _, label := range labels {
+		t.byID[label.ID] = label
+		t.byName[strings.ToLower(label.Name)] = label
+		labelIDs = append(labelIDs, label.ID)
+	}
+	for _, alias := range aliases {
+		t.aliases[strings.ToLower(alias.Alias)] = alias.LabelID
+	}
+
+	parents, err := labelRepo.GetParentsByLabelIDs(labelIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load label parents: %w", err)
+	}
+	for childID, labelParents := range parents {
+		for _, parent := range labelParents {
+			t.children[parent.ID] = append(t.children[parent.ID], childID)
+			t.parents[childID] = append(t.parents[childID], parent.ID)
+		}
+	}
+	return t, nil
+}
+
+// resolve returns the label with the given name or alias, ignoring case, or nil
+func (t *taxonomy) resolve(name string) *db.Label {
+	key := strings.ToLower(strings.TrimSpace(name))
+	if label, ok := t.byName[key]; ok {
+		return label
+	}
+	if labelID, ok := t.aliases[key]; ok {
+		return t.byID[labelID]
+	}
+	return nil
+}
+
+// isDescendant reports whether labelID is below ancestorID in the hierarchy
+func (t *taxonomy) isDescendant(labelID, ancestorID int64) bool {
+	visited := map[int64]bool{ancestorID: true}
+	queue := []int64{ancestorID}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, childID := range t.children[id] {
+			if childID == labelID {
+				return true
+			}
+			if !visited[childID] {
+				visited[childID] = true
+				queue = append(queue, childID)
+			}
+		}
+	}
+	return false
+}
+
+// mergeConflict returns a label whose link would close a cycle once sourceID is merged into targetID, or 0.
+// The target takes over the source's parents and children, so none of those parents may be below the target
+// and none of those children above it.
+func (t *taxonomy) mergeConflict(sourceID, targetID int64) int64 {
+	for _, parentID := range t.parents[sourceID] {
+		if parentID != targetID && t.isDescendant(parentID, targetID) {
+			return parentID
+		}
+	}
+	for _, childID := range t.children[sourceID] {
+		if childID != targetID && t.isDescendant(targetID, childID) {
+			return childID
+		}
+	}
+	return 0
+}
+
+// merge moves the source's links to the target the way LabelRepository.Merge does, dropping links to the target itself
+func (t *taxonomy) merge(sourceID, targetID int64) {
+	for _, parentID := range t.parents[sourceID] {
+		t.children[parentID] = without(t.children[parentID], sourceID)
+		if parentID != targetID && !contains(t.parents[targetID], parentID) {
+			t.parents[targetID] = append(t.parents[targetID], parentID)
+			t.children[parentID] = append(t.children[parentID], targetID)
+		}
+	}
+	for _, childID := range t.children[sourceID] {
+		t.parents[childID] = without(t.parents[childID], sourceID)
+		if childID != targetID && !contains(t.children[targetID], childID) {
+			t.children[targetID] = append(t.children[targetID], childID)
+			t.parents[childID] = append(t.parents[childID], targetID)
+		}
+	}
+	delete(t.parents, sourceID)
+	delete(t.children, sourceID)
+	delete(t.byID, sourceID)
+}
+
+func contains(ids []int64, id int64) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
+
+func without(ids []int64, id int64) []int64 {
+	result := make([]int64, 0, len(ids))
+	for _, candidate := range ids {
+		if candidate != id {
+			result = append(result, candidate)
+		}
+	}
+	return result
+}
+
+// expand returns the names an image label must have to match a search for name: the label the name or alias
+// resolves to and every label below it, so "Pet" also finds cats and dogs. Unknown names are returned as they are.
+func (t *taxonomy) expand(name string) []string {
+	label := t.resolve(name)
+	if label == nil {
+		return []string{name}
+	}
+
+	names := []string{label.Name}
+	visited := map[int64]bool{label.ID: true}
+	queue := []int64{label.ID}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, childID := range t.children[id] {
+			if visited[childID] {
+				continue
+			}
+			visited[childID] = true
+			queue = append(queue, childID)
+			if child, ok := t.byID[childID]; ok {
+				names = append(names, child.Name)
+			}
+		}
+	}
+	return names
+}
+
+// expandSearch rewrites every label term of a search expression into the labels it stands for
+func (t *taxonomy) expandSearch(expr search.Expr) search.Expr {
+	switch e := expr.(type) {
+	case *search.Term:
+		if e.Field != search.FieldLabel {
+			return e
+		}
+		names := t.expand(e.Value)
+		terms := make([]search.Expr, 0, len(names))
+		for _, name := range names {
+			terms = append(terms, &search.Term{Field: search.FieldLabel, Value: name})
+		}
+		return search.AnyOf(terms...)
+	case search.And:
+		operands := make(search.And, 0, len(e))
+		for _, operand := range e {
+			operands = append(operands, t.expandSearch(operand))
+		}
+		return operands
+	case search.Or:
+		operands := make(search.Or, 0, len(e))
+		for _, operand := range e {
+			operands = append(operands, t.expandSearch(operand))
+		}
+		return operands
+	case *search.Not:
+		return &search.Not{Expr: t.expandSearch(e.Expr)}
+	}
+	return expr
+}
+
+// hasLabelTerm reports whether a search expression filters by label, so the taxonomy is only loaded when needed
+func hasLabelTerm(expr search.Expr) bool {
+	switch e := expr.(type) {
+	case *search.Term:
+		return e.Field == search.FieldLabel
+	case search.And:
+		for _, operand := range e {
+			if hasLabelTerm(operand) {
+				return true
+			}
+		}
+	case search.Or:
+		for _, operand := range e {
+			if hasLabelTerm(operand) {
+				return true
+			}
+		}
+	case *search.Not:
+		return hasLabelTerm(e.Expr)
+	}
+	return false
+}
+
+// blocklist holds the patterns of one kind of taxonomy blocklist entry
+type blocklist []string
+
+func loadBlocklist(taxonomyBlockRepo repository.TaxonomyBlockRepository, kind string) (blocklist, error) {
+	blocks, err := taxonomyBlockRepo.List(kind)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s blocklist: %w", kind, err)
+	}
+	patterns := make(blocklist, 0, len(blocks))
+	for _, block := range blocks {
+		patterns = append(patterns, strings.ToLower(block.Pattern))
+	}
+	return patterns, nil
+}
+
+func (b blocklist) blocks(value string) bool {
+	value = strings.ToLower(value)
+	for _, pattern := range b {
+		if matchWildcard(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchWildcard matches value against a pattern where "*" is any run of characters and "?" exactly one
+func matchWildcard(pattern, value string) bool {
+	p, v := []rune(pattern), []rune(value)
+	// star and match remember the last "*" and where the value was when it was reached, for backtracking
+	star, match := -1, 0
+	i, j := 0, 0
+	for j < len(v) {
+		switch {
+		case i < len(p) && (p[i] == '?' || p[i] == v[j]):
+			i++
+			j++
+		case i < len(p) && p[i] == '*':
+			star, match = i, j
+			i++
+		case star >= 0:
+			i = star + 1
+			match++
+			j = match
+		default:
+			return false
+		}
+	}
+	for i < len(p) && p[i] == '*' {
+		i++
+	}
+	return i == len(p)
+}
+
+// labelResolver maps detected label names to their curated labels while labels of one detection are saved
+type labelResolver struct {
+	labelRepo      repository.LabelRepository
+	labelAliasRepo repository.LabelAliasRepository
+	blocked        blocklist
+	names          map[string]string
+}
+
+func (s *mediaService) newLabelResolver() (*labelResolver, error) {
+	blocked, err := loadBlocklist(s.taxonomyBlockRepo, db.TaxonomyBlockLabel)
+	if err != nil {
+		return nil, err
+	}
+	return &labelResolver{
+		labelRepo:      s.labelRepo,
+		labelAliasRepo: s.labelAliasRepo,
+		blocked:        blocked,
+		names:          make(map[string]string),
+	}, nil
+}
+
+// resolve returns the name of the label a detected name is an alias of, the name itself when it is not an alias,
+// and false when the name is blocked
+func (r *labelResolver) resolve(name string) (string, bool, error) {
+	if r.blocked.blocks(name) {
+		return "", false, nil
+	}
+	if resolved, ok := r.names[name]; ok {
+		return resolved, true, nil
+	}
+
+	resolved := name
+	alias, err := r.labelAliasRepo.GetByAlias(name)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to look up label alias %q: %w", name, err)
+	}
+	if alias != nil {
+		label, err := r.labelRepo.GetByID(alias.LabelID)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to get label %d: %w", alias.LabelID, err)
+		}
+		if label != nil {
+			resolved = label.Name
+		}
+	}
+	r.names[name] = resolved
+	return resolved, true, nil
+}
+
+// resolveLabels drops blocked labels and parents and renames aliases to their labels
+func (r *labelResolver) resolveLabels(labels []sdk.DetectedLabel) ([]sdk.DetectedLabel, error) {
+	result := make([]sdk.DetectedLabel, 0, len(labels))
+	for _, label := range labels {
+		name, ok, err := r.resolve(label.Name)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		label.Name = name
+
+		var parents []string
+		for _, parent := range label.Parents {
+			parentName, ok, err := r.resolve(parent)
+			if err != nil {
+				return nil, err
+			}
+			if ok && parentName != name {
+				parents = append(parents, parentName)
+			}
+		}
+		label.Parents = parents
+		result = append(result, label)
+	}
+	return result, nil
+}
@@ -0,0 +1,350 @@
+package service
+
+import (
+	"blog-fanchiikawa-service/db"
+	"blog-fanchiikawa-service/graph/model"
+	"blog-fanchiikawa-service/repository"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+)
+
+const maxTaxonomyTermLength = 255
+
+var (
+	// ErrLabelNotFound is returned when a curation refers to an unknown label
+	ErrLabelNotFound = errors.New("label not found")
+	// ErrTextKeywordNotFound is returned when a curation refers to an unknown keyword
+	ErrTextKeywordNotFound = errors.New("keyword not found")
+	// ErrLabelAliasConflict is returned for an alias that is already a label name or another label's alias
+	ErrLabelAliasConflict = errors.New("alias is already a label or an alias of another label")
+	// ErrLabelCycle is returned when a parent link would make a label its own ancestor
+	ErrLabelCycle = errors.New("label cannot be its own ancestor")
+	// ErrInvalidTaxonomyTerm is returned for empty or over-long aliases and blocklist patterns
+	ErrInvalidTaxonomyTerm = errors.New("invalid alias or pattern")
+)
+
+// TaxonomyService curates the labels and keywords detected by Rekognition: aliases, merges, the label hierarchy,
+// hidden entries and the blocklist that keeps noise from being stored again
+type TaxonomyService interface {
+	// AddLabelAlias makes alias resolve to the label in detections and searches
+	AddLabelAlias(labelID int64, alias string) (*model.Label, error)
+	RemoveLabelAlias(alias string) (bool, error)
+	// MergeLabels moves the images of the source labels to the target and keeps their names as its aliases
+	MergeLabels(sourceIDs []int64, targetID int64) (*model.Label, error)
+	SetLabelParent(labelID, parentID int64) (*model.Label, error)
+	// RemoveLabelParent removes a parent link; later detections do not restore it
+	RemoveLabelParent(labelID, parentID int64) (*model.Label, error)
+	// SetLabelHidden hides a label from images, facets, searches and the public label list
+	SetLabelHidden(labelID int64, hidden bool) (*model.Label, error)
+	// SetKeywordHidden hides a keyword from images, facets and searches
+	SetKeywordHidden(keyword string, hidden bool) (*model.TextKeyword, error)
+	ListBlocks() ([]*model.TaxonomyBlock, error)
+	// AddBlock stops matching labels or keywords from being stored and deletes the ones already stored
+	AddBlock(kind model.TaxonomyKind, pattern string) (*model.TaxonomyBlock, error)
+	RemoveBlock(id int64) (bool, error)
+}
+
+type taxonomyService struct {
+	labelRepo         repository.LabelRepository
+	labelParentRepo   repository.LabelParentRepository
+	labelAliasRepo    repository.LabelAliasRepository
+	textKeywordRepo   repository.TextKeywordRepository
+	taxonomyBlockRepo repository.TaxonomyBlockRepository
+}
+
+func NewTaxonomyService(labelRepo repository.LabelRepository, labelParentRepo repository.LabelParentRepository, labelAliasRepo repository.LabelAliasRepository, textKeywordRepo repository.TextKeywordRepository, taxonomyBlockRepo repository.TaxonomyBlockRepository) TaxonomyService {
+	return &taxonomyService{
+		labelRepo:         labelRepo,
+		labelParentRepo:   labelParentRepo,
+		labelAliasRepo:    labelAliasRepo,
+		textKeywordRepo:   textKeywordRepo,
+		taxonomyBlockRepo: taxonomyBlockRepo,
+	}
+}
+
+func normalizeTaxonomyTerm(term string) (string, error) {
+	term = strings.TrimSpace(term)
+	if term == "" || utf8.RuneCountInString(term) > maxTaxonomyTermLength {
+		return "", fmt.Errorf("%w: %q", ErrInvalidTaxonomyTerm, term)
+	}
+	return term, nil
+}
+
+func (s *taxonomyService) getLabel(id int64) (*db.Label, error) {
+	label, err := s.labelRepo.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get label: %w", err)
+	}
+	if label == nil {
+		return nil, fmt.Errorf("%w: %d", ErrLabelNotFound, id)
+	}
+	return label, nil
+}
+
+func (s *taxonomyService) toLabelModel(label *db.Label) (*model.Label, error) {
+	parents, err := s.labelRepo.GetParentsByLabelIDs([]int64{label.ID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load label parents: %w", err)
+	}
+	aliases, err := s.labelAliasRepo.GetByLabelIDs([]int64{label.ID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load label aliases: %w", err)
+	}
+	return toLabelModel(label, parents[label.ID], aliases[label.ID]), nil
+}
+
+func (s *taxonomyService) AddLabelAlias(labelID int64, alias string) (*model.Label, error) {
+	alias, err := normalizeTaxonomyTerm(alias)
+	if err != nil {
+		return nil, err
+	}
+	label, err := s.getLabel(labelID)
+	if err != nil {
+		return nil, err
+	}
+
+	// A label already stored under the alias has images of its own, which only a merge moves
+	existingLabel, err := s.labelRepo.GetByName(nil, alias)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get label: %w", err)
+	}
+	if existingLabel != nil {
+		return nil, fmt.Errorf("%w: %q is a label", ErrLabelAliasConflict, alias)
+	}
+	existingAlias, err := s.labelAliasRepo.GetByAlias(alias)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get label alias: %w", err)
+	}
+	if existingAlias != nil && existingAlias.LabelID != labelID {
+		return nil, fmt.Errorf("%w: %q", ErrLabelAliasConflict, alias)
+	}
+	if existingAlias == nil {
+		if err := s.labelAliasRepo.Create(&db.LabelAlias{Alias: alias, LabelID: labelID}); err != nil {
+			return nil, fmt.Errorf("failed to create label alias: %w", err)
+		}
+		log.Printf("Added alias %q to label %q", alias, label.Name)
+	}
+	return s.toLabelModel(label)
+}
+
+func (s *taxonomyService) RemoveLabelAlias(alias string) (bool, error) {
+	affected, err := s.labelAliasRepo.Delete(strings.TrimSpace(alias))
+	if err != nil {
+		return false, fmt.Errorf("failed to delete label alias: %w", err)
+	}
+	return affected > 0, nil
+}
+
+func (s *taxonomyService) MergeLabels(sourceIDs []int64, targetID int64) (*model.Label, error) {
+	target, err := s.getLabel(targetID)
+	if err != nil {
+		return nil, err
+	}
+	taxonomy, err := loadTaxonomy(s.labelRepo, s.labelAliasRepo)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every merge is checked against the hierarchy the ones before it leave, before any of them is made
+	var sources []*db.Label
+	for _, sourceID := range sourceIDs {
+		if sourceID == targetID {
+			continue
+		}
+		source, err := s.getLabel(sourceID)
+		if err != nil {
+			return nil, err
+		}
+		if conflictID := taxonomy.mergeConflict(source.ID, target.ID); conflictID != 0 {
+			conflict := fmt.Sprint(conflictID)
+			if label, ok := taxonomy.byID[conflictID]; ok {
+				conflict = label.Name
+			}
+			return nil, fmt.Errorf("%w: merging %q into %q would make %q both an ancestor and a descendant of %q",
+				ErrLabelCycle, source.Name, target.Name, conflict, target.Name)
+		}
+		taxonomy.merge(source.ID, target.ID)
+		sources = append(sources, source)
+	}
+
+	for _, source := range sources {
+		if err := s.labelRepo.Merge(source.ID, target.ID, source.Name); err != nil {
+			log.Printf("Failed to merge label %q into %q: %v", source.Name, target.Name, err)
+			return nil, fmt.Errorf("failed to merge label %q: %w", source.Name, err)
+		}
+		log.Printf("Merged label %q into %q", source.Name, target.Name)
+	}
+	return s.toLabelModel(target)
+}
+
+func (s *taxonomyService) SetLabelParent(labelID, parentID int64) (*model.Label, error) {
+	label, err := s.getLabel(labelID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.getLabel(parentID); err != nil {
+		return nil, err
+	}
+	if labelID == parentID {
+		return nil, ErrLabelCycle
+	}
+	taxonomy, err := loadTaxonomy(s.labelRepo, s.labelAliasRepo)
+	if err != nil {
+		return nil, err
+	}
+	if taxonomy.isDescendant(parentID, labelID) {
+		return nil, ErrLabelCycle
+	}
+
+	link, err := s.labelParentRepo.GetByLabelAndParent(nil, labelID, parentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get label parent: %w", err)
+	}
+	if link == nil {
+		if err := s.labelParentRepo.Create(nil, &db.LabelParent{LabelID: labelID, ParentLabelID: parentID}); err != nil {
+			return nil, fmt.Errorf("failed to create label parent: %w", err)
+		}
+	} else if link.Removed {
+		if _, err := s.labelParentRepo.UpdateRemoved(link.ID, false); err != nil {
+			return nil, fmt.Errorf("failed to restore label parent: %w", err)
+		}
+	}
+	return s.toLabelModel(label)
+}
+
+func (s *taxonomyService) RemoveLabelParent(labelID, parentID int64) (*model.Label, error) {
+	label, err := s.getLabel(labelID)
+	if err != nil {
+		return nil, err
+	}
+	link, err := s.labelParentRepo.GetByLabelAndParent(nil, labelID, parentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get label parent: %w", err)
+	}
+	// The link is kept as removed rather than deleted so the next detection does not add it back
+	if link == nil {
+		if err := s.labelParentRepo.Create(nil, &db.LabelParent{LabelID: labelID, ParentLabelID: parentID, Removed: true}); err != nil {
+			return nil, fmt.Errorf("failed to create label parent: %w", err)
+		}
+	} else if !link.Removed {
+		if _, err := s.labelParentRepo.UpdateRemoved(link.ID, true); err != nil {
+			return nil, fmt.Errorf("failed to remove label parent: %w", err)
+		}
+	}
+	return s.toLabelModel(label)
+}
+
+func (s *taxonomyService) SetLabelHidden(labelID int64, hidden bool) (*model.Label, error) {
+	label, err := s.getLabel(labelID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.labelRepo.UpdateHidden(labelID, hidden); err != nil {
+		return nil, fmt.Errorf("failed to update label: %w", err)
+	}
+	label.Hidden = hidden
+	return s.toLabelModel(label)
+}
+
+func (s *taxonomyService) SetKeywordHidden(keyword string, hidden bool) (*model.TextKeyword, error) {
+	textKeyword, err := s.textKeywordRepo.GetByKeyword(nil, strings.TrimSpace(keyword))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get keyword: %w", err)
+	}
+	if textKeyword == nil {
+		return nil, fmt.Errorf("%w: %q", ErrTextKeywordNotFound, keyword)
+	}
+	if _, err := s.textKeywordRepo.UpdateHidden(textKeyword.ID, hidden); err != nil {
+		return nil, fmt.Errorf("failed to update keyword: %w", err)
+	}
+	textKeyword.Hidden = hidden
+	return toTextKeywordModel(textKeyword), nil
+}
+
+func (s *taxonomyService) ListBlocks() ([]*model.TaxonomyBlock, error) {
+	blocks, err := s.taxonomyBlockRepo.List("")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list taxonomy blocks: %w", err)
+	}
+	result := make([]*model.TaxonomyBlock, 0, len(blocks))
+	for _, block := range blocks {
+		result = append(result, toTaxonomyBlockModel(block))
+	}
+	return result, nil
+}
+
+func (s *taxonomyService) AddBlock(kind model.TaxonomyKind, pattern string) (*model.TaxonomyBlock, error) {
+	pattern, err := normalizeTaxonomyTerm(pattern)
+	if err != nil {
+		return nil, err
+	}
+	// A pattern of wildcards alone would delete every label or keyword
+	if strings.Trim(pattern, "*?") == "" {
+		return nil, fmt.Errorf("%w: %q matches everything", ErrInvalidTaxonomyTerm, pattern)
+	}
+	dbKind := db.TaxonomyBlockLabel
+	if kind == model.TaxonomyKindKeyword {
+		dbKind = db.TaxonomyBlockKeyword
+	}
+
+	block, err := s.taxonomyBlockRepo.GetByKindAndPattern(dbKind, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get taxonomy block: %w", err)
+	}
+	if block == nil {
+		block = &db.TaxonomyBlock{Kind: dbKind, Pattern: pattern}
+		if err := s.taxonomyBlockRepo.Create(block); err != nil {
+			return nil, fmt.Errorf("failed to create taxonomy block: %w", err)
+		}
+	}
+
+	// Purge what was stored before the entry existed; new detections are filtered while they are saved
+	switch dbKind {
+	case db.TaxonomyBlockLabel:
+		labels, err := s.labelRepo.GetByPattern(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find blocked labels: %w", err)
+		}
+		ids := make([]int64, 0, len(labels))
+		for _, label := range labels {
+			ids = append(ids, label.ID)
+		}
+		if err := s.labelRepo.Delete(ids); err != nil {
+			log.Printf("Failed to delete labels blocked by %q: %v", pattern, err)
+			return nil, fmt.Errorf("failed to delete blocked labels: %w", err)
+		}
+		log.Printf("Blocked labels matching %q, deleted %d", pattern, len(ids))
+	case db.TaxonomyBlockKeyword:
+		deleted, err := s.textKeywordRepo.DeleteByPattern(pattern)
+		if err != nil {
+			log.Printf("Failed to delete keywords blocked by %q: %v", pattern, err)
+			return nil, fmt.Errorf("failed to delete blocked keywords: %w", err)
+		}
+		log.Printf("Blocked keywords matching %q, deleted %d", pattern, deleted)
+	}
+	return toTaxonomyBlockModel(block), nil
+}
+
+func (s *taxonomyService) RemoveBlock(id int64) (bool, error) {
+	affected, err := s.taxonomyBlockRepo.Delete(id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete taxonomy block: %w", err)
+	}
+	return affected > 0, nil
+}
+
+func toTaxonomyBlockModel(block *db.TaxonomyBlock) *model.TaxonomyBlock {
+	kind := model.TaxonomyKindLabel
+	if block.Kind == db.TaxonomyBlockKeyword {
+		kind = model.TaxonomyKindKeyword
+	}
+	return &model.TaxonomyBlock{
+		ID:        block.ID,
+		Kind:      kind,
+		Pattern:   block.Pattern,
+		CreatedAt: block.CreatedAt,
+	}
+}
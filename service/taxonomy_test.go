@@ -0,0 +1,77 @@
+package service
+
+import (
+	"blog-fanchiikawa-service/db"
+	"testing"
+)
+
+// newTestTaxonomy builds a taxonomy from child → parent links between labels named by their IDs
+func newTestTaxonomy(links map[int64][]int64) *taxonomy {
+	t := &taxonomy{
+		byID:     make(map[int64]*db.Label),
+		children: make(map[int64][]int64),
+		parents:  make(map[int64][]int64),
+	}
+	for childID, parentIDs := range links {
+		t.byID[childID] = &db.Label{ID: childID}
+		for _, parentID := range parentIDs {
+			t.byID[parentID] = &db.Label{ID: parentID}
+			t.children[parentID] = append(t.children[parentID], childID)
+			t.parents[childID] = append(t.parents[childID], parentID)
+		}
+	}
+	return t
+}
+
+func TestMergeConflict(t *testing.T) {
+	// 1 Animal > 2 Pet > 3 Cat, and 4 Kitten > 3 Cat
+	links := map[int64][]int64{2: {1}, 3: {2, 4}}
+
+	tests := []struct {
+		name             string
+		source, target   int64
+		wantConflictWith int64
+	}{
+		// Kitten's child Cat is below Animal, so Animal taking it over is fine
+		{"child moves below an unrelated target", 4, 1, 0},
+		// Cat's parent Kitten would become a parent of Animal, which is fine, but Cat's parent Pet is below Animal
+		{"parent below the target", 3, 1, 2},
+		// Animal's child Pet is above Cat, so Cat would become Pet's parent and child
+		{"child above the target", 1, 3, 2},
+		// Pet is Cat's parent; merging drops the link between them instead of reporting it
+		{"source linked to the target", 2, 3, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newTestTaxonomy(links).mergeConflict(tt.source, tt.target); got != tt.wantConflictWith {
+				t.Errorf("mergeConflict(%d, %d) = %d, want %d", tt.source, tt.target, got, tt.wantConflictWith)
+			}
+		})
+	}
+}
+
+func TestMergeMovesLinks(t *testing.T) {
+	// 1 Animal > 2 Pet > 3 Cat, and 4 Kitten > 5 Tabby
+	taxonomy := newTestTaxonomy(map[int64][]int64{2: {1}, 3: {2}, 5: {4}})
+	taxonomy.merge(4, 3)
+
+	if !taxonomy.isDescendant(5, 3) || !taxonomy.isDescendant(5, 1) {
+		t.Fatal("Tabby did not move below Cat")
+	}
+	if _, ok := taxonomy.byID[4]; ok || len(taxonomy.parents[5]) != 1 {
+		t.Fatalf("Kitten is still linked: parents of Tabby = %v", taxonomy.parents[5])
+	}
+
+	// Merging Pet into Cat next would drop the Pet > Cat link and put Cat under Animal
+	if conflict := taxonomy.mergeConflict(2, 3); conflict != 0 {
+		t.Fatalf("mergeConflict = %d", conflict)
+	}
+	taxonomy.merge(2, 3)
+	if !taxonomy.isDescendant(3, 1) || taxonomy.isDescendant(3, 3) {
+		t.Fatalf("parents of Cat = %v", taxonomy.parents[3])
+	}
+	// Now Animal cannot be merged into Tabby, which is below it
+	if conflict := taxonomy.mergeConflict(1, 5); conflict != 3 {
+		t.Fatalf("mergeConflict(1, 5) = %d, want 3", conflict)
+	}
+}
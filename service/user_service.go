@@ -4,8 +4,33 @@ import (
 	"blog-fanchiikawa-service/db"
 	"blog-fanchiikawa-service/graph/model"
 	"blog-fanchiikawa-service/repository"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"xorm.io/xorm"
+)
+
+var (
+	// ErrInvalidCredentials is returned for an unknown email, a wrong password or an account without a password
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	// ErrEmailTaken is returned when registering an email that already has an account
+	ErrEmailTaken = errors.New("email is already registered")
+	// ErrPasswordTooShort is returned for passwords shorter than minPasswordLength
+	ErrPasswordTooShort = fmt.Errorf("password must be at least %d characters", minPasswordLength)
 )
 
+// dummyPasswordHash is checked for unknown emails so they take as long to refuse as wrong passwords
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, err := hashPassword("dummy password")
+	if err != nil {
+		log.Printf("Failed to hash dummy password: %v", err)
+	}
+	return hash
+})
+
 // UserService defines the interface for user business logic
 type UserService interface {
 	// Login handles user login/registration logic
@@ -13,6 +38,18 @@ type UserService interface {
 	
 	// GetUsers retrieves a list of users
 	GetUsers(limit int) ([]*model.User, error)
+
+	// GetUser retrieves a user by ID, or nil when there is none
+	GetUser(id int64) (*model.User, error)
+
+	// Register creates a user with a password, or returns ErrEmailTaken
+	Register(nickname, email, password, deviceID string) (*model.User, error)
+
+	// Authenticate returns the user with the email and password, or ErrInvalidCredentials
+	Authenticate(email, password string) (*model.User, error)
+
+	// SetPassword sets a user's password; currentPassword must match once one is set
+	SetPassword(userID int64, currentPassword, newPassword string) error
 }
 
 // userService implements UserService interface
@@ -50,14 +87,14 @@ func (s *userService) Login(nickname, email, deviceID string) (*model.User, erro
 
 	// User doesn't exist, create new user and device in transaction
 	var newUser *db.User
-	err = s.transactionMgr.WithTransaction(func() error {
+	err = s.transactionMgr.WithTransaction(func(session *xorm.Session) error {
 		// Create new user
 		newUser = &db.User{
 			Nickname: nickname,
 			Email:    email,
 		}
 
-		if err := s.userRepo.Create(newUser); err != nil {
+		if err := s.userRepo.Create(session, newUser); err != nil {
 			return err
 		}
 
@@ -67,7 +104,7 @@ func (s *userService) Login(nickname, email, deviceID string) (*model.User, erro
 			DeviceID: deviceID,
 		}
 
-		return s.deviceRepo.Create(userDevice)
+		return s.deviceRepo.Create(session, userDevice)
 	})
 
 	if err != nil {
@@ -93,6 +130,104 @@ func (s *userService) GetUsers(limit int) ([]*model.User, error) {
 	return users, nil
 }
 
+// GetUser retrieves a user by ID, or nil when there is none
+func (s *userService) GetUser(id int64) (*model.User, error) {
+	dbUser, err := s.userRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if dbUser == nil {
+		return nil, nil
+	}
+	return s.convertToGraphQLUser(dbUser), nil
+}
+
+// Register creates a user with a password, or returns ErrEmailTaken
+func (s *userService) Register(nickname, email, password, deviceID string) (*model.User, error) {
+	email = strings.TrimSpace(email)
+	if len(password) < minPasswordLength {
+		return nil, ErrPasswordTooShort
+	}
+	existingUser, err := s.userRepo.GetByEmail(email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user by email: %w", err)
+	}
+	if existingUser != nil {
+		return nil, ErrEmailTaken
+	}
+
+	passwordHash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	newUser := &db.User{
+		Nickname:     nickname,
+		Email:        email,
+		PasswordHash: passwordHash,
+	}
+	err = s.transactionMgr.WithTransaction(func(session *xorm.Session) error {
+		if err := s.userRepo.Create(session, newUser); err != nil {
+			return err
+		}
+		return s.deviceRepo.Create(session, &db.UserDevice{
+			UserID:   newUser.ID,
+			DeviceID: deviceID,
+		})
+	})
+	if err != nil {
+		log.Printf("Failed to register user %s: %v", email, err)
+		return nil, fmt.Errorf("failed to register user: %w", err)
+	}
+
+	log.Printf("Registered user ID: %d", newUser.ID)
+	return s.convertToGraphQLUser(newUser), nil
+}
+
+// Authenticate returns the user with the email and password, or ErrInvalidCredentials
+func (s *userService) Authenticate(email, password string) (*model.User, error) {
+	user, err := s.userRepo.GetByEmail(strings.TrimSpace(email))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user by email: %w", err)
+	}
+	if user == nil || user.PasswordHash == "" {
+		checkPassword(password, dummyPasswordHash())
+		return nil, ErrInvalidCredentials
+	}
+	if !checkPassword(password, user.PasswordHash) {
+		log.Printf("Wrong password for user ID: %d", user.ID)
+		return nil, ErrInvalidCredentials
+	}
+	return s.convertToGraphQLUser(user), nil
+}
+
+// SetPassword sets a user's password; currentPassword must match once one is set
+func (s *userService) SetPassword(userID int64, currentPassword, newPassword string) error {
+	if len(newPassword) < minPasswordLength {
+		return ErrPasswordTooShort
+	}
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user %d: %w", userID, err)
+	}
+	if user == nil {
+		return ErrInvalidCredentials
+	}
+	if user.PasswordHash != "" && !checkPassword(currentPassword, user.PasswordHash) {
+		return ErrInvalidCredentials
+	}
+
+	passwordHash, err := hashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdatePassword(userID, passwordHash); err != nil {
+		log.Printf("Failed to update password of user ID %d: %v", userID, err)
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	log.Printf("Updated password of user ID: %d", userID)
+	return nil
+}
+
 // convertToGraphQLUser converts database User model to GraphQL User model
 func (s *userService) convertToGraphQLUser(dbUser *db.User) *model.User {
 	return &model.User{
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"blog-fanchiikawa-service/db"
+	"blog-fanchiikawa-service/sdk"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// videoTextSegmentGapMs is the longest gap between two sightings of the same line that still joins them into one segment.
+// Rekognition Video samples frames for text about once per second, so a shorter gap would split lines that stay on screen.
+const videoTextSegmentGapMs = 2000
+
+// maxVideoTextLength matches the size of the video_text_segment text column
+const maxVideoTextLength = 512
+
+// videoExtensions are the containers Rekognition Video can analyze
+var videoExtensions = map[string]bool{
+	".mp4": true,
+	".mov": true,
+}
+
+func isVideo(image *db.Image) bool {
+	return videoExtensions[strings.ToLower(image.FileExtension)]
+}
+
+// detectVideoLabels starts a Rekognition Video label detection job on the first run and saves its label segments on later runs.
+// It returns errMediaJobWaiting while Rekognition is still working.
+func (s *mediaService) detectVideoLabels(job *db.MediaJob, image *db.Image) error {
+	if !image.Uploaded {
+		return ErrMediaNotUploaded
+	}
+	if job.ExternalJobID == "" {
+		jobID, err := sdk.StartVideoLabelDetection(image.Bucket, image.ObjectKey, s.labelOptions.MinConfidence)
+		if err != nil {
+			return videoJobError("failed to start video label detection", err)
+		}
+		job.ExternalJobID = jobID
+		return errMediaJobWaiting
+	}
+
+	result, err := sdk.GetVideoLabelDetection(job.ExternalJobID)
+	if err != nil {
+		return videoJobError("failed to get video labels", err)
+	}
+	switch result.Status {
+	case sdk.VideoJobInProgress:
+		return errMediaJobWaiting
+	case sdk.VideoJobSucceeded:
+	default:
+		// Start a new Rekognition job on the next attempt
+		failedJobID := job.ExternalJobID
+		job.ExternalJobID = ""
+		return fmt.Errorf("rekognition video job %s %s: %s", failedJobID, strings.ToLower(result.Status), result.StatusMessage)
+	}
+
+	if err := s.SaveVideoLabels(image, result); err != nil {
+		return fmt.Errorf("failed to save video labels: %w", err)
+	}
+	s.publishMediaProcessed(image, MediaStageLabels)
+	return nil
+}
+
+// detectVideoText starts a Rekognition Video text detection job on the first run and saves its text segments on later runs.
+// It returns errMediaJobWaiting while Rekognition is still working.
+func (s *mediaService) detectVideoText(job *db.MediaJob, image *db.Image) error {
+	if !image.Uploaded {
+		return ErrMediaNotUploaded
+	}
+	if job.ExternalJobID == "" {
+		jobID, err := sdk.StartVideoTextDetection(image.Bucket, image.ObjectKey)
+		if err != nil {
+			return videoJobError("failed to start video text detection", err)
+		}
+		job.ExternalJobID = jobID
+		return errMediaJobWaiting
+	}
+
+	result, err := sdk.GetVideoTextDetection(job.ExternalJobID)
+	if err != nil {
+		return videoJobError("failed to get video text", err)
+	}
+	switch result.Status {
+	case sdk.VideoJobInProgress:
+		return errMediaJobWaiting
+	case sdk.VideoJobSucceeded:
+	default:
+		failedJobID := job.ExternalJobID
+		job.ExternalJobID = ""
+		return fmt.Errorf("rekognition video job %s %s: %s", failedJobID, strings.ToLower(result.Status), result.StatusMessage)
+	}
+
+	if err := s.SaveVideoText(image, result); err != nil {
+		return fmt.Errorf("failed to save video text: %w", err)
+	}
+	s.publishMediaProcessed(image, MediaStageText)
+	return nil
+}
+
+// videoJobError marks videos Rekognition cannot read as unsupported so their jobs are dead-lettered without retrying
+func videoJobError(message string, err error) error {
+	if errors.Is(err, sdk.ErrUnsupportedVideo) {
+		return fmt.Errorf("%w: %v", ErrUnsupportedMedia, err)
+	}
+	return fmt.Errorf("%s: %w", message, err)
+}
+
+// SaveVideoLabels replaces the video's label segments and links every label seen in it to the video,
+// with the highest confidence of its segments, so videos are found by the same label filters as images
+func (s *mediaService) SaveVideoLabels(image *db.Image, result *sdk.VideoLabelJob) error {
+	segments := make([]*db.VideoLabelSegment, 0, len(result.Segments))
+	var seen []sdk.DetectedLabel
+	resolver, err := s.newLabelResolver()
+	if err != nil {
+		return err
+	}
+	for _, segment := range result.Segments {
+		name, ok, err := resolver.resolve(segment.Label.Name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		segment.Label.Name = name
+
+		label, err := s.getOrCreateLabel(nil, segment.Label.Name)
+		if err != nil {
+			return err
+		}
+		segments = append(segments, &db.VideoLabelSegment{
+			LabelID:    label.ID,
+			StartMs:    segment.StartMillis,
+			EndMs:      segment.EndMillis,
+			Confidence: segment.Label.Confidence,
+		})
+		seen = append(seen, segment.Label)
+	}
+
+	if err := s.videoSegmentRepo.ReplaceLabelSegments(image.ID, segments); err != nil {
+		return err
+	}
+	s.saveVideoMetadata(image, result.Metadata)
+
+	// Saving the labels marks the video as label detected, so it comes last
+	return s.SaveImageLabels(image.ID, highestConfidenceLabels(seen))
+}
+
+// highestConfidenceLabels keeps each label once, in the order it was first seen, with its highest confidence
+func highestConfidenceLabels(labels []sdk.DetectedLabel) []sdk.DetectedLabel {
+	index := make(map[string]int)
+	var result []sdk.DetectedLabel
+	for _, label := range labels {
+		if i, ok := index[label.Name]; ok {
+			if label.Confidence > result[i].Confidence {
+				result[i].Confidence = label.Confidence
+			}
+			continue
+		}
+		index[label.Name] = len(result)
+		result = append(result, label)
+	}
+	return result
+}
+
+// SaveVideoText replaces the video's text segments, stores the distinct lines as its full text and
+// the words as keywords, so videos are found by the same text searches as images
+func (s *mediaService) SaveVideoText(image *db.Image, result *sdk.VideoTextJob) error {
+	segments := buildVideoTextSegments(result.Detections)
+	if err := s.videoSegmentRepo.ReplaceTextSegments(image.ID, segments); err != nil {
+		return err
+	}
+	s.saveVideoMetadata(image, result.Metadata)
+
+	var lines []string
+	seen := make(map[string]bool)
+	for _, segment := range segments {
+		if !seen[segment.Text] {
+			seen[segment.Text] = true
+			lines = append(lines, segment.Text)
+		}
+	}
+	if _, err := s.imageRepo.UpdateFullText(nil, image.ID, strings.Join(lines, "\n")); err != nil {
+		return fmt.Errorf("failed to save full text: %w", err)
+	}
+
+	var words []string
+	for _, detection := range result.Detections {
+		if detection.Block.Type == sdk.TextBlockWord {
+			words = append(words, detection.Block.Text)
+		}
+	}
+	return s.SaveImageTextKeywords(image.ID, words)
+}
+
+// saveVideoMetadata records the duration of a video and, when the file's metadata had none, its frame size
+func (s *mediaService) saveVideoMetadata(image *db.Image, metadata sdk.VideoMetadata) {
+	if metadata.DurationMillis == 0 {
+		return
+	}
+	width, height := image.Width, image.Height
+	if width == 0 || height == 0 {
+		width, height = metadata.FrameWidth, metadata.FrameHeight
+	}
+	if _, err := s.imageRepo.UpdateVideoMetadata(image.ID, metadata.DurationMillis, width, height); err != nil {
+		log.Printf("Failed to save video metadata of image ID %d: %v", image.ID, err)
+	}
+}
+
+// buildVideoTextSegments joins the sightings of each line into time ranges, splitting a line's range
+// where it disappears for longer than videoTextSegmentGapMs. Segments are ordered by start time.
+func buildVideoTextSegments(detections []sdk.VideoTextDetection) []*db.VideoTextSegment {
+	lines := make([]sdk.VideoTextDetection, 0, len(detections))
+	for _, detection := range detections {
+		if detection.Block.Type == sdk.TextBlockLine && strings.TrimSpace(detection.Block.Text) != "" {
+			lines = append(lines, detection)
+		}
+	}
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].TimestampMillis < lines[j].TimestampMillis
+	})
+
+	var segments []*db.VideoTextSegment
+	// open holds the latest segment of each line
+	open := make(map[string]*db.VideoTextSegment)
+	for _, line := range lines {
+		text := strings.TrimSpace(line.Block.Text)
+		if runes := []rune(text); len(runes) > maxVideoTextLength {
+			text = string(runes[:maxVideoTextLength])
+		}
+		if segment, ok := open[text]; ok && line.TimestampMillis-segment.EndMs <= videoTextSegmentGapMs {
+			segment.EndMs = line.TimestampMillis
+			if line.Block.Confidence > segment.Confidence {
+				segment.Confidence = line.Block.Confidence
+			}
+			continue
+		}
+		segment := &db.VideoTextSegment{
+			Text:       text,
+			StartMs:    line.TimestampMillis,
+			EndMs:      line.TimestampMillis,
+			Confidence: line.Block.Confidence,
+		}
+		open[text] = segment
+		segments = append(segments, segment)
+	}
+	return segments
+}
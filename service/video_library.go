@@ -0,0 +1,106 @@
+package service
+
+import (
+	"blog-fanchiikawa-service/db"
+	"blog-fanchiikawa-service/graph/model"
+	"fmt"
+)
+
+func (s *mediaLibraryService) VideoTimeline(imageID int64, minConfidence float64) (*model.VideoTimeline, error) {
+	image, err := s.imageRepo.GetByID(imageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image: %w", err)
+	}
+	if image == nil || !isVisible(image) {
+		return nil, ErrImageNotFound
+	}
+
+	labelSegments, err := s.videoSegmentRepo.GetLabelSegments(imageID, minConfidence)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get video label segments: %w", err)
+	}
+	textSegments, err := s.videoSegmentRepo.GetTextSegments(imageID, minConfidence)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get video text segments: %w", err)
+	}
+
+	timeline := &model.VideoTimeline{
+		ImageID: imageID,
+		Labels:  []*model.VideoLabelTrack{},
+		Texts:   []*model.VideoTextTrack{},
+	}
+	if image.DurationMs > 0 {
+		durationMs := int(image.DurationMs)
+		timeline.DurationMs = &durationMs
+	}
+
+	labelTracks, err := s.toVideoLabelTracks(labelSegments)
+	if err != nil {
+		return nil, err
+	}
+	timeline.Labels = labelTracks
+
+	// Segments arrive in time order, so tracks are created in order of first appearance
+	textTracks := make(map[string]*model.VideoTextTrack)
+	for _, segment := range textSegments {
+		track, ok := textTracks[segment.Text]
+		if !ok {
+			track = &model.VideoTextTrack{Text: segment.Text, Segments: []*model.VideoSegment{}}
+			textTracks[segment.Text] = track
+			timeline.Texts = append(timeline.Texts, track)
+		}
+		track.Segments = append(track.Segments, toVideoSegmentModel(segment.StartMs, segment.EndMs, segment.Confidence))
+		track.Confidence = max(track.Confidence, segment.Confidence)
+	}
+	return timeline, nil
+}
+
+// toVideoLabelTracks groups time-ordered label segments into one track per label
+func (s *mediaLibraryService) toVideoLabelTracks(segments []*db.VideoLabelSegment) ([]*model.VideoLabelTrack, error) {
+	var labelIDs []int64
+	tracks := make(map[int64]*model.VideoLabelTrack)
+	for _, segment := range segments {
+		if _, ok := tracks[segment.LabelID]; !ok {
+			tracks[segment.LabelID] = &model.VideoLabelTrack{Segments: []*model.VideoSegment{}}
+			labelIDs = append(labelIDs, segment.LabelID)
+		}
+		track := tracks[segment.LabelID]
+		track.Segments = append(track.Segments, toVideoSegmentModel(segment.StartMs, segment.EndMs, segment.Confidence))
+		track.Confidence = max(track.Confidence, segment.Confidence)
+	}
+
+	labels, err := s.labelRepo.GetByIDs(labelIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load video labels: %w", err)
+	}
+	parents, err := s.labelRepo.GetParentsByLabelIDs(labelIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load label parents: %w", err)
+	}
+	aliases, err := s.labelAliasRepo.GetByLabelIDs(labelIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load label aliases: %w", err)
+	}
+	for _, label := range labels {
+		if !label.Hidden {
+			tracks[label.ID].Label = toLabelModel(label, parents[label.ID], aliases[label.ID])
+		}
+	}
+
+	result := make([]*model.VideoLabelTrack, 0, len(labelIDs))
+	for _, labelID := range labelIDs {
+		// Segments of a label deleted since detection, or hidden, have nothing to show
+		if tracks[labelID].Label != nil {
+			result = append(result, tracks[labelID])
+		}
+	}
+	return result, nil
+}
+
+func toVideoSegmentModel(startMs, endMs int64, confidence float64) *model.VideoSegment {
+	return &model.VideoSegment{
+		StartMs:    int(startMs),
+		EndMs:      int(endMs),
+		Confidence: confidence,
+	}
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"blog-fanchiikawa-service/sdk"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func videoLine(ms int64, text string, confidence float64) sdk.VideoTextDetection {
+	return sdk.VideoTextDetection{TimestampMillis: ms, Block: sdk.TextBlock{Type: sdk.TextBlockLine, Text: text, Confidence: confidence}}
+}
+
+func videoWord(ms int64, text string) sdk.VideoTextDetection {
+	return sdk.VideoTextDetection{TimestampMillis: ms, Block: sdk.TextBlock{Type: sdk.TextBlockWord, Text: text, Confidence: 99}}
+}
+
+// textSegment is the part of a db.VideoTextSegment that buildVideoTextSegments fills in
+type textSegment struct {
+	text       string
+	start, end int64
+	confidence float64
+}
+
+func TestBuildVideoTextSegments(t *testing.T) {
+	tests := []struct {
+		name       string
+		detections []sdk.VideoTextDetection
+		want       []textSegment
+	}{
+		{
+			name:       "sightings 2000 ms apart are merged",
+			detections: []sdk.VideoTextDetection{videoLine(0, "EXIT", 90), videoLine(1000, "EXIT", 95), videoLine(3000, "EXIT", 80)},
+			want:       []textSegment{{"EXIT", 0, 3000, 95}},
+		},
+		{
+			name:       "sightings more than 2000 ms apart are split",
+			detections: []sdk.VideoTextDetection{videoLine(0, "EXIT", 90), videoLine(2001, "EXIT", 80)},
+			want:       []textSegment{{"EXIT", 0, 0, 90}, {"EXIT", 2001, 2001, 80}},
+		},
+		{
+			name: "identical lines interleaved with others keep their own segments",
+			detections: []sdk.VideoTextDetection{
+				videoLine(0, "EXIT", 90), videoLine(0, "Platform 3", 85),
+				videoLine(1000, "Platform 3", 88), videoLine(1500, "EXIT", 91),
+				videoLine(2500, "Mind the gap", 70), videoLine(3000, "EXIT", 92),
+				videoLine(6000, "Platform 3", 86),
+			},
+			want: []textSegment{
+				{"EXIT", 0, 3000, 92},
+				{"Platform 3", 0, 1000, 88},
+				{"Mind the gap", 2500, 2500, 70},
+				{"Platform 3", 6000, 6000, 86},
+			},
+		},
+		{
+			name:       "detections are ordered by time and trimmed",
+			detections: []sdk.VideoTextDetection{videoLine(1500, " EXIT ", 90), videoLine(0, "EXIT", 80), videoLine(500, "   ", 99)},
+			want:       []textSegment{{"EXIT", 0, 1500, 90}},
+		},
+		{
+			name:       "words are not segments",
+			detections: []sdk.VideoTextDetection{videoWord(0, "EXIT"), videoLine(0, "EXIT NOW", 90), videoWord(0, "NOW")},
+			want:       []textSegment{{"EXIT NOW", 0, 0, 90}},
+		},
+		{
+			name:       "no detections",
+			detections: nil,
+			want:       nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []textSegment
+			for _, segment := range buildVideoTextSegments(tt.detections) {
+				got = append(got, textSegment{segment.Text, segment.StartMs, segment.EndMs, segment.Confidence})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildVideoTextSegmentsTruncatesOnRuneBoundary(t *testing.T) {
+	// "字" takes three bytes, so a byte limit would cut one in half
+	long := strings.Repeat("字", maxVideoTextLength+10)
+	segments := buildVideoTextSegments([]sdk.VideoTextDetection{
+		videoLine(0, long, 90),
+		// Lines that differ only past the limit are the same stored text and join one segment
+		videoLine(1000, long+"!", 95),
+	})
+	if len(segments) != 1 {
+		t.Fatalf("got %d segments, want 1", len(segments))
+	}
+	text := segments[0].Text
+	if !utf8.ValidString(text) || utf8.RuneCountInString(text) != maxVideoTextLength {
+		t.Fatalf("truncated to %d runes, valid UTF-8 %v", utf8.RuneCountInString(text), utf8.ValidString(text))
+	}
+	if segments[0].EndMs != 1000 || segments[0].Confidence != 95 {
+		t.Fatalf("got %+v", segments[0])
+	}
+
+	short := strings.Repeat("字", maxVideoTextLength)
+	if segments := buildVideoTextSegments([]sdk.VideoTextDetection{videoLine(0, short, 90)}); segments[0].Text != short {
+		t.Fatal("a line at the limit was truncated")
+	}
+}
+
+func TestHighestConfidenceLabels(t *testing.T) {
+	got := highestConfidenceLabels([]sdk.DetectedLabel{
+		{Name: "Dog", Confidence: 80, Parents: []string{"Animal"}},
+		{Name: "Car", Confidence: 95},
+		{Name: "Dog", Confidence: 97},
+		{Name: "Car", Confidence: 60},
+		{Name: "Dog", Confidence: 90},
+	})
+	want := []sdk.DetectedLabel{
+		{Name: "Dog", Confidence: 97, Parents: []string{"Animal"}},
+		{Name: "Car", Confidence: 95},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	if got := highestConfidenceLabels(nil); len(got) != 0 {
+		t.Fatalf("got %+v for no labels", got)
+	}
+}
@@ -0,0 +1,234 @@
+package vectorindex
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// clustered returns n unit-length vectors scattered around a number of random centres, as embeddings of similar
+// content are, from a fixed seed so every run sees the same data
+func clustered(n, dimensions, clusters int, spread float64, seed int64) [][]float32 {
+	random := rand.New(rand.NewSource(seed))
+	centres := make([][]float32, clusters)
+	for i := range centres {
+		centres[i] = randomVector(random, dimensions, 1)
+	}
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		centre := centres[random.Intn(clusters)]
+		noise := randomVector(random, dimensions, spread)
+		vector := make([]float32, dimensions)
+		for j := range vector {
+			vector[j] = centre[j] + noise[j]
+		}
+		vectors[i], _ = normalize(vector)
+	}
+	return vectors
+}
+
+func randomVector(random *rand.Rand, dimensions int, scale float64) []float32 {
+	vector := make([]float32, dimensions)
+	for i := range vector {
+		vector[i] = float32(random.NormFloat64() * scale)
+	}
+	normalized, _ := normalize(vector)
+	for i := range normalized {
+		normalized[i] *= float32(scale)
+	}
+	return normalized
+}
+
+// bruteForce ranks every vector by cosine similarity the way Search orders its results
+func bruteForce(vectors [][]float32, query []float32, k int) []Result {
+	normalized, _ := normalize(query)
+	results := make([]Result, len(vectors))
+	for i, vector := range vectors {
+		results[i] = Result{ID: int64(i), Score: dot(normalized, vector)}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+	return results[:k]
+}
+
+// recallDataset returns 20,000 vectors in 200 clusters and 100 queries drawn from the same clusters
+func recallDataset() ([][]float32, [][]float32) {
+	vectors := clustered(20100, 256, 200, 1.2, 1)
+	return vectors[:20000], vectors[20000:]
+}
+
+func build(t testing.TB, vectors [][]float32, options Options) *Index {
+	t.Helper()
+	index := New(len(vectors[0]), options)
+	for i, vector := range vectors {
+		if err := index.Add(int64(i), vector); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return index
+}
+
+func TestExactSearchMatchesBruteForce(t *testing.T) {
+	vectors := clustered(1020, 64, 20, 0.5, 1)
+	// Queries come from the same clusters but are not in the index
+	vectors, queries := vectors[:1000], vectors[1000:]
+	index := build(t, vectors, Options{})
+
+	for q, query := range queries {
+		got, err := index.Search(query, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := bruteForce(vectors, query, 10)
+		for i := range want {
+			if got[i].ID != want[i].ID {
+				t.Fatalf("query %d: result %d is %d, brute force found %d", q, i, got[i].ID, want[i].ID)
+			}
+		}
+	}
+}
+
+// TestSearchRecall backs the figures given for the defaults: on its fixed dataset 48 tables of 12 bits find
+// about 92% of the 10 nearest neighbours while comparing each query with about 15% of the vectors
+func TestSearchRecall(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a 20,000 vector index")
+	}
+	const k = 10
+	vectors, queries := recallDataset()
+	index := build(t, vectors, Options{})
+
+	recall, compared := measure(t, index, vectors, queries, k)
+	t.Logf("recall %.1f%%, compared %.1f%% of the vectors", 100*recall, 100*compared)
+	if recall < 0.9 {
+		t.Errorf("recall %.1f%% is below 90%%", 100*recall)
+	}
+	if compared > 0.2 {
+		t.Errorf("compared %.1f%% of the vectors, above 20%%", 100*compared)
+	}
+}
+
+// measure returns the share of the true k nearest neighbours Search finds and the share of vectors it compares
+func measure(t testing.TB, index *Index, vectors, queries [][]float32, k int) (float64, float64) {
+	found, compared := 0, 0
+	for _, query := range queries {
+		got, err := index.Search(query, k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make(map[int64]bool, len(got))
+		for _, result := range got {
+			ids[result.ID] = true
+		}
+		for _, result := range bruteForce(vectors, query, k) {
+			if ids[result.ID] {
+				found++
+			}
+		}
+
+		normalized, _ := normalize(query)
+		index.mu.RLock()
+		candidates := index.candidates(index.hash(normalized), k)
+		index.mu.RUnlock()
+		if candidates == nil {
+			compared += len(vectors)
+		} else {
+			compared += len(candidates)
+		}
+	}
+	return float64(found) / float64(k*len(queries)), float64(compared) / float64(len(vectors)*len(queries))
+}
+
+func BenchmarkSearch(b *testing.B) {
+	vectors, queries := recallDataset()
+	index := build(b, vectors, Options{})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := index.Search(queries[i%len(queries)], 10); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestAddReplaces(t *testing.T) {
+	index := New(3, Options{ExactBelow: 1})
+	if err := index.Add(1, []float32{1, 0, 0}); err != nil {
+		t.Fatal(err)
+	}
+	if err := index.Add(2, []float32{0, 1, 0}); err != nil {
+		t.Fatal(err)
+	}
+	if err := index.Add(1, []float32{0, 0, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if index.Len() != 2 {
+		t.Fatalf("Len = %d after replacing a vector", index.Len())
+	}
+
+	results, err := index.Search([]float32{0, 0, 1}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].ID != 1 || results[0].Score < 0.999 {
+		t.Fatalf("results = %+v, want the replacement vector", results)
+	}
+	results, err = index.Search([]float32{1, 0, 0}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range results {
+		if result.Score > 0.5 {
+			t.Fatalf("the replaced vector is still found: %+v", results)
+		}
+	}
+}
+
+func TestRemove(t *testing.T) {
+	vectors := clustered(200, 16, 4, 0.5, 1)
+	index := build(t, vectors, Options{ExactBelow: 1})
+	for id := int64(0); id < 100; id++ {
+		index.Remove(id)
+	}
+	index.Remove(1000) // unknown IDs are ignored
+	if index.Len() != 100 {
+		t.Fatalf("Len = %d", index.Len())
+	}
+
+	for _, query := range vectors[:20] {
+		results, err := index.Search(query, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, result := range results {
+			if result.ID < 100 {
+				t.Fatalf("removed vector %d was found", result.ID)
+			}
+		}
+	}
+	for table := range index.tables {
+		for key, bucket := range index.tables[table] {
+			for _, id := range bucket {
+				if id < 100 {
+					t.Fatalf("removed vector %d is still in bucket %x of table %d", id, key, table)
+				}
+			}
+		}
+	}
+}
+
+func TestInvalidVectors(t *testing.T) {
+	index := New(3, Options{})
+	if err := index.Add(1, []float32{1, 0}); err == nil {
+		t.Error("accepted a vector of the wrong dimension")
+	}
+	if err := index.Add(1, []float32{0, 0, 0}); err == nil {
+		t.Error("accepted a zero vector")
+	}
+	if _, err := index.Search([]float32{1}, 1); err == nil {
+		t.Error("searched with a query of the wrong dimension")
+	}
+}
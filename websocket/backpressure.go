@@ -0,0 +1,88 @@
+package websocket
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// SlowClientPolicy decides what happens to a frame when a client's outbound queue is full
+type SlowClientPolicy string
+
+const (
+	// PolicyDrop discards the new frame; the client sees a gap in seq and can resume
+	PolicyDrop SlowClientPolicy = "drop"
+	// PolicyDisconnect closes the connection
+	PolicyDisconnect SlowClientPolicy = "disconnect"
+	// PolicyCoalesce replaces a queued frame of the same kind (typing, presence, read receipts)
+	// and falls back to dropping for frames that cannot be coalesced
+	PolicyCoalesce SlowClientPolicy = "coalesce"
+)
+
+const sendQueueSize = 256
+
+// ParseSlowClientPolicy parses WS_SLOW_CLIENT_POLICY, defaulting to PolicyDisconnect
+func ParseSlowClientPolicy(value string) SlowClientPolicy {
+	switch policy := SlowClientPolicy(strings.ToLower(strings.TrimSpace(value))); policy {
+	case PolicyDrop, PolicyDisconnect, PolicyCoalesce:
+		return policy
+	case "":
+		return PolicyDisconnect
+	default:
+		log.Printf("Unknown WS_SLOW_CLIENT_POLICY %q, using %s", value, PolicyDisconnect)
+		return PolicyDisconnect
+	}
+}
+
+// coalesceKey returns the key under which newer frames supersede older ones,
+// or "" when every frame of this kind must be delivered
+func coalesceKey(m *Message) string {
+	switch m.Type {
+	case "typing_start", "typing_stop":
+		return fmt.Sprintf("typing:%d", m.ChatID)
+	case "message_read":
+		return fmt.Sprintf("read:%d", m.ChatID)
+	case "presence":
+		if data, ok := m.Data.(*PresencePayload); ok {
+			return fmt.Sprintf("presence:%d", data.UserID)
+		}
+	}
+	return ""
+}
+
+// pushLocked appends a frame to the outbound queue applying the slow client policy.
+// It reports false when the client must be disconnected. c.mu must be held.
+func (c *Client) pushLocked(message *Message) bool {
+	if len(c.queue) < sendQueueSize {
+		c.queue = append(c.queue, message)
+		return true
+	}
+
+	switch c.hub.slowClientPolicy {
+	case PolicyDisconnect:
+		return false
+
+	case PolicyCoalesce:
+		if key := coalesceKey(message); key != "" {
+			for i, queued := range c.queue {
+				if coalesceKey(queued) == key {
+					c.queue[i] = message
+					return true
+				}
+			}
+		}
+		// Make room by dropping the oldest frame that a newer one would supersede anyway
+		for i, queued := range c.queue {
+			if coalesceKey(queued) != "" {
+				c.queue = append(c.queue[:i], c.queue[i+1:]...)
+				c.queue = append(c.queue, message)
+				c.skipped++
+				return true
+			}
+		}
+	}
+
+	log.Printf("Dropping %s frame for slow client %s", message.Type, c.ID)
+	c.skipped++
+	return true
+}
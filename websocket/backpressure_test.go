@@ -0,0 +1,113 @@
+package websocket
+
+import (
+	"context"
+	"testing"
+)
+
+// newQueueClient returns a client without a connection whose queue can be filled directly
+func newQueueClient(policy SlowClientPolicy) *Client {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Client{
+		ID:     "test",
+		hub:    &Hub{slowClientPolicy: policy},
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		notify: make(chan struct{}, 1),
+	}
+}
+
+// fill queues n chat messages, which can never be coalesced
+func fill(t *testing.T, c *Client, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if !c.enqueue(&Message{Type: "chat_message", ChatID: 1}) {
+			t.Fatalf("frame %d was refused before the queue was full", i)
+		}
+	}
+}
+
+func TestSlowClientPolicyDrop(t *testing.T) {
+	c := newQueueClient(PolicyDrop)
+	fill(t, c, sendQueueSize)
+
+	if !c.enqueue(&Message{Type: "chat_message", ChatID: 2}) {
+		t.Fatal("drop policy disconnected the client")
+	}
+	if len(c.queue) != sendQueueSize || c.skipped != 1 {
+		t.Fatalf("queue = %d frames, skipped = %d", len(c.queue), c.skipped)
+	}
+	for _, message := range c.queue {
+		if message.ChatID == 2 {
+			t.Fatal("the frame that did not fit was queued")
+		}
+	}
+
+	// The dropped frame shows up as a gap in seq
+	frames := c.drain()
+	if len(frames) != sendQueueSize || c.seq != sendQueueSize+1 {
+		t.Fatalf("drained %d frames up to seq %d", len(frames), c.seq)
+	}
+}
+
+func TestSlowClientPolicyDisconnect(t *testing.T) {
+	c := newQueueClient(PolicyDisconnect)
+	fill(t, c, sendQueueSize)
+
+	if c.enqueue(&Message{Type: "chat_message", ChatID: 2}) {
+		t.Fatal("disconnect policy kept the client")
+	}
+	select {
+	case <-c.done:
+	default:
+		t.Fatal("client was not closed")
+	}
+	if c.ctx.Err() == nil {
+		t.Fatal("in-flight requests were not cancelled")
+	}
+	if err := c.SendMessage(&Message{Type: "chat_message"}); err != nil {
+		t.Fatalf("sending to a closed client: %v", err)
+	}
+}
+
+func TestSlowClientPolicyCoalesce(t *testing.T) {
+	t.Run("replaces a frame of the same kind", func(t *testing.T) {
+		c := newQueueClient(PolicyCoalesce)
+		c.enqueue(&Message{Type: "typing_start", ChatID: 5})
+		fill(t, c, sendQueueSize-1)
+
+		if !c.enqueue(&Message{Type: "typing_stop", ChatID: 5}) {
+			t.Fatal("coalesce policy disconnected the client")
+		}
+		if c.queue[0].Type != "typing_stop" || len(c.queue) != sendQueueSize || c.skipped != 0 {
+			t.Fatalf("queue starts with %s, %d frames, skipped = %d", c.queue[0].Type, len(c.queue), c.skipped)
+		}
+	})
+
+	t.Run("drops a supersedable frame to make room", func(t *testing.T) {
+		c := newQueueClient(PolicyCoalesce)
+		c.enqueue(&Message{Type: "typing_start", ChatID: 5})
+		fill(t, c, sendQueueSize-1)
+
+		if !c.enqueue(&Message{Type: "chat_message", ChatID: 2}) {
+			t.Fatal("coalesce policy disconnected the client")
+		}
+		if c.queue[0].Type != "chat_message" || c.queue[sendQueueSize-1].ChatID != 2 || c.skipped != 1 {
+			t.Fatalf("queue starts with %s and ends with chat %d, skipped = %d",
+				c.queue[0].Type, c.queue[sendQueueSize-1].ChatID, c.skipped)
+		}
+	})
+
+	t.Run("falls back to dropping", func(t *testing.T) {
+		c := newQueueClient(PolicyCoalesce)
+		fill(t, c, sendQueueSize)
+
+		if !c.enqueue(&Message{Type: "chat_message", ChatID: 2}) {
+			t.Fatal("coalesce policy disconnected the client")
+		}
+		if c.queue[sendQueueSize-1].ChatID == 2 || c.skipped != 1 {
+			t.Fatalf("queue ends with chat %d, skipped = %d", c.queue[sendQueueSize-1].ChatID, c.skipped)
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// brokerSubscriberBuffer is how many envelopes a memory broker subscriber can fall behind by
+const brokerSubscriberBuffer = 256
+
+// Envelope carries a frame between hub nodes to the connections of UserID
+type Envelope struct {
+	NodeID         string   `json:"nodeId"`
+	UserID         int64    `json:"userId,omitempty"`
+	ExceptClientID string   `json:"exceptClientId,omitempty"`
+	Message        *Message `json:"message"`
+}
+
+// Broker fans frames out to every hub node, including the publishing one
+type Broker interface {
+	Publish(ctx context.Context, envelope *Envelope) error
+	// Subscribe returns a channel of envelopes published by any node.
+	// The channel is closed once ctx is done or the broker is closed.
+	Subscribe(ctx context.Context) (<-chan *Envelope, error)
+	Close() error
+}
+
+// NewBrokerFromEnv selects the broker implementation from WS_BROKER ("memory" or "redis")
+func NewBrokerFromEnv() (Broker, error) {
+	switch os.Getenv("WS_BROKER") {
+	case "redis":
+		url := os.Getenv("WS_REDIS_URL")
+		if url == "" {
+			url = "redis://localhost:6379/0"
+		}
+		channel := os.Getenv("WS_REDIS_CHANNEL")
+		if channel == "" {
+			channel = defaultRedisChannel
+		}
+		return NewRedisBroker(url, channel)
+	case "", "memory":
+		return NewMemoryBroker(), nil
+	default:
+		log.Printf("Unknown WS_BROKER %q, using in-process broker", os.Getenv("WS_BROKER"))
+		return NewMemoryBroker(), nil
+	}
+}
+
+// memoryBroker implements Broker for a single process. Chat messages wait for room in a full
+// subscriber until the publish context is done, so only the hub's slow client policy drops them;
+// other frames are dropped and counted.
+type memoryBroker struct {
+	mutex       sync.RWMutex
+	subscribers map[chan *Envelope]struct{}
+	closed      bool
+	dropped     atomic.Int64
+}
+
+// NewMemoryBroker creates an in-process Broker
+func NewMemoryBroker() Broker {
+	return &memoryBroker{
+		subscribers: make(map[chan *Envelope]struct{}),
+	}
+}
+
+func (b *memoryBroker) Publish(ctx context.Context, envelope *Envelope) error {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	var err error
+	for ch := range b.subscribers {
+		select {
+		case ch <- envelope:
+			continue
+		default:
+		}
+
+		if envelope.Message.Type == "chat_message" {
+			select {
+			case ch <- envelope:
+				continue
+			case <-ctx.Done():
+				err = fmt.Errorf("broker subscriber stayed full: %w", ctx.Err())
+			}
+		}
+		dropped := b.dropped.Add(1)
+		log.Printf("Dropping %s frame for slow broker subscriber (%d dropped so far)", envelope.Message.Type, dropped)
+	}
+	return err
+}
+
+func (b *memoryBroker) Subscribe(ctx context.Context) (<-chan *Envelope, error) {
+	ch := make(chan *Envelope, brokerSubscriberBuffer)
+
+	b.mutex.Lock()
+	if b.closed {
+		b.mutex.Unlock()
+		close(ch)
+		return ch, nil
+	}
+	b.subscribers[ch] = struct{}{}
+	b.mutex.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}()
+
+	return ch, nil
+}
+
+func (b *memoryBroker) Close() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.closed = true
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+	return nil
+}
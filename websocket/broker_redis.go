@@ -0,0 +1,88 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const defaultRedisChannel = "chat:ws"
+
+// redisBroker implements Broker on Redis pub/sub so sockets held by different nodes see the same frames
+type redisBroker struct {
+	client  *redis.Client
+	channel string
+}
+
+// NewRedisBroker creates a Broker backed by the Redis server at url (redis://host:port/db)
+func NewRedisBroker(url, channel string) (Broker, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis url: %w", err)
+	}
+
+	client := redis.NewClient(opts)
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+
+	return &redisBroker{
+		client:  client,
+		channel: channel,
+	}, nil
+}
+
+func (b *redisBroker) Publish(ctx context.Context, envelope *Envelope) error {
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return fmt.Errorf("failed to encode envelope: %w", err)
+	}
+	return b.client.Publish(ctx, b.channel, data).Err()
+}
+
+func (b *redisBroker) Subscribe(ctx context.Context) (<-chan *Envelope, error) {
+	pubsub := b.client.Subscribe(ctx, b.channel)
+	// Wait for the subscription to be confirmed so no frame published afterwards is missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("failed to subscribe to %s: %w", b.channel, err)
+	}
+
+	out := make(chan *Envelope, 256)
+	go func() {
+		defer close(out)
+		defer pubsub.Close()
+
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				var envelope Envelope
+				if err := json.Unmarshal([]byte(msg.Payload), &envelope); err != nil {
+					log.Printf("Dropping malformed broker frame: %v", err)
+					continue
+				}
+				select {
+				case out <- &envelope:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func (b *redisBroker) Close() error {
+	return b.client.Close()
+}
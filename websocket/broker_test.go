@@ -0,0 +1,466 @@
+package websocket
+
+import (
+	"blog-fanchiikawa-service/db"
+	"blog-fanchiikawa-service/service"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const testTimeout = 5 * time.Second
+
+// fakeSessions is a SessionService over an in-memory token table
+type fakeSessions struct {
+	mu      sync.Mutex
+	tokens  map[string]int64
+	revoked map[string]bool
+}
+
+func newFakeSessions() *fakeSessions {
+	return &fakeSessions{tokens: make(map[string]int64), revoked: make(map[string]bool)}
+}
+
+func (f *fakeSessions) CreateSession(userID int64) (*db.UserSession, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	token := fmt.Sprintf("token-%d-%d", userID, len(f.tokens))
+	f.tokens[token] = userID
+	return &db.UserSession{UserID: userID, Token: token, ExpiresAt: time.Now().Add(time.Hour)}, nil
+}
+
+func (f *fakeSessions) ValidateToken(token string) (*db.UserSession, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	userID, ok := f.tokens[token]
+	if !ok || f.revoked[token] {
+		return nil, service.ErrInvalidSession
+	}
+	return &db.UserSession{UserID: userID, Token: token, ExpiresAt: time.Now().Add(time.Hour)}, nil
+}
+
+func (f *fakeSessions) RevokeSession(token string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.revoked[token] = true
+	return nil
+}
+
+func (f *fakeSessions) token(t *testing.T, userID int64) string {
+	t.Helper()
+	session, err := f.CreateSession(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return session.Token
+}
+
+// startHub runs a hub on broker behind a test server; nodeID tells hubs of one test apart
+func startHub(t *testing.T, broker Broker, sessions service.SessionService, chats service.ChatService, nodeID string) (*Hub, *httptest.Server) {
+	t.Helper()
+	hub := NewHub(chats, sessions, service.NewEventPublisher(), broker)
+	hub.nodeID = nodeID
+	if err := hub.Start(); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
+	t.Cleanup(server.Close)
+	return hub, server
+}
+
+func dial(t *testing.T, server *httptest.Server, token string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL(server, token), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func wsURL(server *httptest.Server, token string) string {
+	return "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?token=" + token
+}
+
+func writeFrame(t *testing.T, conn *websocket.Conn, message *Message) {
+	t.Helper()
+	data, err := json.Marshal(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetWriteDeadline(time.Now().Add(testTimeout))
+	if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
+
+// readFrames returns the frames of one websocket message, which holds several under chat.v1
+func readFrames(conn *websocket.Conn, deadline time.Time) ([]*Message, error) {
+	conn.SetReadDeadline(deadline)
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	var frames []*Message
+	for _, line := range bytes.Split(data, newline) {
+		var message Message
+		if err := json.Unmarshal(line, &message); err != nil {
+			return nil, err
+		}
+		frames = append(frames, &message)
+	}
+	return frames, nil
+}
+
+// waitFrame reads until a frame of the given type and chat arrives
+func waitFrame(t *testing.T, conn *websocket.Conn, msgType string, chatID int64) *Message {
+	t.Helper()
+	deadline := time.Now().Add(testTimeout)
+	for {
+		frames, err := readFrames(conn, deadline)
+		if err != nil {
+			t.Fatalf("waiting for %s: %v", msgType, err)
+		}
+		for _, frame := range frames {
+			if frame.Type == msgType && frame.ChatID == chatID {
+				return frame
+			}
+		}
+	}
+}
+
+// expectNoFrame fails if a frame of the given type and chat arrives within wait
+func expectNoFrame(t *testing.T, conn *websocket.Conn, msgType string, chatID int64, wait time.Duration) {
+	t.Helper()
+	deadline := time.Now().Add(wait)
+	for {
+		frames, err := readFrames(conn, deadline)
+		if err != nil {
+			return
+		}
+		for _, frame := range frames {
+			if frame.Type == msgType && frame.ChatID == chatID {
+				t.Fatalf("unexpected %s frame: %+v", msgType, frame)
+			}
+		}
+	}
+}
+
+func TestBrokerFansOutAcrossHubs(t *testing.T) {
+	brokers := map[string]func(t *testing.T) (Broker, Broker){
+		"memory": func(t *testing.T) (Broker, Broker) {
+			broker := NewMemoryBroker()
+			t.Cleanup(func() { broker.Close() })
+			return broker, broker
+		},
+		"redis": func(t *testing.T) (Broker, Broker) {
+			url := "redis://" + startFakeRedis(t) + "/0"
+			// Each node holds its own connection, as separate processes would
+			a, err := NewRedisBroker(url, defaultRedisChannel)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := NewRedisBroker(url, defaultRedisChannel)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				a.Close()
+				b.Close()
+			})
+			return a, b
+		},
+	}
+
+	for name, newBrokers := range brokers {
+		t.Run(name, func(t *testing.T) {
+			brokerA, brokerB := newBrokers(t)
+			sessions := newFakeSessions()
+			_, serverA := startHub(t, brokerA, sessions, nil, "node-a")
+			_, serverB := startHub(t, brokerB, sessions, nil, "node-b")
+
+			sender := dial(t, serverA, sessions.token(t, 1))
+			receiver := dial(t, serverB, sessions.token(t, 1))
+			stranger := dial(t, serverB, sessions.token(t, 2))
+			// Each hub announces the user once their first connection registers, so both are subscribed by now
+			waitFrame(t, receiver, "presence", 0)
+			waitFrame(t, sender, "presence", 0)
+
+			writeFrame(t, sender, &Message{Type: "typing_start", ChatID: 7})
+
+			frame := waitFrame(t, receiver, "typing_start", 7)
+			payload, ok := frame.Data.(*TypingPayload)
+			if !ok || payload.UserID != 1 {
+				t.Fatalf("typing payload = %#v", frame.Data)
+			}
+			// The sending connection and other users see nothing
+			expectNoFrame(t, sender, "typing_start", 7, 200*time.Millisecond)
+			expectNoFrame(t, stranger, "typing_start", 7, 200*time.Millisecond)
+		})
+	}
+}
+
+func TestHubStopsWhenBrokerCloses(t *testing.T) {
+	broker := NewMemoryBroker()
+	sessions := newFakeSessions()
+	hub, server := startHub(t, broker, sessions, nil, "node-a")
+	conn := dial(t, server, sessions.token(t, 1))
+	waitFrame(t, conn, "presence", 0)
+
+	broker.Close()
+	select {
+	case <-hub.stopped:
+	case <-time.After(testTimeout):
+		t.Fatal("hub did not stop after the broker closed")
+	}
+
+	// The open connection is closed and its readPump returns instead of blocking on unregister
+	deadline := time.Now().Add(testTimeout)
+	for {
+		if _, err := readFrames(conn, deadline); err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				t.Fatal("connection stayed open after the hub stopped")
+			}
+			break
+		}
+	}
+
+	// New connections are closed straight away
+	late := dial(t, server, sessions.token(t, 1))
+	if _, err := readFrames(late, time.Now().Add(testTimeout)); err == nil {
+		t.Fatal("connection accepted after the hub stopped")
+	}
+}
+
+func TestMemoryBrokerFullSubscriber(t *testing.T) {
+	broker := NewMemoryBroker().(*memoryBroker)
+	t.Cleanup(func() { broker.Close() })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	frames, err := broker.Subscribe(ctx)
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	publish := func(ctx context.Context, msgType string, chatID int64) error {
+		return broker.Publish(ctx, &Envelope{UserID: 1, Message: &Message{Type: msgType, ChatID: chatID}})
+	}
+	for i := 0; i < brokerSubscriberBuffer; i++ {
+		if err := publish(context.Background(), "typing_start", int64(i)); err != nil {
+			t.Fatalf("publish %d: %v", i, err)
+		}
+	}
+
+	// Frames that a newer one supersedes are dropped and counted
+	if err := publish(context.Background(), "typing_stop", 0); err != nil {
+		t.Fatalf("publish typing_stop: %v", err)
+	}
+	if broker.dropped.Load() != 1 {
+		t.Fatalf("dropped = %d, want 1", broker.dropped.Load())
+	}
+
+	// Chat messages wait for room until the publish context is done
+	short, cancelShort := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancelShort()
+	if err := publish(short, "chat_message", 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want the publish to time out", err)
+	}
+	if broker.dropped.Load() != 2 {
+		t.Fatalf("dropped = %d, want 2", broker.dropped.Load())
+	}
+
+	published := make(chan error, 1)
+	go func() { published <- publish(context.Background(), "chat_message", 2) }()
+	select {
+	case err := <-published:
+		t.Fatalf("chat message was not held for a full subscriber: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	<-frames
+	select {
+	case err := <-published:
+		if err != nil {
+			t.Fatalf("publish chat_message: %v", err)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("chat message was not delivered once the subscriber had room")
+	}
+
+	var last *Envelope
+	for i := 0; i < brokerSubscriberBuffer; i++ {
+		last = <-frames
+	}
+	if last.Message.Type != "chat_message" || last.Message.ChatID != 2 || broker.dropped.Load() != 2 {
+		t.Fatalf("last frame is %s for chat %d, dropped = %d", last.Message.Type, last.Message.ChatID, broker.dropped.Load())
+	}
+}
+
+// startFakeRedis serves the subset of the Redis protocol the broker uses (PING, SUBSCRIBE, PUBLISH)
+// and returns its address. It stands in for a Redis server, which the test environment may not have.
+func startFakeRedis(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &fakeRedis{subscribers: make(map[string]map[*fakeRedisConn]bool)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(&fakeRedisConn{conn: conn, writer: bufio.NewWriter(conn)})
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+	return listener.Addr().String()
+}
+
+type fakeRedis struct {
+	mu          sync.Mutex
+	subscribers map[string]map[*fakeRedisConn]bool
+}
+
+type fakeRedisConn struct {
+	mu         sync.Mutex
+	conn       net.Conn
+	writer     *bufio.Writer
+	subscribed map[string]bool
+}
+
+// reply writes RESP values: strings as bulk strings, ints as integers, errors as errors and []interface{} as arrays
+func (c *fakeRedisConn) reply(values ...interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, value := range values {
+		writeRESP(c.writer, value)
+	}
+	c.writer.Flush()
+}
+
+func writeRESP(w *bufio.Writer, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(v), v)
+	case int:
+		fmt.Fprintf(w, ":%d\r\n", v)
+	case error:
+		fmt.Fprintf(w, "-%s\r\n", v.Error())
+	case []interface{}:
+		fmt.Fprintf(w, "*%d\r\n", len(v))
+		for _, item := range v {
+			writeRESP(w, item)
+		}
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("unexpected %q", line)
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(header[1:]))
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		args = append(args, string(data[:size]))
+	}
+	return args, nil
+}
+
+func (s *fakeRedis) serve(c *fakeRedisConn) {
+	defer func() {
+		s.mu.Lock()
+		for channel := range c.subscribed {
+			delete(s.subscribers[channel], c)
+		}
+		s.mu.Unlock()
+		c.conn.Close()
+	}()
+
+	reader := bufio.NewReader(c.conn)
+	c.subscribed = make(map[string]bool)
+	for {
+		args, err := readCommand(reader)
+		if err != nil || len(args) == 0 {
+			return
+		}
+		switch strings.ToUpper(args[0]) {
+		case "PING":
+			if len(c.subscribed) > 0 {
+				c.reply([]interface{}{"pong", ""})
+			} else {
+				c.reply("PONG")
+			}
+		case "SUBSCRIBE":
+			for _, channel := range args[1:] {
+				s.mu.Lock()
+				if s.subscribers[channel] == nil {
+					s.subscribers[channel] = make(map[*fakeRedisConn]bool)
+				}
+				s.subscribers[channel][c] = true
+				c.subscribed[channel] = true
+				count := len(c.subscribed)
+				s.mu.Unlock()
+				c.reply([]interface{}{"subscribe", channel, count})
+			}
+		case "UNSUBSCRIBE":
+			for _, channel := range args[1:] {
+				s.mu.Lock()
+				delete(s.subscribers[channel], c)
+				delete(c.subscribed, channel)
+				count := len(c.subscribed)
+				s.mu.Unlock()
+				c.reply([]interface{}{"unsubscribe", channel, count})
+			}
+		case "PUBLISH":
+			s.mu.Lock()
+			receivers := make([]*fakeRedisConn, 0, len(s.subscribers[args[1]]))
+			for receiver := range s.subscribers[args[1]] {
+				receivers = append(receivers, receiver)
+			}
+			s.mu.Unlock()
+			for _, receiver := range receivers {
+				receiver.reply([]interface{}{"message", args[1], args[2]})
+			}
+			c.reply(len(receivers))
+		case "CLIENT", "SELECT":
+			c.reply(fmt.Errorf("ERR unsupported"))
+		default:
+			// HELLO fails too, so the client falls back to RESP2
+			c.reply(fmt.Errorf("ERR unknown command '%s'", args[0]))
+		}
+	}
+}
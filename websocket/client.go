@@ -4,18 +4,24 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
+	"sort"
+	"sync"
 	"time"
 
+	"blog-fanchiikawa-service/db"
 	"blog-fanchiikawa-service/service"
 	"github.com/gorilla/websocket"
 )
 
 const (
-	writeWait      = 10 * time.Second
-	pongWait       = 60 * time.Second
-	pingPeriod     = (pongWait * 9) / 10
-	maxMessageSize = 512
+	writeWait          = 10 * time.Second
+	pongWait           = 60 * time.Second
+	pingPeriod         = (pongWait * 9) / 10
+	authWait           = 10 * time.Second
+	sessionCheckPeriod = 30 * time.Second
+	maxInflight        = 8
 )
 
 var (
@@ -24,29 +30,121 @@ var (
 )
 
 type Client struct {
-	ID   string
-	hub  *Hub
-	conn *websocket.Conn
-	send chan []byte
+	ID       string
+	hub      *Hub
+	conn     *websocket.Conn
+	protocol string
+
+	// chunks holds partially received chunked frames, keyed by chunk ID
+	chunks map[string]*chunkBuffer
+
+	// ctx is cancelled when the connection goes away, aborting in-flight requests
+	ctx       context.Context
+	cancel    context.CancelFunc
+	done      chan struct{}
+	closeOnce sync.Once
+	notify    chan struct{}
+	inflight  chan struct{}
+
+	// sends holds the send_message requests waiting per chat; a chat has an entry
+	// while its worker runs, so each chat's requests are handled one at a time in order
+	sendMu sync.Mutex
+	sends  map[int64][]Message
+
+	mu     sync.RWMutex
+	userID int64
+	token  string
+
+	// Outbound frames wait in queue until writePump drains them; seq and
+	// skipped number them so dropped frames show up as gaps
+	queue   []*Message
+	seq     uint64
+	skipped uint64
+
+	// While replaying, live chat messages are parked in pending
+	replaying bool
+	pending   []*Message
 }
 
 type Message struct {
-	Type      string      `json:"type"`
-	ChatID    int64       `json:"chatId,omitempty"`
-	Content   string      `json:"content,omitempty"`
-	MessageID string      `json:"messageId,omitempty"`
-	Data      interface{} `json:"data,omitempty"`
-	Error     string      `json:"error,omitempty"`
+	Type          string          `json:"type"`
+	ChatID        int64           `json:"chatId,omitempty"`
+	Content       string          `json:"content,omitempty"`
+	MessageID     string          `json:"messageId,omitempty"`
+	Token         string          `json:"token,omitempty"`
+	ReadMessageID int64           `json:"readMessageId,omitempty"`
+	LastSeen      map[int64]int64 `json:"lastSeen,omitempty"`
+	Seq           uint64          `json:"seq,omitempty"`
+	Data          interface{}     `json:"data,omitempty"`
+	Error         string          `json:"error,omitempty"`
+	Chunk         *Chunk          `json:"chunk,omitempty"`
+}
+
+func newClient(hub *Hub, conn *websocket.Conn) *Client {
+	protocol := conn.Subprotocol()
+	if protocol == "" {
+		protocol = ProtocolV1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Client{
+		ID:       generateClientID(),
+		hub:      hub,
+		conn:     conn,
+		protocol: protocol,
+		chunks:   make(map[string]*chunkBuffer),
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+		notify:   make(chan struct{}, 1),
+		inflight: make(chan struct{}, maxInflight),
+		sends:    make(map[int64][]Message),
+	}
+}
+
+// authenticate binds the session's user identity to the client
+func (c *Client) authenticate(session *db.UserSession) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.userID = session.UserID
+	c.token = session.Token
+}
+
+// UserID returns the authenticated user, or 0 before authentication
+func (c *Client) UserID() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.userID
+}
+
+func (c *Client) sessionToken() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.token
+}
+
+// close stops the client's pumps and cancels in-flight requests. It is safe to
+// call from any goroutine and more than once; map cleanup is left to the hub.
+func (c *Client) close() {
+	c.closeOnce.Do(func() {
+		c.cancel()
+		close(c.done)
+	})
 }
 
 func (c *Client) readPump() {
 	defer func() {
-		c.hub.unregister <- c
+		c.close()
+		c.hub.send(c.hub.unregister, c)
 		c.conn.Close()
 	}()
 
-	c.conn.SetReadLimit(maxMessageSize)
-	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetReadLimit(c.hub.limits.MaxFrameSize)
+	if c.UserID() == 0 {
+		c.conn.SetReadDeadline(time.Now().Add(authWait))
+	} else {
+		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	}
 	c.conn.SetPongHandler(func(string) error {
 		c.conn.SetReadDeadline(time.Now().Add(pongWait))
 		return nil
@@ -69,74 +167,246 @@ func (c *Client) readPump() {
 			continue
 		}
 
-		log.Printf("Received message: %+v", msg)
-		
-		// Handle different message types
-		switch msg.Type {
-		case "send_message":
-			c.handleSendMessage(msg)
-		case "ping":
-			c.handlePing(msg)
-		default:
-			log.Printf("Unknown message type: %s", msg.Type)
+		// Frames are not logged whole, as auth frames carry the session token
+		log.Printf("Received %s frame %s from client %s", msg.Type, msg.MessageID, c.ID)
+
+		// Unauthenticated clients may only send an auth frame
+		if c.UserID() == 0 {
+			if !c.handleAuth(msg) {
+				break
+			}
+			continue
+		}
+
+		if msg.Type == "chunk" {
+			full, err := c.assembleChunk(msg)
+			if err != nil {
+				c.sendErrorResponse(msg.MessageID, err.Error())
+				continue
+			}
+			if full == nil {
+				continue // waiting for more chunks
+			}
+			msg = *full
+		}
+
+		if msg.Type == "send_message" {
+			// Sends wait on Lex, so they run beside the read loop, in order per chat;
+			// reading pauses once maxInflight sends are outstanding
+			select {
+			case c.inflight <- struct{}{}:
+			case <-c.done:
+				return
+			}
+			c.queueSend(msg)
+			continue
 		}
+		c.handle(msg)
+	}
+}
+
+// queueSend appends a send_message request to its chat's queue and starts the chat's worker if it is idle
+func (c *Client) queueSend(msg Message) {
+	c.sendMu.Lock()
+	queued, running := c.sends[msg.ChatID]
+	c.sends[msg.ChatID] = append(queued, msg)
+	c.sendMu.Unlock()
+
+	if !running {
+		go c.runSends(msg.ChatID)
+	}
+}
+
+// runSends handles a chat's queued send_message requests one at a time until the queue is empty
+func (c *Client) runSends(chatID int64) {
+	for {
+		c.sendMu.Lock()
+		queued := c.sends[chatID]
+		if len(queued) == 0 {
+			delete(c.sends, chatID)
+			c.sendMu.Unlock()
+			return
+		}
+		msg := queued[0]
+		c.sends[chatID] = queued[1:]
+		c.sendMu.Unlock()
+
+		c.handleSendMessage(msg)
+		<-c.inflight
+	}
+}
+
+// handle runs every request except send_message inline, so they keep the order they were read in
+func (c *Client) handle(msg Message) {
+	// Handle different message types
+	switch msg.Type {
+	case "ping":
+		c.handlePing(msg)
+	case "typing_start", "typing_stop":
+		c.handleTyping(msg)
+	case "message_read":
+		c.handleMessageRead(msg)
+	case "resume":
+		c.handleResume(msg)
+	default:
+		log.Printf("Unknown message type: %s", msg.Type)
 	}
 }
 
 func (c *Client) writePump() {
 	ticker := time.NewTicker(pingPeriod)
+	sessionTicker := time.NewTicker(sessionCheckPeriod)
 	defer func() {
 		ticker.Stop()
+		sessionTicker.Stop()
+		c.close()
 		c.conn.Close()
 	}()
 
 	for {
 		select {
-		case message, ok := <-c.send:
+		case <-c.done:
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if !ok {
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
-				return
+			c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+			return
+
+		case <-c.notify:
+			frames := c.drain()
+			if len(frames) == 0 {
+				continue
 			}
 
-			w, err := c.conn.NextWriter(websocket.TextMessage)
-			if err != nil {
+			if err := c.write(frames); err != nil {
 				return
 			}
-			w.Write(message)
 
-			n := len(c.send)
-			for i := 0; i < n; i++ {
-				w.Write(newline)
-				w.Write(<-c.send)
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
 			}
 
-			if err := w.Close(); err != nil {
+		case <-sessionTicker.C:
+			// Close connections whose token has expired or been revoked
+			token := c.sessionToken()
+			if token == "" {
+				continue
+			}
+			if _, err := c.hub.sessionService.ValidateToken(token); err != nil {
+				log.Printf("Closing client %s: %v", c.ID, err)
+				c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+				c.conn.WriteMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "session expired"))
 				return
 			}
+		}
+	}
+}
 
-		case <-ticker.C:
+// write sends encoded frames in the connection's subprotocol
+func (c *Client) write(frames [][]byte) error {
+	if c.protocol == ProtocolV1 {
+		c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+		w, err := c.conn.NextWriter(websocket.TextMessage)
+		if err != nil {
+			return err
+		}
+		for i, frame := range frames {
+			if i > 0 {
+				w.Write(newline)
+			}
+			w.Write(frame)
+		}
+		return w.Close()
+	}
+
+	for _, frame := range frames {
+		parts := [][]byte{frame}
+		if int64(len(frame)) > c.hub.limits.MaxFrameSize {
+			// Leave room for the escaping the encoded frame picks up inside the chunk's data string
+			parts = splitFrame(randomString(8), frame, int(c.hub.limits.MaxFrameSize/2))
+		}
+		for _, part := range parts {
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-				return
+			if err := c.conn.WriteMessage(websocket.TextMessage, part); err != nil {
+				return err
 			}
 		}
 	}
+	return nil
 }
 
-func (c *Client) SendMessage(message *Message) error {
-	data, err := json.Marshal(message)
-	if err != nil {
-		return err
+// drain takes every queued frame and encodes it with the next sequence number.
+// Frames are copied before stamping, so frames shared between clients are never mutated.
+func (c *Client) drain() [][]byte {
+	c.mu.Lock()
+	queue := c.queue
+	c.queue = nil
+	frames := make([]Message, len(queue))
+	for i, message := range queue {
+		c.seq += 1 + c.skipped
+		c.skipped = 0
+		frames[i] = *message
+		frames[i].Seq = c.seq
+	}
+	c.mu.Unlock()
+
+	encoded := make([][]byte, 0, len(frames))
+	for i := range frames {
+		data, err := json.Marshal(&frames[i])
+		if err != nil {
+			log.Printf("Failed to encode %s frame: %v", frames[i].Type, err)
+			continue
+		}
+		encoded = append(encoded, data)
+	}
+	return encoded
+}
+
+// enqueue queues a frame for writePump. It reports false when the slow client
+// policy decided to disconnect, in which case the client is already closing.
+func (c *Client) enqueue(message *Message) bool {
+	return c.enqueueFrame(message, false)
+}
+
+// enqueueLive queues a live chat message, parking it while a replay is in progress
+func (c *Client) enqueueLive(message *Message) bool {
+	return c.enqueueFrame(message, true)
+}
+
+func (c *Client) enqueueFrame(message *Message, live bool) bool {
+	select {
+	case <-c.done:
+		return true // closing; nothing to deliver to
+	default:
+	}
+
+	c.mu.Lock()
+	if live && c.replaying {
+		c.pending = append(c.pending, message)
+		c.mu.Unlock()
+		return true
+	}
+	ok := c.pushLocked(message)
+	c.mu.Unlock()
+
+	if !ok {
+		log.Printf("Disconnecting slow client %s", c.ID)
+		c.close()
+		return false
 	}
 
 	select {
-	case c.send <- data:
+	case c.notify <- struct{}{}:
 	default:
-		close(c.send)
-		delete(c.hub.clients, c)
 	}
+	return true
+}
 
+func (c *Client) SendMessage(message *Message) error {
+	if !c.enqueue(message) {
+		return fmt.Errorf("client %s disconnected: send queue full", c.ID)
+	}
 	return nil
 }
 
@@ -168,11 +438,12 @@ func (c *Client) handleSendMessage(msg Message) {
 		return
 	}
 
-	// Call chat service to send message
-	ctx := context.Background()
+	// Call chat service to send message; the context is cancelled on disconnect
+	ctx := c.ctx
 	req := &service.SendMessageRequest{
 		ChatID:  chatId,
 		Message: content,
+		UserID:  c.UserID(),
 	}
 
 	response, err := c.hub.chatService.SendMessage(ctx, req)
@@ -193,6 +464,155 @@ func (c *Client) handleSendMessage(msg Message) {
 	}
 }
 
+// handleAuth validates the token of an auth frame and reports whether the client may continue
+func (c *Client) handleAuth(msg Message) bool {
+	if msg.Type != "auth" {
+		c.sendErrorResponse(msg.MessageID, "Authentication required")
+		return false
+	}
+
+	session, err := c.hub.sessionService.ValidateToken(msg.Token)
+	if err != nil {
+		c.sendErrorResponse(msg.MessageID, "Invalid session token")
+		return false
+	}
+
+	c.authenticate(session)
+	if !c.hub.send(c.hub.authenticated, c) {
+		return false
+	}
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+
+	authMsg := &Message{
+		Type:      "auth_ok",
+		MessageID: msg.MessageID,
+		Data:      &AuthOKPayload{UserID: session.UserID},
+	}
+	if err := c.SendMessage(authMsg); err != nil {
+		log.Printf("Failed to send auth response: %v", err)
+	}
+	return true
+}
+
+// handleTyping relays the user's typing indicator to their other connections
+func (c *Client) handleTyping(msg Message) {
+	if msg.ChatID == 0 {
+		c.sendErrorResponse(msg.MessageID, "Chat ID is required")
+		return
+	}
+
+	c.sendToUser(&Message{
+		Type:   msg.Type,
+		ChatID: msg.ChatID,
+		Data:   &TypingPayload{UserID: c.UserID()},
+	}, c)
+}
+
+// handleMessageRead persists the read marker and syncs it to all of the user's connections
+func (c *Client) handleMessageRead(msg Message) {
+	if msg.ChatID == 0 || msg.ReadMessageID == 0 {
+		c.sendErrorResponse(msg.MessageID, "Chat ID and read message ID are required")
+		return
+	}
+
+	chat, err := c.hub.chatService.MarkRead(c.UserID(), msg.ChatID, msg.ReadMessageID)
+	if err != nil {
+		c.sendErrorResponse(msg.MessageID, err.Error())
+		return
+	}
+
+	c.sendToUser(&Message{
+		Type:          "message_read",
+		ChatID:        msg.ChatID,
+		MessageID:     msg.MessageID,
+		ReadMessageID: msg.ReadMessageID,
+		Data:          chat,
+	}, nil)
+}
+
+// sendToUser publishes a frame to every connection of this client's user on any node
+func (c *Client) sendToUser(message *Message, except *Client) {
+	envelope := &Envelope{
+		UserID:  c.UserID(),
+		Message: message,
+	}
+	if except != nil {
+		envelope.ExceptClientID = except.ID
+	}
+	c.hub.publish(envelope)
+}
+
+// handleResume replays chat messages newer than the client's last seen IDs,
+// then releases live messages that arrived during the replay
+func (c *Client) handleResume(msg Message) {
+	c.mu.Lock()
+	c.replaying = true
+	c.pending = nil
+	c.mu.Unlock()
+
+	chatIDs := make([]int64, 0, len(msg.LastSeen))
+	for chatID := range msg.LastSeen {
+		chatIDs = append(chatIDs, chatID)
+	}
+	sort.Slice(chatIDs, func(i, j int) bool { return chatIDs[i] < chatIDs[j] })
+
+	replayed := make(map[int64]int64)
+	var replayErr error
+	for _, chatID := range chatIDs {
+		lastSeen := msg.LastSeen[chatID]
+		replayed[chatID] = lastSeen
+
+		messages, err := c.hub.chatService.GetMessagesAfter(c.UserID(), chatID, lastSeen)
+		if err != nil {
+			replayErr = err
+			break
+		}
+		for _, m := range messages {
+			c.SendMessage(chatMessageFrame(m))
+			replayed[chatID] = m.ID
+		}
+	}
+
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = nil
+	c.replaying = false
+	c.mu.Unlock()
+
+	for _, m := range pending {
+		if last, ok := replayed[m.ChatID]; ok && chatMessageID(m) <= last {
+			continue // already replayed
+		}
+		c.SendMessage(m)
+	}
+
+	if replayErr != nil {
+		c.sendErrorResponse(msg.MessageID, replayErr.Error())
+		return
+	}
+
+	c.SendMessage(&Message{
+		Type:      "resume_ok",
+		MessageID: msg.MessageID,
+		LastSeen:  replayed,
+	})
+}
+
+func chatMessageFrame(m *service.MessageResponse) *Message {
+	return &Message{
+		Type:   "chat_message",
+		ChatID: m.ChatID,
+		Data:   m,
+	}
+}
+
+func chatMessageID(m *Message) int64 {
+	if data, ok := m.Data.(*service.MessageResponse); ok {
+		return data.ID
+	}
+	return 0
+}
+
 func (c *Client) handlePing(msg Message) {
 	pongMsg := &Message{
 		Type:      "pong",
@@ -0,0 +1,126 @@
+package websocket
+
+import (
+	"blog-fanchiikawa-service/service"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingChats is a ChatService whose SendMessage waits until its context is cancelled
+type blockingChats struct {
+	service.ChatService
+	started   chan struct{}
+	cancelled chan struct{}
+}
+
+func (b *blockingChats) SendMessage(ctx context.Context, req *service.SendMessageRequest) (*service.MessageResponse, error) {
+	close(b.started)
+	<-ctx.Done()
+	close(b.cancelled)
+	return nil, ctx.Err()
+}
+
+func TestDisconnectCancelsInflightRequests(t *testing.T) {
+	broker := NewMemoryBroker()
+	t.Cleanup(func() { broker.Close() })
+	sessions := newFakeSessions()
+	chats := &blockingChats{started: make(chan struct{}), cancelled: make(chan struct{})}
+	_, server := startHub(t, broker, sessions, chats, "node-a")
+
+	conn := dial(t, server, sessions.token(t, 1))
+	writeFrame(t, conn, &Message{Type: "send_message", ChatID: 1, Content: "hello", MessageID: "m1"})
+	select {
+	case <-chats.started:
+	case <-time.After(testTimeout):
+		t.Fatal("send_message never reached the chat service")
+	}
+
+	conn.Close()
+	select {
+	case <-chats.cancelled:
+	case <-time.After(testTimeout):
+		t.Fatal("in-flight send_message was not cancelled when the client disconnected")
+	}
+}
+
+// recordingChats is a ChatService that records the order in which messages reach SendMessage.
+// Earlier messages take longer, so requests handled concurrently would finish out of order.
+type recordingChats struct {
+	service.ChatService
+	mu       sync.Mutex
+	received map[int64][]string
+}
+
+func (r *recordingChats) SendMessage(ctx context.Context, req *service.SendMessageRequest) (*service.MessageResponse, error) {
+	index, _ := strconv.Atoi(req.Message)
+	time.Sleep(time.Duration(burstSize-index) * time.Millisecond)
+
+	r.mu.Lock()
+	r.received[req.ChatID] = append(r.received[req.ChatID], req.Message)
+	r.mu.Unlock()
+	return &service.MessageResponse{ChatID: req.ChatID, Content: req.Message}, nil
+}
+
+const burstSize = 10
+
+func TestSendBurstKeepsOrderPerChat(t *testing.T) {
+	broker := NewMemoryBroker()
+	t.Cleanup(func() { broker.Close() })
+	sessions := newFakeSessions()
+	chats := &recordingChats{received: make(map[int64][]string)}
+	_, server := startHub(t, broker, sessions, chats, "node-a")
+
+	conn := dial(t, server, sessions.token(t, 1))
+	for i := 0; i < burstSize; i++ {
+		for _, chatID := range []int64{1, 2} {
+			writeFrame(t, conn, &Message{
+				Type:      "send_message",
+				ChatID:    chatID,
+				Content:   strconv.Itoa(i),
+				MessageID: fmt.Sprintf("%d-%d", chatID, i),
+			})
+		}
+	}
+
+	replies := make(map[string][]string)
+	deadline := time.Now().Add(testTimeout)
+	for count := 0; count < 2*burstSize; {
+		frames, err := readFrames(conn, deadline)
+		if err != nil {
+			t.Fatalf("got %d of %d replies: %v", count, 2*burstSize, err)
+		}
+		for _, frame := range frames {
+			if frame.Type == "error" {
+				t.Fatalf("send %s failed: %s", frame.MessageID, frame.Error)
+			}
+			if frame.Type != "message_response" {
+				continue
+			}
+			chat, index, _ := strings.Cut(frame.MessageID, "-")
+			replies[chat] = append(replies[chat], index)
+			count++
+		}
+	}
+
+	want := make([]string, burstSize)
+	for i := range want {
+		want[i] = strconv.Itoa(i)
+	}
+	chats.mu.Lock()
+	defer chats.mu.Unlock()
+	for _, chatID := range []int64{1, 2} {
+		key := strconv.FormatInt(chatID, 10)
+		if !slices.Equal(chats.received[chatID], want) {
+			t.Errorf("chat %d: sends reached the chat service as %v", chatID, chats.received[chatID])
+		}
+		if !slices.Equal(replies[key], want) {
+			t.Errorf("chat %d: replies arrived as %v", chatID, replies[key])
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package websocket
+
+import (
+	"blog-fanchiikawa-service/service"
+	"encoding/json"
+)
+
+// Payloads carried in Message.Data, one type per frame kind.
+// The JSON Schema served at /ws/schema describes the same shapes.
+
+// AuthOKPayload is the data of an auth_ok frame
+type AuthOKPayload struct {
+	UserID int64 `json:"userId"`
+}
+
+// TypingPayload is the data of typing_start and typing_stop frames
+type TypingPayload struct {
+	UserID int64 `json:"userId"`
+	IsBot  bool  `json:"isBot"`
+}
+
+// PresencePayload is the data of a presence frame
+type PresencePayload struct {
+	UserID int64  `json:"userId"`
+	Status string `json:"status"`
+}
+
+// newPayload returns a pointer to the typed payload for a frame kind,
+// or nil for kinds that carry no data
+func newPayload(msgType string) interface{} {
+	switch msgType {
+	case "auth_ok":
+		return &AuthOKPayload{}
+	case "typing_start", "typing_stop":
+		return &TypingPayload{}
+	case "presence":
+		return &PresencePayload{}
+	case "chat_message", "message_response":
+		return &service.MessageResponse{}
+	case "message_read":
+		return &service.ChatResponse{}
+	}
+	return nil
+}
+
+// UnmarshalJSON decodes Data into the payload type of the frame's kind,
+// so frames relayed through an external broker keep their types
+func (m *Message) UnmarshalJSON(data []byte) error {
+	type message Message
+	aux := struct {
+		*message
+		Data json.RawMessage `json:"data,omitempty"`
+	}{message: (*message)(m)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	m.Data = nil
+	if len(aux.Data) == 0 || string(aux.Data) == "null" {
+		return nil
+	}
+	payload := newPayload(m.Type)
+	if payload == nil {
+		return nil
+	}
+	if err := json.Unmarshal(aux.Data, payload); err != nil {
+		return err
+	}
+	m.Data = payload
+	return nil
+}
@@ -1,79 +1,302 @@
 package websocket
 
 import (
+	"blog-fanchiikawa-service/db"
 	"blog-fanchiikawa-service/service"
+	"context"
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"os"
+	"strings"
 
 	"github.com/gorilla/websocket"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
-}
+const (
+	presenceOnline  = "online"
+	presenceOffline = "offline"
+)
 
 type Hub struct {
-	clients     map[*Client]bool
-	broadcast   chan []byte
-	register    chan *Client
-	unregister  chan *Client
-	chatService service.ChatService
+	clients       map[*Client]bool
+	userClients   map[int64]map[*Client]bool
+	broadcast     chan *Message
+	register      chan *Client
+	authenticated chan *Client
+	unregister    chan *Client
+	// stopped is closed once the hub loop has returned, so clients never block handing themselves to it
+	stopped        chan struct{}
+	chatService    service.ChatService
+	sessionService service.SessionService
+	publisher      service.EventPublisher
+	broker         Broker
+	nodeID         string
+	upgrader       websocket.Upgrader
+
+	// slowClientPolicy decides what happens when a client's outbound queue is full
+	slowClientPolicy SlowClientPolicy
+	limits           Limits
 }
 
-func NewHub(chatService service.ChatService) *Hub {
+func NewHub(chatService service.ChatService, sessionService service.SessionService, publisher service.EventPublisher, broker Broker) *Hub {
+	hostname, _ := os.Hostname()
 	return &Hub{
-		clients:     make(map[*Client]bool),
-		broadcast:   make(chan []byte),
-		register:    make(chan *Client),
-		unregister:  make(chan *Client),
-		chatService: chatService,
+		clients:        make(map[*Client]bool),
+		userClients:    make(map[int64]map[*Client]bool),
+		broadcast:      make(chan *Message),
+		register:       make(chan *Client),
+		authenticated:  make(chan *Client),
+		unregister:     make(chan *Client),
+		stopped:        make(chan struct{}),
+		chatService:    chatService,
+		sessionService: sessionService,
+		publisher:      publisher,
+		broker:         broker,
+		nodeID:         fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		upgrader: websocket.Upgrader{
+			CheckOrigin:       NewOriginChecker(os.Getenv("WS_ALLOWED_ORIGINS")),
+			Subprotocols:      supportedProtocols,
+			EnableCompression: compressionFromEnv(),
+		},
+		slowClientPolicy: ParseSlowClientPolicy(os.Getenv("WS_SLOW_CLIENT_POLICY")),
+		limits:           LimitsFromEnv(),
+	}
+}
+
+// NewOriginChecker builds a CheckOrigin function from a comma-separated allowlist.
+// "*" allows every origin; an empty list only allows same-origin requests.
+func NewOriginChecker(allowlist string) func(r *http.Request) bool {
+	allowed := make(map[string]bool)
+	for _, origin := range strings.Split(allowlist, ",") {
+		origin = strings.TrimSpace(origin)
+		if origin != "" {
+			allowed[strings.ToLower(origin)] = true
+		}
+	}
+
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" || allowed["*"] {
+			return true
+		}
+		if allowed[strings.ToLower(origin)] {
+			return true
+		}
+		if len(allowed) == 0 {
+			u, err := url.Parse(origin)
+			if err == nil && strings.EqualFold(u.Host, r.Host) {
+				return true
+			}
+		}
+		log.Printf("Rejected WebSocket origin: %s", origin)
+		return false
 	}
 }
 
-func (h *Hub) Run() {
+// Start subscribes to the broker and runs the hub in the background until the broker is closed
+func (h *Hub) Start() error {
+	// Every frame, including those published by this node, arrives through the broker
+	frames, err := h.broker.Subscribe(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to WebSocket broker: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go h.forwardEvents(ctx)
+	go func() {
+		defer cancel()
+		h.run(frames)
+	}()
+	return nil
+}
+
+func (h *Hub) run(frames <-chan *Envelope) {
+	defer close(h.stopped)
+
 	for {
 		select {
 		case client := <-h.register:
 			h.clients[client] = true
-			log.Printf("Client connected: %s", client.ID)
+			log.Printf("Client connected: %s (%s)", client.ID, client.protocol)
+			if client.UserID() != 0 {
+				h.addUserClient(client)
+			}
+
+		case client := <-h.authenticated:
+			if _, ok := h.clients[client]; ok {
+				h.addUserClient(client)
+			}
 
 		case client := <-h.unregister:
 			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
+				h.removeClient(client)
 				log.Printf("Client disconnected: %s", client.ID)
 			}
 
 		case message := <-h.broadcast:
 			for client := range h.clients {
-				select {
-				case client.send <- message:
-				default:
-					close(client.send)
-					delete(h.clients, client)
+				if !client.enqueue(message) {
+					h.removeClient(client)
 				}
 			}
+
+		case envelope, ok := <-frames:
+			if !ok {
+				log.Println("WebSocket broker subscription closed")
+				h.closeAll()
+				return
+			}
+			h.deliver(envelope)
+		}
+	}
+}
+
+// send hands a client to the hub loop and reports false once the hub has stopped
+func (h *Hub) send(ch chan<- *Client, client *Client) bool {
+	select {
+	case ch <- client:
+		return true
+	case <-h.stopped:
+		return false
+	}
+}
+
+// closeAll disconnects every client when the hub stops, without announcing presence on the closed broker
+func (h *Hub) closeAll() {
+	for client := range h.clients {
+		client.close()
+	}
+	h.clients = make(map[*Client]bool)
+	h.userClients = make(map[int64]map[*Client]bool)
+}
+
+// forwardEvents publishes service events produced on this node to every node until ctx is done
+func (h *Hub) forwardEvents(ctx context.Context) {
+	events := h.publisher.Subscribe(ctx, func(e *service.Event) bool {
+		return e.Type == service.EventTyping || e.Type == service.EventMessageAdded
+	})
+
+	for e := range events {
+		switch e.Type {
+		case service.EventTyping:
+			h.publish(&Envelope{UserID: e.UserID, Message: typingMessage(e.Typing)})
+		case service.EventMessageAdded:
+			h.publish(&Envelope{UserID: e.UserID, Message: chatMessageFrame(e.Message)})
+		}
+	}
+}
+
+// publish hands an envelope to the broker for delivery on every node
+func (h *Hub) publish(envelope *Envelope) {
+	envelope.NodeID = h.nodeID
+
+	ctx, cancel := context.WithTimeout(context.Background(), writeWait)
+	defer cancel()
+	if err := h.broker.Publish(ctx, envelope); err != nil {
+		log.Printf("Failed to publish %s frame: %v", envelope.Message.Type, err)
+	}
+}
+
+// deliver writes an envelope received from the broker to the addressed user's local connections
+func (h *Hub) deliver(envelope *Envelope) {
+	for client := range h.userClients[envelope.UserID] {
+		if envelope.NodeID == h.nodeID && client.ID == envelope.ExceptClientID {
+			continue
+		}
+		ok := false
+		if envelope.Message.Type == "chat_message" {
+			ok = client.enqueueLive(envelope.Message)
+		} else {
+			ok = client.enqueue(envelope.Message)
+		}
+		if !ok {
+			h.removeClient(client)
 		}
 	}
 }
 
+// addUserClient indexes an authenticated client by user and announces presence
+func (h *Hub) addUserClient(client *Client) {
+	userID := client.UserID()
+	if h.userClients[userID] == nil {
+		h.userClients[userID] = make(map[*Client]bool)
+	}
+	h.userClients[userID][client] = true
+	if len(h.userClients[userID]) == 1 {
+		h.broadcastPresence(userID, presenceOnline)
+	}
+}
+
+// removeClient drops a client from all indexes and stops its pumps
+func (h *Hub) removeClient(client *Client) {
+	delete(h.clients, client)
+	client.close()
+
+	userID := client.UserID()
+	if userClients, ok := h.userClients[userID]; ok && userClients[client] {
+		delete(userClients, client)
+		if len(userClients) == 0 {
+			delete(h.userClients, userID)
+			h.broadcastPresence(userID, presenceOffline)
+		}
+	}
+}
+
+// broadcastPresence announces a user's presence change on this node to the user's connections on every node.
+// Chats are between a user and a bot, so no other user is told who is online.
+func (h *Hub) broadcastPresence(userID int64, status string) {
+	message := &Message{
+		Type: "presence",
+		Data: &PresencePayload{UserID: userID, Status: status},
+	}
+
+	// Publish outside the hub goroutine, which is also the broker's consumer
+	go h.publish(&Envelope{UserID: userID, Message: message})
+}
+
+func typingMessage(t *service.TypingEvent) *Message {
+	msgType := "typing_stop"
+	if t.Active {
+		msgType = "typing_start"
+	}
+	return &Message{
+		Type:   msgType,
+		ChatID: t.ChatID,
+		Data:   &TypingPayload{UserID: t.UserID, IsBot: t.IsBot},
+	}
+}
+
 func (h *Hub) ServeWS(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+	// A token in the query string authenticates during the upgrade,
+	// otherwise the client must send an "auth" frame first
+	var session *db.UserSession
+	if token := r.URL.Query().Get("token"); token != "" {
+		var err error
+		session, err = h.sessionService.ValidateToken(token)
+		if err != nil {
+			http.Error(w, "invalid session token", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println("WebSocket upgrade error:", err)
 		return
 	}
 
-	client := &Client{
-		ID:   generateClientID(),
-		hub:  h,
-		conn: conn,
-		send: make(chan []byte, 256),
+	client := newClient(h, conn)
+	if session != nil {
+		client.authenticate(session)
 	}
 
-	client.hub.register <- client
+	if !h.send(h.register, client) {
+		client.close()
+		conn.Close()
+		return
+	}
 
 	go client.writePump()
 	go client.readPump()
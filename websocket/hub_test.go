@@ -0,0 +1,99 @@
+package websocket
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// TestHubConcurrentConnectionsAndDelivery connects and disconnects clients while frames are broadcast
+// and delivered to them; run with -race to catch unsynchronised access to the hub's and clients' state
+func TestHubConcurrentConnectionsAndDelivery(t *testing.T) {
+	broker := NewMemoryBroker()
+	t.Cleanup(func() { broker.Close() })
+	sessions := newFakeSessions()
+	hub, server := startHub(t, broker, sessions, nil, "node-a")
+
+	const users = 4
+	tokens := make([]string, users)
+	for i := range tokens {
+		tokens[i] = sessions.token(t, int64(i+1))
+	}
+
+	stop := make(chan struct{})
+	var senders sync.WaitGroup
+	senders.Add(2)
+	go func() {
+		defer senders.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			hub.publish(&Envelope{UserID: int64(i%users + 1), Message: &Message{Type: "typing_start", ChatID: int64(i)}})
+		}
+	}()
+	go func() {
+		defer senders.Done()
+		for {
+			select {
+			case hub.broadcast <- &Message{Type: "ping"}:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	var clients sync.WaitGroup
+	for i := 0; i < 40; i++ {
+		clients.Add(1)
+		go func(i int) {
+			defer clients.Done()
+			conn, _, err := websocket.DefaultDialer.Dial(wsURL(server, tokens[i%users]), nil)
+			if err != nil {
+				t.Errorf("dial: %v", err)
+				return
+			}
+			// Read a little so some connections close mid-delivery and others with a full queue
+			readFrames(conn, time.Now().Add(time.Duration(i%5)*time.Millisecond))
+			conn.Close()
+		}(i)
+	}
+	clients.Wait()
+	close(stop)
+	senders.Wait()
+
+	// Once every connection has been unregistered, a new one is the user's first again and is announced
+	deadline := time.Now().Add(testTimeout)
+	for {
+		if time.Now().After(deadline) {
+			t.Fatal("closed connections were never unregistered")
+		}
+		conn := dial(t, server, tokens[0])
+		if announcedOnline(conn, 1, 200*time.Millisecond) {
+			return
+		}
+		conn.Close()
+		// Give the hub time to unregister this connection, or the next one is never the user's first
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// announcedOnline reports whether conn is told within wait that userID came online
+func announcedOnline(conn *websocket.Conn, userID int64, wait time.Duration) bool {
+	deadline := time.Now().Add(wait)
+	for {
+		frames, err := readFrames(conn, deadline)
+		if err != nil {
+			return false
+		}
+		for _, frame := range frames {
+			if payload, ok := frame.Data.(*PresencePayload); ok && payload.UserID == userID && payload.Status == presenceOnline {
+				return true
+			}
+		}
+	}
+}
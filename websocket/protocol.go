@@ -0,0 +1,177 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Subprotocols negotiated through the Sec-WebSocket-Protocol header.
+// Clients that do not ask for one speak ProtocolV1.
+const (
+	// ProtocolV1 batches several frames into one websocket message, separated by newlines
+	ProtocolV1 = "chat.v1"
+	// ProtocolV2 sends exactly one frame per websocket message and splits long frames into chunks
+	ProtocolV2 = "chat.v2"
+)
+
+var supportedProtocols = []string{ProtocolV2, ProtocolV1}
+
+const (
+	defaultMaxFrameSize   = 64 << 10
+	defaultMaxMessageSize = 1 << 20
+	// maxPendingChunks bounds how many chunked frames a client may be sending at once
+	maxPendingChunks = 8
+	// minChunkSize is the smallest chunk a client is expected to send; with MaxMessageSize
+	// it bounds the number of chunks a frame may be split into
+	minChunkSize = 256
+)
+
+// Limits bounds the size of inbound frames
+type Limits struct {
+	// MaxFrameSize is the largest single websocket message accepted; longer frames must be chunked.
+	// Outbound ProtocolV2 frames above this size are chunked as well.
+	MaxFrameSize int64
+	// MaxMessageSize is the largest frame accepted after reassembling its chunks
+	MaxMessageSize int64
+}
+
+// LimitsFromEnv reads WS_MAX_FRAME_SIZE and WS_MAX_MESSAGE_SIZE (bytes)
+func LimitsFromEnv() Limits {
+	limits := Limits{
+		MaxFrameSize:   envBytes("WS_MAX_FRAME_SIZE", defaultMaxFrameSize),
+		MaxMessageSize: envBytes("WS_MAX_MESSAGE_SIZE", defaultMaxMessageSize),
+	}
+	if limits.MaxMessageSize < limits.MaxFrameSize {
+		limits.MaxMessageSize = limits.MaxFrameSize
+	}
+	return limits
+}
+
+func envBytes(name string, fallback int64) int64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 1024 {
+		log.Printf("Invalid %s %q, using %d", name, value, fallback)
+		return fallback
+	}
+	return n
+}
+
+// compressionFromEnv reports whether permessage-deflate is offered; WS_COMPRESSION=false disables it
+func compressionFromEnv() bool {
+	switch strings.ToLower(os.Getenv("WS_COMPRESSION")) {
+	case "false", "0", "off":
+		return false
+	default:
+		return true
+	}
+}
+
+// Chunk carries one slice of a frame that is too long for a single websocket message.
+// Data holds part of the frame's JSON encoding; joining every part in Index order yields the frame.
+type Chunk struct {
+	ID    string `json:"id"`
+	Index int    `json:"index"`
+	Total int    `json:"total"`
+	Data  string `json:"data"`
+}
+
+type chunkBuffer struct {
+	// parts is filled as chunks arrive, so a claimed total allocates nothing up front
+	parts map[int]string
+	total int
+	size  int64
+}
+
+// assembleChunk stores an inbound chunk and returns the reassembled frame once every part has arrived.
+// It is only called from readPump, so c.chunks needs no locking.
+func (c *Client) assembleChunk(msg Message) (*Message, error) {
+	if c.protocol != ProtocolV2 {
+		return nil, fmt.Errorf("chunked frames require the %s subprotocol", ProtocolV2)
+	}
+	chunk := msg.Chunk
+	if chunk == nil || chunk.ID == "" || chunk.Total < 1 || chunk.Index < 0 || chunk.Index >= chunk.Total {
+		return nil, fmt.Errorf("invalid chunk")
+	}
+	if maxChunks := int(c.hub.limits.MaxMessageSize / minChunkSize); chunk.Total > maxChunks {
+		return nil, fmt.Errorf("frame split into more than %d chunks", maxChunks)
+	}
+
+	buffer, ok := c.chunks[chunk.ID]
+	if !ok {
+		if len(c.chunks) >= maxPendingChunks {
+			return nil, fmt.Errorf("too many chunked frames in progress")
+		}
+		buffer = &chunkBuffer{parts: make(map[int]string), total: chunk.Total}
+		c.chunks[chunk.ID] = buffer
+	}
+	if _, seen := buffer.parts[chunk.Index]; seen || buffer.total != chunk.Total {
+		delete(c.chunks, chunk.ID)
+		return nil, fmt.Errorf("inconsistent chunk %d of %s", chunk.Index, chunk.ID)
+	}
+
+	buffer.size += int64(len(chunk.Data))
+	if buffer.size > c.hub.limits.MaxMessageSize {
+		delete(c.chunks, chunk.ID)
+		return nil, fmt.Errorf("frame exceeds %d bytes", c.hub.limits.MaxMessageSize)
+	}
+	buffer.parts[chunk.Index] = chunk.Data
+	if len(buffer.parts) < buffer.total {
+		return nil, nil
+	}
+
+	delete(c.chunks, chunk.ID)
+	var data strings.Builder
+	data.Grow(int(buffer.size))
+	for i := 0; i < buffer.total; i++ {
+		data.WriteString(buffer.parts[i])
+	}
+	var full Message
+	if err := json.Unmarshal([]byte(data.String()), &full); err != nil {
+		return nil, fmt.Errorf("invalid chunked frame: %w", err)
+	}
+	if full.Type == "chunk" {
+		return nil, fmt.Errorf("chunked frames cannot be nested")
+	}
+	return &full, nil
+}
+
+// splitFrame cuts an encoded frame into chunk frames of at most size bytes of data,
+// never splitting a UTF-8 sequence
+func splitFrame(id string, frame []byte, size int) [][]byte {
+	var parts []string
+	for len(frame) > 0 {
+		cut := size
+		if cut >= len(frame) {
+			cut = len(frame)
+		} else {
+			for cut > 0 && !utf8.RuneStart(frame[cut]) {
+				cut--
+			}
+		}
+		parts = append(parts, string(frame[:cut]))
+		frame = frame[cut:]
+	}
+
+	chunks := make([][]byte, 0, len(parts))
+	for i, part := range parts {
+		data, err := json.Marshal(&Message{
+			Type:  "chunk",
+			Chunk: &Chunk{ID: id, Index: i, Total: len(parts), Data: part},
+		})
+		if err != nil {
+			log.Printf("Failed to encode chunk: %v", err)
+			return nil
+		}
+		chunks = append(chunks, data)
+	}
+	return chunks
+}
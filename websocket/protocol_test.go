@@ -0,0 +1,82 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func newChunkClient() *Client {
+	return &Client{
+		protocol: ProtocolV2,
+		hub:      &Hub{limits: Limits{MaxFrameSize: defaultMaxFrameSize, MaxMessageSize: defaultMaxMessageSize}},
+		chunks:   make(map[string]*chunkBuffer),
+	}
+}
+
+func TestAssembleChunkReassemblesOutOfOrder(t *testing.T) {
+	c := newChunkClient()
+	frame, err := json.Marshal(&Message{Type: "send_message", ChatID: 12, Content: "hello there, this is long"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := splitFrame("a1", frame, 10)
+
+	var full *Message
+	for i := len(parts) - 1; i >= 0; i-- {
+		var msg Message
+		if err := json.Unmarshal(parts[i], &msg); err != nil {
+			t.Fatal(err)
+		}
+		full, err = c.assembleChunk(msg)
+		if err != nil {
+			t.Fatalf("chunk %d: %v", i, err)
+		}
+		if i > 0 && full != nil {
+			t.Fatalf("frame completed after chunk %d of %d", i, len(parts))
+		}
+	}
+	if full == nil || full.Type != "send_message" || full.ChatID != 12 || full.Content != "hello there, this is long" {
+		t.Fatalf("reassembled %+v", full)
+	}
+	if len(c.chunks) != 0 {
+		t.Fatalf("%d chunk buffers left over", len(c.chunks))
+	}
+}
+
+func TestAssembleChunkRejectsHugeTotal(t *testing.T) {
+	c := newChunkClient()
+	maxChunks := int(defaultMaxMessageSize / minChunkSize)
+
+	_, err := c.assembleChunk(Message{Type: "chunk", Chunk: &Chunk{ID: "a1", Index: 0, Total: 1 << 30, Data: "{"}})
+	if err == nil {
+		t.Fatal("accepted a chunk claiming a billion parts")
+	}
+	if len(c.chunks) != 0 {
+		t.Fatal("kept a buffer for a rejected chunk")
+	}
+
+	// The largest allowed total allocates only for the chunks that arrived
+	if _, err := c.assembleChunk(Message{Type: "chunk", Chunk: &Chunk{ID: "a2", Index: maxChunks - 1, Total: maxChunks, Data: "}"}}); err != nil {
+		t.Fatalf("rejected %d chunks: %v", maxChunks, err)
+	}
+	if parts := len(c.chunks["a2"].parts); parts != 1 {
+		t.Fatalf("buffer holds %d parts", parts)
+	}
+}
+
+func TestAssembleChunkRejectsInconsistentChunks(t *testing.T) {
+	c := newChunkClient()
+	if _, err := c.assembleChunk(Message{Type: "chunk", Chunk: &Chunk{ID: "a1", Index: 0, Total: 3, Data: "{"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.assembleChunk(Message{Type: "chunk", Chunk: &Chunk{ID: "a1", Index: 0, Total: 3, Data: "{"}}); err == nil {
+		t.Fatal("accepted the same chunk twice")
+	}
+
+	if _, err := c.assembleChunk(Message{Type: "chunk", Chunk: &Chunk{ID: "a2", Index: 0, Total: 3, Data: "{"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.assembleChunk(Message{Type: "chunk", Chunk: &Chunk{ID: "a2", Index: 1, Total: 4, Data: "}"}}); err == nil {
+		t.Fatal("accepted a chunk with a different total")
+	}
+}
@@ -0,0 +1,157 @@
+package websocket
+
+import (
+	"net/http"
+)
+
+// frameSchema is the JSON Schema of every frame exchanged over /ws, for both subprotocols
+const frameSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "$id": "/ws/schema",
+  "title": "Chat WebSocket frame",
+  "description": "Subprotocols: chat.v1 (newline-separated frames per message) and chat.v2 (one frame per message, chunked when long).",
+  "type": "object",
+  "required": ["type"],
+  "properties": {
+    "type": {"type": "string"},
+    "chatId": {"type": "integer"},
+    "content": {"type": "string"},
+    "messageId": {"type": "string"},
+    "token": {"type": "string"},
+    "readMessageId": {"type": "integer"},
+    "lastSeen": {"type": "object", "additionalProperties": {"type": "integer"}},
+    "seq": {"type": "integer", "minimum": 1},
+    "data": {},
+    "error": {"type": "string"},
+    "chunk": {"$ref": "#/$defs/chunk"}
+  },
+  "oneOf": [
+    {"$ref": "#/$defs/auth"},
+    {"$ref": "#/$defs/authOk"},
+    {"$ref": "#/$defs/sendMessage"},
+    {"$ref": "#/$defs/messageResponse"},
+    {"$ref": "#/$defs/chatMessage"},
+    {"$ref": "#/$defs/typing"},
+    {"$ref": "#/$defs/messageRead"},
+    {"$ref": "#/$defs/presence"},
+    {"$ref": "#/$defs/resume"},
+    {"$ref": "#/$defs/resumeOk"},
+    {"$ref": "#/$defs/ping"},
+    {"$ref": "#/$defs/pong"},
+    {"$ref": "#/$defs/error"},
+    {"$ref": "#/$defs/chunkFrame"}
+  ],
+  "$defs": {
+    "message": {
+      "type": "object",
+      "required": ["id", "chatId", "content", "isUser", "intent", "sentAt"],
+      "properties": {
+        "id": {"type": "integer"},
+        "chatId": {"type": "integer"},
+        "content": {"type": "string"},
+        "isUser": {"type": "boolean"},
+        "intent": {"type": "string"},
+        "sentAt": {"type": "string", "format": "date-time"}
+      }
+    },
+    "chat": {
+      "type": "object",
+      "required": ["id", "userId", "title", "botName", "sessionId", "unreadCount", "createdAt", "updatedAt"],
+      "properties": {
+        "id": {"type": "integer"},
+        "userId": {"type": "integer"},
+        "title": {"type": "string"},
+        "botName": {"type": "string"},
+        "sessionId": {"type": "string"},
+        "unreadCount": {"type": "integer"},
+        "createdAt": {"type": "string", "format": "date-time"},
+        "updatedAt": {"type": "string", "format": "date-time"}
+      }
+    },
+    "chunk": {
+      "type": "object",
+      "required": ["id", "index", "total", "data"],
+      "properties": {
+        "id": {"type": "string"},
+        "index": {"type": "integer", "minimum": 0},
+        "total": {"type": "integer", "minimum": 1},
+        "data": {"type": "string", "description": "Slice of the JSON encoding of the whole frame"}
+      }
+    },
+    "auth": {
+      "properties": {"type": {"const": "auth"}},
+      "required": ["token"]
+    },
+    "authOk": {
+      "properties": {
+        "type": {"const": "auth_ok"},
+        "data": {"type": "object", "required": ["userId"], "properties": {"userId": {"type": "integer"}}}
+      }
+    },
+    "sendMessage": {
+      "properties": {"type": {"const": "send_message"}},
+      "required": ["chatId", "content"]
+    },
+    "messageResponse": {
+      "properties": {"type": {"const": "message_response"}, "data": {"$ref": "#/$defs/message"}}
+    },
+    "chatMessage": {
+      "properties": {"type": {"const": "chat_message"}, "data": {"$ref": "#/$defs/message"}},
+      "required": ["chatId", "data"]
+    },
+    "typing": {
+      "properties": {
+        "type": {"enum": ["typing_start", "typing_stop"]},
+        "data": {
+          "type": "object",
+          "required": ["userId", "isBot"],
+          "properties": {"userId": {"type": "integer"}, "isBot": {"type": "boolean"}}
+        }
+      },
+      "required": ["chatId"]
+    },
+    "messageRead": {
+      "properties": {"type": {"const": "message_read"}, "data": {"$ref": "#/$defs/chat"}},
+      "required": ["chatId", "readMessageId"]
+    },
+    "presence": {
+      "properties": {
+        "type": {"const": "presence"},
+        "data": {
+          "type": "object",
+          "required": ["userId", "status"],
+          "properties": {"userId": {"type": "integer"}, "status": {"enum": ["online", "offline"]}}
+        }
+      }
+    },
+    "resume": {
+      "properties": {"type": {"const": "resume"}},
+      "required": ["lastSeen"]
+    },
+    "resumeOk": {
+      "properties": {"type": {"const": "resume_ok"}},
+      "required": ["lastSeen"]
+    },
+    "ping": {
+      "properties": {"type": {"const": "ping"}}
+    },
+    "pong": {
+      "properties": {"type": {"const": "pong"}}
+    },
+    "error": {
+      "properties": {"type": {"const": "error"}},
+      "required": ["error"]
+    },
+    "chunkFrame": {
+      "properties": {"type": {"const": "chunk"}},
+      "required": ["chunk"]
+    }
+  }
+}
+`
+
+// ServeSchema publishes the JSON Schema of the WebSocket frames
+func (h *Hub) ServeSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.Write([]byte(frameSchema))
+}